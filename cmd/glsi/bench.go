@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/user/glsi/internal/bench"
+)
+
+// runBench implements `glsi bench compare [-threshold pct] old.txt new.txt`.
+// It exits non-zero when any benchmark regresses past the threshold so it
+// can gate CI jobs.
+func runBench(args []string) error {
+	if len(args) == 0 || args[0] != "compare" {
+		return fmt.Errorf("usage: glsi bench compare [-threshold pct] old.txt new.txt")
+	}
+
+	fs := flag.NewFlagSet("bench compare", flag.ExitOnError)
+	threshold := fs.Float64("threshold", 10, "max allowed increase in ns/op or allocs/op, in percent")
+	fs.Parse(args[1:])
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: glsi bench compare [-threshold pct] old.txt new.txt")
+	}
+
+	base, err := loadBench(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	head, err := loadBench(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	deltas := bench.Compare(base, head)
+	if len(deltas) == 0 {
+		return fmt.Errorf("no common benchmarks between %s and %s", fs.Arg(0), fs.Arg(1))
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\told ns/op\tnew ns/op\tdelta\told allocs/op\tnew allocs/op\tdelta\t")
+	regressions := 0
+	for _, d := range deltas {
+		mark := ""
+		if d.Regressed(*threshold) {
+			mark = "REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%+.1f%%\t%.0f\t%.0f\t%+.1f%%\t%s\n",
+			d.Name,
+			d.Old.NsPerOp, d.New.NsPerOp, d.NsPct,
+			d.Old.AllocsPerOp, d.New.AllocsPerOp, d.AllocsPct,
+			mark)
+	}
+	tw.Flush()
+
+	if regressions > 0 {
+		return fmt.Errorf("%d benchmark(s) regressed by more than %.1f%%", regressions, *threshold)
+	}
+	return nil
+}
+
+func loadBench(path string) (map[string]bench.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	return bench.Parse(f)
+}
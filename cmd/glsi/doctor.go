@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// doctorQuery is the query the pipeline check searches for. Its cache entry
+// is scoped apart from real ones and removed afterwards.
+const doctorQuery = "glsi doctor fixture"
+
+// doctorPages are the fixture pages the pipeline check scrapes, by path.
+var doctorPages = map[string]string{
+	"/generics": "Generics let functions and types work over any of a set of types. Type parameters are declared in square brackets and constrained by interfaces, so the compiler checks every use.",
+	"/modules":  "A module is a collection of packages versioned together. The go.mod file at its root names the module path and lists the modules it depends on with their minimum versions.",
+}
+
+// doctorCheck is one line of the installation report.
+type doctorCheck struct {
+	name   string
+	detail string
+	err    error
+}
+
+// runDoctor implements `glsi doctor [-e engines] [-n probes] [-canary url]`:
+// it validates the installation (the full pipeline against local fixtures,
+// the cache database and outbound fetches) and then runs canary searches
+// that tell whether the egress IP is blocked or throttled. It exits non-zero
+// when an installation check fails or the configured engine is not clean.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	engines := fs.String("e", "google,duckduckgo", "comma-separated engines to check; empty skips the engine checks")
+	probes := fs.Int("n", 2, "canary searches per engine (max 3)")
+	canary := fs.String("canary", "https://example.com/", "URL fetched through the configured proxies; empty skips it")
+	fs.Parse(args)
+
+	// Probes are paced by the configured rate limits.
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	report := checkPipeline(ctx, c)
+	if *canary != "" {
+		report = append(report, checkCanary(ctx, eng, *canary))
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	failed := 0
+	for _, ch := range report {
+		status, detail := "PASS", ch.detail
+		if ch.err != nil {
+			status, detail = "FAIL", ch.err.Error()
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ch.name, status, detail)
+	}
+	tw.Flush()
+	if *engines == "" {
+		if failed > 0 {
+			return fmt.Errorf("%d of %d installation checks failed", failed, len(report))
+		}
+		return nil
+	}
+	fmt.Println()
+
+	checks, err := eng.SelfCheck(ctx, strings.Split(*engines, ","), *probes)
+	if err != nil {
+		return err
+	}
+
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENGINE\tQUERY\tRESULTS\tLATENCY\tERROR")
+	for _, ch := range checks {
+		for _, p := range ch.Probes {
+			errText := ""
+			if p.Err != nil {
+				errText = p.Err.Error()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%v\t%s\n", ch.Engine, p.Query, p.Results, p.Latency.Round(time.Millisecond), errText)
+		}
+	}
+	tw.Flush()
+
+	fmt.Println()
+	configured := search.CanonicalEngine(os.Getenv("GLSI_SEARCH_ENGINE"))
+	healthy := true
+	for _, ch := range checks {
+		fmt.Printf("%s: %s\n", ch.Engine, ch.Verdict)
+		if ch.Recommendation != "" {
+			fmt.Printf("  → %s\n", ch.Recommendation)
+		}
+		if ch.Engine == configured && ch.Verdict != search.VerdictClean {
+			healthy = false
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d installation checks failed", failed, len(report))
+	}
+	if !healthy {
+		return fmt.Errorf("configured engine %s is not clean", configured)
+	}
+	return nil
+}
+
+// checkPipeline searches local fixture pages with the real scraper and
+// extractor, storing the result in c, then searches again to read it back.
+// It reports the pipeline and the cache as separate checks.
+func checkPipeline(ctx context.Context, c *cache.Cache) []doctorCheck {
+	pipeline := doctorCheck{name: "pipeline"}
+	store := doctorCheck{name: "cache"}
+
+	mux := http.NewServeMux()
+	for path, body := range doctorPages {
+		title := strings.TrimPrefix(path, "/")
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<!DOCTYPE html><html><head><title>%[1]s</title></head><body><article><h1>%[1]s</h1><p>%[2]s</p></article></body></html>", title, body)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	eng := engine.New(c, engine.Config{
+		KeyScope:      "glsi-doctor",
+		HostDelay:     -1,
+		ScrapeRetries: -1,
+		Searcher: engine.SearcherFunc(func(context.Context, string, int, string) ([]search.Result, *search.InstantAnswer, error) {
+			var results []search.Result
+			for path := range doctorPages {
+				results = append(results, search.Result{URL: srv.URL + path, Title: strings.TrimPrefix(path, "/")})
+			}
+			return results, nil, nil
+		}),
+	})
+	// The fixture client talks to the local server directly, whatever the
+	// proxy settings; checkCanary covers those.
+	ctx = scraper.WithHTTPClient(ctx, srv.Client())
+	opts := engine.SearchOptions{Count: len(doctorPages), Force: true}
+
+	start := time.Now()
+	fresh, err := eng.SearchWithOptions(ctx, doctorQuery, opts)
+	switch {
+	case err != nil:
+		pipeline.err = err
+	case fresh.ResultCount != len(doctorPages):
+		pipeline.err = fmt.Errorf("scraped %d of %d fixture pages", fresh.ResultCount, len(doctorPages))
+	default:
+		pipeline.detail = fmt.Sprintf("searched, scraped and consolidated %d fixture pages in %v", fresh.ResultCount, time.Since(start).Round(time.Millisecond))
+	}
+	if pipeline.err != nil {
+		store.err = errors.New("skipped: the pipeline check failed")
+		return []doctorCheck{pipeline, store}
+	}
+
+	opts.Force = false
+	cached, err := eng.SearchWithOptions(ctx, doctorQuery, opts)
+	switch {
+	case err != nil:
+		store.err = err
+	case !cached.FromCache || cached.Content != fresh.Content:
+		store.err = fmt.Errorf("result not read back from %s", c.Path())
+	default:
+		store.detail = "wrote and read back " + c.Path()
+	}
+	if err := eng.ClearCache(doctorQuery); err != nil && store.err == nil {
+		store.err = err
+	}
+	return []doctorCheck{pipeline, store}
+}
+
+// checkCanary fetches rawURL with eng's page-fetch settings, so a bad proxy,
+// missing CA certificates or blocked egress show up before real searches.
+func checkCanary(ctx context.Context, eng *engine.Engine, rawURL string) doctorCheck {
+	start := time.Now()
+	page := eng.Probe(ctx, rawURL)
+	if page.Err != nil {
+		return doctorCheck{name: "canary", err: page.Err}
+	}
+	return doctorCheck{name: "canary", detail: fmt.Sprintf("fetched %s in %v", rawURL, time.Since(start).Round(time.Millisecond))}
+}
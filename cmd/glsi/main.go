@@ -0,0 +1,1034 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/user/glsi/internal/api"
+	"github.com/user/glsi/internal/mcp"
+	"github.com/user/glsi/internal/summarize"
+	"github.com/user/glsi/internal/systemd"
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/metrics"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+const usage = `Usage: glsi <command> [flags]
+
+Commands:
+  search   Search the web, scrape pages, and return consolidated text
+  serve    Start the HTTP API server
+  mcp      Start the MCP stdio server
+  bench    Compare two benchmark runs (bench compare old.txt new.txt)
+  pin      Pin, unpin or list cached queries exempt from expiry (pin add|rm|ls)
+  warm     Pre-populate the cache from a file of queries (warm -f queries.txt)
+  prune    Delete expired cache entries, optionally reclaiming disk space (prune [-vacuum])
+  top      List the most read cached queries (top [-n 20])
+  doctor   Validate the installation and check whether search engines are blocking this IP
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "search":
+		err = runSearch(os.Args[2:])
+	case "serve":
+		err = runServe(os.Args[2:])
+	case "mcp":
+		err = runMCP()
+	case "bench":
+		err = runBench(os.Args[2:])
+	case "pin":
+		err = runPin(os.Args[2:])
+	case "warm":
+		err = runWarm(os.Args[2:])
+	case "prune":
+		err = runPrune(os.Args[2:])
+	case "top":
+		err = runTop(os.Args[2:])
+	case "doctor":
+		err = runDoctor(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glsi: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newEngine builds an engine from environment configuration. The caller must
+// close the returned cache.
+func newEngine() (*engine.Engine, *cache.Cache, error) {
+	logger, err := loggerFromEnv(os.Stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := cacheKeyFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := cache.Open(os.Getenv("GLSI_DB_PATH"), cache.Options{EncryptionKey: key, Logger: logger})
+	if err != nil {
+		return nil, nil, fmt.Errorf("initializing cache: %w", err)
+	}
+
+	rateLimit := 1 * time.Second
+	if v := os.Getenv("GLSI_RATE_LIMIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_RATE_LIMIT %q: %w", v, err)
+		}
+		rateLimit = d
+	}
+
+	var rateLimits map[string]time.Duration
+	if v := os.Getenv("GLSI_RATE_LIMITS"); v != "" {
+		rateLimits, err = search.ParseRateLimits(v)
+		if err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_RATE_LIMITS: %w", err)
+		}
+	}
+
+	var rateBurst int
+	if v := os.Getenv("GLSI_RATE_BURST"); v != "" {
+		rateBurst, err = strconv.Atoi(v)
+		if err != nil || rateBurst < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_RATE_BURST %q", v)
+		}
+	}
+
+	domainExtractors, err := parseDomainExtractors(os.Getenv("GLSI_DOMAIN_EXTRACTORS"))
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_DOMAIN_EXTRACTORS: %w", err)
+	}
+
+	fallbackExtractor := os.Getenv("GLSI_FALLBACK_EXTRACTOR")
+	if !scraper.ValidExtractor(fallbackExtractor) {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_FALLBACK_EXTRACTOR %q", fallbackExtractor)
+	}
+
+	var minContentChars int
+	if v := os.Getenv("GLSI_MIN_CONTENT_CHARS"); v != "" {
+		if minContentChars, err = strconv.Atoi(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MIN_CONTENT_CHARS %q", v)
+		}
+	}
+
+	var minPageChars int
+	if v := os.Getenv("GLSI_MIN_PAGE_CHARS"); v != "" {
+		if minPageChars, err = strconv.Atoi(v); err != nil || minPageChars < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MIN_PAGE_CHARS %q", v)
+		}
+	}
+
+	scrapeProxy := os.Getenv("GLSI_SCRAPE_PROXY")
+	if err := scraper.ValidProxy(scrapeProxy); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_SCRAPE_PROXY: %w", err)
+	}
+	geoProxy := os.Getenv("GLSI_GEO_PROXY")
+	if err := scraper.ValidProxy(geoProxy); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_GEO_PROXY: %w", err)
+	}
+	domainProxies, err := parseDomainProxies(os.Getenv("GLSI_SCRAPE_PROXIES"))
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_SCRAPE_PROXIES: %w", err)
+	}
+
+	searchEngine := os.Getenv("GLSI_SEARCH_ENGINE")
+	if !search.ValidEngine(searchEngine) {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_SEARCH_ENGINE %q", searchEngine)
+	}
+
+	extractor := os.Getenv("GLSI_EXTRACTOR")
+	if !scraper.ValidExtractor(extractor) {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_EXTRACTOR %q", extractor)
+	}
+
+	output := os.Getenv("GLSI_OUTPUT")
+	if !scraper.ValidOutput(output) {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_OUTPUT %q", output)
+	}
+
+	sectionTemplate, err := sectionTemplateFromEnv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	renderMode := os.Getenv("GLSI_RENDER")
+	if !scraper.ValidRenderMode(renderMode) {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_RENDER %q", renderMode)
+	}
+	// A browser is required when rendering is configured explicitly;
+	// otherwise use one if present so per-request rendering works.
+	var renderer scraper.Renderer
+	chrome, err := scraper.NewChromeRenderer(os.Getenv("GLSI_CHROME_PATH"))
+	switch {
+	case err == nil:
+		chrome.Args = strings.Fields(os.Getenv("GLSI_CHROME_ARGS"))
+		if scrapeProxy != "" && scrapeProxy != scraper.ProxyDirect {
+			// Rendered pages go through the scrape proxy too; the browser
+			// has no per-domain rules.
+			chrome.Args = append(chrome.Args, "--proxy-server="+scrapeProxy)
+		}
+		renderer = chrome
+	case os.Getenv("GLSI_CHROME_PATH") != "" || (renderMode != "" && renderMode != scraper.RenderNever):
+		c.Close()
+		return nil, nil, err
+	}
+
+	budget, err := budgetFromEnv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	guardrails, err := guardrailsFromEnv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	var auditor engine.Auditor
+	if path := os.Getenv("GLSI_AUDIT_LOG"); path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("opening GLSI_AUDIT_LOG: %w", err)
+		}
+		auditor = engine.NewJSONAuditor(f) // open for the life of the process
+	} else if guardrails != nil && guardrails.RequireAudit {
+		c.Close()
+		return nil, nil, fmt.Errorf("GLSI_GUARDRAIL_REQUIRE_AUDIT needs GLSI_AUDIT_LOG")
+	}
+
+	refresh, err := refreshFromEnv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	breaker, err := breakerFromEnv()
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	var scrapeTimeout time.Duration
+	if v := os.Getenv("GLSI_SCRAPE_TIMEOUT"); v != "" {
+		if scrapeTimeout, err = time.ParseDuration(v); err != nil || scrapeTimeout <= 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_SCRAPE_TIMEOUT %q", v)
+		}
+	}
+
+	var hostDelay time.Duration
+	if v := os.Getenv("GLSI_HOST_DELAY"); v != "" {
+		if hostDelay, err = time.ParseDuration(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_HOST_DELAY %q: %w", v, err)
+		}
+	}
+
+	var scrapeRetries int
+	if v := os.Getenv("GLSI_SCRAPE_RETRIES"); v != "" {
+		if scrapeRetries, err = strconv.Atoi(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_SCRAPE_RETRIES %q", v)
+		}
+	}
+
+	var maxBodyBytes int64
+	if v := os.Getenv("GLSI_MAX_BODY_BYTES"); v != "" {
+		if maxBodyBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MAX_BODY_BYTES %q", v)
+		}
+	}
+
+	var summarizer engine.Summarizer
+	switch v := os.Getenv("GLSI_SUMMARIZER"); v {
+	case "", "openai":
+	case "extractive":
+		summarizer = engine.ExtractiveSummarizer{}
+	default:
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_SUMMARIZER %q", v)
+	}
+	if v := os.Getenv("GLSI_SUMMARIZER_URL"); v != "" && summarizer == nil {
+		s, err := summarize.NewOpenAI(summarize.Config{
+			BaseURL: v,
+			Model:   os.Getenv("GLSI_SUMMARIZER_MODEL"),
+			APIKey:  os.Getenv("GLSI_SUMMARIZER_KEY"),
+		})
+		if err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+		summarizer = s
+	}
+
+	var maxPerHost int
+	if v := os.Getenv("GLSI_MAX_PER_HOST"); v != "" {
+		if maxPerHost, err = strconv.Atoi(v); err != nil || maxPerHost < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MAX_PER_HOST %q", v)
+		}
+	}
+
+	var maxSections int
+	if v := os.Getenv("GLSI_MAX_SECTIONS"); v != "" {
+		if maxSections, err = strconv.Atoi(v); err != nil || maxSections < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MAX_SECTIONS %q", v)
+		}
+	}
+
+	var maxContentBytes int
+	if v := os.Getenv("GLSI_MAX_CONTENT_BYTES"); v != "" {
+		if maxContentBytes, err = strconv.Atoi(v); err != nil || maxContentBytes < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_MAX_CONTENT_BYTES %q", v)
+		}
+	}
+
+	var depth int
+	if v := os.Getenv("GLSI_DEPTH"); v != "" {
+		if depth, err = strconv.Atoi(v); err != nil || depth < 0 || depth > 1 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_DEPTH %q", v)
+		}
+	}
+
+	var followBudget int
+	if v := os.Getenv("GLSI_FOLLOW_BUDGET"); v != "" {
+		if followBudget, err = strconv.Atoi(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_FOLLOW_BUDGET %q", v)
+		}
+	}
+
+	var includeSponsored bool
+	if v := os.Getenv("GLSI_INCLUDE_SPONSORED"); v != "" {
+		if includeSponsored, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_INCLUDE_SPONSORED %q", v)
+		}
+	}
+
+	var archiveFallback bool
+	if v := os.Getenv("GLSI_ARCHIVE_FALLBACK"); v != "" {
+		if archiveFallback, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_ARCHIVE_FALLBACK %q", v)
+		}
+	}
+	var archiveDeadLinks bool
+	if v := os.Getenv("GLSI_ARCHIVE_DEAD_LINKS"); v != "" {
+		if archiveDeadLinks, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_ARCHIVE_DEAD_LINKS %q", v)
+		}
+	}
+
+	var classifier engine.Classifier
+	if v := os.Getenv("GLSI_CLASSIFY_INTENT"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_CLASSIFY_INTENT %q", v)
+		}
+		if on {
+			classifier = engine.RuleClassifier{}
+		}
+	}
+
+	var scrapeCookies bool
+	if v := os.Getenv("GLSI_SCRAPE_COOKIES"); v != "" {
+		if scrapeCookies, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_SCRAPE_COOKIES %q", v)
+		}
+	}
+
+	var conditionalFetch bool
+	if v := os.Getenv("GLSI_CONDITIONAL_FETCH"); v != "" {
+		if conditionalFetch, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_CONDITIONAL_FETCH %q", v)
+		}
+	}
+
+	var serveStale bool
+	if v := os.Getenv("GLSI_SERVE_STALE"); v != "" {
+		if serveStale, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_SERVE_STALE %q", v)
+		}
+	}
+
+	var history bool
+	if v := os.Getenv("GLSI_HISTORY"); v != "" {
+		if history, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_HISTORY %q", v)
+		}
+	}
+
+	var historyLimit int
+	if v := os.Getenv("GLSI_HISTORY_LIMIT"); v != "" {
+		if historyLimit, err = strconv.Atoi(v); err != nil || historyLimit < 1 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_HISTORY_LIMIT %q", v)
+		}
+	}
+
+	var keys engine.KeyDeriver
+	if v := os.Getenv("GLSI_FUZZY_KEYS"); v != "" {
+		fuzzy, err := strconv.ParseBool(v)
+		if err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_FUZZY_KEYS %q", v)
+		}
+		if fuzzy {
+			keys = engine.TokenSetKeys
+		}
+	}
+
+	var pageTTL time.Duration
+	if v := os.Getenv("GLSI_PAGE_TTL"); v != "" {
+		if pageTTL, err = time.ParseDuration(v); err != nil || pageTTL < 0 {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_PAGE_TTL %q", v)
+		}
+	}
+
+	var respectNoindex bool
+	if v := os.Getenv("GLSI_RESPECT_NOINDEX"); v != "" {
+		if respectNoindex, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_RESPECT_NOINDEX %q", v)
+		}
+	}
+
+	var allowPrivate bool
+	if v := os.Getenv("GLSI_ALLOW_PRIVATE"); v != "" {
+		if allowPrivate, err = strconv.ParseBool(v); err != nil {
+			c.Close()
+			return nil, nil, fmt.Errorf("invalid GLSI_ALLOW_PRIVATE %q", v)
+		}
+	}
+	allowedNets, err := scraper.ParseNets(os.Getenv("GLSI_ALLOWED_NETS"))
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_ALLOWED_NETS: %w", err)
+	}
+
+	counts, err := countLimitsFromEnv("GLSI_")
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	featureDefaults, err := engine.ParseFeatures(os.Getenv("GLSI_FEATURES"))
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_FEATURES: %w", err)
+	}
+	keyFeatures, err := engine.ParseKeyFeatures(os.Getenv("GLSI_KEY_FEATURES"))
+	if err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("invalid GLSI_KEY_FEATURES: %w", err)
+	}
+	features, err := engine.NewFeatures(featureDefaults, keyFeatures)
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+
+	eng := engine.New(c, engine.Config{
+		SearchEngine:     searchEngine,
+		RateLimit:        rateLimit,
+		RateLimits:       rateLimits,
+		RateBurst:        rateBurst,
+		Budget:           budget,
+		MaxPerHost:       maxPerHost,
+		MaxSections:      maxSections,
+		MaxContentBytes:  maxContentBytes,
+		Depth:            depth,
+		FollowBudget:     followBudget,
+		IncludeSponsored: includeSponsored,
+		DefaultCount:     counts.Default,
+		MaxCount:         counts.Max,
+
+		ScrapeTimeout: scrapeTimeout,
+		HostDelay:     hostDelay,
+		ScrapeRetries: scrapeRetries,
+		MaxBodyBytes:  maxBodyBytes,
+		GitHubToken:   os.Getenv("GLSI_GITHUB_TOKEN"),
+
+		ArchiveFallback:  archiveFallback,
+		ArchiveDeadLinks: archiveDeadLinks,
+		ScrapeProxy:      scrapeProxy,
+		DomainProxies:    domainProxies,
+		GeoProxy:         geoProxy,
+		ScrapeCookies:    scrapeCookies,
+		ConditionalFetch: conditionalFetch,
+		ServeStale:       serveStale,
+		Refresh:          refresh,
+		Breaker:          breaker,
+		PageTTL:          pageTTL,
+		History:          history,
+		HistoryLimit:     historyLimit,
+		RespectNoindex:   respectNoindex,
+		AllowPrivate:     allowPrivate,
+		AllowedNets:      allowedNets,
+
+		Extractor:         extractor,
+		DomainExtractors:  domainExtractors,
+		FallbackExtractor: fallbackExtractor,
+		MinContentChars:   minContentChars,
+		MinPageChars:      minPageChars,
+
+		Renderer: renderer,
+		Render:   renderMode,
+
+		Features: features,
+		Metrics:  metrics.New("glsi"),
+		Logger:   logger,
+
+		Output:          output,
+		SectionTemplate: sectionTemplate,
+
+		Keys: keys,
+
+		Summarizer: summarizer,
+
+		Classifier: classifier,
+
+		Guardrails: guardrails,
+		Auditor:    auditor,
+	})
+	return eng, c, nil
+}
+
+// loggerFromEnv builds the logger for the engine and cache, writing to w
+// at GLSI_LOG_LEVEL (debug, info, warn or error; default warn) in
+// GLSI_LOG_FORMAT (text or json; default text).
+func loggerFromEnv(w io.Writer) (*slog.Logger, error) {
+	level := slog.LevelWarn
+	if v := os.Getenv("GLSI_LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid GLSI_LOG_LEVEL %q (want debug, info, warn or error)", v)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch v := os.Getenv("GLSI_LOG_FORMAT"); strings.ToLower(v) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid GLSI_LOG_FORMAT %q (want text or json)", v)
+	}
+}
+
+// cacheKeyFromEnv reads the cache encryption key from GLSI_CACHE_KEY, or
+// from the file named by GLSI_CACHE_KEY_FILE. Neither set means no
+// encryption.
+func cacheKeyFromEnv() ([]byte, error) {
+	if v := os.Getenv("GLSI_CACHE_KEY"); v != "" {
+		key, err := cache.ParseKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GLSI_CACHE_KEY: %w", err)
+		}
+		return key, nil
+	}
+	if path := os.Getenv("GLSI_CACHE_KEY_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading GLSI_CACHE_KEY_FILE: %w", err)
+		}
+		key, err := cache.ParseKey(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in GLSI_CACHE_KEY_FILE: %w", err)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// sectionTemplateFromEnv parses the consolidation template in
+// GLSI_SECTION_TEMPLATE, or in the file named by
+// GLSI_SECTION_TEMPLATE_FILE. Neither set keeps the default layout.
+func sectionTemplateFromEnv() (*template.Template, error) {
+	text, name := os.Getenv("GLSI_SECTION_TEMPLATE"), "GLSI_SECTION_TEMPLATE"
+	if path := os.Getenv("GLSI_SECTION_TEMPLATE_FILE"); text == "" && path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading GLSI_SECTION_TEMPLATE_FILE: %w", err)
+		}
+		text, name = string(b), "GLSI_SECTION_TEMPLATE_FILE"
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("section").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// guardrailsFromEnv reads the compliance guardrails, which are off unless
+// GLSI_GUARDRAILS is true: GLSI_GUARDRAIL_DOMAIN_PAGES_HOURLY,
+// GLSI_GUARDRAIL_ROBOTS, GLSI_GUARDRAIL_DISALLOWED_INTENTS (a comma list)
+// and GLSI_GUARDRAIL_REQUIRE_AUDIT.
+func guardrailsFromEnv() (*engine.Guardrails, error) {
+	if v := os.Getenv("GLSI_GUARDRAILS"); v == "" {
+		return nil, nil
+	} else if on, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid GLSI_GUARDRAILS %q", v)
+	} else if !on {
+		return nil, nil
+	}
+	var g engine.Guardrails
+	var err error
+	if v := os.Getenv("GLSI_GUARDRAIL_DOMAIN_PAGES_HOURLY"); v != "" {
+		if g.DomainPagesPerHour, err = strconv.Atoi(v); err != nil || g.DomainPagesPerHour < 0 {
+			return nil, fmt.Errorf("invalid GLSI_GUARDRAIL_DOMAIN_PAGES_HOURLY %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_GUARDRAIL_ROBOTS"); v != "" {
+		if g.RespectRobots, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid GLSI_GUARDRAIL_ROBOTS %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_GUARDRAIL_DISALLOWED_INTENTS"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			intent := engine.Intent(strings.TrimSpace(name))
+			if !engine.ValidIntent(intent) {
+				return nil, fmt.Errorf("invalid GLSI_GUARDRAIL_DISALLOWED_INTENTS: unknown intent %q", intent)
+			}
+			g.DisallowedIntents = append(g.DisallowedIntents, intent)
+		}
+	}
+	if v := os.Getenv("GLSI_GUARDRAIL_REQUIRE_AUDIT"); v != "" {
+		if g.RequireAudit, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid GLSI_GUARDRAIL_REQUIRE_AUDIT %q", v)
+		}
+	}
+	return &g, nil
+}
+
+// refreshFromEnv reads the popular-entry refresher settings, which are off
+// unless GLSI_REFRESH is true: GLSI_REFRESH_MIN_HITS, GLSI_REFRESH_WITHIN
+// and GLSI_REFRESH_INTERVAL.
+func refreshFromEnv() (*engine.RefreshPolicy, error) {
+	if v := os.Getenv("GLSI_REFRESH"); v == "" {
+		return nil, nil
+	} else if on, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid GLSI_REFRESH %q", v)
+	} else if !on {
+		return nil, nil
+	}
+	var p engine.RefreshPolicy
+	var err error
+	if v := os.Getenv("GLSI_REFRESH_MIN_HITS"); v != "" {
+		if p.MinHits, err = strconv.Atoi(v); err != nil || p.MinHits < 0 {
+			return nil, fmt.Errorf("invalid GLSI_REFRESH_MIN_HITS %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_REFRESH_WITHIN"); v != "" {
+		if p.Within, err = time.ParseDuration(v); err != nil || p.Within < 0 {
+			return nil, fmt.Errorf("invalid GLSI_REFRESH_WITHIN %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_REFRESH_INTERVAL"); v != "" {
+		if p.Interval, err = time.ParseDuration(v); err != nil || p.Interval < 0 {
+			return nil, fmt.Errorf("invalid GLSI_REFRESH_INTERVAL %q", v)
+		}
+	}
+	return &p, nil
+}
+
+// breakerFromEnv reads the circuit breaker settings, which are off unless
+// GLSI_BREAKER is true: GLSI_BREAKER_THRESHOLD and GLSI_BREAKER_COOLDOWN.
+func breakerFromEnv() (*engine.BreakerPolicy, error) {
+	if v := os.Getenv("GLSI_BREAKER"); v == "" {
+		return nil, nil
+	} else if on, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid GLSI_BREAKER %q", v)
+	} else if !on {
+		return nil, nil
+	}
+	var p engine.BreakerPolicy
+	var err error
+	if v := os.Getenv("GLSI_BREAKER_THRESHOLD"); v != "" {
+		if p.Threshold, err = strconv.Atoi(v); err != nil || p.Threshold < 0 {
+			return nil, fmt.Errorf("invalid GLSI_BREAKER_THRESHOLD %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_BREAKER_COOLDOWN"); v != "" {
+		if p.Cooldown, err = time.ParseDuration(v); err != nil || p.Cooldown < 0 {
+			return nil, fmt.Errorf("invalid GLSI_BREAKER_COOLDOWN %q", v)
+		}
+	}
+	return &p, nil
+}
+
+// budgetFromEnv reads macro budgets from GLSI_SEARCH_BUDGET_HOURLY,
+// GLSI_SEARCH_BUDGET_DAILY (both "engine=n,..." lists) and
+// GLSI_PAGE_BUDGET_DAILY.
+func budgetFromEnv() (engine.Budget, error) {
+	var b engine.Budget
+	var err error
+	if b.SearchesPerHour, err = parseEngineCounts(os.Getenv("GLSI_SEARCH_BUDGET_HOURLY")); err != nil {
+		return b, fmt.Errorf("invalid GLSI_SEARCH_BUDGET_HOURLY: %w", err)
+	}
+	if b.SearchesPerDay, err = parseEngineCounts(os.Getenv("GLSI_SEARCH_BUDGET_DAILY")); err != nil {
+		return b, fmt.Errorf("invalid GLSI_SEARCH_BUDGET_DAILY: %w", err)
+	}
+	if v := os.Getenv("GLSI_PAGE_BUDGET_DAILY"); v != "" {
+		if b.PagesPerDay, err = strconv.Atoi(v); err != nil {
+			return b, fmt.Errorf("invalid GLSI_PAGE_BUDGET_DAILY %q: %w", v, err)
+		}
+	}
+	return b, nil
+}
+
+// parseEngineCounts parses "google=100,duckduckgo=300" into counts keyed
+// by canonical engine name. Unknown engines are an error, so a typo does
+// not end up as Google's budget.
+func parseEngineCounts(s string) (map[string]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want engine=count", part)
+		}
+		if strings.TrimSpace(name) == "" || !search.ValidEngine(name) {
+			return nil, fmt.Errorf("%q: %w", part, search.ErrUnknownEngine)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		m[search.CanonicalEngine(name)] = n
+	}
+	return m, nil
+}
+
+// countLimitsFromEnv reads <prefix>DEFAULT_COUNT and <prefix>MAX_COUNT.
+func countLimitsFromEnv(prefix string) (engine.CountLimits, error) {
+	var l engine.CountLimits
+	for _, v := range []struct {
+		name string
+		dst  *int
+	}{
+		{prefix + "DEFAULT_COUNT", &l.Default},
+		{prefix + "MAX_COUNT", &l.Max},
+	} {
+		s := os.Getenv(v.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return l, fmt.Errorf("invalid %s %q", v.name, s)
+		}
+		*v.dst = n
+	}
+	return l, nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	query := fs.String("q", "", "search query (required)")
+	count := fs.Int("n", 0, "number of results to scrape (default GLSI_DEFAULT_COUNT, or 5)")
+	force := fs.Bool("f", false, "bypass cache, force fresh scrape")
+	verbose := fs.Bool("v", false, "print per-page fetch timings to stderr")
+	summary := fs.Bool("s", false, "summarize results with the configured summarizer")
+	markdown := fs.Bool("m", false, "return page text as Markdown")
+	cite := fs.Bool("c", false, "end each paragraph with an [Sn] marker naming its source")
+	format := fs.String("format", "", "print the text as markdown (default), plain or json, or one record per page as csv or jsonl")
+	site := fs.String("site", "", "search this site's sitemap instead of the web, e.g. go.dev")
+	fs.Parse(args)
+
+	if *query == "" {
+		fs.Usage()
+		return fmt.Errorf("missing required flag -q")
+	}
+	if !engine.ValidFormat(*format) && !engine.ValidContentFormat(*format) {
+		return fmt.Errorf("invalid -format %q, want markdown, plain, json, csv or jsonl", *format)
+	}
+	contentFormat := *format
+	if engine.ValidFormat(*format) {
+		contentFormat = ""
+	}
+	if *site != "" {
+		if err := scraper.ValidSite(*site); err != nil {
+			return fmt.Errorf("invalid -site: %w", err)
+		}
+	}
+
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	result, err := eng.SearchWithOptions(context.Background(), *query, engine.SearchOptions{
+		Count:     *count,
+		Force:     *force,
+		Cite:      *cite,
+		Summarize: *summary,
+		Output:    outputFlag(*markdown),
+		Format:    contentFormat,
+		Site:      *site,
+	})
+	if err != nil {
+		return err
+	}
+	if *verbose {
+		printTimings(os.Stderr, result.Pages)
+	}
+	if engine.ValidFormat(*format) {
+		return engine.WriteSources(os.Stdout, *format, result.Sources)
+	}
+	fmt.Println(result.Content)
+	return nil
+}
+
+// outputFlag maps the -m flag to an output format; unset leaves the
+// GLSI_OUTPUT default in place.
+func outputFlag(markdown bool) string {
+	if markdown {
+		return scraper.OutputMarkdown
+	}
+	return ""
+}
+
+// printTimings writes a per-page breakdown of fetch phases.
+func printTimings(w io.Writer, pages []engine.PageInfo) {
+	if pages == nil {
+		fmt.Fprintln(w, "served from cache")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tQUEUE\tDNS\tCONNECT\tTLS\tTTFB\tDOWNLOAD\tEXTRACT\tTOTAL\tERROR")
+	for _, p := range pages {
+		t := p.Timings
+		errText := ""
+		if p.Err != nil {
+			errText = p.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%s\n", p.URL, t.Queue.Round(time.Millisecond),
+			t.DNS.Round(time.Millisecond), t.Connect.Round(time.Millisecond), t.TLS.Round(time.Millisecond),
+			t.TTFB.Round(time.Millisecond), t.Download.Round(time.Millisecond), t.Extract.Round(time.Millisecond),
+			t.Total.Round(time.Millisecond), errText)
+	}
+	tw.Flush()
+}
+
+func runServe(args []string) error {
+	defaultPort := os.Getenv("GLSI_PORT")
+	if defaultPort == "" {
+		defaultPort = "8080"
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := fs.String("p", defaultPort, "HTTP server port")
+	socket := fs.String("socket", os.Getenv("GLSI_SOCKET"), "listen on this unix socket path instead of TCP")
+	fs.Parse(args)
+
+	cfg := api.Config{Addr: ":" + *port, SocketPath: *socket}
+	counts, err := countLimitsFromEnv("GLSI_HTTP_")
+	if err != nil {
+		return err
+	}
+	cfg.Counts = counts
+	if v := os.Getenv("GLSI_SOCKET_MODE"); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid GLSI_SOCKET_MODE %q: %w", v, err)
+		}
+		cfg.SocketMode = os.FileMode(mode)
+	}
+	if v := os.Getenv("GLSI_HEALTH_MIN_FREE_BYTES"); v != "" {
+		if cfg.MinFreeBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("invalid GLSI_HEALTH_MIN_FREE_BYTES %q", v)
+		}
+	}
+
+	prune, pruneEvery, err := pruneFromEnv()
+	if err != nil {
+		return err
+	}
+	warmQueries, err := warmFromEnv()
+	if err != nil {
+		return err
+	}
+
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	cfg.Features = eng.Features()
+	cfg.AdminToken = os.Getenv("GLSI_ADMIN_TOKEN")
+	cfg.Metrics, _ = eng.Metrics().(*metrics.Registry)
+
+	// Prefer a socket handed over by systemd socket activation.
+	lns, err := systemd.Listeners()
+	if err != nil {
+		return err
+	}
+	if len(lns) > 0 {
+		// Only one socket is served; say so rather than silently
+		// dropping the rest of a multi-socket unit.
+		cfg.Listener = lns[0]
+		for i, extra := range lns[1:] {
+			fmt.Fprintf(os.Stderr, "glsi: ignoring socket-activated fd %d (%s); only the first socket is served\n",
+				systemd.ListenFdsStart+1+i, extra.Addr())
+			extra.Close()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go eng.RunRefresher(ctx)
+	go pruner(ctx, c, prune, pruneEvery)
+	go warm(ctx, eng, warmQueries)
+
+	cfg.Ready = func() {
+		if err := systemd.Notify("READY=1"); err != nil {
+			fmt.Fprintf(os.Stderr, "glsi: %v\n", err)
+		}
+		go watchdog(ctx)
+	}
+	defer systemd.Notify("STOPPING=1")
+
+	return api.Run(ctx, cfg, eng)
+}
+
+// watchdog pings the systemd watchdog at half its configured interval until
+// ctx is done. It returns immediately if the watchdog is not enabled.
+func watchdog(ctx context.Context) {
+	interval := systemd.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			systemd.Notify("WATCHDOG=1")
+		}
+	}
+}
+
+func runMCP() error {
+	counts, err := countLimitsFromEnv("GLSI_MCP_")
+	if err != nil {
+		return err
+	}
+	prune, pruneEvery, err := pruneFromEnv()
+	if err != nil {
+		return err
+	}
+	warmQueries, err := warmFromEnv()
+	if err != nil {
+		return err
+	}
+
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go eng.RunRefresher(ctx)
+	go pruner(ctx, c, prune, pruneEvery)
+	go warm(ctx, eng, warmQueries)
+
+	return mcp.Run(mcp.Config{Counts: counts}, eng)
+}
+
+// parseDomainProxies parses "intranet.example=direct,example.org=http://proxy:3128".
+func parseDomainProxies(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		domain, proxy, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || domain == "" || proxy == "" {
+			return nil, fmt.Errorf("%q: want domain=proxy-url|direct", part)
+		}
+		if err := scraper.ValidProxy(proxy); err != nil {
+			return nil, err
+		}
+		m[domain] = proxy
+	}
+	return m, nil
+}
+
+// parseDomainExtractors parses "example.com=density,docs.rs=readability".
+func parseDomainExtractors(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		domain, name, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !scraper.ValidExtractor(name) {
+			return nil, fmt.Errorf("%q: want domain=extractor, e.g. example.com=density", part)
+		}
+		m[domain] = name
+	}
+	return m, nil
+}
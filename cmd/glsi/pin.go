@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const pinUsage = "usage: glsi pin add -q query | glsi pin rm -q query | glsi pin ls"
+
+// runPin implements `glsi pin add|rm|ls`.
+func runPin(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(pinUsage)
+	}
+
+	fs := flag.NewFlagSet("pin "+args[0], flag.ExitOnError)
+	query := fs.String("q", "", "search query")
+	fs.Parse(args[1:])
+
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch args[0] {
+	case "add":
+		if *query == "" {
+			return fmt.Errorf("missing required flag -q")
+		}
+		if err := eng.Pin(context.Background(), *query); err != nil {
+			return err
+		}
+		fmt.Printf("pinned %q\n", *query)
+	case "rm":
+		if *query == "" {
+			return fmt.Errorf("missing required flag -q")
+		}
+		if err := eng.Unpin(*query); err != nil {
+			return err
+		}
+		fmt.Printf("unpinned %q\n", *query)
+	case "ls":
+		entries, err := eng.Pinned()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "QUERY\tUPDATED\tSIZE")
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", e.Query, e.UpdatedAt.Local().Format(time.DateTime), e.Size)
+		}
+		tw.Flush()
+	default:
+		return fmt.Errorf(pinUsage)
+	}
+	return nil
+}
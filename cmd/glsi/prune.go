@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// defaultPruneInterval is how often serve and mcp prune the cache when
+// GLSI_PRUNE_INTERVAL is unset.
+const defaultPruneInterval = 6 * time.Hour
+
+// runPrune implements `glsi prune`.
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	vacuum := fs.Bool("vacuum", false, "rebuild the database file afterwards to return freed space")
+	fs.Parse(args)
+
+	opts, _, err := pruneFromEnv()
+	if err != nil {
+		return err
+	}
+	opts.Vacuum = *vacuum
+
+	c, err := cache.New(os.Getenv("GLSI_DB_PATH"))
+	if err != nil {
+		return fmt.Errorf("initializing cache: %w", err)
+	}
+	defer c.Close()
+	st, err := c.Prune(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pruned %d entries, %d page bodies, %d pages\n", st.Entries, st.Fetches, st.Pages)
+	return nil
+}
+
+// pruneFromEnv reads GLSI_PRUNE_GRACE, GLSI_PRUNE_VACUUM and
+// GLSI_PRUNE_INTERVAL, which is 0 when periodic pruning is disabled.
+func pruneFromEnv() (cache.PruneOptions, time.Duration, error) {
+	var opts cache.PruneOptions
+	var err error
+	if v := os.Getenv("GLSI_PRUNE_GRACE"); v != "" {
+		if opts.Grace, err = time.ParseDuration(v); err != nil || opts.Grace < 0 {
+			return opts, 0, fmt.Errorf("invalid GLSI_PRUNE_GRACE %q", v)
+		}
+	}
+	if v := os.Getenv("GLSI_PRUNE_VACUUM"); v != "" {
+		if opts.Vacuum, err = strconv.ParseBool(v); err != nil {
+			return opts, 0, fmt.Errorf("invalid GLSI_PRUNE_VACUUM %q", v)
+		}
+	}
+	interval := defaultPruneInterval
+	if v := os.Getenv("GLSI_PRUNE_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval < 0 {
+			return opts, 0, fmt.Errorf("invalid GLSI_PRUNE_INTERVAL %q", v)
+		}
+	}
+	return opts, interval, nil
+}
+
+// pruner prunes c every interval until ctx is done. It returns at once if
+// interval is 0. Failures are logged and retried on the next tick.
+func pruner(ctx context.Context, c *cache.Cache, opts cache.PruneOptions, interval time.Duration) {
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Prune(ctx, opts); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "glsi: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/glsi/pkg/engine"
+)
+
+// runTop implements `glsi top`, listing the most read cached queries.
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	n := fs.Int("n", engine.DefaultTopQueries, "number of queries to list")
+	fs.Parse(args)
+
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	entries, err := eng.TopQueries(context.Background(), *n)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUERY\tHITS\tRECENT\tLAST HIT\tUPDATED")
+	for _, e := range entries {
+		last := "-"
+		if !e.LastHitAt.IsZero() {
+			last = e.LastHitAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", e.Query, e.TotalHits, e.Hits, last, e.UpdatedAt.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/user/glsi/pkg/engine"
+)
+
+const warmUsage = "usage: glsi warm -f queries.txt (- reads standard input)"
+
+// runWarm implements `glsi warm`.
+func runWarm(args []string) error {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	file := fs.String("f", "", "file with one query per line; - reads standard input")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf(warmUsage)
+	}
+
+	queries, err := readQueryFile(*file)
+	if err != nil {
+		return err
+	}
+	eng, c, err := newEngine()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	wr, err := eng.Warm(context.Background(), queries)
+	fmt.Printf("searched %d, already cached %d, failed %d\n", wr.Searched, wr.Cached, wr.Failed)
+	return err
+}
+
+// readQueryFile reads queries for Warm from path, or from standard input
+// if path is "-".
+func readQueryFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading queries: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return engine.ReadQueries(r)
+}
+
+// warmFromEnv reads the queries in GLSI_WARM_FILE, if set, so serve and mcp
+// can warm the cache at start-up. A missing or unreadable file fails the
+// start rather than leaving the cache cold unnoticed.
+func warmFromEnv() ([]string, error) {
+	path := os.Getenv("GLSI_WARM_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	queries, err := readQueryFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("GLSI_WARM_FILE: %w", err)
+	}
+	return queries, nil
+}
+
+// warm runs Warm over queries in the background of a server and reports
+// the outcome on standard error.
+func warm(ctx context.Context, eng *engine.Engine, queries []string) {
+	if len(queries) == 0 {
+		return
+	}
+	wr, err := eng.Warm(ctx, queries)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "glsi: warmed cache: searched %d, already cached %d, failed %d\n", wr.Searched, wr.Cached, wr.Failed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glsi: %v\n", err)
+	}
+}
@@ -2,6 +2,7 @@ package glsi_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,10 +11,10 @@ import (
 	"testing"
 	"time"
 
-	"github.com/user/glsi/internal/cache"
-	"github.com/user/glsi/internal/engine"
-	"github.com/user/glsi/internal/scraper"
-	"github.com/user/glsi/internal/search"
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
 )
 
 // fakeGoogleHTML builds a Google-like SERP page pointing at the given URLs.
@@ -40,6 +41,17 @@ func fakeArticlePage(title, body string) string {
 </html>`
 }
 
+// serverContext returns a context under which searches go to searchSrv and,
+// unless contentSrv is nil, page fetches go through contentSrv's client.
+func serverContext(searchSrv, contentSrv *httptest.Server) context.Context {
+	ctx := search.WithHTTPClient(context.Background(), searchSrv.Client())
+	ctx = search.WithBaseURLs(ctx, searchSrv.URL, searchSrv.URL)
+	if contentSrv != nil {
+		ctx = scraper.WithHTTPClient(ctx, contentSrv.Client())
+	}
+	return ctx
+}
+
 // TestIntegrationSearchScrapeCache exercises the full pipeline:
 //
 //	search → scrape → cache → (cache-hit) → clear-cache → (cache-miss)
@@ -81,15 +93,8 @@ func TestIntegrationSearchScrapeCache(t *testing.T) {
 	searchSrv := httptest.NewServer(searchMux)
 	defer searchSrv.Close()
 
-	// ── 3. Override the search and scraper HTTP clients ──
-	restoreSearchClient := search.OverrideHTTPClient(searchSrv.Client())
-	defer restoreSearchClient()
-
-	restoreBaseURLs := search.OverrideBaseURLs(searchSrv.URL, searchSrv.URL)
-	defer restoreBaseURLs()
-
-	restoreScraperClient := scraper.OverrideHTTPClient(contentSrv.Client())
-	defer restoreScraperClient()
+	// ── 3. Route the search and scraper HTTP clients to the test servers ──
+	ctx := serverContext(searchSrv, contentSrv)
 
 	// ── 4. Create a real SQLite cache in a temp directory ──
 	dbPath := filepath.Join(t.TempDir(), "integration_test.db")
@@ -105,8 +110,6 @@ func TestIntegrationSearchScrapeCache(t *testing.T) {
 		RateLimit:    0, // no delay in tests
 	})
 
-	ctx := context.Background()
-
 	// ── 6. First search → should be a cache MISS (fresh scrape) ──
 	result, err := eng.Search(ctx, "golang concurrency", 5, false)
 	if err != nil {
@@ -186,12 +189,7 @@ func TestIntegrationForceBypassCache(t *testing.T) {
 	searchSrv := httptest.NewServer(searchMux)
 	defer searchSrv.Close()
 
-	restoreSearchClient := search.OverrideHTTPClient(searchSrv.Client())
-	defer restoreSearchClient()
-	restoreBaseURLs := search.OverrideBaseURLs(searchSrv.URL, searchSrv.URL)
-	defer restoreBaseURLs()
-	restoreScraperClient := scraper.OverrideHTTPClient(contentSrv.Client())
-	defer restoreScraperClient()
+	ctx := serverContext(searchSrv, contentSrv)
 
 	dbPath := filepath.Join(t.TempDir(), "force_test.db")
 	c, err := cache.New(dbPath)
@@ -205,8 +203,6 @@ func TestIntegrationForceBypassCache(t *testing.T) {
 		RateLimit:    0,
 	})
 
-	ctx := context.Background()
-
 	// First search — cache miss.
 	result1, err := eng.Search(ctx, "dynamic", 5, false)
 	if err != nil {
@@ -243,12 +239,7 @@ func TestIntegrationClearAllCache(t *testing.T) {
 	}))
 	defer searchSrv.Close()
 
-	restoreSearchClient := search.OverrideHTTPClient(searchSrv.Client())
-	defer restoreSearchClient()
-	restoreBaseURLs := search.OverrideBaseURLs(searchSrv.URL, searchSrv.URL)
-	defer restoreBaseURLs()
-	restoreScraperClient := scraper.OverrideHTTPClient(contentSrv.Client())
-	defer restoreScraperClient()
+	ctx := serverContext(searchSrv, contentSrv)
 
 	dbPath := filepath.Join(t.TempDir(), "clearall_test.db")
 	c, err := cache.New(dbPath)
@@ -258,7 +249,6 @@ func TestIntegrationClearAllCache(t *testing.T) {
 	defer c.Close()
 
 	eng := engine.New(c, engine.Config{SearchEngine: "google", RateLimit: 0})
-	ctx := context.Background()
 
 	// Populate cache with two different queries.
 	if _, err := eng.Search(ctx, "query one", 5, false); err != nil {
@@ -287,7 +277,8 @@ func TestIntegrationClearAllCache(t *testing.T) {
 	}
 }
 
-// TestIntegrationRateLimit ensures the engine respects the rate limit.
+// TestIntegrationRateLimit ensures consecutive SERP requests to the same
+// engine are spaced by the configured rate limit.
 func TestIntegrationRateLimit(t *testing.T) {
 	contentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
@@ -301,12 +292,7 @@ func TestIntegrationRateLimit(t *testing.T) {
 	}))
 	defer searchSrv.Close()
 
-	restoreSearchClient := search.OverrideHTTPClient(searchSrv.Client())
-	defer restoreSearchClient()
-	restoreBaseURLs := search.OverrideBaseURLs(searchSrv.URL, searchSrv.URL)
-	defer restoreBaseURLs()
-	restoreScraperClient := scraper.OverrideHTTPClient(contentSrv.Client())
-	defer restoreScraperClient()
+	ctx := serverContext(searchSrv, contentSrv)
 
 	dbPath := filepath.Join(t.TempDir(), "ratelimit_test.db")
 	c, err := cache.New(dbPath)
@@ -322,14 +308,217 @@ func TestIntegrationRateLimit(t *testing.T) {
 	})
 
 	start := time.Now()
-	_, err = eng.Search(context.Background(), "rate limit test", 5, false)
+	if _, err := eng.Search(ctx, "rate limit test one", 5, false); err != nil {
+		t.Fatalf("first Search: %v", err)
+	}
+	if _, err := eng.Search(ctx, "rate limit test two", 5, false); err != nil {
+		t.Fatalf("second Search: %v", err)
+	}
 	elapsed := time.Since(start)
+
+	// The second SERP request should have waited for the rate limit.
+	if elapsed < rateLimit {
+		t.Errorf("two searches completed in %v, expected at least %v (rate limit)", elapsed, rateLimit)
+	}
+}
+
+// TestIntegrationEngineRateLimitsIsolated verifies that constructing an
+// engine does not change the rate limits of engines already running.
+func TestIntegrationEngineRateLimitsIsolated(t *testing.T) {
+	searchSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML([]string{"https://example.com/p"})))
+	}))
+	defer searchSrv.Close()
+
+	ctx := serverContext(searchSrv, nil)
+
+	c, err := cache.New(filepath.Join(t.TempDir(), "isolated_test.db"))
 	if err != nil {
-		t.Fatalf("Search: %v", err)
+		t.Fatalf("cache.New: %v", err)
 	}
+	defer c.Close()
 
-	// The engine should have waited at least the rate limit duration.
-	if elapsed < rateLimit {
-		t.Errorf("search completed in %v, expected at least %v (rate limit)", elapsed, rateLimit)
+	scrape := engine.ScraperFunc(func(ctx context.Context, urls []string, _ scraper.Options) []scraper.ScrapedPage {
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			out[i] = scraper.ScrapedPage{URL: u, Title: "P", Content: "Some page content."}
+		}
+		return out
+	})
+	fast := engine.New(c, engine.Config{SearchEngine: "google", Scraper: scrape})
+	engine.New(c, engine.Config{SearchEngine: "google", RateLimit: time.Hour})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for _, q := range []string{"isolated one", "isolated two"} {
+		if _, err := fast.Search(ctx, q, 5, false); err != nil {
+			t.Fatalf("Search %q: %v; a later engine's rate limit leaked into this one", q, err)
+		}
+	}
+}
+
+// TestIntegrationBudgetExhausted verifies that macro budgets stop upstream
+// traffic with ErrBudgetExhausted while cache hits keep working.
+func TestIntegrationBudgetExhausted(t *testing.T) {
+	contentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Budgeted", "Content served under a search budget.")))
+	}))
+	defer contentSrv.Close()
+
+	searchSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML([]string{contentSrv.URL + "/p"})))
+	}))
+	defer searchSrv.Close()
+
+	ctx := serverContext(searchSrv, contentSrv)
+
+	dbPath := filepath.Join(t.TempDir(), "budget_test.db")
+	c, err := cache.New(dbPath)
+	if err != nil {
+		t.Fatalf("cache.New: %v", err)
+	}
+	defer c.Close()
+
+	eng := engine.New(c, engine.Config{
+		SearchEngine: "google",
+		Budget:       engine.Budget{SearchesPerHour: map[string]int{"google": 1}},
+	})
+
+	if _, err := eng.Search(ctx, "budget one", 5, false); err != nil {
+		t.Fatalf("first Search: %v", err)
+	}
+	_, err = eng.Search(ctx, "budget two", 5, false)
+	if !errors.Is(err, engine.ErrBudgetExhausted) {
+		t.Fatalf("second Search: err = %v, want ErrBudgetExhausted", err)
+	}
+
+	// Cached queries do not consume budget.
+	result, err := eng.Search(ctx, "budget one", 5, false)
+	if err != nil {
+		t.Fatalf("cached Search: %v", err)
+	}
+	if !result.FromCache {
+		t.Error("expected cache hit")
+	}
+}
+
+// fakeSummarizer records its input and returns a fixed summary.
+type fakeSummarizer struct{ content string }
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, query, content string) (string, error) {
+	f.content = content
+	return "short answer", nil
+}
+
+func TestIntegrationSummarize(t *testing.T) {
+	contentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Long", "A long article that the summarizer should condense.")))
+	}))
+	defer contentSrv.Close()
+
+	searchSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML([]string{contentSrv.URL + "/p"})))
+	}))
+	defer searchSrv.Close()
+
+	ctx := serverContext(searchSrv, contentSrv)
+
+	c, err := cache.New(filepath.Join(t.TempDir(), "summarize_test.db"))
+	if err != nil {
+		t.Fatalf("cache.New: %v", err)
+	}
+	defer c.Close()
+
+	// Without a summarizer the request is rejected up front.
+	_, err = engine.New(c, engine.Config{SearchEngine: "google"}).
+		SearchWithOptions(ctx, "summarize me", engine.SearchOptions{Count: 1, Summarize: true})
+	if !errors.Is(err, engine.ErrNoSummarizer) {
+		t.Fatalf("err = %v, want ErrNoSummarizer", err)
+	}
+
+	sum := &fakeSummarizer{}
+	eng := engine.New(c, engine.Config{SearchEngine: "google", Summarizer: sum})
+	result, err := eng.SearchWithOptions(ctx, "summarize me", engine.SearchOptions{Count: 1, Summarize: true})
+	if err != nil {
+		t.Fatalf("SearchWithOptions: %v", err)
+	}
+	if result.Content != "short answer" || !result.Summarized {
+		t.Errorf("result = %+v, want summarized content", result)
+	}
+	if !strings.Contains(sum.content, "condense") {
+		t.Errorf("summarizer input = %q, want consolidated page text", sum.content)
+	}
+	if len(result.Sources) != 1 {
+		t.Errorf("sources = %+v, want the scraped page", result.Sources)
+	}
+
+	// The cache keeps the full text.
+	result, err = eng.Search(ctx, "summarize me", 1, false)
+	if err != nil {
+		t.Fatalf("cached Search: %v", err)
+	}
+	if !result.FromCache || result.Summarized || !strings.Contains(result.Content, "condense") {
+		t.Errorf("cached result = %+v, want full text", result)
+	}
+}
+
+func TestIntegrationPin(t *testing.T) {
+	var fetches int
+	contentSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Reference", "Canonical reference material the team keeps offline.")))
+	}))
+	defer contentSrv.Close()
+
+	searchSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML([]string{contentSrv.URL + "/ref"})))
+	}))
+	defer searchSrv.Close()
+
+	ctx := serverContext(searchSrv, contentSrv)
+
+	c, err := cache.New(filepath.Join(t.TempDir(), "pin_test.db"))
+	if err != nil {
+		t.Fatalf("cache.New: %v", err)
+	}
+	defer c.Close()
+	eng := engine.New(c, engine.Config{SearchEngine: "google"})
+
+	// Pinning an uncached query searches it first.
+	if err := eng.Pin(ctx, "team reference"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if fetches != 1 {
+		t.Fatalf("fetches = %d, want 1", fetches)
+	}
+	pins, err := eng.Pinned()
+	if err != nil || len(pins) != 1 || pins[0].Query != "team reference" {
+		t.Fatalf("Pinned = %+v, %v", pins, err)
+	}
+
+	// Flushing the cache keeps pinned entries; clearing one explicitly fails.
+	if err := eng.ClearCache(""); err != nil {
+		t.Fatalf("ClearCache: %v", err)
+	}
+	if err := eng.ClearCache("team reference"); !errors.Is(err, cache.ErrPinned) {
+		t.Errorf("ClearCache pinned: err = %v, want cache.ErrPinned", err)
+	}
+	result, err := eng.Search(ctx, "team reference", 5, false)
+	if err != nil || !result.FromCache {
+		t.Fatalf("Search after flush: %+v, %v; want cache hit", result, err)
+	}
+
+	if err := eng.Unpin("team reference"); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+	if err := eng.Unpin("never pinned"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Unpin missing: err = %v, want cache.ErrNotFound", err)
 	}
 }
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/user/glsi/pkg/engine"
+)
+
+// apiKeyHeader identifies the caller for per-key feature flags. Keys are
+// opaque secrets handed out by the operator; an unknown or missing key gets
+// the deployment-wide flags.
+const apiKeyHeader = "X-API-Key"
+
+// requireAdmin rejects requests that lack "Authorization: Bearer <token>".
+func requireAdmin(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid admin token", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type featuresResponse struct {
+	Features map[string]bool            `json:"features"` // effective deployment-wide flags
+	Keys     map[string]map[string]bool `json:"keys"`     // per-key overrides
+}
+
+// featuresHandler lists feature flags (GET), sets one (PUT, with feature,
+// enabled and optionally key), or drops a setting so the default applies
+// again (DELETE, with feature and optionally key). Without key, changes
+// apply deployment-wide.
+func featuresHandler(features *engine.Features) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodDelete:
+			name := q.Get("feature")
+			if !engine.ValidFeature(name) {
+				badParam(w, r, "feature", "unknown feature %q, want one of %s", name, strings.Join(engine.FeatureNames(), ", "))
+				return
+			}
+			var err error
+			if r.Method == http.MethodPut {
+				on, perr := strconv.ParseBool(q.Get("enabled"))
+				if perr != nil {
+					badParam(w, r, "enabled", "invalid enabled %q, want true or false", q.Get("enabled"))
+					return
+				}
+				err = features.Set(name, q.Get("key"), on)
+			} else {
+				err = features.Reset(name, q.Get("key"))
+			}
+			if err != nil {
+				writeEngineError(w, r, err)
+				return
+			}
+		default:
+			methodNotAllowed(w, r, "GET, PUT, DELETE")
+			return
+		}
+		s := features.Snapshot()
+		writeJSON(w, http.StatusOK, featuresResponse{Features: s.Defaults, Keys: s.Keys})
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/engine/enginetest"
+)
+
+func TestFeaturesEndpoint(t *testing.T) {
+	features, err := engine.NewFeatures(nil, nil)
+	if err != nil {
+		t.Fatalf("NewFeatures: %v", err)
+	}
+	mux := newMux(&enginetest.Fake{}, Config{Features: features, AdminToken: "s3cret"})
+	do := func(method, target, token string) (*httptest.ResponseRecorder, featuresResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		var resp featuresResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rr, resp
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if rr, _ := do(http.MethodGet, "/admin/features", token); rr.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, rr.Code)
+		}
+	}
+
+	rr, resp := do(http.MethodGet, "/admin/features", "s3cret")
+	if rr.Code != http.StatusOK || !resp.Features[engine.FeatureRender] {
+		t.Fatalf("GET = %d %+v, want render on by default", rr.Code, resp)
+	}
+
+	rr, resp = do(http.MethodPut, "/admin/features?feature=render&enabled=false", "s3cret")
+	if rr.Code != http.StatusOK || resp.Features[engine.FeatureRender] || features.Enabled(engine.FeatureRender, "") {
+		t.Fatalf("PUT deployment = %d %+v, want render off", rr.Code, resp)
+	}
+	rr, resp = do(http.MethodPut, "/admin/features?feature=render&enabled=true&key=beta", "s3cret")
+	if rr.Code != http.StatusOK || !resp.Keys["beta"][engine.FeatureRender] || !features.Enabled(engine.FeatureRender, "beta") {
+		t.Fatalf("PUT key = %d %+v, want render on for beta", rr.Code, resp)
+	}
+	rr, resp = do(http.MethodDelete, "/admin/features?feature=render&key=beta", "s3cret")
+	if rr.Code != http.StatusOK || len(resp.Keys) != 0 || features.Enabled(engine.FeatureRender, "beta") {
+		t.Fatalf("DELETE key = %d %+v, want the override gone", rr.Code, resp)
+	}
+
+	if rr, _ := do(http.MethodPut, "/admin/features?feature=teleport&enabled=true", "s3cret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown feature: status = %d, want 400", rr.Code)
+	}
+	if rr, _ := do(http.MethodPut, "/admin/features?feature=render&enabled=maybe", "s3cret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad enabled: status = %d, want 400", rr.Code)
+	}
+
+	// Without a token the endpoint does not exist.
+	rr = httptest.NewRecorder()
+	newMux(&enginetest.Fake{}, Config{Features: features}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/features", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("no admin token: status = %d, want 404", rr.Code)
+	}
+}
+
+func TestSearchHandlerPassesAPIKey(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"q": {Content: "text", ResultCount: 1},
+	}}
+	req := httptest.NewRequest(http.MethodGet, "/search?q=q", nil)
+	req.Header.Set(apiKeyHeader, "beta")
+	rr := httptest.NewRecorder()
+	searchHandler(fake, engine.CountLimits{})(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Opts.Key != "beta" {
+		t.Errorf("calls = %+v, want Key beta", calls)
+	}
+}
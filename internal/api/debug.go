@@ -0,0 +1,99 @@
+package api
+
+import (
+	"time"
+
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// timingsMillis is scraper.Timings in fractional milliseconds.
+type timingsMillis struct {
+	Queue    float64 `json:"queue"`
+	DNS      float64 `json:"dns"`
+	Connect  float64 `json:"connect"`
+	TLS      float64 `json:"tls"`
+	TTFB     float64 `json:"ttfb"`
+	Download float64 `json:"download"`
+	Extract  float64 `json:"extract"`
+	Total    float64 `json:"total"`
+}
+
+func newTimingsMillis(t scraper.Timings) timingsMillis {
+	return timingsMillis{
+		Queue:    ms(t.Queue),
+		DNS:      ms(t.DNS),
+		Connect:  ms(t.Connect),
+		TLS:      ms(t.TLS),
+		TTFB:     ms(t.TTFB),
+		Download: ms(t.Download),
+		Extract:  ms(t.Extract),
+		Total:    ms(t.Total),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+type debugPage struct {
+	URL         string        `json:"url"`
+	FinalURL    string        `json:"final_url,omitempty"`     // where redirects ended
+	From        string        `json:"followed_from,omitempty"` // result page linking here, with depth=1
+	Error       string        `json:"error,omitempty"`
+	Status      int           `json:"status,omitempty"`      // HTTP status of the last response
+	Bytes       int64         `json:"bytes"`                 // body size after decompression
+	Words       int           `json:"words"`                 // words of extracted text
+	Blocked     string        `json:"blocked,omitempty"`     // paywall, consent or geo
+	Archive     string        `json:"archive_url,omitempty"` // snapshot used instead of the blocked page
+	Via         string        `json:"via,omitempty"`         // geo-proxy or archive
+	Trimmed     bool          `json:"trimmed,omitempty"`     // cut by max_sections
+	Truncated   bool          `json:"truncated,omitempty"`   // shortened to fit GLSI_MAX_CONTENT_BYTES
+	Duplicate   bool          `json:"duplicate,omitempty"`   // same final URL as an earlier page
+	Skipped     bool          `json:"skipped,omitempty"`     // text shorter than GLSI_MIN_PAGE_CHARS
+	Revalidated bool          `json:"revalidated,omitempty"` // 304: the stored body was reused
+	Cached      bool          `json:"cached,omitempty"`      // reused from the page cache, not fetched
+	Reused      bool          `json:"reused_conn"`
+	TimingsMs   timingsMillis `json:"timings_ms"`
+}
+
+// searchTimings is engine.Timings in fractional milliseconds.
+type searchTimings struct {
+	SERP          float64 `json:"serp_ms"`
+	RateLimitWait float64 `json:"rate_limit_wait_ms"`
+	Scrape        float64 `json:"scrape_ms"`
+	Consolidate   float64 `json:"consolidate_ms"`
+	Cache         float64 `json:"cache_ms"`
+	Total         float64 `json:"total_ms"`
+}
+
+func newSearchTimings(t engine.Timings) searchTimings {
+	return searchTimings{
+		SERP:          ms(t.SERP),
+		RateLimitWait: ms(t.RateLimitWait),
+		Scrape:        ms(t.Scrape),
+		Consolidate:   ms(t.Consolidate),
+		Cache:         ms(t.Cache),
+		Total:         ms(t.Total),
+	}
+}
+
+// debugInfo is returned with ?debug=1. Pages are only listed for fresh
+// (uncached) searches.
+type debugInfo struct {
+	Intent  string        `json:"intent,omitempty"` // with GLSI_CLASSIFY_INTENT
+	Timings searchTimings `json:"timings"`
+	Pages   []debugPage   `json:"pages,omitempty"`
+}
+
+func newDebugInfo(result engine.SearchResult) *debugInfo {
+	pages := result.Pages
+	d := &debugInfo{Intent: string(result.Intent), Timings: newSearchTimings(result.Timings), Pages: make([]debugPage, len(pages))}
+	for i, p := range pages {
+		d.Pages[i] = debugPage{URL: p.URL, FinalURL: p.FinalURL, From: p.FollowedFrom, Duplicate: p.Duplicate, Skipped: p.Skipped, Revalidated: p.Revalidated, Cached: p.Cached, Blocked: p.Blocked, Archive: p.Archive, Via: p.Via, Trimmed: p.Trimmed, Truncated: p.Truncated, Status: p.Status, Bytes: p.Bytes, Words: p.Words, Reused: p.Timings.Reused, TimingsMs: newTimingsMillis(p.Timings)}
+		if p.Err != nil {
+			d.Pages[i].Error = p.Err.Error()
+		}
+	}
+	return d
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Stable error codes returned in apiError.Code. Clients should switch on
+// these rather than on messages, which may change.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeBudgetExhausted  = "budget_exhausted"
+	CodeSearchBlocked    = "search_blocked"
+	CodeCircuitOpen      = "circuit_open"
+	CodeNoResults        = "no_results"
+	CodeScrapeFailed     = "scrape_failed"
+	CodeTimeout          = "timeout"
+	CodeCanceled         = "canceled"
+	CodeNotFound         = "not_found"
+	CodePinned           = "pinned"
+	CodeNoSummarizer     = "summarizer_unavailable"
+	CodeSummarizeFailed  = "summarize_failed"
+	CodeFeatureDisabled  = "feature_disabled"
+	CodeGuardrail        = "guardrail"
+	CodeUnauthorized     = "unauthorized"
+	CodeNoCacheSearch    = "cache_search_unavailable"
+	CodeNoTopQueries     = "top_queries_unavailable"
+	CodeNoHistory        = "history_unavailable"
+	CodeInternal         = "internal"
+)
+
+// statusClientClosedRequest is the non-standard status (popularised by
+// nginx) for a request the client abandoned before the response was ready.
+const statusClientClosedRequest = 499
+
+// requestIDHeader carries the request ID. A client-supplied value is echoed
+// back; otherwise one is generated.
+const requestIDHeader = "X-Request-ID"
+
+// apiError is the error body shared by every handler.
+type apiError struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Retryable bool           `json:"retryable"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+}
+
+// errorMapping is the HTTP status and code for an engine error.
+type errorMapping struct {
+	target    error
+	status    int
+	code      string
+	retryable bool
+}
+
+// engineErrors maps typed errors from the pipeline to stable codes. The
+// first match wins.
+var engineErrors = []errorMapping{
+	{engine.ErrBudgetExhausted, http.StatusTooManyRequests, CodeBudgetExhausted, true},
+	{search.ErrBlocked, http.StatusBadGateway, CodeSearchBlocked, true},
+	{engine.ErrCircuitOpen, http.StatusServiceUnavailable, CodeCircuitOpen, true},
+	{engine.ErrInvalidURL, http.StatusBadRequest, CodeBadRequest, false},
+	{engine.ErrNoResults, http.StatusNotFound, CodeNoResults, false},
+	{engine.ErrScrapeFailed, http.StatusBadGateway, CodeScrapeFailed, true},
+	{cache.ErrNotFound, http.StatusNotFound, CodeNotFound, false},
+	{cache.ErrPinned, http.StatusConflict, CodePinned, false},
+	{engine.ErrNoSummarizer, http.StatusNotImplemented, CodeNoSummarizer, false},
+	{engine.ErrSummarizeFailed, http.StatusBadGateway, CodeSummarizeFailed, true},
+	{engine.ErrFeatureDisabled, http.StatusForbidden, CodeFeatureDisabled, false},
+	{engine.ErrGuardrail, http.StatusForbidden, CodeGuardrail, false},
+	{engine.ErrNoCacheSearch, http.StatusNotImplemented, CodeNoCacheSearch, false},
+	{cache.ErrSearchEncrypted, http.StatusNotImplemented, CodeNoCacheSearch, false},
+	{engine.ErrNoTopQueries, http.StatusNotImplemented, CodeNoTopQueries, false},
+	{engine.ErrNoHistory, http.StatusNotImplemented, CodeNoHistory, false},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, CodeTimeout, true},
+	{context.Canceled, statusClientClosedRequest, CodeCanceled, true},
+}
+
+// writeError writes a non-retryable error body with the given status and
+// code. Errors known to be transient go through engineErrors instead.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string, details map[string]any) {
+	writeJSON(w, status, apiResponse{Error: &apiError{
+		Code:      code,
+		Message:   msg,
+		Details:   details,
+		RequestID: requestID(w, r),
+	}})
+}
+
+// writeEngineError maps err to a status and code and writes it.
+func writeEngineError(w http.ResponseWriter, r *http.Request, err error) {
+	status, body := engineError(w, r, err)
+	writeJSON(w, status, apiResponse{Error: body})
+}
+
+// engineError maps err to a status and error body.
+func engineError(w http.ResponseWriter, r *http.Request, err error) (int, *apiError) {
+	for _, m := range engineErrors {
+		if errors.Is(err, m.target) {
+			return m.status, &apiError{
+				Code:      m.code,
+				Message:   err.Error(),
+				Retryable: m.retryable,
+				RequestID: requestID(w, r),
+			}
+		}
+	}
+	return http.StatusInternalServerError, &apiError{Code: CodeInternal, Message: err.Error(), RequestID: requestID(w, r)}
+}
+
+// badParam writes a 400 for an invalid query parameter.
+func badParam(w http.ResponseWriter, r *http.Request, param, format string, args ...any) {
+	writeError(w, r, http.StatusBadRequest, CodeBadRequest, fmt.Sprintf(format, args...),
+		map[string]any{"param": param})
+}
+
+// methodNotAllowed writes a 405 listing the allowed method.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed", nil)
+}
+
+// withRequestID assigns every request an ID and echoes it in the response
+// headers so it can be correlated with error bodies and logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID returns the request's ID, assigning one on first use.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > 128 {
+		var b [8]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/search"
+)
+
+func TestWriteEngineError(t *testing.T) {
+	tests := []struct {
+		err           error
+		wantStatus    int
+		wantCode      string
+		wantRetryable bool
+	}{
+		{fmt.Errorf("engine: google search budget: %w", engine.ErrBudgetExhausted), http.StatusTooManyRequests, CodeBudgetExhausted, true},
+		{fmt.Errorf("engine: search: %w", search.ErrBlocked), http.StatusBadGateway, CodeSearchBlocked, true},
+		{fmt.Errorf("engine: search engines google and duckduckgo: %w", engine.ErrCircuitOpen), http.StatusServiceUnavailable, CodeCircuitOpen, true},
+		{fmt.Errorf("engine: %w for %q", engine.ErrNoResults, "q"), http.StatusNotFound, CodeNoResults, false},
+		{fmt.Errorf("engine: %w for %q", engine.ErrScrapeFailed, "q"), http.StatusBadGateway, CodeScrapeFailed, true},
+		{fmt.Errorf("engine: search: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout, true},
+		{fmt.Errorf("engine: %w", engine.ErrNoSummarizer), http.StatusNotImplemented, CodeNoSummarizer, false},
+		{fmt.Errorf("engine: %w: %w", engine.ErrSummarizeFailed, context.DeadlineExceeded), http.StatusBadGateway, CodeSummarizeFailed, true},
+		{fmt.Errorf("engine: render always: %w", engine.ErrFeatureDisabled), http.StatusForbidden, CodeFeatureDisabled, false},
+		{fmt.Errorf("engine: local queries: %w", engine.ErrGuardrail), http.StatusForbidden, CodeGuardrail, false},
+		{fmt.Errorf("engine: search: %w", context.Canceled), statusClientClosedRequest, CodeCanceled, true},
+		{errors.New("disk on fire"), http.StatusInternalServerError, CodeInternal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeEngineError(rr, httptest.NewRequest(http.MethodGet, "/search", nil), tt.err)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			var resp apiResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Error == nil {
+				t.Fatal("missing error body")
+			}
+			if resp.Error.Code != tt.wantCode || resp.Error.Retryable != tt.wantRetryable {
+				t.Errorf("error = %+v, want code %q retryable %v", resp.Error, tt.wantCode, tt.wantRetryable)
+			}
+			if resp.Error.Message != tt.err.Error() {
+				t.Errorf("message = %q, want %q", resp.Error.Message, tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestRequestIDEchoed(t *testing.T) {
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methodNotAllowed(w, r, http.MethodGet)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/search", nil)
+	req.Header.Set("X-Request-ID", "client-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Request-ID"); got != "client-123" {
+		t.Errorf("X-Request-ID header = %q, want client-123", got)
+	}
+	if got := rr.Header().Get("Allow"); got != http.MethodGet {
+		t.Errorf("Allow = %q, want GET", got)
+	}
+	var resp apiResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Error == nil || resp.Error.RequestID != "client-123" || resp.Error.Code != CodeMethodNotAllowed {
+		t.Errorf("error = %+v", resp.Error)
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/engine/enginetest"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenFormats are the /search response formats checked against golden
+// files; every one is rendered from the same engine result.
+var goldenFormats = []struct {
+	file   string // under testdata/golden
+	params string // appended to the query string
+	accept string
+}{
+	{"search.json", "", ""},
+	{"search.csv", "&format=csv", ""},
+	{"search.jsonl", "&format=jsonl", ""},
+	{"search.md", "", "text/markdown"},
+	{"search.txt", "", "text/plain"},
+	{"search_cited.md", "&cite=1", "text/markdown"},
+}
+
+// goldenPage is one fixture page and the metadata every format must agree on.
+type goldenPage struct {
+	url, title, date string
+}
+
+var goldenPages = []goldenPage{
+	{"https://ads.example/generics-course", "Learn Generics Fast", ""},
+	{"https://go.dev/doc/tutorial/generics", "Tutorial: Getting started with generics", "2022-03-15"},
+	{"https://example.com/blog/go-generics", `Generics, "in practice"`, "2023-07-01"},
+	{"https://beispiel.de/go-generika", "Generika in Go", ""},
+}
+
+// goldenEngine serves goldenPages through the real pipeline, with stubbed
+// search and scraping.
+func goldenEngine() *engine.Engine {
+	results := make([]search.Result, len(goldenPages))
+	for i, p := range goldenPages {
+		results[i] = search.Result{URL: p.url, Sponsored: i == 0}
+	}
+	pages := map[string]scraper.ScrapedPage{}
+	for _, p := range goldenPages {
+		page := enginetest.Page(p.title, "")
+		if p.date != "" {
+			t, _ := time.Parse("2006-01-02", p.date)
+			page.Published = scraper.PublishDate{Time: t, Source: scraper.DateSourceMetadata, Confidence: scraper.DateConfidenceHigh}
+		}
+		pages[p.url] = page
+	}
+	set := func(url string, f func(*scraper.ScrapedPage)) {
+		p := pages[url]
+		f(&p)
+		pages[url] = p
+	}
+	set(goldenPages[0].url, func(p *scraper.ScrapedPage) {
+		p.Content = "Master Go generics in one weekend. Enroll today."
+		p.Language = "en"
+	})
+	set(goldenPages[1].url, func(p *scraper.ScrapedPage) {
+		p.Content = "This tutorial introduces the basics of generics in Go.\n\nWith generics, you can declare and use functions or types that are written to work with any of a set of types provided by calling code."
+		p.Language, p.SiteName, p.Description = "en", "The Go Programming Language", "An introduction to generics."
+	})
+	set(goldenPages[2].url, func(p *scraper.ScrapedPage) {
+		p.Content = "Type parameters arrived in Go 1.18.\n## Not a section header\nPublished: not metadata either\n\n| Version | Feature |\n|---|---|\n| 1.18 | Generics |"
+		p.Language, p.Author = "en", "Jane Doe"
+	})
+	set(goldenPages[3].url, func(p *scraper.ScrapedPage) {
+		p.Content = "Seit Go 1.18 unterstützt die Sprache generische Typen – ein lang erwarteter Schritt."
+		p.Language = "de"
+	})
+	return engine.New(&enginetest.MemoryStore{}, engine.Config{
+		Searcher:         enginetest.StaticSearcher{Results: map[string][]search.Result{"go generics": results}},
+		Scraper:          enginetest.StaticScraper{Pages: pages},
+		IncludeSponsored: true,
+	})
+}
+
+func TestSearchFormatsGolden(t *testing.T) {
+	handler := searchHandler(goldenEngine(), engine.CountLimits{})
+	for _, f := range goldenFormats {
+		t.Run(f.file, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/search?q=go+generics&force=1"+f.params, nil)
+			if f.accept != "" {
+				req.Header.Set("Accept", f.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+			}
+			got := rr.Body.Bytes()
+
+			path := filepath.Join("testdata", "golden", f.file)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("output differs from %s (run go test -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+			}
+
+			// Whatever the format, it must list the same pages in the
+			// same order with the same metadata.
+			parsed := parseGoldenFormat(t, f.file, got)
+			if len(parsed) != len(goldenPages) {
+				t.Fatalf("%d pages, want %d: %+v", len(parsed), len(goldenPages), parsed)
+			}
+			for i, p := range parsed {
+				want := goldenPages[i]
+				if p.url != want.url || p.title != want.title {
+					t.Errorf("page %d = %q %q, want %q %q", i, p.url, p.title, want.url, want.title)
+				}
+				if p.date != want.date {
+					t.Errorf("page %d date = %q, want %q", i, p.date, want.date)
+				}
+			}
+		})
+	}
+}
+
+// rxGoldenHeader matches a consolidated section header and, within the
+// section, a Published line.
+var (
+	rxGoldenHeader    = regexp.MustCompile(`(?m)^## (.+) — (\S+) \(\w+\)$`)
+	rxGoldenPublished = regexp.MustCompile(`(?m)^Published: (\d{4}-\d{2}-\d{2})`)
+)
+
+// parseGoldenFormat recovers the pages a response lists, in order.
+func parseGoldenFormat(t *testing.T, file string, body []byte) []goldenPage {
+	t.Helper()
+	var pages []goldenPage
+	switch filepath.Ext(file) {
+	case ".json":
+		var resp apiResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range resp.Sources {
+			p := goldenPage{url: s.URL, title: s.Title}
+			if s.Published != nil {
+				p.date = s.Published.Date
+			}
+			pages = append(pages, p)
+		}
+		// The content inside the JSON lists them too.
+		if inner := parseGoldenFormat(t, ".md", []byte(resp.Content)); len(inner) != len(pages) {
+			t.Errorf("content has %d sections, sources %d", len(inner), len(pages))
+		}
+	case ".csv":
+		rows, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows[1:] {
+			pages = append(pages, goldenPage{url: row[0], title: row[1], date: row[2]})
+		}
+	case ".jsonl":
+		sc := bufio.NewScanner(strings.NewReader(string(body)))
+		for sc.Scan() {
+			var row exportRowJSON
+			if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+				t.Fatal(err)
+			}
+			pages = append(pages, goldenPage{url: row.URL, title: row.Title, date: row.Date})
+		}
+	default: // consolidated text, markdown or plain
+		sections := strings.Split(string(body), "\n\n---\n\n")
+		for _, sec := range sections {
+			m := rxGoldenHeader.FindStringSubmatch(sec)
+			if m == nil {
+				t.Fatalf("section without a header:\n%s", sec)
+			}
+			p := goldenPage{url: m[2], title: m[1]}
+			if d := rxGoldenPublished.FindStringSubmatch(sec); d != nil {
+				p.date = d[1]
+			}
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+// exportRowJSON mirrors the JSONL export's keys.
+type exportRowJSON struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Date  string `json:"date"`
+}
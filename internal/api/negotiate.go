@@ -0,0 +1,66 @@
+package api
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Response bodies /search offers through the Accept header, in order of
+// preference when a client accepts several equally.
+const (
+	mediaJSON     = "application/json"
+	mediaMarkdown = "text/markdown"
+	mediaText     = "text/plain"
+)
+
+var offeredMedia = []string{mediaJSON, mediaMarkdown, mediaText}
+
+// negotiate picks the /search body type for an Accept header: the offered
+// type with the highest q-value, where each type takes its q from the most
+// specific matching range. A missing header, or one that accepts none of
+// the offered types, gets JSON; the errors clients switch on are JSON
+// anyway.
+func negotiate(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return mediaJSON
+	}
+	best, bestQ := mediaJSON, 0.0
+	for _, offer := range offeredMedia {
+		q, specificity := 0.0, -1
+		for _, part := range strings.Split(accept, ",") {
+			mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			s := matchMedia(mt, offer)
+			if s <= specificity {
+				continue
+			}
+			specificity, q = s, 1
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// matchMedia reports how specifically the media range rng matches offer:
+// 2 for an exact match, 1 for type/*, 0 for */*, and -1 for no match.
+func matchMedia(rng, offer string) int {
+	switch {
+	case rng == offer:
+		return 2
+	case rng == "*/*":
+		return 0
+	case strings.HasSuffix(rng, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(rng, "*")):
+		return 1
+	}
+	return -1
+}
@@ -1,32 +1,246 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/user/glsi/internal/engine"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/metrics"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
 )
 
+const (
+	defaultSocketMode = 0o660
+	shutdownTimeout   = 10 * time.Second
+)
+
+// Config holds HTTP listener configuration.
+type Config struct {
+	Addr       string      // TCP listen address, e.g. ":8080"
+	SocketPath string      // if set, listen on this unix socket instead of Addr
+	SocketMode os.FileMode // unix socket permissions (default 0660)
+
+	// Counts overrides the engine's default and maximum result count for
+	// /search requests.
+	Counts engine.CountLimits
+
+	// MinFreeBytes is the free space on the cache database's volume below
+	// which a deep health check reports "degraded" (default
+	// DefaultMinFreeBytes; negative disables the check).
+	MinFreeBytes int64
+
+	// Features is served at /admin/features so flags can be changed at
+	// runtime; pass the same value as the engine's Config.Features.
+	// AdminToken must be set too, or the endpoint is not registered.
+	Features   *engine.Features
+	AdminToken string // bearer token required by /admin endpoints
+
+	// Metrics is served at /metrics in the Prometheus text format; pass
+	// the engine's Config.Metrics. Nil leaves the endpoint out.
+	Metrics *metrics.Registry
+
+	// Listener, if set, is used as-is instead of opening Addr or SocketPath
+	// (e.g. a socket inherited via systemd socket activation).
+	Listener net.Listener
+
+	// Ready, if set, is called once the server is accepting connections.
+	Ready func()
+}
+
+// DefaultMinFreeBytes is the default Config.MinFreeBytes.
+const DefaultMinFreeBytes = 512 << 20
+
 // ListenAndServe starts an HTTP API server on the given address.
-func ListenAndServe(addr string, eng *engine.Engine) error {
+func ListenAndServe(addr string, eng engine.Service) error {
+	return Run(context.Background(), Config{Addr: addr}, eng)
+}
+
+// Run starts the HTTP API server described by cfg and blocks until ctx is
+// cancelled or the server fails. On cancellation in-flight requests are given
+// a grace period to finish, and a unix socket file is removed.
+func Run(ctx context.Context, cfg Config, eng engine.Service) error {
+	ln, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: withRequestID(newMux(eng, cfg))}
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "GLSI HTTP API listening on %s\n", ln.Addr())
+		errCh <- srv.Serve(ln)
+	}()
+	if cfg.Ready != nil {
+		cfg.Ready()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("api: shutdown: %w", err)
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// listen opens the TCP or unix listener for cfg. A unix listener unlinks its
+// socket file itself when the server closes it.
+func listen(cfg Config) (net.Listener, error) {
+	if cfg.Listener != nil {
+		return cfg.Listener, nil
+	}
+	if cfg.SocketPath == "" {
+		ln, err := net.Listen("tcp", cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("api: listen %s: %w", cfg.Addr, err)
+		}
+		return ln, nil
+	}
+
+	if err := removeStaleSocket(cfg.SocketPath); err != nil {
+		return nil, err
+	}
+	mode := cfg.SocketMode
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+	ln, err := listenUnix(cfg.SocketPath, mode)
+	if err != nil {
+		return nil, fmt.Errorf("api: listen unix %s: %w", cfg.SocketPath, err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket deletes a socket left behind by a crashed process. It
+// never clobbers a regular file, and refuses to take over a socket that
+// another instance is still serving.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("api: %s exists and is not a socket", path)
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("api: %s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("api: probe existing socket %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("api: remove stale socket: %w", err)
+	}
+	return nil
+}
+
+func newMux(eng engine.Service, cfg Config) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/search", searchHandler(eng))
+	mux.HandleFunc("/search", searchHandler(eng, cfg.Counts))
+	mux.HandleFunc("/search/stream", streamHandler(eng, cfg.Counts))
+	mux.HandleFunc("/fetch", fetchHandler(eng))
 	mux.HandleFunc("/cache", cacheHandler(eng))
-	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/cache/pin", pinHandler(eng))
+	mux.HandleFunc("/cache/search", cacheSearchHandler(eng))
+	mux.HandleFunc("/cache/top", topHandler(eng))
+	mux.HandleFunc("/history", historyHandler(eng))
+	mux.HandleFunc("/history/replay", replayHandler(eng))
+	mux.HandleFunc("/stats", statsHandler(eng))
+	mux.HandleFunc("/health", healthHandler(eng, cfg.MinFreeBytes))
+	if cfg.Metrics != nil {
+		mux.HandleFunc("/metrics", metricsHandler(cfg.Metrics))
+	}
+	if cfg.AdminToken != "" && cfg.Features != nil {
+		mux.Handle("/admin/features", requireAdmin(cfg.AdminToken, featuresHandler(cfg.Features)))
+	}
+	return mux
+}
 
-	fmt.Fprintf(os.Stderr, "GLSI HTTP API listening on %s\n", addr)
-	return http.ListenAndServe(addr, mux)
+type sourceResponse struct {
+	Title       string             `json:"title,omitempty"`
+	URL         string             `json:"url"`
+	Language    string             `json:"language,omitempty"`
+	Published   *publishedResponse `json:"published,omitempty"`
+	Author      string             `json:"author,omitempty"`
+	SiteName    string             `json:"site_name,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Sponsored   bool               `json:"sponsored,omitempty"`
+	Via         string             `json:"retrieved_via,omitempty"` // geo-proxy or archive
+}
+
+type publishedResponse struct {
+	Date       string `json:"date"`
+	Source     string `json:"source"`     // metadata, text, url or last-modified
+	Confidence string `json:"confidence"` // high, medium or low
+}
+
+func newSourceResponse(src engine.Source) sourceResponse {
+	resp := sourceResponse{
+		Title:       src.Title,
+		URL:         src.URL,
+		Language:    src.Language,
+		Author:      src.Author,
+		SiteName:    src.SiteName,
+		Description: src.Description,
+		Sponsored:   src.Sponsored,
+		Via:         src.Via,
+	}
+	if d := src.Published; !d.IsZero() {
+		resp.Published = &publishedResponse{Date: d.Time.Format("2006-01-02"), Source: d.Source, Confidence: d.Confidence}
+	}
+	return resp
+}
+
+// failedResponse is a page of a fresh search left out of content.
+type failedResponse struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`          // timeout, robots.txt, HTTP 404, too short, ...
+	Error  string `json:"error,omitempty"` // the scrape error, if any
+}
+
+func newFailedResponse(f engine.FailedPage) failedResponse {
+	resp := failedResponse{URL: f.URL, Reason: f.Reason}
+	if f.Err != nil {
+		resp.Error = f.Err.Error()
+	}
+	return resp
 }
 
 type apiResponse struct {
-	Content     string `json:"content,omitempty"`
-	ResultCount int    `json:"result_count,omitempty"`
-	FromCache   bool   `json:"from_cache,omitempty"`
-	Error       string `json:"error,omitempty"`
-	Status      string `json:"status,omitempty"`
+	Content     string           `json:"content,omitempty"`
+	ResultCount int              `json:"result_count,omitempty"`
+	FromCache   bool             `json:"from_cache,omitempty"`
+	Stale       bool             `json:"stale,omitempty"`
+	Partial     bool             `json:"partial,omitempty"`   // the deadline cut the scrape short
+	Engine      string           `json:"engine,omitempty"`    // google, duckduckgo, site or url
+	CachedAt    *time.Time       `json:"cached_at,omitempty"` // when a cached result was stored
+	Summarized  bool             `json:"summarized,omitempty"`
+	Sources     []sourceResponse `json:"sources,omitempty"`
+	Failed      []failedResponse `json:"failed,omitempty"` // pages that could not be used, with why
+	Debug       *debugInfo       `json:"debug,omitempty"`
+	Error       *apiError        `json:"error,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	Cache       *cacheHealth     `json:"cache,omitempty"`
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -35,55 +249,375 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
-func searchHandler(eng *engine.Engine) http.HandlerFunc {
+func searchHandler(eng engine.Service, counts engine.CountLimits) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			writeJSON(w, http.StatusMethodNotAllowed, apiResponse{Error: "method not allowed"})
+			methodNotAllowed(w, r, http.MethodGet)
 			return
 		}
 
-		q := r.URL.Query().Get("q")
-		if q == "" {
-			writeJSON(w, http.StatusBadRequest, apiResponse{Error: "missing required query parameter 'q'"})
+		// Clients that ask for markdown get the pages as markdown unless
+		// they pick an output explicitly.
+		media := negotiate(r.Header.Get("Accept"))
+		w.Header().Add("Vary", "Accept")
+		defaultOutput := ""
+		if media == mediaMarkdown {
+			defaultOutput = scraper.OutputMarkdown
+		}
+		q, opts, ok := searchOptions(w, r, counts, defaultOutput)
+		if !ok {
 			return
 		}
 
-		count := 5
-		if c := r.URL.Query().Get("count"); c != "" {
-			if n, err := strconv.Atoi(c); err == nil && n > 0 {
-				count = n
-			}
+		format := r.URL.Query().Get("format")
+		if format != "" && format != formatJSON && !engine.ValidFormat(format) {
+			badParam(w, r, "format", "unknown format %q", format)
+			return
 		}
 
-		force := false
-		if f := r.URL.Query().Get("force"); f == "true" || f == "1" {
-			force = true
+		result, err := eng.SearchWithOptions(r.Context(), q, opts)
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
 		}
+		if engine.ValidFormat(format) {
+			writeExport(w, format, result.Sources)
+			return
+		}
+		if format == "" && media != mediaJSON {
+			writeContent(w, media, result)
+			return
+		}
+		writeResult(w, r, result)
+	}
+}
+
+// searchOptions reads the query and options of a search request, shared
+// by /search and /search/stream. On a bad parameter it writes the error
+// and returns false.
+func searchOptions(w http.ResponseWriter, r *http.Request, counts engine.CountLimits, defaultOutput string) (string, engine.SearchOptions, bool) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		badParam(w, r, "q", "missing required query parameter 'q'")
+		return "", engine.SearchOptions{}, false
+	}
 
-		result, err := eng.Search(r.Context(), q, count, force)
+	var requested int
+	if c := r.URL.Query().Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
 		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, apiResponse{Error: err.Error()})
+			badParam(w, r, "count", "invalid count %q", c)
+			return "", engine.SearchOptions{}, false
+		}
+		requested = n
+	}
+	count, err := counts.Resolve(requested)
+	if err != nil {
+		badParam(w, r, "count", "%v", err)
+		return "", engine.SearchOptions{}, false
+	}
+
+	force := false
+	if f := r.URL.Query().Get("force"); f == "true" || f == "1" {
+		force = true
+	}
+
+	maxAge, ok := maxAgeParam(w, r)
+	if !ok {
+		return "", engine.SearchOptions{}, false
+	}
+
+	searchEngine := r.URL.Query().Get("engine")
+	if !search.ValidEngine(searchEngine) {
+		badParam(w, r, "engine", "unknown search engine %q", searchEngine)
+		return "", engine.SearchOptions{}, false
+	}
+
+	extractor := r.URL.Query().Get("extractor")
+	if !scraper.ValidExtractor(extractor) {
+		badParam(w, r, "extractor", "unknown extractor %q", extractor)
+		return "", engine.SearchOptions{}, false
+	}
+
+	render := r.URL.Query().Get("render")
+	if !scraper.ValidRenderMode(render) {
+		badParam(w, r, "render", "unknown render mode %q", render)
+		return "", engine.SearchOptions{}, false
+	}
+
+	output := r.URL.Query().Get("output")
+	if output == "" {
+		output = defaultOutput
+	}
+	if !scraper.ValidOutput(output) {
+		badParam(w, r, "output", "unknown output format %q", output)
+		return "", engine.SearchOptions{}, false
+	}
+
+	contentFormat := r.URL.Query().Get("content_format")
+	if !engine.ValidContentFormat(contentFormat) {
+		badParam(w, r, "content_format", "unknown content format %q", contentFormat)
+		return "", engine.SearchOptions{}, false
+	}
+
+	maxPerHost := 0
+	if v := r.URL.Query().Get("max_per_host"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badParam(w, r, "max_per_host", "invalid max_per_host %q", v)
+			return "", engine.SearchOptions{}, false
+		}
+		maxPerHost = n
+	}
+
+	maxSections := 0
+	if v := r.URL.Query().Get("max_sections"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badParam(w, r, "max_sections", "invalid max_sections %q", v)
+			return "", engine.SearchOptions{}, false
+		}
+		maxSections = n
+	}
+
+	depth := 0
+	switch v := r.URL.Query().Get("depth"); v {
+	case "":
+	case "0":
+		depth = -1 // explicitly off, even if the server default follows links
+	case "1":
+		depth = 1
+	default:
+		badParam(w, r, "depth", "invalid depth %q, want 0 or 1", v)
+		return "", engine.SearchOptions{}, false
+	}
+
+	site := r.URL.Query().Get("site")
+	if site != "" {
+		if err := scraper.ValidSite(site); err != nil {
+			badParam(w, r, "site", "invalid site %q", site)
+			return "", engine.SearchOptions{}, false
+		}
+	}
+
+	var scrapeTimeout time.Duration
+	if v := r.URL.Query().Get("scrape_timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > engine.MaxScrapeTimeout {
+			badParam(w, r, "scrape_timeout", "invalid scrape_timeout %q, want a duration up to %s", v, engine.MaxScrapeTimeout)
+			return "", engine.SearchOptions{}, false
+		}
+		scrapeTimeout = d
+	}
+
+	var timeout time.Duration
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > engine.MaxTimeout {
+			badParam(w, r, "timeout", "invalid timeout %q, want a duration up to %s", v, engine.MaxTimeout)
+			return "", engine.SearchOptions{}, false
+		}
+		timeout = d
+	}
+
+	return q, engine.SearchOptions{
+		Count:      count,
+		Force:      force,
+		MaxAge:     maxAge,
+		Engine:     searchEngine,
+		Extractor:  extractor,
+		Render:     render,
+		Output:     output,
+		Format:     contentFormat,
+		MaxPerHost: maxPerHost,
+
+		MaxSections:   maxSections,
+		Depth:         depth,
+		Site:          site,
+		ScrapeTimeout: scrapeTimeout,
+		Timeout:       timeout,
+
+		Cite:      r.URL.Query().Get("cite") == "true" || r.URL.Query().Get("cite") == "1",
+		Summarize: r.URL.Query().Get("summarize") == "true" || r.URL.Query().Get("summarize") == "1",
+
+		Key: r.Header.Get(apiKeyHeader),
+	}, true
+}
+
+// maxAgeParam reads the max_age parameter, a positive duration such as
+// "1h". On a bad value it writes the error and returns false.
+func maxAgeParam(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	v := r.URL.Query().Get("max_age")
+	if v == "" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		badParam(w, r, "max_age", "invalid max_age %q, want a positive duration such as 1h", v)
+		return 0, false
+	}
+	return d, true
+}
+
+// writeContent writes a result's bare content as media.
+func writeContent(w http.ResponseWriter, media string, result engine.SearchResult) {
+	w.Header().Set("Content-Type", media+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, result.Content)
+}
+
+// writeResult writes a result as JSON, with debug details if asked for.
+func writeResult(w http.ResponseWriter, r *http.Request, result engine.SearchResult) {
+	writeJSON(w, http.StatusOK, newAPIResponse(r, result))
+}
+
+func newAPIResponse(r *http.Request, result engine.SearchResult) apiResponse {
+	resp := apiResponse{
+		Content:     result.Content,
+		ResultCount: result.ResultCount,
+		FromCache:   result.FromCache,
+		Stale:       result.Stale,
+		Partial:     result.Partial,
+		Engine:      result.Engine,
+		Summarized:  result.Summarized,
+	}
+	if !result.CachedAt.IsZero() {
+		resp.CachedAt = &result.CachedAt
+	}
+	for _, src := range result.Sources {
+		resp.Sources = append(resp.Sources, newSourceResponse(src))
+	}
+	for _, f := range result.Failed {
+		resp.Failed = append(resp.Failed, newFailedResponse(f))
+	}
+	if d := r.URL.Query().Get("debug"); d == "true" || d == "1" {
+		resp.Debug = newDebugInfo(result)
+	}
+	return resp
+}
+
+// fetchHandler reads one page by URL, as a search does its result pages.
+func fetchHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
 			return
 		}
 
-		writeJSON(w, http.StatusOK, apiResponse{
-			Content:     result.Content,
-			ResultCount: result.ResultCount,
-			FromCache:   result.FromCache,
+		pageURL := r.URL.Query().Get("url")
+		if pageURL == "" {
+			badParam(w, r, "url", "missing required query parameter 'url'")
+			return
+		}
+
+		maxAge, ok := maxAgeParam(w, r)
+		if !ok {
+			return
+		}
+
+		extractor := r.URL.Query().Get("extractor")
+		if !scraper.ValidExtractor(extractor) {
+			badParam(w, r, "extractor", "unknown extractor %q", extractor)
+			return
+		}
+
+		render := r.URL.Query().Get("render")
+		if !scraper.ValidRenderMode(render) {
+			badParam(w, r, "render", "unknown render mode %q", render)
+			return
+		}
+
+		media := negotiate(r.Header.Get("Accept"))
+		w.Header().Add("Vary", "Accept")
+		output := r.URL.Query().Get("output")
+		if output == "" && media == mediaMarkdown {
+			output = scraper.OutputMarkdown
+		}
+		if !scraper.ValidOutput(output) {
+			badParam(w, r, "output", "unknown output format %q", output)
+			return
+		}
+
+		contentFormat := r.URL.Query().Get("content_format")
+		if !engine.ValidContentFormat(contentFormat) {
+			badParam(w, r, "content_format", "unknown content format %q", contentFormat)
+			return
+		}
+
+		var scrapeTimeout time.Duration
+		if v := r.URL.Query().Get("scrape_timeout"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 || d > engine.MaxScrapeTimeout {
+				badParam(w, r, "scrape_timeout", "invalid scrape_timeout %q, want a duration up to %s", v, engine.MaxScrapeTimeout)
+				return
+			}
+			scrapeTimeout = d
+		}
+
+		depth, followLinks := -1, 0
+		if v := r.URL.Query().Get("follow_links"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				badParam(w, r, "follow_links", "invalid follow_links %q", v)
+				return
+			}
+			if n > 0 {
+				depth, followLinks = 1, n
+			}
+		}
+
+		result, err := eng.FetchWithOptions(r.Context(), pageURL, engine.SearchOptions{
+			Force:         r.URL.Query().Get("force") == "true" || r.URL.Query().Get("force") == "1",
+			MaxAge:        maxAge,
+			Extractor:     extractor,
+			Render:        render,
+			Output:        output,
+			Format:        contentFormat,
+			ScrapeTimeout: scrapeTimeout,
+			Depth:         depth,
+			FollowLinks:   followLinks,
+			Cite:          r.URL.Query().Get("cite") == "true" || r.URL.Query().Get("cite") == "1",
+			Summarize:     r.URL.Query().Get("summarize") == "true" || r.URL.Query().Get("summarize") == "1",
+			Key:           r.Header.Get(apiKeyHeader),
 		})
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
+		}
+		if media != mediaJSON {
+			writeContent(w, media, result)
+			return
+		}
+		writeResult(w, r, result)
 	}
 }
 
-func cacheHandler(eng *engine.Engine) http.HandlerFunc {
+// formatJSON is the default /search response format.
+const formatJSON = "json"
+
+// exportContentTypes maps export formats to their media types.
+var exportContentTypes = map[string]string{
+	engine.FormatCSV:   "text/csv; charset=utf-8",
+	engine.FormatJSONL: "application/x-ndjson",
+}
+
+// writeExport writes sources as a CSV or JSONL download.
+func writeExport(w http.ResponseWriter, format string, sources []engine.Source) {
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.WriteHeader(http.StatusOK)
+	engine.WriteSources(w, format, sources)
+}
+
+func cacheHandler(eng engine.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
-			writeJSON(w, http.StatusMethodNotAllowed, apiResponse{Error: "method not allowed"})
+			methodNotAllowed(w, r, http.MethodDelete)
 			return
 		}
 
 		q := r.URL.Query().Get("q")
 		if err := eng.ClearCache(q); err != nil {
-			writeJSON(w, http.StatusInternalServerError, apiResponse{Error: err.Error()})
+			writeEngineError(w, r, err)
 			return
 		}
 
@@ -91,6 +625,376 @@ func cacheHandler(eng *engine.Engine) http.HandlerFunc {
 	}
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, apiResponse{Status: "ok"})
+type pinnedEntry struct {
+	Query     string    `json:"query"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Size      int       `json:"size"`
+}
+
+type pinsResponse struct {
+	Pinned []pinnedEntry `json:"pinned"`
+}
+
+// pinHandler lists (GET), pins (POST) or unpins (DELETE) cached queries.
+func pinHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		switch r.Method {
+		case http.MethodGet:
+			entries, err := eng.Pinned()
+			if err != nil {
+				writeEngineError(w, r, err)
+				return
+			}
+			resp := pinsResponse{Pinned: []pinnedEntry{}}
+			for _, e := range entries {
+				resp.Pinned = append(resp.Pinned, pinnedEntry{Query: e.Query, UpdatedAt: e.UpdatedAt, Size: e.Size})
+			}
+			writeJSON(w, http.StatusOK, resp)
+		case http.MethodPost, http.MethodDelete:
+			if q == "" {
+				badParam(w, r, "q", "missing required query parameter 'q'")
+				return
+			}
+			var err error
+			if r.Method == http.MethodPost {
+				err = eng.Pin(r.Context(), q)
+			} else {
+				err = eng.Unpin(q)
+			}
+			if err != nil {
+				writeEngineError(w, r, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, apiResponse{Status: "ok"})
+		default:
+			methodNotAllowed(w, r, "GET, POST, DELETE")
+		}
+	}
+}
+
+type cacheMatch struct {
+	Query     string    `json:"query"`
+	Snippet   string    `json:"snippet"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Stale     bool      `json:"stale,omitempty"`
+}
+
+type cacheSearchResponse struct {
+	Matches []cacheMatch `json:"matches"`
+}
+
+// cacheSearchHandler searches already-cached content without touching the
+// network.
+func cacheSearchHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		q := r.URL.Query().Get("q")
+		if q == "" {
+			badParam(w, r, "q", "missing required query parameter 'q'")
+			return
+		}
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > engine.MaxCacheSearchLimit {
+				badParam(w, r, "limit", "invalid limit %q, want 1 to %d", v, engine.MaxCacheSearchLimit)
+				return
+			}
+			limit = n
+		}
+
+		matches, err := eng.SearchCache(r.Context(), q, limit)
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
+		}
+		resp := cacheSearchResponse{Matches: []cacheMatch{}}
+		for _, m := range matches {
+			resp.Matches = append(resp.Matches, cacheMatch{Query: m.Query, Snippet: m.Snippet, UpdatedAt: m.UpdatedAt, Stale: m.Stale})
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+type topEntry struct {
+	Query     string     `json:"query"`
+	Hits      int        `json:"hits"`   // reads since first cached
+	Recent    int        `json:"recent"` // reads since last stored
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Pinned    bool       `json:"pinned,omitempty"`
+	Stale     bool       `json:"stale,omitempty"`
+}
+
+type topResponse struct {
+	Top []topEntry `json:"top"`
+}
+
+// topHandler reports the most read cached queries.
+func topHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > engine.MaxTopQueries {
+				badParam(w, r, "limit", "invalid limit %q, want 1 to %d", v, engine.MaxTopQueries)
+				return
+			}
+			limit = n
+		}
+
+		entries, err := eng.TopQueries(r.Context(), limit)
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
+		}
+		resp := topResponse{Top: []topEntry{}}
+		for _, e := range entries {
+			te := topEntry{Query: e.Query, Hits: e.TotalHits, Recent: e.Hits, UpdatedAt: e.UpdatedAt, Pinned: e.Pinned, Stale: e.Stale}
+			if !e.LastHitAt.IsZero() {
+				t := e.LastHitAt
+				te.LastHitAt = &t
+			}
+			resp.Top = append(resp.Top, te)
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+type historyEntry struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`  // search or fetch
+	Query       string    `json:"query"` // the URL for a fetch
+	Site        string    `json:"site,omitempty"`
+	Engine      string    `json:"engine,omitempty"`
+	ResultCount int       `json:"result_count"`
+	FromCache   bool      `json:"from_cache,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type historyResponse struct {
+	History []historyEntry `json:"history"`
+}
+
+// historyHandler lists recorded searches and fetches, newest first.
+func historyHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > engine.MaxHistoryList {
+				badParam(w, r, "limit", "invalid limit %q, want 1 to %d", v, engine.MaxHistoryList)
+				return
+			}
+			limit = n
+		}
+
+		records, err := eng.SearchHistory(r.Context(), r.URL.Query().Get("q"), limit)
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
+		}
+		resp := historyResponse{History: []historyEntry{}}
+		for _, rec := range records {
+			resp.History = append(resp.History, historyEntry{ID: rec.ID, Time: rec.Time, Kind: rec.Kind, Query: rec.Query, Site: rec.Site,
+				Engine: rec.Engine, ResultCount: rec.ResultCount, FromCache: rec.FromCache, Error: rec.Error})
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// replayHandler runs a recorded search or fetch again, answering as
+// /search does.
+func replayHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		v := r.URL.Query().Get("id")
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			badParam(w, r, "id", "invalid history id %q", v)
+			return
+		}
+		maxAge, ok := maxAgeParam(w, r)
+		if !ok {
+			return
+		}
+
+		opts := engine.SearchOptions{
+			Force:  r.URL.Query().Get("force") == "true" || r.URL.Query().Get("force") == "1",
+			MaxAge: maxAge,
+			Key:    r.Header.Get(apiKeyHeader),
+		}
+		result, err := eng.ReplayHistory(r.Context(), id, opts)
+		if err != nil {
+			writeEngineError(w, r, err)
+			return
+		}
+		writeResult(w, r, result)
+	}
+}
+
+type engineStatsResponse struct {
+	Requests    int64      `json:"requests"`
+	Blocked     int64      `json:"blocked"`
+	Empty       int64      `json:"empty"`
+	Errors      int64      `json:"errors"`
+	BlockRate   float64    `json:"block_rate"`
+	LastBlocked *time.Time `json:"last_blocked,omitempty"`
+}
+
+type scrapeStatsResponse struct {
+	Pages  int64         `json:"pages"`
+	Errors int64         `json:"errors"`
+	Reused int64         `json:"reused_conns"` // pages fetched over a pooled connection
+	AvgMs  timingsMillis `json:"avg_ms"`
+}
+
+type searchStatsResponse struct {
+	Searches        int64              `json:"searches"` // searches and fetches
+	CacheHits       int64              `json:"cache_hits"`
+	HitRatio        float64            `json:"hit_ratio"`
+	Partial         int64              `json:"partial"`
+	Errors          int64              `json:"errors"`
+	Pages           int64              `json:"pages"`
+	PagesFailed     int64              `json:"pages_failed"`
+	PagesCached     int64              `json:"pages_cached"`
+	PageSuccessRate float64            `json:"page_success_rate"`
+	StageAvgMs      map[string]float64 `json:"stage_avg_ms"`
+}
+
+type statsResponse struct {
+	Engines      map[string]engineStatsResponse `json:"engines"`
+	Scrape       scrapeStatsResponse            `json:"scrape"`
+	Searches     *searchStatsResponse           `json:"searches,omitempty"`
+	OpenCircuits map[string]time.Time           `json:"open_circuits,omitempty"` // skipped engines and sites, with when they are retried
+}
+
+func statsHandler(eng engine.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+
+		stats := eng.Stats()
+		resp := statsResponse{Engines: make(map[string]engineStatsResponse, len(stats.Engines)), OpenCircuits: stats.OpenCircuits}
+		for name, s := range stats.Engines {
+			es := engineStatsResponse{
+				Requests:  s.Requests,
+				Blocked:   s.Blocked,
+				Empty:     s.Empty,
+				Errors:    s.Errors,
+				BlockRate: s.BlockRate(),
+			}
+			if !s.LastBlocked.IsZero() {
+				t := s.LastBlocked
+				es.LastBlocked = &t
+			}
+			resp.Engines[name] = es
+		}
+		resp.Scrape = scrapeStatsResponse{
+			Pages:  stats.Scrape.Pages,
+			Errors: stats.Scrape.Errors,
+			Reused: stats.Scrape.Reused,
+			AvgMs:  newTimingsMillis(stats.Scrape.Avg()),
+		}
+		if s := stats.Searches; s != nil {
+			resp.Searches = &searchStatsResponse{
+				Searches:        s.Searches,
+				CacheHits:       s.CacheHits,
+				HitRatio:        s.HitRatio(),
+				Partial:         s.Partial,
+				Errors:          s.Errors,
+				Pages:           s.Pages,
+				PagesFailed:     s.PagesFailed,
+				PagesCached:     s.PagesCached,
+				PageSuccessRate: s.PageSuccessRate(),
+				StageAvgMs:      make(map[string]float64, len(s.Stages)),
+			}
+			for stage, d := range s.Stages {
+				resp.Searches.StageAvgMs[stage] = ms(d)
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// metricsHandler serves reg in the Prometheus text format.
+func metricsHandler(reg *metrics.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.WritePrometheus(w)
+	}
+}
+
+// Deep health statuses. "degraded" still answers 200 so load balancers keep
+// routing while an operator frees space; "failing" answers 503.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFailing  = "failing"
+)
+
+type cacheHealth struct {
+	Integrity string     `json:"integrity,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	SizeBytes int64      `json:"size_bytes"`
+	FreeBytes int64      `json:"free_bytes"` // -1 if unknown
+	Error     string     `json:"error,omitempty"`
+}
+
+// healthHandler answers liveness probes. With deep=true it also checks the
+// cache database: a failed integrity check fails the probe, and free disk
+// space below minFree degrades it before cache writes start failing.
+func healthHandler(eng engine.Service, minFree int64) http.HandlerFunc {
+	if minFree == 0 {
+		minFree = DefaultMinFreeBytes
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if deep := r.URL.Query().Get("deep"); deep != "true" && deep != "1" {
+			writeJSON(w, http.StatusOK, apiResponse{Status: healthOK})
+			return
+		}
+
+		h, err := eng.Health(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, apiResponse{
+				Status: healthFailing,
+				Cache:  &cacheHealth{FreeBytes: -1, Error: err.Error()},
+			})
+			return
+		}
+		ch := &cacheHealth{Integrity: h.Integrity, SizeBytes: h.SizeBytes, FreeBytes: h.FreeBytes}
+		if !h.CheckedAt.IsZero() {
+			ch.CheckedAt = &h.CheckedAt
+		}
+		switch {
+		case h.Integrity != "ok":
+			writeJSON(w, http.StatusServiceUnavailable, apiResponse{Status: healthFailing, Cache: ch})
+		case minFree > 0 && h.FreeBytes >= 0 && h.FreeBytes < minFree:
+			writeJSON(w, http.StatusOK, apiResponse{Status: healthDegraded, Cache: ch})
+		default:
+			writeJSON(w, http.StatusOK, apiResponse{Status: healthOK, Cache: ch})
+		}
+	}
 }
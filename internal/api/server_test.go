@@ -1,17 +1,33 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/engine/enginetest"
+	"github.com/user/glsi/pkg/metrics"
+	"github.com/user/glsi/pkg/scraper"
 )
 
 func TestHealthEndpoint(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rr := httptest.NewRecorder()
 
-	healthHandler(rr, req)
+	fake := &enginetest.Fake{}
+	healthHandler(fake, 0)(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
@@ -24,11 +40,70 @@ func TestHealthEndpoint(t *testing.T) {
 	if resp.Status != "ok" {
 		t.Fatalf("status = %q, want %q", resp.Status, "ok")
 	}
+	if resp.Cache != nil {
+		t.Errorf("shallow health reported cache %+v", resp.Cache)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("shallow health called the engine: %+v", fake.Calls())
+	}
+}
+
+func TestHealthEndpointDeep(t *testing.T) {
+	checked := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		health     cache.Health
+		err        error
+		minFree    int64
+		wantCode   int
+		wantStatus string
+	}{
+		{"ok", cache.Health{Integrity: "ok", CheckedAt: checked, SizeBytes: 4096, FreeBytes: 1 << 30}, nil, 0, http.StatusOK, "ok"},
+		{"low disk", cache.Health{Integrity: "ok", CheckedAt: checked, SizeBytes: 4096, FreeBytes: 1 << 20}, nil, 0, http.StatusOK, "degraded"},
+		{"low disk check disabled", cache.Health{Integrity: "ok", CheckedAt: checked, FreeBytes: 1 << 20}, nil, -1, http.StatusOK, "ok"},
+		{"free space unknown", cache.Health{Integrity: "ok", CheckedAt: checked, FreeBytes: -1}, nil, 0, http.StatusOK, "ok"},
+		{"corrupt", cache.Health{Integrity: "row 3 missing from index", CheckedAt: checked, FreeBytes: 1 << 30}, nil, 0, http.StatusServiceUnavailable, "failing"},
+		{"error", cache.Health{}, errors.New("database is locked"), 0, http.StatusServiceUnavailable, "failing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &enginetest.Fake{Store: tt.health, Err: tt.err}
+			req := httptest.NewRequest(http.MethodGet, "/health?deep=true", nil)
+			rr := httptest.NewRecorder()
+			healthHandler(fake, tt.minFree)(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", rr.Code, tt.wantCode)
+			}
+			var resp apiResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if resp.Cache == nil {
+				t.Fatal("cache missing from deep health response")
+			}
+			if tt.err != nil {
+				if resp.Cache.Error == "" {
+					t.Error("cache error not reported")
+				}
+				return
+			}
+			if resp.Cache.Integrity != tt.health.Integrity || resp.Cache.FreeBytes != tt.health.FreeBytes {
+				t.Errorf("cache = %+v, want %+v", resp.Cache, tt.health)
+			}
+			if resp.Cache.CheckedAt == nil || !resp.Cache.CheckedAt.Equal(checked) {
+				t.Errorf("checked_at = %v, want %v", resp.Cache.CheckedAt, checked)
+			}
+		})
+	}
 }
 
 func TestSearchHandlerMissingQuery(t *testing.T) {
 	// Create a handler with a nil engine — it should reject before calling engine.
-	handler := searchHandler(nil)
+	handler := searchHandler(nil, engine.CountLimits{})
 
 	req := httptest.NewRequest(http.MethodGet, "/search", nil)
 	rr := httptest.NewRecorder()
@@ -41,13 +116,19 @@ func TestSearchHandlerMissingQuery(t *testing.T) {
 
 	var resp apiResponse
 	json.NewDecoder(rr.Body).Decode(&resp)
-	if resp.Error == "" {
-		t.Fatal("expected error message for missing query")
+	if resp.Error == nil || resp.Error.Code != CodeBadRequest {
+		t.Fatalf("error = %+v, want code %q", resp.Error, CodeBadRequest)
+	}
+	if resp.Error.Details["param"] != "q" {
+		t.Errorf("details = %v, want param q", resp.Error.Details)
+	}
+	if resp.Error.RequestID == "" || resp.Error.RequestID != rr.Header().Get("X-Request-ID") {
+		t.Errorf("request_id = %q, header = %q", resp.Error.RequestID, rr.Header().Get("X-Request-ID"))
 	}
 }
 
 func TestSearchHandlerWrongMethod(t *testing.T) {
-	handler := searchHandler(nil)
+	handler := searchHandler(nil, engine.CountLimits{})
 
 	req := httptest.NewRequest(http.MethodPost, "/search?q=test", nil)
 	rr := httptest.NewRecorder()
@@ -71,3 +152,719 @@ func TestCacheHandlerWrongMethod(t *testing.T) {
 		t.Fatalf("status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
 	}
 }
+
+func TestRunUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "glsi.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, Config{SocketPath: sock, SocketMode: 0o600}, nil)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+
+	// Wait for the listener to come up.
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("http://glsi/health")
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /health over unix socket: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket file should be removed on shutdown, stat err = %v", err)
+	}
+}
+
+func TestListenRefusesNonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(Config{SocketPath: path}); err == nil {
+		t.Fatal("expected error when socket path is a regular file")
+	}
+}
+
+func TestListenExistingSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "glsi.sock")
+
+	// A live instance keeps its socket.
+	live, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if _, err := listen(Config{SocketPath: sock}); err == nil {
+		t.Fatal("expected error when another process serves the socket")
+	}
+
+	// A stale socket from a crashed process is replaced.
+	live.(*net.UnixListener).SetUnlinkOnClose(false)
+	live.Close()
+	ln, err := listen(Config{SocketPath: sock, SocketMode: 0o600})
+	if err != nil {
+		t.Fatalf("listen over stale socket: %v", err)
+	}
+	defer ln.Close()
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+}
+
+func TestRunWithListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	ready := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, Config{Listener: ln, Ready: func() { close(ready) }}, nil)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Ready was not called")
+	}
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestStatsHandlerWrongMethod(t *testing.T) {
+	handler := statsHandler(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/stats", nil)
+	rr := httptest.NewRecorder()
+
+	handler(rr, req)
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	reg := metrics.New("glsi")
+	reg.Inc(engine.MetricSearches, "kind", "search", "outcome", "hit")
+
+	rr := httptest.NewRecorder()
+	newMux(&enginetest.Fake{}, Config{Metrics: reg}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status = %d, type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), `glsi_searches_total{kind="search",outcome="hit"} 1`) {
+		t.Errorf("body = %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	newMux(&enginetest.Fake{}, Config{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("without a registry: status = %d, want 404", rr.Code)
+	}
+}
+
+func TestPinHandlerMissingQuery(t *testing.T) {
+	handler := pinHandler(nil)
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(method, "/cache/pin", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", method, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPut, "/cache/pin?q=x", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT: status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCacheSearchHandler(t *testing.T) {
+	fake := &enginetest.Fake{Matches: []cache.Match{
+		{Query: "go mutex", Snippet: "[Mutexes] guard state"},
+		{Query: "go channels", Snippet: "[Mutexes] are not channels", Stale: true},
+	}}
+	handler := cacheSearchHandler(fake)
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/cache/search?q=mutexes&limit=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rr.Code, rr.Body)
+	}
+	var resp cacheSearchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Matches) != 1 || resp.Matches[0].Query != "go mutex" {
+		t.Errorf("matches = %+v", resp.Matches)
+	}
+
+	for _, target := range []string{"/cache/search", "/cache/search?q=x&limit=0", "/cache/search?q=x&limit=500"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	cacheSearchHandler(enginetest.Failing(fmt.Errorf("engine: %w", cache.ErrSearchEncrypted)))(rr, httptest.NewRequest(http.MethodGet, "/cache/search?q=x", nil))
+	if rr.Code != http.StatusNotImplemented || !strings.Contains(rr.Body.String(), CodeNoCacheSearch) {
+		t.Errorf("encrypted cache: status = %d, body %s", rr.Code, rr.Body)
+	}
+}
+
+func TestTopHandler(t *testing.T) {
+	last := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake := &enginetest.Fake{Top: []cache.Entry{
+		{Meta: cache.Meta{Query: "go mutex"}, TotalHits: 9, Hits: 2, LastHitAt: last},
+		{Meta: cache.Meta{Query: "never read"}},
+	}}
+	rr := httptest.NewRecorder()
+	topHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/cache/top?limit=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rr.Code, rr.Body)
+	}
+	var resp topResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Top) != 2 || resp.Top[0].Hits != 9 || resp.Top[0].Recent != 2 || !resp.Top[0].LastHitAt.Equal(last) || resp.Top[1].LastHitAt != nil {
+		t.Errorf("top = %+v", resp.Top)
+	}
+
+	rr = httptest.NewRecorder()
+	topHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/cache/top?limit=abc", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHistoryHandlers(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake := &enginetest.Fake{
+		Results: map[string]engine.SearchResult{"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1}},
+		History: []cache.HistoryRecord{
+			{ID: 2, Time: at, Kind: "search", Query: "golang", Engine: "google", ResultCount: 1, FromCache: true},
+			{ID: 1, Time: at, Kind: "search", Query: "rust", Engine: "google", Error: "no results"},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	historyHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/history?q=go&limit=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rr.Code, rr.Body)
+	}
+	var resp historyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.History) != 1 || resp.History[0].ID != 2 || !resp.History[0].FromCache || !resp.History[0].Time.Equal(at) {
+		t.Errorf("history = %+v", resp.History)
+	}
+	rr = httptest.NewRecorder()
+	historyHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/history?limit=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	rr = httptest.NewRecorder()
+	replayHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/history/replay?id=2&force=1", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "A language.") {
+		t.Fatalf("replay: status = %d, body %s", rr.Code, rr.Body)
+	}
+	if calls := fake.Calls(); calls[len(calls)-1].Query != "golang" || !calls[len(calls)-1].Opts.Force {
+		t.Errorf("replayed call = %+v, want a forced search for golang", calls[len(calls)-1])
+	}
+
+	for _, tt := range []struct {
+		id   string
+		want int
+	}{{"7", http.StatusNotFound}, {"x", http.StatusBadRequest}, {"1", http.StatusNotFound}} {
+		rr = httptest.NewRecorder()
+		replayHandler(fake)(rr, httptest.NewRequest(http.MethodGet, "/history/replay?id="+tt.id, nil))
+		if rr.Code != tt.want {
+			t.Errorf("replay id=%s: status = %d, want %d", tt.id, rr.Code, tt.want)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	historyHandler(enginetest.Failing(engine.ErrNoHistory))(rr, httptest.NewRequest(http.MethodGet, "/history", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("no history: status = %d, want %d", rr.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestSearchHandlerWithFakeEngine(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"golang", http.StatusOK, ""},
+		{"rust", http.StatusNotFound, CodeNoResults},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q="+tt.query+"&count=3", nil))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.query, rr.Code, tt.wantStatus)
+			continue
+		}
+		var resp apiResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if tt.wantCode != "" && (resp.Error == nil || resp.Error.Code != tt.wantCode) {
+			t.Errorf("%s: error = %+v, want code %q", tt.query, resp.Error, tt.wantCode)
+		}
+	}
+	if calls := fake.Calls(); len(calls) != 2 || calls[0].Opts.Count != 3 {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestSearchHandlerCountLimits(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{Default: 3, Max: 8})
+
+	tests := []struct {
+		count      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"", http.StatusOK, 3},
+		{"8", http.StatusOK, 8},
+		{"9", http.StatusBadRequest, 0},
+		{"-1", http.StatusBadRequest, 0},
+		{"many", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&count="+tt.count, nil))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("count=%q: status = %d, want %d", tt.count, rr.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		calls := fake.Calls()
+		if got := calls[len(calls)-1].Opts.Count; got != tt.wantCount {
+			t.Errorf("count=%q: engine got Count %d, want %d", tt.count, got, tt.wantCount)
+		}
+	}
+}
+
+func TestSearchHandlerMaxSections(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&count=8&max_sections=3", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	if calls := fake.Calls(); calls[0].Opts.Count != 8 || calls[0].Opts.MaxSections != 3 {
+		t.Errorf("opts = %+v", calls[0].Opts)
+	}
+	for _, v := range []string{"-1", "all"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&max_sections="+v, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("max_sections=%s: status = %d, want 400", v, rr.Code)
+		}
+	}
+}
+
+func TestFetchHandler(t *testing.T) {
+	fake := &enginetest.Fake{Pages: map[string]engine.SearchResult{
+		"https://go.dev/doc": {Content: "## Docs — https://go.dev/doc\n\nDocumentation.", ResultCount: 1, Engine: "url"},
+	}}
+	handler := fetchHandler(fake)
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/fetch?url=https%3A%2F%2Fgo.dev%2Fdoc&force=1&output=markdown&content_format=plain", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	var resp apiResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Engine != "url" || !strings.Contains(resp.Content, "Documentation.") {
+		t.Errorf("resp = %+v", resp)
+	}
+	if calls := fake.Calls(); calls[0].Query != "https://go.dev/doc" || !calls[0].Opts.Force || calls[0].Opts.Output != "markdown" || calls[0].Opts.Format != "plain" {
+		t.Errorf("call = %+v", calls[0])
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/fetch?url=https%3A%2F%2Fgo.dev%2Fdoc&follow_links=2", nil))
+	if calls := fake.Calls(); rr.Code != http.StatusOK || calls[1].Opts.Depth != 1 || calls[1].Opts.FollowLinks != 2 {
+		t.Errorf("follow_links=2: status = %d, call = %+v", rr.Code, calls[1])
+	}
+
+	for _, target := range []string{"/fetch", "/fetch?url=go.dev", "/fetch?url=https%3A%2F%2Fgo.dev%2Fdoc&render=sometimes", "/fetch?url=https%3A%2F%2Fgo.dev%2Fdoc&content_format=yaml", "/fetch?url=https%3A%2F%2Fgo.dev%2Fdoc&follow_links=-1"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/fetch?url=https%3A%2F%2Fmissing.example%2F", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("failed page: status = %d, want 502", rr.Code)
+	}
+}
+
+func TestSearchHandlerTimeout(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1, Partial: true},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&timeout=8s&format=json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), `"partial":true`) {
+		t.Errorf("body = %s, want partial", rr.Body)
+	}
+	if calls := fake.Calls(); calls[0].Opts.Timeout != 8*time.Second {
+		t.Errorf("Opts.Timeout = %v, want 8s", calls[0].Opts.Timeout)
+	}
+	for _, v := range []string{"0s", "-1s", "1h", "soon"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&timeout="+v, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("timeout=%s: status = %d, want 400", v, rr.Code)
+		}
+	}
+}
+
+func TestSearchHandlerDepth(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	for v, want := range map[string]int{"": 0, "0": -1, "1": 1} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&depth="+v, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("depth=%s: status = %d: %s", v, rr.Code, rr.Body)
+		}
+		calls := fake.Calls()
+		if got := calls[len(calls)-1].Opts.Depth; got != want {
+			t.Errorf("depth=%s: Opts.Depth = %d, want %d", v, got, want)
+		}
+	}
+	for _, v := range []string{"2", "-1", "deep"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&depth="+v, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("depth=%s: status = %d, want 400", v, rr.Code)
+		}
+	}
+}
+
+func TestSearchHandlerSite(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"generics": {Content: "## Generics — https://go.dev/doc/tutorial/generics\n\nA tutorial.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=generics&site=go.dev", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	calls := fake.Calls()
+	if got := calls[len(calls)-1].Opts.Site; got != "go.dev" {
+		t.Errorf("Opts.Site = %q, want go.dev", got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=generics&site=ftp://go.dev", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("site=ftp://go.dev: status = %d, want 400", rr.Code)
+	}
+}
+
+func TestSearchHandlerEngine(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1, Engine: "duckduckgo"},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&engine=ddg", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	calls := fake.Calls()
+	if got := calls[len(calls)-1].Opts.Engine; got != "ddg" {
+		t.Errorf("Opts.Engine = %q, want ddg", got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&engine=bing", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("engine=bing: status = %d, want 400", rr.Code)
+	}
+}
+
+func TestSearchHandlerMaxAge(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&max_age=90m", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	calls := fake.Calls()
+	if got := calls[len(calls)-1].Opts.MaxAge; got != 90*time.Minute {
+		t.Errorf("Opts.MaxAge = %v, want 1h30m", got)
+	}
+
+	for _, v := range []string{"0s", "-1h", "soon"} {
+		rr = httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&max_age="+v, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("max_age=%s: status = %d, want 400", v, rr.Code)
+		}
+	}
+}
+
+func TestSearchHandlerFailed(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1, Failed: []engine.FailedPage{
+			{URL: "https://slow.example/", Reason: engine.FailTimeout, Err: errors.New("context deadline exceeded")},
+			{URL: "https://thin.example/", Reason: engine.FailThin},
+		}},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&format=json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	var resp struct {
+		Failed []map[string]string `json:"failed"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []map[string]string{
+		{"url": "https://slow.example/", "reason": "timeout", "error": "context deadline exceeded"},
+		{"url": "https://thin.example/", "reason": "too short"},
+	}
+	if !reflect.DeepEqual(resp.Failed, want) {
+		t.Errorf("failed = %v, want %v", resp.Failed, want)
+	}
+}
+
+func TestSearchHandlerDebugTimings(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1, FromCache: true,
+			Timings: engine.Timings{Cache: 2 * time.Millisecond, Total: 3500 * time.Microsecond}},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	for _, q := range []string{"", "&debug=1"} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang"+q, nil))
+		var resp struct {
+			Debug *struct {
+				Timings map[string]float64 `json:"timings"`
+				Pages   []json.RawMessage  `json:"pages"`
+			} `json:"debug"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if q == "" {
+			if resp.Debug != nil {
+				t.Errorf("debug without debug=1: %+v", resp.Debug)
+			}
+			continue
+		}
+		// Cache hits have no pages but still report timings.
+		if resp.Debug == nil || resp.Debug.Timings["cache_ms"] != 2 || resp.Debug.Timings["total_ms"] != 3.5 || resp.Debug.Pages != nil {
+			t.Errorf("debug = %+v", resp.Debug)
+		}
+		if _, ok := resp.Debug.Timings["rate_limit_wait_ms"]; !ok {
+			t.Errorf("timings = %v, want every phase", resp.Debug.Timings)
+		}
+	}
+}
+
+func TestSearchHandlerExportFormats(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go — https://go.dev\n\nA language.", ResultCount: 1, Sources: []engine.Source{
+			{Title: "Go, the language", URL: "https://go.dev", Excerpt: "A language.", TextLength: 11},
+		}},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	tests := []struct {
+		format      string
+		wantStatus  int
+		contentType string
+		body        string
+	}{
+		{"csv", http.StatusOK, "text/csv; charset=utf-8",
+			"url,title,date,excerpt,text_length\nhttps://go.dev,\"Go, the language\",,A language.,11\n"},
+		{"jsonl", http.StatusOK, "application/x-ndjson",
+			`{"url":"https://go.dev","title":"Go, the language","date":"","excerpt":"A language.","text_length":11}` + "\n"},
+		{"json", http.StatusOK, "application/json", ""},
+		{"xml", http.StatusBadRequest, "application/json", ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, "/search?q=golang&format="+tt.format, nil))
+		if rr.Code != tt.wantStatus {
+			t.Errorf("format=%s: status = %d, want %d", tt.format, rr.Code, tt.wantStatus)
+			continue
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("format=%s: Content-Type = %q, want %q", tt.format, got, tt.contentType)
+		}
+		if tt.body != "" && rr.Body.String() != tt.body {
+			t.Errorf("format=%s: body =\n%s\nwant\n%s", tt.format, rr.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                      mediaJSON,
+		"*/*":                                   mediaJSON,
+		"application/json":                      mediaJSON,
+		"text/markdown":                         mediaMarkdown,
+		"text/plain; charset=utf-8":             mediaText,
+		"text/*":                                mediaMarkdown,
+		"text/html,*/*;q=0.8":                   mediaJSON,
+		"application/json;q=0.5, text/markdown": mediaMarkdown,
+		"text/markdown;q=0, */*":                mediaJSON,
+		"text/*;q=0.9, text/plain":              mediaText,
+		"image/png":                             mediaJSON,
+	} {
+		if got := negotiate(accept); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestSearchHandlerAccept(t *testing.T) {
+	const content = "## Go — https://go.dev\n\nA language."
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: content, ResultCount: 1},
+	}}
+	handler := searchHandler(fake, engine.CountLimits{})
+
+	tests := []struct {
+		accept      string
+		query       string
+		contentType string
+		wantOutput  string
+	}{
+		{"text/markdown", "", "text/markdown; charset=utf-8", scraper.OutputMarkdown},
+		{"text/markdown", "&output=text", "text/markdown; charset=utf-8", scraper.OutputText},
+		{"text/plain", "", "text/plain; charset=utf-8", ""},
+		{"application/json", "", "application/json", ""},
+		{"text/markdown", "&format=json", "application/json", scraper.OutputMarkdown},
+		{"text/markdown", "&format=csv", "text/csv; charset=utf-8", scraper.OutputMarkdown},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=golang"+tt.query, nil)
+		req.Header.Set("Accept", tt.accept)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		name := tt.accept + tt.query
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", name, rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", name, got, tt.contentType)
+		}
+		if rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: Vary = %q, want Accept", name, rr.Header().Get("Vary"))
+		}
+		if strings.HasPrefix(tt.contentType, "text/markdown") || strings.HasPrefix(tt.contentType, "text/plain") {
+			if rr.Body.String() != content {
+				t.Errorf("%s: body = %q, want the raw content", name, rr.Body.String())
+			}
+		}
+		calls := fake.Calls()
+		if got := calls[len(calls)-1].Opts.Output; got != tt.wantOutput {
+			t.Errorf("%s: output = %q, want %q", name, got, tt.wantOutput)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package api
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix binds a unix socket and then applies mode. Platforms without a
+// umask have no way to set it at creation time.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package api
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnix binds a unix socket with mode applied atomically: the umask is
+// narrowed for the duration of the bind, so the socket never exists with
+// looser permissions. The umask is process-wide; this runs once at startup.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	old := syscall.Umask(0o777 &^ int(mode.Perm()))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/user/glsi/pkg/engine"
+)
+
+type streamResult struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	Sponsored bool   `json:"sponsored,omitempty"`
+}
+
+// streamResults is the data of a "results" event.
+type streamResults struct {
+	Results []streamResult `json:"results"`
+}
+
+// streamPage is the data of a "page" event.
+type streamPage struct {
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Words    int    `json:"words"`
+	Cached   bool   `json:"cached,omitempty"`  // reused from the page cache, not fetched
+	Content  string `json:"content,omitempty"` // the page's Markdown section, empty if unusable
+}
+
+// sseWriter writes server-sent events, sending the response header with
+// the first one so errors before it can still get their own status.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func (s *sseWriter) event(name string, v any) {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+	}
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data)
+	s.flusher.Flush()
+}
+
+// streamHandler runs a search like /search, sending its progress as
+// server-sent events: "results" with the links about to be scraped, a
+// "page" per page as it finishes, then "done" with the JSON response or
+// "error" with the error body. Cache hits send only "done". Errors before
+// the first event are plain JSON errors with their usual status.
+func streamHandler(eng engine.Service, counts engine.CountLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, http.StatusInternalServerError, CodeInternal, "streaming unsupported", nil)
+			return
+		}
+		q, opts, ok := searchOptions(w, r, counts, "")
+		if !ok {
+			return
+		}
+
+		sse := &sseWriter{w: w, flusher: flusher}
+		result, err := eng.SearchStream(r.Context(), q, opts, func(u engine.StreamUpdate) {
+			switch u.Kind {
+			case engine.StreamResults:
+				data := streamResults{Results: make([]streamResult, len(u.Results))}
+				for i, res := range u.Results {
+					data.Results[i] = streamResult{URL: res.URL, Title: res.Title, Sponsored: res.Sponsored}
+				}
+				sse.event("results", data)
+			case engine.StreamPage:
+				p := u.Page
+				data := streamPage{URL: p.URL, FinalURL: p.FinalURL, Title: p.Title, Status: p.Status, Words: p.Words, Cached: p.Cached, Content: p.Content}
+				if p.Err != nil {
+					data.Error = p.Err.Error()
+				}
+				sse.event("page", data)
+			}
+		})
+		if err != nil {
+			if !sse.started {
+				writeEngineError(w, r, err)
+				return
+			}
+			_, body := engineError(w, r, err)
+			sse.event("error", apiResponse{Error: body})
+			return
+		}
+		sse.event("done", newAPIResponse(r, result))
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/engine/enginetest"
+)
+
+func TestStreamHandler(t *testing.T) {
+	fake := &enginetest.Fake{Results: map[string]engine.SearchResult{
+		"golang": {
+			Content:     "## Go — https://go.dev\n\nA language.",
+			ResultCount: 1,
+			Sources:     []engine.Source{{Title: "Go", URL: "https://go.dev"}},
+		},
+	}}
+	handler := streamHandler(fake, engine.CountLimits{})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search/stream?q=golang&max_sections=2", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, type %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body)
+	}
+	body := rr.Body.String()
+	var events []string
+	for _, line := range strings.Split(body, "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, name)
+		}
+	}
+	if strings.Join(events, " ") != "results page done" {
+		t.Errorf("events = %v, want results page done", events)
+	}
+	if !strings.Contains(body, `data: {"results":[{"url":"https://go.dev","title":"Go"}]}`) || !strings.Contains(body, `"content":"## Go — https://go.dev\n\nA language."`) {
+		t.Errorf("body = %s", body)
+	}
+	if calls := fake.Calls(); calls[0].Method != "SearchStream" || calls[0].Opts.MaxSections != 2 {
+		t.Errorf("call = %+v", calls[0])
+	}
+
+	// Errors before the first event keep their status.
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search/stream?q=rust", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown query: status = %d, want 404", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/search/stream", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("missing q: status = %d, want 400", rr.Code)
+	}
+}
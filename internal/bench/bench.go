@@ -0,0 +1,129 @@
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result holds the averaged metrics for one benchmark across all runs found
+// in a `go test -bench` output.
+type Result struct {
+	Name        string
+	NsPerOp     float64
+	AllocsPerOp float64
+	BytesPerOp  float64
+	runs        int
+}
+
+// Delta describes the change of a single benchmark between two runs.
+type Delta struct {
+	Name      string
+	Old, New  Result
+	NsPct     float64 // percentage change in ns/op (positive = slower)
+	AllocsPct float64 // percentage change in allocs/op (positive = more)
+}
+
+// Parse reads `go test -bench` output and returns results keyed by benchmark
+// name. The GOMAXPROCS suffix (e.g. "-8") is stripped so runs from machines
+// with different core counts can be compared. Repeated runs (-count=N) are
+// averaged.
+func Parse(r io.Reader) (map[string]Result, error) {
+	results := make(map[string]Result)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// fields[1] is the iteration count; skip lines where it is not one
+		// (e.g. "BenchmarkFoo ... FAIL").
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		name := trimProcs(fields[0])
+		cur := results[name]
+		cur.Name = name
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bench: parse %s value %q: %w", name, fields[i], err)
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				cur.NsPerOp += v
+			case "allocs/op":
+				cur.AllocsPerOp += v
+			case "B/op":
+				cur.BytesPerOp += v
+			}
+		}
+		cur.runs++
+		results[name] = cur
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("bench: read: %w", err)
+	}
+
+	for name, r := range results {
+		n := float64(r.runs)
+		r.NsPerOp /= n
+		r.AllocsPerOp /= n
+		r.BytesPerOp /= n
+		results[name] = r
+	}
+	return results, nil
+}
+
+// Compare returns deltas for every benchmark present in both base and head,
+// sorted by name. Benchmarks present in only one run are ignored.
+func Compare(base, head map[string]Result) []Delta {
+	var deltas []Delta
+	for name, o := range base {
+		n, ok := head[name]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, Delta{
+			Name:      name,
+			Old:       o,
+			New:       n,
+			NsPct:     pctChange(o.NsPerOp, n.NsPerOp),
+			AllocsPct: pctChange(o.AllocsPerOp, n.AllocsPerOp),
+		})
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	return deltas
+}
+
+// Regressed reports whether ns/op or allocs/op grew by more than threshold
+// percent.
+func (d Delta) Regressed(threshold float64) bool {
+	return d.NsPct > threshold || d.AllocsPct > threshold
+}
+
+// trimProcs strips the trailing "-N" GOMAXPROCS suffix from a benchmark name.
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+func pctChange(before, after float64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (after - before) / before * 100
+}
@@ -0,0 +1,70 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+)
+
+const oldOutput = `goos: linux
+goarch: amd64
+pkg: github.com/user/glsi/pkg/search
+BenchmarkParseGoogle-8   	     100	  1000000 ns/op	  61.10 MB/s	  500000 B/op	    1000 allocs/op
+BenchmarkParseGoogle-8   	     100	  3000000 ns/op	  61.10 MB/s	  500000 B/op	    1000 allocs/op
+BenchmarkConsolidate-8   	    5000	    20000 ns/op	  200000 B/op	      10 allocs/op
+BenchmarkRemoved-8       	    5000	    20000 ns/op
+PASS
+`
+
+const newOutput = `BenchmarkParseGoogle-16  	     100	  2200000 ns/op	  61.10 MB/s	  500000 B/op	    1000 allocs/op
+BenchmarkConsolidate-16  	    5000	    20000 ns/op	  200000 B/op	      20 allocs/op
+BenchmarkAdded-16        	    5000	    20000 ns/op
+`
+
+func TestParse(t *testing.T) {
+	results, err := Parse(strings.NewReader(oldOutput))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	g := results["BenchmarkParseGoogle"]
+	if g.NsPerOp != 2000000 {
+		t.Errorf("ParseGoogle ns/op = %v, want 2000000 (average of runs)", g.NsPerOp)
+	}
+	if g.AllocsPerOp != 1000 {
+		t.Errorf("ParseGoogle allocs/op = %v, want 1000", g.AllocsPerOp)
+	}
+	if g.BytesPerOp != 500000 {
+		t.Errorf("ParseGoogle B/op = %v, want 500000", g.BytesPerOp)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old, _ := Parse(strings.NewReader(oldOutput))
+	new, _ := Parse(strings.NewReader(newOutput))
+
+	deltas := Compare(old, new)
+	if len(deltas) != 2 {
+		t.Fatalf("got %d deltas, want 2 (only common benchmarks)", len(deltas))
+	}
+
+	// Sorted by name: Consolidate, ParseGoogle.
+	c, g := deltas[0], deltas[1]
+	if c.Name != "BenchmarkConsolidate" || g.Name != "BenchmarkParseGoogle" {
+		t.Fatalf("unexpected order: %q, %q", c.Name, g.Name)
+	}
+	if c.AllocsPct != 100 {
+		t.Errorf("Consolidate allocs change = %v%%, want 100%%", c.AllocsPct)
+	}
+	if !c.Regressed(10) {
+		t.Error("Consolidate should be flagged as regressed at 10% threshold")
+	}
+	if g.NsPct != 10 {
+		t.Errorf("ParseGoogle ns change = %v%%, want 10%%", g.NsPct)
+	}
+	if g.Regressed(10) {
+		t.Error("ParseGoogle at exactly the threshold should not be flagged")
+	}
+}
@@ -3,16 +3,69 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	gomcp "github.com/modelcontextprotocol/go-sdk/mcp"
-	"github.com/user/glsi/internal/engine"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
 )
 
 // webSearchInput defines the parameters for the web_search tool.
 type webSearchInput struct {
-	Query string `json:"query" jsonschema:"description=The search query string"`
-	Count int    `json:"count" jsonschema:"description=Number of results to scrape (default 5)"`
-	Force bool   `json:"force" jsonschema:"description=Bypass cache and force a fresh scrape"`
+	Query  string `json:"query" jsonschema:"description=The search query string"`
+	Count  int    `json:"count" jsonschema:"description=Number of results to scrape (0 uses the server default)"`
+	Force  bool   `json:"force" jsonschema:"description=Bypass cache and force a fresh scrape"`
+	MaxAge int    `json:"max_age_seconds,omitempty" jsonschema:"description=Accept a cached result only if it is younger than this many seconds and otherwise search again (0 accepts any cached result)"`
+
+	Engine    string `json:"engine,omitempty" jsonschema:"description=Search engine: google or duckduckgo (empty uses the server default)"`
+	Extractor string `json:"extractor,omitempty" jsonschema:"description=Content extraction backend: readability (default) or density or auto"`
+	Render    string `json:"render,omitempty" jsonschema:"description=Headless browser rendering for JS-heavy pages: never (default) or auto or always"`
+	Output    string `json:"output,omitempty" jsonschema:"description=Page text format: text (default) or markdown to keep headings and lists and links and code blocks"`
+	Format    string `json:"format,omitempty" jsonschema:"description=Layout of the consolidated text: markdown (default) with a heading per page or plain without markup or json with one object per page"`
+
+	MaxPerHost    int `json:"max_per_host,omitempty" jsonschema:"description=Maximum number of results from any one site (0 uses the server default)"`
+	MaxSections   int `json:"max_sections,omitempty" jsonschema:"description=Maximum number of sections in the output keeping the best pages; count more than this scrapes spares (0 uses the server default)"`
+	Depth         int `json:"depth,omitempty" jsonschema:"description=1 also scrapes the most relevant same-site links on result pages so thin landing pages lead to the real content (0 uses the server default)"`
+	ScrapeTimeout int `json:"scrape_timeout_seconds,omitempty" jsonschema:"description=Per-page fetch timeout in seconds for slow sites (0 uses the server default and max 60)"`
+	Timeout       int `json:"timeout_seconds,omitempty" jsonschema:"description=Time limit in seconds for the whole call; near it the pages scraped so far are returned marked partial (0 means no limit and max 300)"`
+
+	Site string `json:"site,omitempty" jsonschema:"description=Search this one site instead of the web by scraping the pages in its sitemap whose URLs match the query; a host such as go.dev or a URL such as https://example.com/docs/ to stay under a path"`
+
+	Cite      bool `json:"cite,omitempty" jsonschema:"description=End each paragraph with a source marker such as [S2] where 2 is the source's position in sources"`
+	Summarize bool `json:"summarize,omitempty" jsonschema:"description=Return a server-side summary instead of the full consolidated text (requires a configured summarizer)"`
+	Debug     bool `json:"debug,omitempty" jsonschema:"description=Add a timings breakdown of where the call spent its time in milliseconds"`
+}
+
+// fetchURLInput defines the parameters for the fetch_url tool.
+type fetchURLInput struct {
+	URL    string `json:"url" jsonschema:"description=Absolute http or https URL of the page to read"`
+	Force  bool   `json:"force,omitempty" jsonschema:"description=Bypass cache and fetch the page again"`
+	MaxAge int    `json:"max_age_seconds,omitempty" jsonschema:"description=Accept a cached copy only if it is younger than this many seconds and otherwise fetch again (0 accepts any cached copy)"`
+
+	Extractor     string `json:"extractor,omitempty" jsonschema:"description=Content extraction backend: readability (default) or density or auto"`
+	Render        string `json:"render,omitempty" jsonschema:"description=Headless browser rendering for JS-heavy pages: never (default) or auto or always"`
+	Output        string `json:"output,omitempty" jsonschema:"description=Page text format: text (default) or markdown to keep headings and lists and links and code blocks"`
+	Format        string `json:"format,omitempty" jsonschema:"description=Layout of the page text: markdown (default) with a heading or plain without markup or json"`
+	ScrapeTimeout int    `json:"scrape_timeout_seconds,omitempty" jsonschema:"description=Fetch timeout in seconds for slow sites (0 uses the server default and max 60)"`
+	FollowLinks   int    `json:"follow_links,omitempty" jsonschema:"description=Also read up to this many of the page's same-site links that best match its title, for landing pages that only link to the content (0 follows none; capped by the server's follow budget)"`
+
+	Cite      bool `json:"cite,omitempty" jsonschema:"description=End each paragraph with a source marker such as [S1]"`
+	Summarize bool `json:"summarize,omitempty" jsonschema:"description=Return a server-side summary of the page instead of its full text (requires a configured summarizer)"`
+	Debug     bool `json:"debug,omitempty" jsonschema:"description=Add a timings breakdown of where the call spent its time in milliseconds"`
+}
+
+// deepResearchInput defines the parameters for the deep_research tool.
+type deepResearchInput struct {
+	Query    string `json:"query" jsonschema:"description=The research question or topic"`
+	Depth    int    `json:"depth,omitempty" jsonschema:"description=Follow-up rounds after the first search (0 uses the default of 2 and max 4)"`
+	MaxPages int    `json:"max_pages,omitempty" jsonschema:"description=Pages to read across all rounds (0 uses the default of 20 and max 50)"`
+	Breadth  int    `json:"breadth,omitempty" jsonschema:"description=Follow-up searches and links per round (0 uses the default of 3 and max 5)"`
+	Count    int    `json:"count,omitempty" jsonschema:"description=Results to scrape for the first search (0 uses the server default)"`
+	Force    bool   `json:"force,omitempty" jsonschema:"description=Bypass cache and force fresh scrapes"`
+	Engine   string `json:"engine,omitempty" jsonschema:"description=Search engine: google or duckduckgo (empty uses the server default)"`
 }
 
 // clearCacheInput defines the parameters for the clear_cache tool.
@@ -20,12 +73,277 @@ type clearCacheInput struct {
 	Query string `json:"query" jsonschema:"description=Specific query to evict from cache. If omitted all entries are flushed."`
 }
 
+// pinInput defines the parameters for the pin_cache and unpin_cache tools.
+type pinInput struct {
+	Query string `json:"query" jsonschema:"description=The search query whose cached result to pin or unpin"`
+}
+
+// searchCacheInput defines the parameters for the search_cache tool.
+type searchCacheInput struct {
+	Query string `json:"query" jsonschema:"description=Words to look for in already-cached results; every word must match"`
+	Limit int    `json:"limit,omitempty" jsonschema:"description=Maximum number of matches (default 10 and max 50)"`
+}
+
+// searchHistoryInput defines the parameters for the search_history tool.
+type searchHistoryInput struct {
+	Query  string `json:"query,omitempty" jsonschema:"description=Only list past queries or URLs containing this text, ignoring case (default all)"`
+	Limit  int    `json:"limit,omitempty" jsonschema:"description=Maximum number of entries, newest first (default 20 and max 500)"`
+	Replay int64  `json:"replay,omitempty" jsonschema:"description=ID of a listed entry to run again instead of listing; returns its result like web_search or fetch_url"`
+	Force  bool   `json:"force,omitempty" jsonschema:"description=With replay: bypass the cache and fetch fresh results"`
+}
+
+// listPinnedInput defines the (empty) parameters for the list_pinned tool.
+type listPinnedInput struct{}
+
+// statsInput defines the (empty) parameters for the stats tool.
+type statsInput struct{}
+
+// selfCheckInput defines the parameters for the self_check tool.
+type selfCheckInput struct {
+	Engine string `json:"engine,omitempty" jsonschema:"description=Engine to check: google or duckduckgo (default both)"`
+	Probes int    `json:"probes,omitempty" jsonschema:"description=Canary searches per engine (default 2 and max 3)"`
+}
+
 // empty output — we return everything via CallToolResult text content.
 type emptyOutput struct{}
 
+// webSearchOutput is the structured result of the web_search tool; the
+// consolidated text itself is returned as text content.
+type webSearchOutput struct {
+	ResultCount int            `json:"result_count,omitempty"`
+	FromCache   bool           `json:"from_cache,omitempty"`
+	Stale       bool           `json:"stale,omitempty"`     // past its TTL; a fresh result is being fetched
+	Partial     bool           `json:"partial,omitempty"`   // only the pages scraped before the deadline
+	Engine      string         `json:"engine,omitempty"`    // google, duckduckgo, site or url
+	CachedAt    string         `json:"cached_at,omitempty"` // RFC 3339 time a cached result was stored
+	Summarized  bool           `json:"summarized,omitempty"`
+	Intent      string         `json:"intent,omitempty"` // navigational, informational, news, code, academic or local
+	Sources     []sourceOutput `json:"sources,omitempty"`
+	Failed      []failedOutput `json:"failed,omitempty"`  // pages that could not be used, with why
+	Timings     *timingsOutput `json:"timings,omitempty"` // only with debug
+}
+
+// failedOutput is a page of a fresh search left out of the content.
+type failedOutput struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`          // timeout, robots.txt, HTTP 404, too short, ...
+	Error  string `json:"error,omitempty"` // the scrape error, if any
+}
+
+// deepResearchOutput is the structured result of the deep_research tool;
+// the report itself is returned as text content.
+type deepResearchOutput struct {
+	Pages   int                   `json:"pages"` // read across all rounds
+	Rounds  []researchRoundOutput `json:"rounds"`
+	Sources []sourceOutput        `json:"sources,omitempty"`
+}
+
+type researchRoundOutput struct {
+	Steps []researchStepOutput `json:"steps"`
+}
+
+type researchStepOutput struct {
+	Query       string `json:"query,omitempty"` // a search
+	URL         string `json:"url,omitempty"`   // a followed link
+	ResultCount int    `json:"result_count,omitempty"`
+	FromCache   bool   `json:"from_cache,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// searchHistoryOutput is the structured result of the search_history
+// tool: the entries listed, or the result of the one replayed.
+type searchHistoryOutput struct {
+	History []historyOutput  `json:"history,omitempty"`
+	Replay  *webSearchOutput `json:"replay,omitempty"`
+}
+
+type historyOutput struct {
+	ID          int64  `json:"id"`
+	Time        string `json:"time"` // RFC 3339
+	Kind        string `json:"kind"` // search or fetch
+	Query       string `json:"query"`
+	Site        string `json:"site,omitempty"`
+	Engine      string `json:"engine,omitempty"`
+	ResultCount int    `json:"result_count"`
+	FromCache   bool   `json:"from_cache,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func newDeepResearchOutput(report engine.ResearchReport) deepResearchOutput {
+	out := deepResearchOutput{Pages: report.Pages}
+	for _, r := range report.Rounds {
+		var ro researchRoundOutput
+		for _, s := range r.Steps {
+			so := researchStepOutput{Query: s.Query, URL: s.URL, ResultCount: s.Result.ResultCount, FromCache: s.Result.FromCache}
+			if s.Err != nil {
+				so.Error = s.Err.Error()
+			}
+			ro.Steps = append(ro.Steps, so)
+		}
+		out.Rounds = append(out.Rounds, ro)
+	}
+	for _, src := range report.Sources {
+		out.Sources = append(out.Sources, newSourceOutput(src))
+	}
+	return out
+}
+
+// timingsOutput is engine.Timings in fractional milliseconds.
+type timingsOutput struct {
+	SERP          float64 `json:"serp_ms"`
+	RateLimitWait float64 `json:"rate_limit_wait_ms"`
+	Scrape        float64 `json:"scrape_ms"`
+	Consolidate   float64 `json:"consolidate_ms"`
+	Cache         float64 `json:"cache_ms"`
+	Total         float64 `json:"total_ms"`
+}
+
+func newTimingsOutput(t engine.Timings) *timingsOutput {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return &timingsOutput{
+		SERP:          ms(t.SERP),
+		RateLimitWait: ms(t.RateLimitWait),
+		Scrape:        ms(t.Scrape),
+		Consolidate:   ms(t.Consolidate),
+		Cache:         ms(t.Cache),
+		Total:         ms(t.Total),
+	}
+}
+
+type sourceOutput struct {
+	Title       string           `json:"title,omitempty"`
+	URL         string           `json:"url"`
+	Language    string           `json:"language,omitempty"`
+	Published   *publishedOutput `json:"published,omitempty"`
+	Author      string           `json:"author,omitempty"`
+	SiteName    string           `json:"site_name,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Sponsored   bool             `json:"sponsored,omitempty"`
+	Via         string           `json:"retrieved_via,omitempty"` // geo-proxy or archive
+}
+
+type publishedOutput struct {
+	Date       string `json:"date"`       // YYYY-MM-DD
+	Source     string `json:"source"`     // metadata, text, url or last-modified
+	Confidence string `json:"confidence"` // high, medium or low
+}
+
+func newWebSearchOutput(result engine.SearchResult) webSearchOutput {
+	out := webSearchOutput{ResultCount: result.ResultCount, FromCache: result.FromCache, Stale: result.Stale, Partial: result.Partial, Engine: result.Engine, Summarized: result.Summarized, Intent: string(result.Intent)}
+	if !result.CachedAt.IsZero() {
+		out.CachedAt = result.CachedAt.Format(time.RFC3339)
+	}
+	for _, src := range result.Sources {
+		out.Sources = append(out.Sources, newSourceOutput(src))
+	}
+	for _, f := range result.Failed {
+		fo := failedOutput{URL: f.URL, Reason: f.Reason}
+		if f.Err != nil {
+			fo.Error = f.Err.Error()
+		}
+		out.Failed = append(out.Failed, fo)
+	}
+	return out
+}
+
+func newSourceOutput(src engine.Source) sourceOutput {
+	so := sourceOutput{
+		Title:       src.Title,
+		URL:         src.URL,
+		Language:    src.Language,
+		Author:      src.Author,
+		SiteName:    src.SiteName,
+		Description: src.Description,
+		Sponsored:   src.Sponsored,
+		Via:         src.Via,
+	}
+	if d := src.Published; !d.IsZero() {
+		so.Published = &publishedOutput{Date: d.Time.Format("2006-01-02"), Source: d.Source, Confidence: d.Confidence}
+	}
+	return so
+}
+
+// notifyProgress sends a search's progress as MCP progress notifications
+// for token: progress counts the pages finished against the number of
+// results found, with the page's URL as the message. Pages reached by
+// following links can take progress past the total, which is then left
+// out. Notifications are best effort.
+func notifyProgress(ctx context.Context, req *gomcp.CallToolRequest, token any) func(engine.StreamUpdate) {
+	var total, done int
+	return func(u engine.StreamUpdate) {
+		params := &gomcp.ProgressNotificationParams{ProgressToken: token}
+		switch u.Kind {
+		case engine.StreamResults:
+			total = len(u.Results)
+			params.Message = fmt.Sprintf("found %d results", total)
+		case engine.StreamPage:
+			done++
+			params.Message = "scraped " + u.Page.URL
+			if u.Page.Err != nil {
+				params.Message = "failed " + u.Page.URL
+			}
+		default:
+			return
+		}
+		params.Progress = float64(done)
+		if done <= total {
+			params.Total = float64(total)
+		}
+		req.Session.NotifyProgress(ctx, params)
+	}
+}
+
+// formatStats renders engine statistics as text for the stats tool.
+func formatStats(stats engine.Stats) string {
+	var b strings.Builder
+	if s := stats.Searches; s != nil {
+		fmt.Fprintf(&b, "searches: %d (cache hit ratio %.2f, partial %d, errors %d)\n", s.Searches, s.HitRatio(), s.Partial, s.Errors)
+		fmt.Fprintf(&b, "pages: %d (success rate %.2f, failed %d, from page cache %d)\n", s.Pages, s.PageSuccessRate(), s.PagesFailed, s.PagesCached)
+		stages := make([]string, 0, len(s.Stages))
+		for stage := range s.Stages {
+			stages = append(stages, stage)
+		}
+		sort.Strings(stages)
+		for _, stage := range stages {
+			fmt.Fprintf(&b, "  avg %s: %v\n", stage, s.Stages[stage].Round(time.Millisecond))
+		}
+	}
+	names := make([]string, 0, len(stats.Engines))
+	for name := range stats.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := stats.Engines[name]
+		fmt.Fprintf(&b, "%s: %d requests, %d blocked, %d empty, %d errors (block rate %.2f)\n", name, e.Requests, e.Blocked, e.Empty, e.Errors, e.BlockRate())
+	}
+	fmt.Fprintf(&b, "page fetches: %d, %d errors, avg %v\n", stats.Scrape.Pages, stats.Scrape.Errors, stats.Scrape.Avg().Total.Round(time.Millisecond))
+	circuits := make([]string, 0, len(stats.OpenCircuits))
+	for key := range stats.OpenCircuits {
+		circuits = append(circuits, key)
+	}
+	sort.Strings(circuits)
+	for _, key := range circuits {
+		fmt.Fprintf(&b, "circuit open: %s, retried in %v\n", key, time.Until(stats.OpenCircuits[key]).Round(time.Second))
+	}
+	return b.String()
+}
+
+// Config holds MCP server configuration.
+type Config struct {
+	// Counts overrides the engine's default and maximum result count for
+	// web_search calls.
+	Counts engine.CountLimits
+}
+
 // Serve starts the MCP stdio server, registering tools that delegate to the
 // provided engine. It blocks until the client disconnects.
-func Serve(eng *engine.Engine) error {
+func Serve(eng engine.Service) error {
+	return Run(Config{}, eng)
+}
+
+// Run is like Serve but takes a Config.
+func Run(cfg Config, eng engine.Service) error {
 	server := gomcp.NewServer(
 		&gomcp.Implementation{
 			Name:    "glsi",
@@ -38,28 +356,356 @@ func Serve(eng *engine.Engine) error {
 	gomcp.AddTool(server, &gomcp.Tool{
 		Name:        "web_search",
 		Description: "Search the web for a query, scrape the top result pages, and return consolidated text. Results are cached for 24 hours.",
-	}, func(ctx context.Context, req *gomcp.CallToolRequest, input webSearchInput) (*gomcp.CallToolResult, emptyOutput, error) {
-		count := input.Count
-		if count <= 0 {
-			count = 5
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input webSearchInput) (*gomcp.CallToolResult, webSearchOutput, error) {
+		count, err := cfg.Counts.Resolve(input.Count)
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: err.Error()},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if input.MaxAge < 0 {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid max_age_seconds %d", input.MaxAge)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !search.ValidEngine(input.Engine) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown search engine %q", input.Engine)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidExtractor(input.Extractor) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown extractor %q", input.Extractor)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidOutput(input.Output) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown output format %q", input.Output)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !engine.ValidContentFormat(input.Format) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown format %q", input.Format)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidRenderMode(input.Render) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown render mode %q", input.Render)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if input.Site != "" {
+			if err := scraper.ValidSite(input.Site); err != nil {
+				return &gomcp.CallToolResult{
+					IsError: true,
+					Content: []gomcp.Content{
+						&gomcp.TextContent{Text: fmt.Sprintf("invalid site %q", input.Site)},
+					},
+				}, webSearchOutput{}, nil
+			}
+		}
+
+		scrapeTimeout := time.Duration(input.ScrapeTimeout) * time.Second
+		if scrapeTimeout < 0 || scrapeTimeout > engine.MaxScrapeTimeout {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid scrape_timeout_seconds %d, want 0 to %d", input.ScrapeTimeout, int(engine.MaxScrapeTimeout.Seconds()))},
+				},
+			}, webSearchOutput{}, nil
 		}
 
-		result, err := eng.Search(ctx, input.Query, count, input.Force)
+		timeout := time.Duration(input.Timeout) * time.Second
+		if timeout < 0 || timeout > engine.MaxTimeout {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid timeout_seconds %d, want 0 to %d", input.Timeout, int(engine.MaxTimeout.Seconds()))},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		opts := engine.SearchOptions{
+			Count:      count,
+			Force:      input.Force,
+			MaxAge:     time.Duration(input.MaxAge) * time.Second,
+			Engine:     input.Engine,
+			Extractor:  input.Extractor,
+			Render:     input.Render,
+			Output:     input.Output,
+			Format:     input.Format,
+			MaxPerHost: input.MaxPerHost,
+
+			MaxSections:   input.MaxSections,
+			Depth:         input.Depth,
+			Site:          input.Site,
+			ScrapeTimeout: scrapeTimeout,
+			Timeout:       timeout,
+
+			Cite:      input.Cite,
+			Summarize: input.Summarize,
+		}
+		var result engine.SearchResult
+		if token := req.Params.GetProgressToken(); token != nil {
+			result, err = eng.SearchStream(ctx, input.Query, opts, notifyProgress(ctx, req, token))
+		} else {
+			result, err = eng.SearchWithOptions(ctx, input.Query, opts)
+		}
 		if err != nil {
 			return &gomcp.CallToolResult{
 				IsError: true,
 				Content: []gomcp.Content{
 					&gomcp.TextContent{Text: fmt.Sprintf("search failed: %v", err)},
 				},
-			}, emptyOutput{}, nil
+			}, webSearchOutput{}, nil
 		}
 
-		meta := fmt.Sprintf("[results: %d, from_cache: %v]\n\n", result.ResultCount, result.FromCache)
+		meta := fmt.Sprintf("[results: %d, from_cache: %v", result.ResultCount, result.FromCache)
+		if result.Stale {
+			meta += ", stale: true"
+		}
+		if result.Partial {
+			meta += ", partial: true"
+		}
+		if result.Summarized {
+			meta += ", summarized: true"
+		}
+		if len(result.Failed) > 0 {
+			meta += fmt.Sprintf(", failed: %d", len(result.Failed))
+		}
+		meta += "]\n"
+		for _, f := range result.Failed {
+			meta += fmt.Sprintf("[failed: %s (%s)]\n", f.URL, f.Reason)
+		}
+		meta += "\n"
+		out := newWebSearchOutput(result)
+		if input.Debug {
+			out.Timings = newTimingsOutput(result.Timings)
+		}
 		return &gomcp.CallToolResult{
 			Content: []gomcp.Content{
 				&gomcp.TextContent{Text: meta + result.Content},
 			},
-		}, emptyOutput{}, nil
+		}, out, nil
+	})
+
+	// Register fetch_url tool.
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "fetch_url",
+		Description: "Read one web page by URL, such as a link from earlier results, and return its extracted text. Pages are cached like searches.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input fetchURLInput) (*gomcp.CallToolResult, webSearchOutput, error) {
+		if input.MaxAge < 0 {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid max_age_seconds %d", input.MaxAge)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidExtractor(input.Extractor) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown extractor %q", input.Extractor)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidOutput(input.Output) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown output format %q", input.Output)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !engine.ValidContentFormat(input.Format) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown format %q", input.Format)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if !scraper.ValidRenderMode(input.Render) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown render mode %q", input.Render)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		scrapeTimeout := time.Duration(input.ScrapeTimeout) * time.Second
+		if scrapeTimeout < 0 || scrapeTimeout > engine.MaxScrapeTimeout {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid scrape_timeout_seconds %d, want 0 to %d", input.ScrapeTimeout, int(engine.MaxScrapeTimeout.Seconds()))},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		if input.FollowLinks < 0 {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid follow_links %d", input.FollowLinks)},
+				},
+			}, webSearchOutput{}, nil
+		}
+		depth := -1
+		if input.FollowLinks > 0 {
+			depth = 1
+		}
+
+		result, err := eng.FetchWithOptions(ctx, input.URL, engine.SearchOptions{
+			Force:         input.Force,
+			MaxAge:        time.Duration(input.MaxAge) * time.Second,
+			Extractor:     input.Extractor,
+			Render:        input.Render,
+			Output:        input.Output,
+			Format:        input.Format,
+			ScrapeTimeout: scrapeTimeout,
+			Depth:         depth,
+			FollowLinks:   input.FollowLinks,
+			Cite:          input.Cite,
+			Summarize:     input.Summarize,
+		})
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("fetch failed: %v", err)},
+				},
+			}, webSearchOutput{}, nil
+		}
+
+		meta := fmt.Sprintf("[from_cache: %v", result.FromCache)
+		if result.Summarized {
+			meta += ", summarized: true"
+		}
+		if len(result.Failed) > 0 {
+			meta += fmt.Sprintf(", failed: %d", len(result.Failed))
+		}
+		meta += "]\n"
+		for _, f := range result.Failed {
+			meta += fmt.Sprintf("[failed: %s (%s)]\n", f.URL, f.Reason)
+		}
+		meta += "\n"
+		out := newWebSearchOutput(result)
+		if input.Debug {
+			out.Timings = newTimingsOutput(result.Timings)
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: meta + result.Content},
+			},
+		}, out, nil
+	})
+
+	// Register deep_research tool.
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "deep_research",
+		Description: "Research a topic in rounds: search it, then search the sub-topics and read the links the results point to, within a page budget. Returns the text of every round under a heading naming its search or link. Slower than web_search; use it for broad questions.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input deepResearchInput) (*gomcp.CallToolResult, deepResearchOutput, error) {
+		count, err := cfg.Counts.Resolve(input.Count)
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: err.Error()},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		if input.Depth < 0 || input.Depth > engine.MaxResearchDepth {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid depth %d, want 0 to %d", input.Depth, engine.MaxResearchDepth)},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		if input.MaxPages < 0 || input.MaxPages > engine.MaxResearchPages {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid max_pages %d, want 0 to %d", input.MaxPages, engine.MaxResearchPages)},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		if input.Breadth < 0 || input.Breadth > engine.MaxResearchBreadth {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid breadth %d, want 0 to %d", input.Breadth, engine.MaxResearchBreadth)},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		if !search.ValidEngine(input.Engine) {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unknown search engine %q", input.Engine)},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		report, err := eng.DeepResearch(ctx, input.Query, engine.ResearchOptions{
+			Depth:    input.Depth,
+			MaxPages: input.MaxPages,
+			Breadth:  input.Breadth,
+			Search:   engine.SearchOptions{Count: count, Force: input.Force, Engine: input.Engine},
+		})
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("research failed: %v", err)},
+				},
+			}, deepResearchOutput{}, nil
+		}
+
+		meta := fmt.Sprintf("[rounds: %d, pages: %d, sources: %d]\n\n", len(report.Rounds), report.Pages, len(report.Sources))
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: meta + report.Content},
+			},
+		}, newDeepResearchOutput(report), nil
 	})
 
 	// Register clear_cache tool.
@@ -87,6 +733,241 @@ func Serve(eng *engine.Engine) error {
 		}, emptyOutput{}, nil
 	})
 
+	// Register pinning tools.
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "pin_cache",
+		Description: "Pin a query's cached result so it never expires or gets flushed. Uncached queries are searched first.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input pinInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		if err := eng.Pin(ctx, input.Query); err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("pin failed: %v", err)},
+				},
+			}, emptyOutput{}, nil
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: fmt.Sprintf("cache entry for %q pinned", input.Query)},
+			},
+		}, emptyOutput{}, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "unpin_cache",
+		Description: "Unpin a query's cached result, returning it to normal 24-hour expiry.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input pinInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		if err := eng.Unpin(input.Query); err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("unpin failed: %v", err)},
+				},
+			}, emptyOutput{}, nil
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: fmt.Sprintf("cache entry for %q unpinned", input.Query)},
+			},
+		}, emptyOutput{}, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "search_cache",
+		Description: "Search the text of results already in the cache without touching the network. Try this before web_search when the topic may have been researched already; pass a listed query to web_search to read the full cached result.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input searchCacheInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		if input.Limit < 0 || input.Limit > engine.MaxCacheSearchLimit {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid limit %d, want 0 to %d", input.Limit, engine.MaxCacheSearchLimit)},
+				},
+			}, emptyOutput{}, nil
+		}
+		matches, err := eng.SearchCache(ctx, input.Query, input.Limit)
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("search cache failed: %v", err)},
+				},
+			}, emptyOutput{}, nil
+		}
+		var b strings.Builder
+		if len(matches) == 0 {
+			b.WriteString("no cached results match")
+		}
+		for _, m := range matches {
+			fmt.Fprintf(&b, "%q (cached %s", m.Query, m.UpdatedAt.Format(time.RFC3339))
+			if m.Stale {
+				b.WriteString(", stale")
+			}
+			fmt.Fprintf(&b, ")\n  %s\n", m.Snippet)
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: b.String()},
+			},
+		}, emptyOutput{}, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "search_history",
+		Description: "List the searches and URL fetches made so far, newest first, with when they ran, the engine, how many results they found and whether they came from the cache; or run one again by its ID with replay. Useful in long sessions to recall what was already looked up.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input searchHistoryInput) (*gomcp.CallToolResult, searchHistoryOutput, error) {
+		if input.Replay < 0 {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid replay id %d", input.Replay)},
+				},
+			}, searchHistoryOutput{}, nil
+		}
+		if input.Replay > 0 {
+			result, err := eng.ReplayHistory(ctx, input.Replay, engine.SearchOptions{Force: input.Force})
+			if err != nil {
+				return &gomcp.CallToolResult{
+					IsError: true,
+					Content: []gomcp.Content{
+						&gomcp.TextContent{Text: fmt.Sprintf("replay failed: %v", err)},
+					},
+				}, searchHistoryOutput{}, nil
+			}
+			out := newWebSearchOutput(result)
+			meta := fmt.Sprintf("[results: %d, from_cache: %v]\n\n", result.ResultCount, result.FromCache)
+			return &gomcp.CallToolResult{
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: meta + result.Content},
+				},
+			}, searchHistoryOutput{Replay: &out}, nil
+		}
+
+		if input.Limit < 0 || input.Limit > engine.MaxHistoryList {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("invalid limit %d, want 0 to %d", input.Limit, engine.MaxHistoryList)},
+				},
+			}, searchHistoryOutput{}, nil
+		}
+		records, err := eng.SearchHistory(ctx, input.Query, input.Limit)
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("search history failed: %v", err)},
+				},
+			}, searchHistoryOutput{}, nil
+		}
+		var (
+			b   strings.Builder
+			out searchHistoryOutput
+		)
+		if len(records) == 0 {
+			b.WriteString("no history entries")
+		}
+		for _, rec := range records {
+			at := rec.Time.Format(time.RFC3339)
+			fmt.Fprintf(&b, "#%d %s %s %q (%s", rec.ID, at, rec.Kind, rec.Query, rec.Engine)
+			switch {
+			case rec.Error != "":
+				fmt.Fprintf(&b, ", failed: %s", rec.Error)
+			case rec.FromCache:
+				fmt.Fprintf(&b, ", %d results from cache", rec.ResultCount)
+			default:
+				fmt.Fprintf(&b, ", %d results", rec.ResultCount)
+			}
+			b.WriteString(")\n")
+			out.History = append(out.History, historyOutput{ID: rec.ID, Time: at, Kind: rec.Kind, Query: rec.Query, Site: rec.Site,
+				Engine: rec.Engine, ResultCount: rec.ResultCount, FromCache: rec.FromCache, Error: rec.Error})
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: b.String()},
+			},
+		}, out, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "list_pinned",
+		Description: "List pinned cache entries.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input listPinnedInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		entries, err := eng.Pinned()
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("list pinned failed: %v", err)},
+				},
+			}, emptyOutput{}, nil
+		}
+		var b strings.Builder
+		if len(entries) == 0 {
+			b.WriteString("no pinned entries")
+		}
+		for _, e := range entries {
+			fmt.Fprintf(&b, "%s (updated %s, %d bytes)\n", e.Query, e.UpdatedAt.Format(time.RFC3339), e.Size)
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: b.String()},
+			},
+		}, emptyOutput{}, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "self_check",
+		Description: "Run a few canary searches against each search engine and report whether this machine's IP looks blocked or rate-limited or clean. Use when results suddenly get worse.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input selfCheckInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		var engines []string
+		if input.Engine != "" {
+			engines = []string{input.Engine}
+		}
+		probes := input.Probes
+		if probes == 0 {
+			probes = 2
+		}
+		checks, err := eng.SelfCheck(ctx, engines, probes)
+		if err != nil {
+			return &gomcp.CallToolResult{
+				IsError: true,
+				Content: []gomcp.Content{
+					&gomcp.TextContent{Text: fmt.Sprintf("self check failed: %v", err)},
+				},
+			}, emptyOutput{}, nil
+		}
+		var b strings.Builder
+		for _, c := range checks {
+			fmt.Fprintf(&b, "%s: %s\n", c.Engine, c.Verdict)
+			for _, p := range c.Probes {
+				if p.Err != nil {
+					fmt.Fprintf(&b, "  %q: error: %v\n", p.Query, p.Err)
+				} else {
+					fmt.Fprintf(&b, "  %q: %d results in %v\n", p.Query, p.Results, p.Latency.Round(time.Millisecond))
+				}
+			}
+			if c.Recommendation != "" {
+				fmt.Fprintf(&b, "  recommendation: %s\n", c.Recommendation)
+			}
+		}
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: b.String()},
+			},
+		}, emptyOutput{}, nil
+	})
+
+	gomcp.AddTool(server, &gomcp.Tool{
+		Name:        "stats",
+		Description: "Report how this server has been doing since it started: searches, cache hit ratio, page success rate, time per stage and per-engine block rates.",
+	}, func(ctx context.Context, req *gomcp.CallToolRequest, input statsInput) (*gomcp.CallToolResult, emptyOutput, error) {
+		return &gomcp.CallToolResult{
+			Content: []gomcp.Content{
+				&gomcp.TextContent{Text: formatStats(eng.Stats())},
+			},
+		}, emptyOutput{}, nil
+	})
+
 	// Run the server over stdio until the client disconnects.
 	return server.Run(context.Background(), &gomcp.StdioTransport{})
 }
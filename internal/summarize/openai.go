@@ -0,0 +1,143 @@
+// Package summarize condenses consolidated search content with a language
+// model served over an OpenAI-compatible chat completions API, such as a
+// local llama.cpp or vLLM server.
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout       = 60 * time.Second
+	defaultMaxInputChars = 48000 // roughly 12k tokens of source text
+	defaultMaxTokens     = 512
+)
+
+const systemPrompt = `You condense web search results. Answer the user's query using only the provided sources. ` +
+	`Be concise and factual, keep concrete details such as numbers, names, versions and code identifiers, ` +
+	`and cite the source URL for each claim in parentheses. If the sources do not answer the query, say so.`
+
+// Config configures an OpenAI-compatible endpoint.
+type Config struct {
+	BaseURL       string        // API root including the version, e.g. "http://localhost:8000/v1"
+	Model         string        // model name passed through to the server
+	APIKey        string        // sent as a bearer token when set
+	MaxTokens     int           // completion length cap; 0 uses 512
+	MaxInputChars int           // content beyond this is truncated; 0 uses 48000
+	Timeout       time.Duration // per-request timeout; 0 uses 60s
+}
+
+// OpenAI summarizes content via POST {BaseURL}/chat/completions.
+type OpenAI struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewOpenAI returns a summarizer for the endpoint in cfg.
+func NewOpenAI(cfg Config) (*OpenAI, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("summarize: base URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, errors.New("summarize: model is required")
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = defaultMaxTokens
+	}
+	if cfg.MaxInputChars <= 0 {
+		cfg.MaxInputChars = defaultMaxInputChars
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &OpenAI{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// userPrompt asks about query, or for a plain summary if it is empty, as
+// for a single fetched page.
+func userPrompt(query, content string) string {
+	if query == "" {
+		return fmt.Sprintf("Summarize these sources.\n\nSources:\n\n%s", content)
+	}
+	return fmt.Sprintf("Query: %s\n\nSources:\n\n%s", query, content)
+}
+
+// Summarize condenses content into an answer for query, or into a summary
+// of it if query is empty.
+func (o *OpenAI) Summarize(ctx context.Context, query, content string) (string, error) {
+	if len(content) > o.cfg.MaxInputChars {
+		content = strings.ToValidUTF8(content[:o.cfg.MaxInputChars], "") + "\n\n[truncated]"
+	}
+	body, err := json.Marshal(chatRequest{
+		Model: o.cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt(query, content)},
+		},
+		MaxTokens:   o.cfg.MaxTokens,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarize: encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("summarize: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.cfg.APIKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("summarize: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out chatResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", fmt.Errorf("summarize: status %d: decode response: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if out.Error != nil {
+			return "", fmt.Errorf("summarize: status %d: %s", resp.StatusCode, out.Error.Message)
+		}
+		return "", fmt.Errorf("summarize: unexpected status %d", resp.StatusCode)
+	}
+	if len(out.Choices) == 0 || strings.TrimSpace(out.Choices[0].Message.Content) == "" {
+		return "", errors.New("summarize: empty completion")
+	}
+	return strings.TrimSpace(out.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,81 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  Go 1.22 added range-over-int (https://go.dev).  "}}]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewOpenAI(Config{BaseURL: srv.URL + "/v1/", Model: "llama", APIKey: "secret", MaxInputChars: 20})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+	summary, err := s.Summarize(context.Background(), "go 1.22", strings.Repeat("x", 50))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if summary != "Go 1.22 added range-over-int (https://go.dev)." {
+		t.Errorf("summary = %q", summary)
+	}
+	if got.Model != "llama" || len(got.Messages) != 2 {
+		t.Fatalf("request = %+v", got)
+	}
+	user := got.Messages[1].Content
+	if !strings.Contains(user, "Query: go 1.22") || !strings.Contains(user, "[truncated]") || strings.Contains(user, strings.Repeat("x", 21)) {
+		t.Errorf("user message not truncated as expected: %q", user)
+	}
+}
+
+func TestSummarizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"api_error", http.StatusBadRequest, `{"error":{"message":"model not found"}}`, "model not found"},
+		{"empty", http.StatusOK, `{"choices":[]}`, "empty completion"},
+		{"not_json", http.StatusBadGateway, `<html>bad gateway</html>`, "status 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s, _ := NewOpenAI(Config{BaseURL: srv.URL, Model: "m"})
+			_, err := s.Summarize(context.Background(), "q", "content")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewOpenAIValidation(t *testing.T) {
+	if _, err := NewOpenAI(Config{Model: "m"}); err == nil {
+		t.Error("expected error without base URL")
+	}
+	if _, err := NewOpenAI(Config{BaseURL: "http://x"}); err == nil {
+		t.Error("expected error without model")
+	}
+}
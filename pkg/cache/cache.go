@@ -0,0 +1,411 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultDBDir  = ".glsi"
+	defaultDBFile = "cache.db"
+	cacheTTL      = 24 * time.Hour
+
+	// busyTimeout is how long a write waits for another writer, in this
+	// process or another, before failing with "database is locked".
+	busyTimeout = 10 * time.Second
+)
+
+// Cache provides a SQLite-backed key–value cache with TTL support.
+type Cache struct {
+	db   *sql.DB
+	path string // database file, for disk space checks
+
+	checkMu   sync.Mutex // serializes integrity checks
+	lastCheck integrityCheck
+
+	sealer *sealer // encrypts stored content; nil stores it in the clear
+	log    *slog.Logger
+}
+
+// Options configures a cache opened with Open.
+type Options struct {
+	// EncryptionKey, if set, encrypts cached content, result URLs, page
+	// bodies and scraped pages with AES-256-GCM before they are written.
+	// Queries and other metadata stay readable so entries can be listed.
+	// It must be KeySize bytes; see ParseKey.
+	EncryptionKey []byte
+
+	// Logger receives debug logs of reads and writes, with their query
+	// hash and duration, warnings for failed ones and a summary of each
+	// Prune; nil logs nothing.
+	Logger *slog.Logger
+}
+
+// New opens (or creates) a SQLite cache database at dbPath.
+// If dbPath is empty, it defaults to ~/.glsi/cache.db.
+func New(dbPath string) (*Cache, error) {
+	return Open(dbPath, Options{})
+}
+
+// Open is like New but takes options. Entries written without a key stay
+// readable after one is configured; entries written with a key fail to read
+// with ErrNoKey without one.
+func Open(dbPath string, opts Options) (*Cache, error) {
+	var s *sealer
+	if opts.EncryptionKey != nil {
+		var err error
+		if s, err = newSealer(opts.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if dbPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cache: user home dir: %w", err)
+		}
+		dir := filepath.Join(home, defaultDBDir)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("cache: create dir %s: %w", dir, err)
+		}
+		dbPath = filepath.Join(dir, defaultDBFile)
+	}
+
+	db, err := sql.Open("sqlite", dsn(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("cache: open db: %w", err)
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+	c := &Cache{db: db, path: dbPath, sealer: s, log: log}
+	// Create and migrate every table in one transaction, so an interrupted
+	// upgrade is retried from scratch on the next start.
+	start := time.Now()
+	if err := c.withTx(context.Background(), migrate); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Debug("cache opened", "path", dbPath, "encrypted", s != nil, "duration", time.Since(start))
+	return c, nil
+}
+
+// dsn adds connection settings to dbPath, applied to every pooled
+// connection: WAL journaling so readers and the writer do not block each
+// other, a busy timeout so writers queue instead of failing, and immediate
+// transactions so a transaction takes the write lock when it begins rather
+// than failing on a lock upgrade part way through. Together they let the
+// HTTP API, the MCP server and the CLI share one cache file.
+func dsn(dbPath string) string {
+	v := url.Values{}
+	v.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	v.Add("_pragma", "journal_mode(WAL)")
+	v.Set("_txlock", "immediate")
+	return dbPath + "?" + v.Encode()
+}
+
+// baseSchema is schema version 1. It creates the tables if they do not
+// exist and upgrades databases from before schema versions were recorded,
+// whatever columns they had, by adding the missing ones.
+func baseSchema(tx *sql.Tx) error {
+	const createSQL = `
+		CREATE TABLE IF NOT EXISTS cache (
+			query_hash TEXT PRIMARY KEY,
+			query      TEXT NOT NULL DEFAULT '',
+			content    TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			pinned     INTEGER NOT NULL DEFAULT 0,
+			hits       INTEGER NOT NULL DEFAULT 0,
+			engine     TEXT NOT NULL DEFAULT '',
+			results    INTEGER NOT NULL DEFAULT 0,
+			urls       TEXT NOT NULL DEFAULT '',
+			expires_at TEXT NOT NULL DEFAULT ''
+		);`
+	if _, err := tx.Exec(createSQL); err != nil {
+		return fmt.Errorf("cache: create table: %w", err)
+	}
+	if err := migrateColumns(tx); err != nil {
+		return fmt.Errorf("cache: add columns: %w", err)
+	}
+	// Rows from before per-entry TTLs expire after the default one.
+	if _, err := tx.Exec("UPDATE cache SET expires_at = datetime(updated_at, ?) WHERE expires_at = ''",
+		fmt.Sprintf("+%d seconds", int(cacheTTL.Seconds()))); err != nil {
+		return fmt.Errorf("cache: set expiry: %w", err)
+	}
+	if _, err := tx.Exec(createUsageSQL); err != nil {
+		return fmt.Errorf("cache: create usage table: %w", err)
+	}
+	if _, err := tx.Exec(createFetchSQL); err != nil {
+		return fmt.Errorf("cache: create fetches table: %w", err)
+	}
+	if _, err := tx.Exec(createPagesSQL); err != nil {
+		return fmt.Errorf("cache: create pages table: %w", err)
+	}
+	return nil
+}
+
+// Meta describes how a cached result was produced. Entries stored before
+// a field existed have it empty.
+type Meta struct {
+	Query       string   // query the entry was stored under
+	Engine      string   // search engine the links came from, or "site" for site searches
+	ResultCount int      // pages consolidated into the content
+	URLs        []string // those pages' URLs, in order
+
+	// TTL is how long the entry stays fresh from when it is stored; 0
+	// uses the default of 24 hours. It is not read back; see
+	// Entry.ExpiresAt.
+	TTL time.Duration
+}
+
+// Entry is a cached result as stored, whether or not it is still fresh.
+type Entry struct {
+	Meta
+	QueryHash string
+	Content   string
+	UpdatedAt time.Time // when the content was stored
+	ExpiresAt time.Time // when it goes stale, unless pinned
+	Pinned    bool
+	Stale     bool      // past ExpiresAt and not pinned
+	Hits      int       // reads since the entry was last stored, not counting this one
+	TotalHits int       // reads since the entry was first stored, not counting this one
+	LastHitAt time.Time // when it was last read before this read; zero if never
+}
+
+// Get retrieves cached content for the given query hash.
+// It returns the content, whether the cache was hit (i.e. entry exists and is
+// pinned or has not expired), and any error.
+func (c *Cache) Get(queryHash string) (string, bool, error) {
+	return c.GetContext(context.Background(), queryHash)
+}
+
+// GetContext is like Get but gives up when ctx is done.
+func (c *Cache) GetContext(ctx context.Context, queryHash string) (string, bool, error) {
+	e, ok, err := c.Lookup(ctx, queryHash)
+	if err != nil || !ok || e.Stale {
+		return "", false, err
+	}
+	return e.Content, true, nil
+}
+
+// Lookup returns the entry stored under queryHash even if it is stale, for
+// callers that serve stale content while refreshing it. ok is false if
+// there is no entry. Each read, stale or not, counts towards the entry's
+// Hits and TotalHits and updates its LastHitAt.
+func (c *Cache) Lookup(ctx context.Context, queryHash string) (_ Entry, ok bool, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			c.log.WarnContext(ctx, "cache read failed", "query_hash", queryHash, "duration", time.Since(start), "err", err)
+			return
+		}
+		c.log.DebugContext(ctx, "cache read", "query_hash", queryHash, "hit", ok, "duration", time.Since(start))
+	}()
+	e := Entry{QueryHash: queryHash}
+	var urls, expires, lastHit string
+	err = c.db.QueryRowContext(ctx,
+		`SELECT content, query, engine, results, urls, updated_at, expires_at, pinned, hits, total_hits, last_hit_at
+		 FROM cache WHERE query_hash = ?`,
+		queryHash,
+	).Scan(&e.Content, &e.Query, &e.Engine, &e.ResultCount, &urls, &e.UpdatedAt, &expires, &e.Pinned, &e.Hits, &e.TotalHits, &lastHit)
+
+	if err == sql.ErrNoRows {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	if e.Content, err = c.sealer.openText(e.Content); err != nil {
+		return Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	if urls, err = c.sealer.openText(urls); err != nil {
+		return Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	if urls != "" {
+		e.URLs = strings.Split(urls, "\n")
+	}
+	if e.ExpiresAt, err = parseTime(expires); err != nil {
+		return Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	if e.LastHitAt, err = parseTime(lastHit); err != nil {
+		return Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	e.Stale = !e.Pinned && time.Now().After(e.ExpiresAt)
+	if _, err := c.db.ExecContext(ctx,
+		"UPDATE cache SET hits = hits + 1, total_hits = total_hits + 1, last_hit_at = ? WHERE query_hash = ?",
+		formatTime(time.Now()), queryHash,
+	); err != nil {
+		return Entry{}, false, fmt.Errorf("cache: count hit %q: %w", queryHash, err)
+	}
+	return e, true, nil
+}
+
+// Set upserts content for the given query hash, storing meta alongside so
+// entries can be told apart and described without reversing the hash. An
+// empty meta.Query keeps the one already stored. The entry expires after
+// meta.TTL, or 24 hours if that is zero. Refreshing a pinned entry keeps it
+// pinned and restarts its hit count.
+func (c *Cache) Set(queryHash, content string, meta Meta) error {
+	return c.SetContext(context.Background(), queryHash, content, meta)
+}
+
+// SetContext is like Set but gives up when ctx is done. The write runs in a
+// transaction so rows kept alongside an entry can join it.
+func (c *Cache) SetContext(ctx context.Context, queryHash, content string, meta Meta) error {
+	const upsertSQL = `
+		INSERT INTO cache (query_hash, query, engine, results, urls, content, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+		ON CONFLICT(query_hash) DO UPDATE SET
+			query      = CASE WHEN excluded.query = '' THEN cache.query ELSE excluded.query END,
+			engine     = excluded.engine,
+			results    = excluded.results,
+			urls       = excluded.urls,
+			content    = excluded.content,
+			updated_at = excluded.updated_at,
+			expires_at = excluded.expires_at,
+			hits       = 0;`
+
+	urls := strings.Join(meta.URLs, "\n")
+	if urls != "" {
+		urls = c.sealer.sealText(urls)
+	}
+	content = c.sealer.sealText(content)
+	ttl := meta.TTL
+	if ttl <= 0 {
+		ttl = cacheTTL
+	}
+	start := time.Now()
+	expires := formatTime(start.Add(ttl))
+	err := c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, upsertSQL, queryHash, meta.Query, meta.Engine, meta.ResultCount, urls, content, expires); err != nil {
+			return fmt.Errorf("cache: set %q: %w", queryHash, err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.log.WarnContext(ctx, "cache write failed", "query_hash", queryHash, "engine", meta.Engine, "duration", time.Since(start), "err", err)
+		return err
+	}
+	c.log.DebugContext(ctx, "cache write", "query_hash", queryHash, "engine", meta.Engine, "bytes", len(content), "duration", time.Since(start))
+	return nil
+}
+
+// Clear removes cached entries.
+// If queryHash is empty, all unpinned entries are flushed, along with the
+// page bodies kept for conditional fetches and the scraped pages kept for
+// reuse, in one transaction. Otherwise,
+// only the entry matching the hash is deleted; deleting a pinned entry fails
+// with ErrPinned.
+func (c *Cache) Clear(queryHash string) error {
+	if queryHash == "" {
+		return c.withTx(context.Background(), func(tx *sql.Tx) error {
+			if _, err := tx.Exec("DELETE FROM cache WHERE pinned = 0"); err != nil {
+				return fmt.Errorf("cache: clear: %w", err)
+			}
+			if _, err := tx.Exec("DELETE FROM fetches"); err != nil {
+				return fmt.Errorf("cache: clear fetches: %w", err)
+			}
+			if _, err := tx.Exec("DELETE FROM pages"); err != nil {
+				return fmt.Errorf("cache: clear pages: %w", err)
+			}
+			return nil
+		})
+	}
+
+	res, err := c.db.Exec("DELETE FROM cache WHERE query_hash = ? AND pinned = 0", queryHash)
+	if err != nil {
+		return fmt.Errorf("cache: clear: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var pinned bool
+		err := c.db.QueryRow("SELECT pinned FROM cache WHERE query_hash = ?", queryHash).Scan(&pinned)
+		if err == nil && pinned {
+			return fmt.Errorf("cache: clear %q: %w", queryHash, ErrPinned)
+		}
+	}
+	return nil
+}
+
+// addedColumns are columns added to the cache table after its first
+// release, with their definitions, so older databases can be upgraded.
+var addedColumns = []struct{ name, def string }{
+	{"pinned", "INTEGER NOT NULL DEFAULT 0"},
+	{"query", "TEXT NOT NULL DEFAULT ''"},
+	{"hits", "INTEGER NOT NULL DEFAULT 0"},
+	{"engine", "TEXT NOT NULL DEFAULT ''"},
+	{"results", "INTEGER NOT NULL DEFAULT 0"},
+	{"urls", "TEXT NOT NULL DEFAULT ''"},
+	{"expires_at", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// formatTime formats t for the expires_at and last_hit_at columns, in the
+// layout SQLite's datetime() uses so the columns compare correctly as text.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// parseTime reverses formatTime. An empty string, for a time not yet
+// recorded, is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(time.DateTime, s, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// migrateColumns adds any of addedColumns missing from an existing cache
+// table. Rows written before a column existed get its default.
+func migrateColumns(db execer) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('cache')")
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, col := range addedColumns {
+		if have[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE cache ADD COLUMN %s %s", col.name, col.def)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying database connection.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Path returns the database file's path, with the default filled in.
+func (c *Cache) Path() string {
+	return c.path
+}
@@ -0,0 +1,397 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDB(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "test.db")
+}
+
+func TestNewCreatesTable(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+}
+
+func TestSetAndGet(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	hash := "abc123"
+	want := "hello, world"
+
+	if err := c.Set(hash, want, Meta{}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, hit, err := c.Get(hash)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !hit {
+		t.Fatal("expected cache hit, got miss")
+	}
+	if got != want {
+		t.Fatalf("Get = %q, want %q", got, want)
+	}
+}
+
+func TestGetMiss(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	_, hit, err := c.Get("nonexistent")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if hit {
+		t.Fatal("expected cache miss, got hit")
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	hash := "key1"
+	if err := c.Set(hash, "first", Meta{}); err != nil {
+		t.Fatalf("Set first: %v", err)
+	}
+	if err := c.Set(hash, "second", Meta{}); err != nil {
+		t.Fatalf("Set second: %v", err)
+	}
+
+	got, hit, err := c.Get(hash)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !hit {
+		t.Fatal("expected cache hit")
+	}
+	if got != "second" {
+		t.Fatalf("Get = %q, want %q", got, "second")
+	}
+}
+
+func TestSetStoresMeta(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	want := Meta{Query: "golang generics", Engine: "duckduckgo", ResultCount: 2, URLs: []string{"https://go.dev/a", "https://go.dev/b"}}
+	c.Set("h", "first", want)
+	e, ok, err := c.Lookup(ctx, "h")
+	if err != nil || !ok {
+		t.Fatalf("Lookup = %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(e.Meta, want) || e.UpdatedAt.IsZero() {
+		t.Errorf("Lookup = %+v, want meta %+v", e, want)
+	}
+	// A refresh without a query keeps the stored one.
+	c.Set("h", "second", Meta{Engine: "google"})
+	if e, _, _ := c.Lookup(ctx, "h"); e.Query != "golang generics" || e.Engine != "google" || e.URLs != nil {
+		t.Errorf("Lookup after refresh = %+v", e)
+	}
+}
+
+func TestClearOne(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", Meta{})
+	c.Set("b", "2", Meta{})
+
+	if err := c.Clear("a"); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+
+	_, hit, _ := c.Get("a")
+	if hit {
+		t.Fatal("expected 'a' to be deleted")
+	}
+
+	_, hit, _ = c.Get("b")
+	if !hit {
+		t.Fatal("expected 'b' to still exist")
+	}
+}
+
+func TestClearAll(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", Meta{})
+	c.Set("b", "2", Meta{})
+
+	if err := c.Clear(""); err != nil {
+		t.Fatalf("Clear all: %v", err)
+	}
+
+	_, hitA, _ := c.Get("a")
+	_, hitB, _ := c.Get("b")
+	if hitA || hitB {
+		t.Fatal("expected all entries to be deleted")
+	}
+}
+
+func TestDefaultDBPath(t *testing.T) {
+	// Verify that New("") defaults to ~/.glsi/cache.db
+	// We'll just check that the directory gets created.
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("cannot determine home dir")
+	}
+
+	c, err := New("")
+	if err != nil {
+		t.Fatalf("New with default path: %v", err)
+	}
+	defer c.Close()
+
+	expectedDir := filepath.Join(home, ".glsi")
+	if _, err := os.Stat(expectedDir); os.IsNotExist(err) {
+		t.Fatalf("expected directory %s to exist", expectedDir)
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	// This test manually checks TTL logic by reducing the constant.
+	// Since we can't easily mock time, we test the boundary:
+	// a freshly-set entry should be a hit.
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("test", "content", Meta{})
+
+	// Should be a hit immediately.
+	_, hit, err := c.Get("test")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !hit {
+		t.Fatal("expected cache hit for fresh entry")
+	}
+
+	// Verify cacheTTL is 24h as expected.
+	if cacheTTL != 24*time.Hour {
+		t.Fatalf("cacheTTL = %v, want 24h", cacheTTL)
+	}
+}
+
+func TestLookupStale(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("old", "content", Meta{Query: "go modules"})
+	if _, err := c.db.Exec("UPDATE cache SET updated_at = datetime('now', '-48 hours'), expires_at = datetime('now', '-24 hours')"); err != nil {
+		t.Fatalf("age entry: %v", err)
+	}
+	if _, hit, _ := c.Get("old"); hit {
+		t.Error("Get hit a stale entry")
+	}
+	e, ok, err := c.Lookup(context.Background(), "old")
+	if err != nil || !ok {
+		t.Fatalf("Lookup = %v, %v", ok, err)
+	}
+	if e.Content != "content" || e.Query != "go modules" || !e.Stale {
+		t.Errorf("Lookup = %+v, want stale content", e)
+	}
+	if _, ok, _ := c.Lookup(context.Background(), "missing"); ok {
+		t.Error("Lookup found a missing entry")
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := c.GetContext(ctx, "abc"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetContext: err = %v, want context.Canceled", err)
+	}
+	if err := c.SetContext(ctx, "abc", "x", Meta{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetContext: err = %v, want context.Canceled", err)
+	}
+	limits := []UsageLimit{{Window: time.Hour, Max: 5}}
+	if n, err := c.ReserveContext(ctx, "pages", 1, limits); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("ReserveContext = %d, %v; want 0, context.Canceled", n, err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := Open(tempDB(t), Options{Logger: logger})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Set("abc", "content", Meta{Engine: "google"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, _, err := c.Get("abc"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.GetContext(ctx, "def")
+
+	logs := buf.String()
+	for _, want := range []string{
+		`msg="cache opened"`,
+		`msg="cache write" query_hash=abc engine=google bytes=7`,
+		`msg="cache read" query_hash=abc hit=true`,
+		`level=WARN msg="cache read failed" query_hash=def`,
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs missing %s:\n%s", want, logs)
+		}
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("a", "1", Meta{})
+	c.PutFetch(context.Background(), "https://a.example/", Fetch{Body: []byte("x")})
+
+	// A write failing after the first table changed leaves both as they were.
+	boom := errors.New("boom")
+	err = c.withTx(context.Background(), func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM cache"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("withTx: err = %v, want boom", err)
+	}
+	if _, hit, _ := c.Get("a"); !hit {
+		t.Error("cache row deleted despite rollback")
+	}
+	if _, ok, _ := c.GetFetch(context.Background(), "https://a.example/"); !ok {
+		t.Error("fetch row lost")
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	c.Set("news", "x", Meta{TTL: time.Hour})
+	c.Set("docs", "x", Meta{TTL: 7 * 24 * time.Hour})
+	c.Set("default", "x", Meta{})
+	for h, want := range map[string]time.Duration{"news": time.Hour, "docs": 7 * 24 * time.Hour, "default": cacheTTL} {
+		e, ok, err := c.Lookup(ctx, h)
+		if err != nil || !ok {
+			t.Fatalf("Lookup(%s) = %v, %v", h, ok, err)
+		}
+		if got := time.Until(e.ExpiresAt); got > want || got < want-time.Minute {
+			t.Errorf("%s expires in %v, want %v", h, got, want)
+		}
+	}
+
+	// Two hours on, the news entry has expired and the docs have not.
+	if _, err := c.db.Exec("UPDATE cache SET expires_at = datetime(expires_at, '-2 hours')"); err != nil {
+		t.Fatalf("age entries: %v", err)
+	}
+	if _, hit, _ := c.Get("news"); hit {
+		t.Error("news entry outlived its TTL")
+	}
+	if _, hit, _ := c.Get("docs"); !hit {
+		t.Error("docs entry expired early")
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	path := tempDB(t)
+	var caches []*Cache
+	for range 2 {
+		c, err := New(path)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer c.Close()
+		caches = append(caches, c)
+	}
+	var mode string
+	if err := caches[0].db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q, %v; want wal", mode, err)
+	}
+
+	// Two handles on one file, as when the HTTP API and MCP server share
+	// a cache, each writing from several goroutines.
+	errs := make(chan error, 80)
+	for i, c := range caches {
+		for g := range 4 {
+			go func() {
+				for n := range 10 {
+					hash := fmt.Sprintf("%d-%d-%d", i, g, n)
+					if err := c.Set(hash, "content", Meta{Query: hash}); err != nil {
+						errs <- err
+						continue
+					}
+					_, err := c.Reserve("serp:google", 1, []UsageLimit{{Window: time.Hour, Max: 1000}})
+					errs <- err
+				}
+			}()
+		}
+	}
+	for range 80 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
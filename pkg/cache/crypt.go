@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of an encryption key in bytes (AES-256).
+const KeySize = 32
+
+// sealedPrefix marks values encrypted by the cache. Values without it were
+// stored in the clear, before a key was configured, and are read as is.
+const sealedPrefix = "glsi-enc1:"
+
+// ErrNoKey is returned when reading a value that was encrypted but the
+// cache was opened without a key.
+var ErrNoKey = errors.New("cache: value is encrypted but no key is configured")
+
+// ParseKey decodes a key given as 64 hex digits or as standard base64 of
+// KeySize bytes.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("cache: key must be %d bytes as hex or base64", KeySize)
+}
+
+// sealer encrypts values with AES-GCM. A nil sealer stores values in the
+// clear.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("cache: key is %d bytes, want %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cache: cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cache: cipher: %w", err)
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal encrypts b under a fresh nonce, prefixed so it can be recognized.
+func (s *sealer) seal(b []byte) []byte {
+	if s == nil {
+		return b
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic("cache: read nonce: " + err.Error())
+	}
+	out := append([]byte(sealedPrefix), nonce...)
+	return s.aead.Seal(out, nonce, b, nil)
+}
+
+// open reverses seal. Values stored in the clear are returned unchanged.
+func (s *sealer) open(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte(sealedPrefix)) {
+		return b, nil
+	}
+	if s == nil {
+		return nil, ErrNoKey
+	}
+	b = b[len(sealedPrefix):]
+	n := s.aead.NonceSize()
+	if len(b) < n {
+		return nil, errors.New("cache: sealed value is truncated")
+	}
+	plain, err := s.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cache: decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// sealText is seal for text columns; the ciphertext is base64-encoded
+// after the prefix.
+func (s *sealer) sealText(v string) string {
+	if s == nil {
+		return v
+	}
+	sealed := s.seal([]byte(v))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed[len(sealedPrefix):])
+}
+
+// openText reverses sealText.
+func (s *sealer) openText(v string) (string, error) {
+	if !strings.HasPrefix(v, sealedPrefix) {
+		return v, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(v[len(sealedPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("cache: decode sealed value: %w", err)
+	}
+	plain, err := s.open(append([]byte(sealedPrefix), raw...))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptionAtRest(t *testing.T) {
+	path := tempDB(t)
+	key := bytes.Repeat([]byte{7}, KeySize)
+	ctx := context.Background()
+
+	// An entry written before a key was configured stays readable.
+	plain, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := plain.Set("old", "old content", Meta{}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	plain.Close()
+
+	c, err := Open(path, Options{EncryptionKey: key})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := c.Set("h", "secret content", Meta{Query: "q", URLs: []string{"https://intranet/a"}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := c.PutPage(ctx, "p", []byte("secret page"), time.Hour); err != nil {
+		t.Fatalf("PutPage: %v", err)
+	}
+	if err := c.PutFetch(ctx, "https://intranet/a", Fetch{Body: []byte("secret body")}); err != nil {
+		t.Fatalf("PutFetch: %v", err)
+	}
+
+	var content, urls string
+	var page, body []byte
+	c.db.QueryRow("SELECT content, urls FROM cache WHERE query_hash = 'h'").Scan(&content, &urls)
+	c.db.QueryRow("SELECT page FROM pages WHERE key = 'p'").Scan(&page)
+	c.db.QueryRow("SELECT body FROM fetches").Scan(&body)
+	for _, raw := range []string{content, urls, string(page), string(body)} {
+		if strings.Contains(raw, "secret") || strings.Contains(raw, "intranet") {
+			t.Errorf("stored in the clear: %q", raw)
+		}
+	}
+
+	e, ok, err := c.Lookup(ctx, "h")
+	if err != nil || !ok || e.Content != "secret content" || len(e.URLs) != 1 || e.URLs[0] != "https://intranet/a" {
+		t.Errorf("Lookup = %+v, %v, %v", e, ok, err)
+	}
+	if got, ok, err := c.GetPage(ctx, "p"); err != nil || !ok || string(got) != "secret page" {
+		t.Errorf("GetPage = %q, %v, %v", got, ok, err)
+	}
+	if f, ok, err := c.GetFetch(ctx, "https://intranet/a"); err != nil || !ok || string(f.Body) != "secret body" {
+		t.Errorf("GetFetch = %q, %v, %v", f.Body, ok, err)
+	}
+	if got, ok, err := c.Get("old"); err != nil || !ok || got != "old content" {
+		t.Errorf("Get(old) = %q, %v, %v", got, ok, err)
+	}
+	c.Close()
+
+	// Without the key, encrypted entries cannot be read.
+	nokey, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer nokey.Close()
+	if _, _, err := nokey.Get("h"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Get without key: err = %v, want ErrNoKey", err)
+	}
+
+	// Nor with the wrong one.
+	wrong, err := Open(path, Options{EncryptionKey: bytes.Repeat([]byte{8}, KeySize)})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer wrong.Close()
+	if _, _, err := wrong.Get("h"); err == nil {
+		t.Error("Get with the wrong key succeeded")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	hexKey := strings.Repeat("ab", KeySize)
+	if k, err := ParseKey(hexKey); err != nil || len(k) != KeySize {
+		t.Errorf("ParseKey(hex) = %x, %v", k, err)
+	}
+	if k, err := ParseKey("  " + strings.Repeat("A", 43) + "=\n"); err != nil || len(k) != KeySize {
+		t.Errorf("ParseKey(base64) = %x, %v", k, err)
+	}
+	for _, bad := range []string{"", "abcd", strings.Repeat("zz", KeySize)} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", bad)
+		}
+	}
+	if _, err := Open(tempDB(t), Options{EncryptionKey: []byte("short")}); err == nil {
+		t.Error("Open with a short key succeeded")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+package cache
+
+import "errors"
+
+// freeSpace is not implemented on this platform; Health reports the free
+// space as unknown.
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.New("cache: free space not supported on this platform")
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Expiring lists unpinned entries that expire within the given time, or
+// already have, and were read at least minHits times since they were last
+// stored, most read first. Entries stored without their query are left
+// out, since they cannot be searched again. Content is not loaded.
+func (c *Cache) Expiring(ctx context.Context, within time.Duration, minHits int) ([]Entry, error) {
+	cutoff := formatTime(time.Now().Add(within))
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT query_hash, query, updated_at, expires_at, hits
+		FROM cache
+		WHERE pinned = 0 AND query != '' AND hits >= ? AND expires_at <= ?
+		ORDER BY hits DESC`, minHits, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("cache: list expiring: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var expires string
+		if err := rows.Scan(&e.QueryHash, &e.Query, &e.UpdatedAt, &expires, &e.Hits); err != nil {
+			return nil, fmt.Errorf("cache: list expiring: %w", err)
+		}
+		var err error
+		if e.ExpiresAt, err = parseTime(expires); err != nil {
+			return nil, fmt.Errorf("cache: list expiring: %w", err)
+		}
+		e.Stale = time.Now().After(e.ExpiresAt)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache: list expiring: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpiring(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	c.Set("hot", "x", Meta{Query: "hot query"})
+	c.Set("cold", "x", Meta{Query: "cold query"})
+	c.Set("fresh", "x", Meta{Query: "fresh query"})
+	c.Set("anon", "x", Meta{})
+	for range 3 {
+		c.Get("hot")
+		c.Get("fresh")
+		c.Get("anon")
+	}
+	c.Get("cold")
+	// Everything but "fresh" expires within the hour.
+	if _, err := c.db.Exec("UPDATE cache SET updated_at = datetime('now', '-23 hours', '-30 minutes'), expires_at = datetime('now', '+30 minutes') WHERE query_hash != 'fresh'"); err != nil {
+		t.Fatalf("age entries: %v", err)
+	}
+
+	got, err := c.Expiring(ctx, time.Hour, 3)
+	if err != nil {
+		t.Fatalf("Expiring: %v", err)
+	}
+	if len(got) != 1 || got[0].QueryHash != "hot" || got[0].Query != "hot query" || got[0].Hits != 3 || got[0].Stale {
+		t.Fatalf("Expiring = %+v, want only the hot entry", got)
+	}
+
+	// Storing the entry again restarts its count.
+	c.Set("hot", "y", Meta{})
+	if got, _ := c.Expiring(ctx, 25*time.Hour, 1); len(got) != 2 {
+		t.Errorf("Expiring after refresh = %+v, want fresh and cold", got)
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// fetchTTL is how long a stored page body is kept for revalidation. Older
+// bodies are pruned as new ones are stored.
+const fetchTTL = 7 * 24 * time.Hour
+
+const createFetchSQL = `
+	CREATE TABLE IF NOT EXISTS fetches (
+		url           TEXT PRIMARY KEY,
+		etag          TEXT NOT NULL,
+		last_modified TEXT NOT NULL,
+		content_type  TEXT NOT NULL,
+		final_url     TEXT NOT NULL,
+		truncated     INTEGER NOT NULL,
+		body          BLOB NOT NULL,
+		fetched_at    INTEGER NOT NULL
+	);`
+
+// Fetch is a page body as last downloaded, with the validators needed to
+// ask the server whether it has changed.
+type Fetch struct {
+	ETag         string // ETag response header
+	LastModified string // Last-Modified response header
+	ContentType  string
+	FinalURL     string // where redirects ended
+	Truncated    bool   // Body was cut off at the scraper's size cap
+	Body         []byte // decoded (uncompressed) body
+}
+
+// GetFetch returns the stored body for url, if one is younger than a week.
+func (c *Cache) GetFetch(ctx context.Context, url string) (Fetch, bool, error) {
+	var f Fetch
+	var fetchedAt int64
+	err := c.db.QueryRowContext(ctx,
+		`SELECT etag, last_modified, content_type, final_url, truncated, body, fetched_at
+		 FROM fetches WHERE url = ?`, url,
+	).Scan(&f.ETag, &f.LastModified, &f.ContentType, &f.FinalURL, &f.Truncated, &f.Body, &fetchedAt)
+	if err == sql.ErrNoRows {
+		return Fetch{}, false, nil
+	}
+	if err != nil {
+		return Fetch{}, false, fmt.Errorf("cache: get fetch %q: %w", url, err)
+	}
+	if time.Since(time.Unix(fetchedAt, 0)) > fetchTTL {
+		return Fetch{}, false, nil
+	}
+	if f.Body, err = c.sealer.open(f.Body); err != nil {
+		return Fetch{}, false, fmt.Errorf("cache: get fetch %q: %w", url, err)
+	}
+	return f, true, nil
+}
+
+// PutFetch stores the body downloaded from url, replacing any earlier one,
+// and prunes bodies past their TTL. A revalidated body should be stored
+// again too, to restart its TTL.
+func (c *Cache) PutFetch(ctx context.Context, url string, f Fetch) error {
+	const upsertSQL = `
+		INSERT INTO fetches (url, etag, last_modified, content_type, final_url, truncated, body, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET
+			etag          = excluded.etag,
+			last_modified = excluded.last_modified,
+			content_type  = excluded.content_type,
+			final_url     = excluded.final_url,
+			truncated     = excluded.truncated,
+			body          = excluded.body,
+			fetched_at    = excluded.fetched_at;`
+
+	now := time.Now()
+	body := c.sealer.seal(f.Body)
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, upsertSQL,
+			url, f.ETag, f.LastModified, f.ContentType, f.FinalURL, f.Truncated, body, now.Unix(),
+		); err != nil {
+			return fmt.Errorf("cache: put fetch %q: %w", url, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM fetches WHERE fetched_at < ?", now.Add(-fetchTTL).Unix()); err != nil {
+			return fmt.Errorf("cache: prune fetches: %w", err)
+		}
+		return nil
+	})
+}
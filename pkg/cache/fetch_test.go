@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFetchRoundTrip(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, ok, err := c.GetFetch(ctx, "https://a.example/"); ok || err != nil {
+		t.Fatalf("GetFetch on empty cache = %v, %v", ok, err)
+	}
+	want := Fetch{ETag: `"v1"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT", ContentType: "text/html", FinalURL: "https://a.example/home", Truncated: true, Body: []byte("<p>hi</p>")}
+	if err := c.PutFetch(ctx, "https://a.example/", want); err != nil {
+		t.Fatalf("PutFetch: %v", err)
+	}
+	got, ok, err := c.GetFetch(ctx, "https://a.example/")
+	if !ok || err != nil || got.ETag != want.ETag || got.LastModified != want.LastModified || got.ContentType != want.ContentType ||
+		got.FinalURL != want.FinalURL || !got.Truncated || string(got.Body) != string(want.Body) {
+		t.Fatalf("GetFetch = %+v, %v, %v", got, ok, err)
+	}
+
+	// Bodies past their TTL are not returned, and are pruned on the next put.
+	if _, err := c.db.Exec("UPDATE fetches SET fetched_at = fetched_at - ?", int64(fetchTTL.Seconds())+60); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.GetFetch(ctx, "https://a.example/"); ok {
+		t.Error("expired body returned")
+	}
+	c.PutFetch(ctx, "https://b.example/", Fetch{ETag: `"b"`, Body: []byte("b")})
+	var n int
+	c.db.QueryRow("SELECT COUNT(*) FROM fetches").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d stored bodies after pruning, want 1", n)
+	}
+
+	// A full flush drops stored bodies too.
+	if err := c.Clear(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.GetFetch(ctx, "https://b.example/"); ok {
+		t.Error("body survived a full flush")
+	}
+}
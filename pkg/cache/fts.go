@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSearchEncrypted is returned by SearchContent on a cache opened with an
+// encryption key. Encrypted content is not indexed, since the index would
+// hold it in the clear.
+var ErrSearchEncrypted = errors.New("cache: full-text search is unavailable when content is encrypted")
+
+// ftsSchema is schema version 2: an FTS5 index over each entry's query and
+// content, kept in step with the cache table by triggers so every write
+// path, pruning included, maintains it. Each entry records the rowid of its
+// index row in fts_rowid, so deleting an entry finds it directly; FTS5
+// rowids, unlike the cache table's, survive VACUUM. Reads that only count a
+// hit do not touch the index. Encrypted content is left out.
+func ftsSchema(tx *sql.Tx) error {
+	unsealed := fmt.Sprintf("NOT LIKE '%s%%'", sealedPrefix)
+	stmts := []string{
+		`ALTER TABLE cache ADD COLUMN fts_rowid INTEGER`,
+		`CREATE VIRTUAL TABLE cache_fts USING fts5(query_hash UNINDEXED, query, content)`,
+		`CREATE TRIGGER cache_fts_insert AFTER INSERT ON cache
+		 WHEN NEW.content ` + unsealed + ` BEGIN
+			INSERT INTO cache_fts (query_hash, query, content) VALUES (NEW.query_hash, NEW.query, NEW.content);
+			UPDATE cache SET fts_rowid = last_insert_rowid() WHERE query_hash = NEW.query_hash;
+		 END`,
+		`CREATE TRIGGER cache_fts_delete AFTER DELETE ON cache BEGIN
+			DELETE FROM cache_fts WHERE rowid = OLD.fts_rowid;
+		 END`,
+		`CREATE TRIGGER cache_fts_update AFTER UPDATE OF query, content ON cache BEGIN
+			DELETE FROM cache_fts WHERE rowid = OLD.fts_rowid;
+			INSERT INTO cache_fts (query_hash, query, content)
+			SELECT NEW.query_hash, NEW.query, NEW.content WHERE NEW.content ` + unsealed + `;
+			UPDATE cache SET fts_rowid = CASE WHEN NEW.content ` + unsealed + ` THEN last_insert_rowid() END
+			WHERE query_hash = NEW.query_hash;
+		 END`,
+		`INSERT INTO cache_fts (rowid, query_hash, query, content)
+		 SELECT rowid, query_hash, query, content FROM cache WHERE content ` + unsealed,
+		`UPDATE cache SET fts_rowid = rowid WHERE content ` + unsealed,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("cache: create full-text index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Match is a cached entry found by SearchContent.
+type Match struct {
+	QueryHash string
+	Query     string // query the entry was stored under; empty for old entries
+	Snippet   string // matching passage, with matched words in [brackets]
+	UpdatedAt time.Time
+	Stale     bool // past its expiry and not pinned
+}
+
+// snippetTokens is roughly how many words of context a Match.Snippet has.
+const snippetTokens = 48
+
+// SearchContent finds cached entries whose query or content contain every
+// word of q, best match first, without touching the network. Stale entries
+// are included and marked, since offline material is better than none.
+func (c *Cache) SearchContent(ctx context.Context, q string, limit int) ([]Match, error) {
+	if c.sealer != nil {
+		return nil, ErrSearchEncrypted
+	}
+	match := ftsQuery(q)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT f.query_hash, f.query, snippet(cache_fts, 2, '[', ']', '…', ?), c.updated_at, c.expires_at, c.pinned
+		FROM cache_fts f JOIN cache c ON c.query_hash = f.query_hash
+		WHERE cache_fts MATCH ?
+		ORDER BY rank
+		LIMIT ?`, snippetTokens, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("cache: search content: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		var expires string
+		var pinned bool
+		if err := rows.Scan(&m.QueryHash, &m.Query, &m.Snippet, &m.UpdatedAt, &expires, &pinned); err != nil {
+			return nil, fmt.Errorf("cache: search content: %w", err)
+		}
+		expiresAt, err := parseTime(expires)
+		if err != nil {
+			return nil, fmt.Errorf("cache: search content: %w", err)
+		}
+		m.Stale = !pinned && time.Now().After(expiresAt)
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache: search content: %w", err)
+	}
+	return matches, nil
+}
+
+// ftsQuery quotes each word of q as an FTS5 string, so user input is never
+// parsed as query syntax; the words are ANDed.
+func ftsQuery(q string) string {
+	var terms []string
+	for _, w := range strings.Fields(q) {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
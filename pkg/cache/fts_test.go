@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSearchContent(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	c.Set("mutex", "A sync.Mutex guards shared state between goroutines.", Meta{Query: "golang mutex"})
+	c.Set("chan", "Channels pass values between goroutines.", Meta{Query: "golang channels"})
+	c.Set("rust", "The borrow checker enforces ownership.", Meta{Query: "rust ownership"})
+
+	queries := func(ms []Match) []string {
+		var qs []string
+		for _, m := range ms {
+			qs = append(qs, m.Query)
+		}
+		return qs
+	}
+	got, err := c.SearchContent(ctx, "goroutines", 10)
+	if err != nil {
+		t.Fatalf("SearchContent: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("SearchContent(goroutines) = %q, want the two Go entries", queries(got))
+	}
+	if !strings.Contains(got[0].Snippet, "[goroutines]") || got[0].Stale {
+		t.Errorf("match = %+v, want a fresh entry with the word marked", got[0])
+	}
+
+	// Every word must match; query syntax in the input is taken literally.
+	if got, _ := c.SearchContent(ctx, `shared goroutines`, 10); len(got) != 1 || got[0].QueryHash != "mutex" {
+		t.Errorf("SearchContent(shared goroutines) = %q", queries(got))
+	}
+	if _, err := c.SearchContent(ctx, `"unbalanced AND OR (`, 10); err != nil {
+		t.Errorf("SearchContent with syntax characters: %v", err)
+	}
+	if got, _ := c.SearchContent(ctx, "ownership", 1); len(got) != 1 {
+		t.Errorf("limit 1: %d matches", len(got))
+	}
+
+	// Replacing, pruning and clearing entries keeps the index in step.
+	c.Set("chan", "Select waits on several operations.", Meta{})
+	c.Clear("rust")
+	if _, err := c.Prune(ctx, PruneOptions{Vacuum: true}); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if got, _ := c.SearchContent(ctx, "goroutines", 10); len(got) != 1 || got[0].QueryHash != "mutex" {
+		t.Errorf("after replace: SearchContent(goroutines) = %q", queries(got))
+	}
+	if got, _ := c.SearchContent(ctx, "select", 10); len(got) != 1 || got[0].Query != "golang channels" {
+		t.Errorf("after replace: SearchContent(select) = %q", queries(got))
+	}
+	if got, _ := c.SearchContent(ctx, "ownership", 10); len(got) != 0 {
+		t.Errorf("after clear: SearchContent(ownership) = %q", queries(got))
+	}
+	c.Set("new", "Fresh goroutines after a vacuum.", Meta{Query: "new"})
+	c.Clear("")
+	var indexed int
+	c.db.QueryRow("SELECT COUNT(*) FROM cache_fts").Scan(&indexed)
+	if indexed != 0 {
+		t.Errorf("%d index rows left after a full flush", indexed)
+	}
+}
+
+func TestSearchContentEncrypted(t *testing.T) {
+	c, err := Open(tempDB(t), Options{EncryptionKey: bytes.Repeat([]byte{1}, KeySize)})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("h", "secret goroutines", Meta{Query: "q"})
+	if _, err := c.SearchContent(context.Background(), "goroutines", 10); !errors.Is(err, ErrSearchEncrypted) {
+		t.Errorf("SearchContent: err = %v, want ErrSearchEncrypted", err)
+	}
+	var indexed int
+	c.db.QueryRow("SELECT COUNT(*) FROM cache_fts").Scan(&indexed)
+	if indexed != 0 {
+		t.Errorf("encrypted content was indexed")
+	}
+}
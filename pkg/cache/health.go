@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// integrityCheckInterval is how long a PRAGMA quick_check result is reused.
+// The check reads the whole database, so it is too slow to run on every
+// health probe.
+const integrityCheckInterval = 10 * time.Minute
+
+// Health describes the state of the cache database.
+type Health struct {
+	Integrity string    // "ok", or the problems PRAGMA quick_check reported
+	CheckedAt time.Time // when Integrity was last determined
+	SizeBytes int64     // database size, excluding the write-ahead log
+	FreeBytes int64     // space available on the database's volume; -1 if unknown
+}
+
+type integrityCheck struct {
+	result string
+	at     time.Time
+}
+
+// Health reports the database's size, the free space on its volume, and the
+// result of PRAGMA quick_check, rerun at most every integrityCheckInterval.
+func (c *Cache) Health(ctx context.Context) (Health, error) {
+	var h Health
+	var pages, pageSize int64
+	if err := c.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return h, fmt.Errorf("cache: page count: %w", err)
+	}
+	if err := c.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return h, fmt.Errorf("cache: page size: %w", err)
+	}
+	h.SizeBytes = pages * pageSize
+
+	h.FreeBytes = -1
+	if free, err := freeSpace(filepath.Dir(c.path)); err == nil {
+		h.FreeBytes = free
+	}
+
+	check, err := c.integrity(ctx)
+	if err != nil {
+		return h, err
+	}
+	h.Integrity, h.CheckedAt = check.result, check.at
+	return h, nil
+}
+
+// integrity returns the last quick_check result, running a new check when
+// it is stale.
+func (c *Cache) integrity(ctx context.Context) (integrityCheck, error) {
+	c.checkMu.Lock()
+	defer c.checkMu.Unlock()
+	if !c.lastCheck.at.IsZero() && time.Since(c.lastCheck.at) < integrityCheckInterval {
+		return c.lastCheck, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, "PRAGMA quick_check")
+	if err != nil {
+		return integrityCheck{}, fmt.Errorf("cache: quick check: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return integrityCheck{}, fmt.Errorf("cache: quick check: %w", err)
+		}
+		problems = append(problems, line)
+	}
+	if err := rows.Err(); err != nil {
+		return integrityCheck{}, fmt.Errorf("cache: quick check: %w", err)
+	}
+	c.lastCheck = integrityCheck{result: strings.Join(problems, "; "), at: time.Now()}
+	return c.lastCheck, nil
+}
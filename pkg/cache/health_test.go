@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	h, err := c.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if h.Integrity != "ok" {
+		t.Errorf("Integrity = %q, want ok", h.Integrity)
+	}
+	if h.SizeBytes <= 0 {
+		t.Errorf("SizeBytes = %d, want > 0", h.SizeBytes)
+	}
+	if runtime.GOOS == "linux" && h.FreeBytes <= 0 {
+		t.Errorf("FreeBytes = %d, want > 0", h.FreeBytes)
+	}
+	if time.Since(h.CheckedAt) > time.Minute {
+		t.Errorf("CheckedAt = %v, want recent", h.CheckedAt)
+	}
+
+	// The integrity check is reused within the interval.
+	again, err := c.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if !again.CheckedAt.Equal(h.CheckedAt) {
+		t.Errorf("CheckedAt = %v, want cached %v", again.CheckedAt, h.CheckedAt)
+	}
+}
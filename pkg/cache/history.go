@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// historySchema is schema version 4: a log of searches and fetches, newest
+// last, for long-running sessions that look back at what they asked.
+func historySchema(tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE history (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			at         TEXT NOT NULL,
+			scope      TEXT NOT NULL DEFAULT '',
+			kind       TEXT NOT NULL DEFAULT '',
+			query      TEXT NOT NULL,
+			site       TEXT NOT NULL DEFAULT '',
+			engine     TEXT NOT NULL DEFAULT '',
+			results    INTEGER NOT NULL DEFAULT 0,
+			from_cache INTEGER NOT NULL DEFAULT 0,
+			error      TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX history_scope ON history (scope, id)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("cache: create history table: %w", err)
+		}
+	}
+	return nil
+}
+
+// HistoryRecord is one search or fetch in the history.
+type HistoryRecord struct {
+	ID          int64 // assigned by AddHistory, increasing
+	Time        time.Time
+	Scope       string // the engine's key scope, to keep tenants apart
+	Kind        string // "search" or "fetch"
+	Query       string // the query as asked, or the URL of a fetch
+	Site        string // the site a site search was limited to
+	Engine      string // search engine used, "site" or "url"
+	ResultCount int
+	FromCache   bool
+	Error       string // why the call failed; empty if it succeeded
+}
+
+// HistoryFilter selects records for History.
+type HistoryFilter struct {
+	Scope string // only records of this scope
+	Match string // only records whose query contains it, ignoring ASCII case; empty matches all
+	Limit int    // most records returned; 0 means all
+}
+
+// AddHistory appends rec, ignoring its ID, and returns the ID assigned.
+// With keep > 0, records beyond the newest keep, of every scope, are
+// dropped.
+func (c *Cache) AddHistory(ctx context.Context, rec HistoryRecord, keep int) (int64, error) {
+	var id int64
+	err := c.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO history (at, scope, kind, query, site, engine, results, from_cache, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			formatTime(rec.Time), rec.Scope, rec.Kind, rec.Query, rec.Site, rec.Engine, rec.ResultCount, rec.FromCache, rec.Error)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if keep > 0 {
+			_, err = tx.ExecContext(ctx, "DELETE FROM history WHERE id <= ?", id-int64(keep))
+		}
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cache: add history: %w", err)
+	}
+	return id, nil
+}
+
+// History lists the records f selects, newest first.
+func (c *Cache) History(ctx context.Context, f HistoryFilter) ([]HistoryRecord, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite's no limit
+	}
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+historyColumns+` FROM history
+		WHERE scope = ? AND query LIKE ? ESCAPE '\'
+		ORDER BY id DESC
+		LIMIT ?`, f.Scope, "%"+escapeLike(f.Match)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("cache: history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []HistoryRecord
+	for rows.Next() {
+		rec, err := scanHistory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("cache: history: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache: history: %w", err)
+	}
+	return records, nil
+}
+
+// HistoryRecord returns the record with id, if it exists.
+func (c *Cache) HistoryRecord(ctx context.Context, id int64) (HistoryRecord, bool, error) {
+	row := c.db.QueryRowContext(ctx, "SELECT "+historyColumns+" FROM history WHERE id = ?", id)
+	rec, err := scanHistory(row)
+	if err == sql.ErrNoRows {
+		return HistoryRecord{}, false, nil
+	}
+	if err != nil {
+		return HistoryRecord{}, false, fmt.Errorf("cache: history record %d: %w", id, err)
+	}
+	return rec, true, nil
+}
+
+const historyColumns = "id, at, scope, kind, query, site, engine, results, from_cache, error"
+
+func scanHistory(row interface{ Scan(...any) error }) (HistoryRecord, error) {
+	var rec HistoryRecord
+	var at string
+	if err := row.Scan(&rec.ID, &at, &rec.Scope, &rec.Kind, &rec.Query, &rec.Site, &rec.Engine, &rec.ResultCount, &rec.FromCache, &rec.Error); err != nil {
+		return HistoryRecord{}, err
+	}
+	var err error
+	if rec.Time, err = parseTime(at); err != nil {
+		return HistoryRecord{}, err
+	}
+	return rec, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, with backslash as the escape.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
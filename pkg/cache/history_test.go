@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	add := func(rec HistoryRecord, keep int) int64 {
+		t.Helper()
+		rec.Time = now
+		id, err := c.AddHistory(ctx, rec, keep)
+		if err != nil {
+			t.Fatalf("AddHistory: %v", err)
+		}
+		return id
+	}
+	first := add(HistoryRecord{Kind: "search", Query: "golang generics", Engine: "google", ResultCount: 3}, 0)
+	add(HistoryRecord{Kind: "fetch", Query: "https://go.dev/", Engine: "url", ResultCount: 1, FromCache: true}, 0)
+	add(HistoryRecord{Kind: "search", Query: "100%_sure", Error: "no results"}, 0)
+	add(HistoryRecord{Scope: "other", Kind: "search", Query: "golang"}, 0)
+
+	got, err := c.History(ctx, HistoryFilter{})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(got) != 3 || got[0].Query != "100%_sure" || got[2].Query != "golang generics" {
+		t.Fatalf("History = %+v, want the unscoped records newest first", got)
+	}
+	if r := got[1]; r.Kind != "fetch" || r.Engine != "url" || !r.FromCache || r.ResultCount != 1 || !r.Time.Equal(now) {
+		t.Errorf("fetch record = %+v", r)
+	}
+
+	for match, want := range map[string]int{"GOLANG": 1, "%_": 1, "_": 1, "go": 2, "rust": 0} {
+		got, err := c.History(ctx, HistoryFilter{Match: match})
+		if err != nil || len(got) != want {
+			t.Errorf("History(%q) = %d records, %v; want %d", match, len(got), err, want)
+		}
+	}
+	if got, _ := c.History(ctx, HistoryFilter{Scope: "other", Limit: 5}); len(got) != 1 {
+		t.Errorf("scoped History = %+v, want one record", got)
+	}
+
+	rec, ok, err := c.HistoryRecord(ctx, first)
+	if err != nil || !ok || rec.Query != "golang generics" || rec.Engine != "google" {
+		t.Errorf("HistoryRecord(%d) = %+v, %v, %v", first, rec, ok, err)
+	}
+
+	add(HistoryRecord{Kind: "search", Query: "latest"}, 2)
+	if _, ok, _ := c.HistoryRecord(ctx, first); ok {
+		t.Error("oldest record kept past keep")
+	}
+	if got, _ := c.History(ctx, HistoryFilter{}); len(got) != 1 || got[0].Query != "latest" {
+		t.Errorf("after keep = 2: %+v, want latest only in this scope", got)
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrSchemaTooNew is returned by New for a database written by a newer
+// release, whose schema this one cannot safely use.
+var ErrSchemaTooNew = errors.New("cache: database schema is newer than this release")
+
+// migrations upgrade the schema one version at a time: migrations[i] takes
+// a database at version i to version i+1. To change the schema, append a
+// step; never edit or reorder released ones, since databases in the wild
+// have already run them.
+var migrations = []func(tx *sql.Tx) error{
+	baseSchema,       // 1: tables as of the first versioned release
+	ftsSchema,        // 2: full-text index over cached content
+	popularitySchema, // 3: lifetime hit counts and last read time
+	historySchema,    // 4: log of searches and fetches
+}
+
+// schemaVersion is the version New brings every database to.
+var schemaVersion = len(migrations)
+
+// migrate runs the migrations a database has not yet had and records the
+// version it ends at. A fresh database runs them all. It runs in New's
+// transaction, so a failed step leaves the database at its old version.
+func migrate(tx *sql.Tx) error {
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"); err != nil {
+		return fmt.Errorf("cache: create schema_version table: %w", err)
+	}
+	var version int
+	err := tx.QueryRow("SELECT version FROM schema_version").Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("cache: read schema version: %w", err)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("%w: version %d, want at most %d", ErrSchemaTooNew, version, schemaVersion)
+	}
+	if version == schemaVersion {
+		return nil
+	}
+	for v := version; v < schemaVersion; v++ {
+		if err := migrations[v](tx); err != nil {
+			return fmt.Errorf("cache: migrate to version %d: %w", v+1, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM schema_version"); err != nil {
+		return fmt.Errorf("cache: record schema version: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion); err != nil {
+		return fmt.Errorf("cache: record schema version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestMigrateRecordsVersion(t *testing.T) {
+	path := tempDB(t)
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var version int
+	if err := c.db.QueryRow("SELECT version FROM schema_version").Scan(&version); err != nil || version != schemaVersion {
+		t.Fatalf("schema version = %d, %v; want %d", version, err, schemaVersion)
+	}
+	c.Set("h", "content", Meta{})
+	c.Close()
+
+	// Reopening an up-to-date database keeps its rows and its version.
+	c, err = New(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer c.Close()
+	var rows int
+	c.db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&rows)
+	if rows != 1 {
+		t.Errorf("schema_version has %d rows, want 1", rows)
+	}
+	if _, hit, err := c.Get("h"); !hit || err != nil {
+		t.Errorf("Get after reopen = %v, %v", hit, err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := tempDB(t)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = db.Exec("CREATE TABLE schema_version (version INTEGER NOT NULL)")
+	if err == nil {
+		_, err = db.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion+1)
+	}
+	db.Close()
+	if err != nil {
+		t.Fatalf("write version: %v", err)
+	}
+
+	if c, err := New(path); !errors.Is(err, ErrSchemaTooNew) {
+		if err == nil {
+			c.Close()
+		}
+		t.Fatalf("New on a newer schema: err = %v, want ErrSchemaTooNew", err)
+	}
+}
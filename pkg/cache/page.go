@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const createPagesSQL = `
+	CREATE TABLE IF NOT EXISTS pages (
+		key        TEXT PRIMARY KEY,
+		page       BLOB NOT NULL,
+		expires_at INTEGER NOT NULL
+	);`
+
+// GetPage returns the scraped page stored under key, unless it has
+// expired. The page is opaque to the cache; the engine encodes it.
+func (c *Cache) GetPage(ctx context.Context, key string) ([]byte, bool, error) {
+	var page []byte
+	var expiresAt int64
+	err := c.db.QueryRowContext(ctx,
+		"SELECT page, expires_at FROM pages WHERE key = ?", key,
+	).Scan(&page, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("cache: get page %q: %w", key, err)
+	}
+	if time.Now().Unix() >= expiresAt {
+		return nil, false, nil
+	}
+	if page, err = c.sealer.open(page); err != nil {
+		return nil, false, fmt.Errorf("cache: get page %q: %w", key, err)
+	}
+	return page, true, nil
+}
+
+// PutPage stores a scraped page under key for ttl, replacing any earlier
+// one, and prunes pages that have expired.
+func (c *Cache) PutPage(ctx context.Context, key string, page []byte, ttl time.Duration) error {
+	const upsertSQL = `
+		INSERT INTO pages (key, page, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			page       = excluded.page,
+			expires_at = excluded.expires_at;`
+
+	now := time.Now()
+	page = c.sealer.seal(page)
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, upsertSQL, key, page, now.Add(ttl).Unix()); err != nil {
+			return fmt.Errorf("cache: put page %q: %w", key, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM pages WHERE expires_at <= ?", now.Unix()); err != nil {
+			return fmt.Errorf("cache: prune pages: %w", err)
+		}
+		return nil
+	})
+}
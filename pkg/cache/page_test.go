@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPageRoundTrip(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, ok, err := c.GetPage(ctx, "a"); ok || err != nil {
+		t.Fatalf("GetPage on empty cache = %v, %v", ok, err)
+	}
+	if err := c.PutPage(ctx, "a", []byte(`{"Title":"A"}`), time.Hour); err != nil {
+		t.Fatalf("PutPage: %v", err)
+	}
+	if got, ok, err := c.GetPage(ctx, "a"); !ok || err != nil || string(got) != `{"Title":"A"}` {
+		t.Fatalf("GetPage = %q, %v, %v", got, ok, err)
+	}
+
+	// Expired pages are not returned, and are pruned on the next put.
+	if _, err := c.db.Exec("UPDATE pages SET expires_at = expires_at - 7200"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.GetPage(ctx, "a"); ok {
+		t.Error("expired page returned")
+	}
+	c.PutPage(ctx, "b", []byte("b"), time.Hour)
+	var n int
+	c.db.QueryRow("SELECT COUNT(*) FROM pages").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d stored pages after pruning, want 1", n)
+	}
+
+	// A full flush drops stored pages too.
+	if err := c.Clear(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.GetPage(ctx, "b"); ok {
+		t.Error("page survived a full flush")
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when an operation targets a missing cache entry.
+var ErrNotFound = errors.New("cache entry not found")
+
+// ErrPinned is returned when deleting a pinned entry; unpin it first.
+var ErrPinned = errors.New("cache entry is pinned")
+
+// PinnedEntry describes a pinned cache entry.
+type PinnedEntry struct {
+	QueryHash string
+	Query     string
+	UpdatedAt time.Time
+	Size      int // content length in bytes
+}
+
+// Pin exempts an entry from TTL expiry and bulk eviction. query replaces the
+// one stored with the entry, so pinned entries are listed by the name they
+// were pinned under.
+func (c *Cache) Pin(queryHash, query string) error {
+	res, err := c.db.Exec("UPDATE cache SET pinned = 1, query = ? WHERE query_hash = ?", query, queryHash)
+	if err != nil {
+		return fmt.Errorf("cache: pin %q: %w", queryHash, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("cache: pin %q: %w", queryHash, ErrNotFound)
+	}
+	return nil
+}
+
+// Unpin returns an entry to normal TTL handling. An entry older than the
+// TTL becomes stale immediately.
+func (c *Cache) Unpin(queryHash string) error {
+	res, err := c.db.Exec("UPDATE cache SET pinned = 0 WHERE query_hash = ?", queryHash)
+	if err != nil {
+		return fmt.Errorf("cache: unpin %q: %w", queryHash, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("cache: unpin %q: %w", queryHash, ErrNotFound)
+	}
+	return nil
+}
+
+// Pinned lists pinned entries, most recently updated first.
+func (c *Cache) Pinned() ([]PinnedEntry, error) {
+	rows, err := c.db.Query(`
+		SELECT query_hash, query, updated_at, length(CAST(content AS BLOB))
+		FROM cache WHERE pinned = 1
+		ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("cache: list pinned: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []PinnedEntry
+	for rows.Next() {
+		var e PinnedEntry
+		if err := rows.Scan(&e.QueryHash, &e.Query, &e.UpdatedAt, &e.Size); err != nil {
+			return nil, fmt.Errorf("cache: list pinned: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache: list pinned: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestPinSurvivesTTLAndFlush(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	c.Set("keep", "reference material", Meta{})
+	c.Set("drop", "ephemeral", Meta{})
+	if err := c.Pin("keep", "go memory model"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	// Age both entries past the TTL.
+	if _, err := c.db.Exec("UPDATE cache SET updated_at = datetime('now', '-48 hours'), expires_at = datetime('now', '-24 hours')"); err != nil {
+		t.Fatalf("age entries: %v", err)
+	}
+	if _, hit, _ := c.Get("keep"); !hit {
+		t.Error("pinned entry should not expire")
+	}
+	if _, hit, _ := c.Get("drop"); hit {
+		t.Error("unpinned entry should expire")
+	}
+
+	if err := c.Clear(""); err != nil {
+		t.Fatalf("Clear all: %v", err)
+	}
+	if _, hit, _ := c.Get("keep"); !hit {
+		t.Error("pinned entry should survive a flush")
+	}
+	if err := c.Clear("keep"); !errors.Is(err, ErrPinned) {
+		t.Errorf("Clear pinned: err = %v, want ErrPinned", err)
+	}
+
+	// Refreshing keeps the pin.
+	c.Set("keep", "updated material", Meta{})
+	pins, err := c.Pinned()
+	if err != nil {
+		t.Fatalf("Pinned: %v", err)
+	}
+	if len(pins) != 1 || pins[0].Query != "go memory model" || pins[0].Size != len("updated material") {
+		t.Fatalf("Pinned = %+v", pins)
+	}
+
+	if err := c.Unpin("keep"); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+	if _, hit, _ := c.Get("keep"); !hit {
+		t.Error("freshly refreshed entry should still be a hit after unpinning")
+	}
+	if pins, _ := c.Pinned(); len(pins) != 0 {
+		t.Errorf("Pinned after Unpin = %+v", pins)
+	}
+	if err := c.Clear("keep"); err != nil {
+		t.Errorf("Clear after Unpin: %v", err)
+	}
+}
+
+func TestPinMissing(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Pin("nope", "q"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Pin: err = %v, want ErrNotFound", err)
+	}
+	if err := c.Unpin("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unpin: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMigratePinsOnOldSchema(t *testing.T) {
+	path := tempDB(t)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE cache (
+		query_hash TEXT PRIMARY KEY,
+		content    TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	); INSERT INTO cache (query_hash, content) VALUES ('old', 'legacy');`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New on old schema: %v", err)
+	}
+	defer c.Close()
+	if got, hit, err := c.Get("old"); err != nil || !hit || got != "legacy" {
+		t.Fatalf("Get legacy = %q, %v, %v", got, hit, err)
+	}
+	if err := c.Pin("old", "legacy query"); err != nil {
+		t.Errorf("Pin legacy entry: %v", err)
+	}
+}
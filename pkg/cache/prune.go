@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PruneOptions controls Prune.
+type PruneOptions struct {
+	// Grace keeps entries this long past expiry, for engines that serve
+	// stale content while refreshing it. Zero deletes them once expired.
+	Grace time.Duration
+	// Vacuum rebuilds the database file afterwards so the freed space is
+	// returned to the filesystem. It needs up to the file's size again in
+	// free disk space, and blocks other writers while it runs.
+	Vacuum bool
+}
+
+// PruneStats reports what Prune deleted.
+type PruneStats struct {
+	Entries int64 // expired, unpinned cache entries
+	Fetches int64 // page bodies past their revalidation TTL
+	Pages   int64 // expired scraped pages
+}
+
+// Prune deletes rows that reads already skip: unpinned entries past their
+// expiry (plus opts.Grace), stored page bodies older than a week and expired
+// scraped pages, in one transaction. Pinned entries are never deleted.
+func (c *Cache) Prune(ctx context.Context, opts PruneOptions) (PruneStats, error) {
+	var st PruneStats
+	now := time.Now()
+	cutoff := formatTime(now.Add(-opts.Grace))
+	err := c.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM cache WHERE pinned = 0 AND expires_at < ?", cutoff)
+		if err != nil {
+			return fmt.Errorf("cache: prune entries: %w", err)
+		}
+		st.Entries, _ = res.RowsAffected()
+		if res, err = tx.ExecContext(ctx, "DELETE FROM fetches WHERE fetched_at < ?", now.Add(-fetchTTL).Unix()); err != nil {
+			return fmt.Errorf("cache: prune fetches: %w", err)
+		}
+		st.Fetches, _ = res.RowsAffected()
+		if res, err = tx.ExecContext(ctx, "DELETE FROM pages WHERE expires_at <= ?", now.Unix()); err != nil {
+			return fmt.Errorf("cache: prune pages: %w", err)
+		}
+		st.Pages, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		c.log.WarnContext(ctx, "cache prune failed", "err", err)
+		return PruneStats{}, err
+	}
+	if opts.Vacuum {
+		if _, err := c.db.ExecContext(ctx, "VACUUM"); err != nil {
+			c.log.WarnContext(ctx, "cache vacuum failed", "err", err)
+			return st, fmt.Errorf("cache: vacuum: %w", err)
+		}
+	}
+	c.log.InfoContext(ctx, "cache pruned", "entries", st.Entries, "fetches", st.Fetches, "pages", st.Pages, "vacuum", opts.Vacuum, "duration", time.Since(now))
+	return st, nil
+}
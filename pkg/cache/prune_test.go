@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	for _, h := range []string{"fresh", "old", "older", "pinned"} {
+		if err := c.Set(h, "content", Meta{Query: h}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := c.Pin("pinned", "pinned"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	expired := func(h string, d time.Duration) {
+		t.Helper()
+		if _, err := c.db.Exec("UPDATE cache SET expires_at = ? WHERE query_hash = ?", formatTime(time.Now().Add(-d)), h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired("old", time.Hour)
+	expired("older", 48*time.Hour)
+	expired("pinned", 48*time.Hour)
+	if err := c.PutPage(ctx, "p", []byte("page"), time.Hour); err != nil {
+		t.Fatalf("PutPage: %v", err)
+	}
+	if _, err := c.db.Exec("UPDATE pages SET expires_at = 0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A day's grace keeps the entry that expired an hour ago.
+	st, err := c.Prune(ctx, PruneOptions{Grace: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if st.Entries != 1 || st.Pages != 1 {
+		t.Errorf("Prune with grace = %+v, want 1 entry and 1 page", st)
+	}
+
+	st, err = c.Prune(ctx, PruneOptions{Vacuum: true})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if st.Entries != 1 {
+		t.Errorf("Prune = %+v, want 1 entry", st)
+	}
+	var left []string
+	rows, err := c.db.Query("SELECT query_hash FROM cache ORDER BY query_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h string
+		rows.Scan(&h)
+		left = append(left, h)
+	}
+	if len(left) != 2 || left[0] != "fresh" || left[1] != "pinned" {
+		t.Errorf("left after prune = %v, want [fresh pinned]", left)
+	}
+}
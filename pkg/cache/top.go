@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// popularitySchema is schema version 3: lifetime read counts and the time
+// of the last read, which storing an entry again does not reset, unlike
+// hits. Existing entries start from their current hits.
+func popularitySchema(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE cache ADD COLUMN total_hits INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE cache ADD COLUMN last_hit_at TEXT NOT NULL DEFAULT ''`,
+		`UPDATE cache SET total_hits = hits`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("cache: add hit counters: %w", err)
+		}
+	}
+	return nil
+}
+
+// Top lists up to limit entries by lifetime reads, most read first and
+// most recently read among equals. Entries stored without their query are
+// left out, since they cannot be described. Content is not loaded.
+func (c *Cache) Top(ctx context.Context, limit int) ([]Entry, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT query_hash, query, engine, updated_at, expires_at, pinned, hits, total_hits, last_hit_at
+		FROM cache
+		WHERE query != ''
+		ORDER BY total_hits DESC, last_hit_at DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("cache: top: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var expires, lastHit string
+		if err := rows.Scan(&e.QueryHash, &e.Query, &e.Engine, &e.UpdatedAt, &expires, &e.Pinned, &e.Hits, &e.TotalHits, &lastHit); err != nil {
+			return nil, fmt.Errorf("cache: top: %w", err)
+		}
+		var err error
+		if e.ExpiresAt, err = parseTime(expires); err != nil {
+			return nil, fmt.Errorf("cache: top: %w", err)
+		}
+		if e.LastHitAt, err = parseTime(lastHit); err != nil {
+			return nil, fmt.Errorf("cache: top: %w", err)
+		}
+		e.Stale = !e.Pinned && time.Now().After(e.ExpiresAt)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache: top: %w", err)
+	}
+	return entries, nil
+}
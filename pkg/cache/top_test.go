@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTop(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	c.Set("a", "x", Meta{Query: "alpha"})
+	c.Set("b", "x", Meta{Query: "beta"})
+	c.Set("c", "x", Meta{Query: "gamma"})
+	c.Set("anon", "x", Meta{})
+	for range 3 {
+		c.Get("b")
+		c.Get("anon")
+	}
+	c.Get("a")
+
+	// Storing again restarts hits but keeps the lifetime count.
+	c.Set("b", "y", Meta{})
+	c.Get("b")
+
+	got, err := c.Top(ctx, 2)
+	if err != nil {
+		t.Fatalf("Top: %v", err)
+	}
+	if len(got) != 2 || got[0].Query != "beta" || got[1].Query != "alpha" {
+		t.Fatalf("Top = %+v, want beta then alpha", got)
+	}
+	if got[0].TotalHits != 4 || got[0].Hits != 1 || time.Since(got[0].LastHitAt) > time.Minute {
+		t.Errorf("beta: %d total hits, %d hits, last hit %v", got[0].TotalHits, got[0].Hits, got[0].LastHitAt)
+	}
+
+	e, _, _ := c.Lookup(ctx, "c")
+	if e.TotalHits != 0 || !e.LastHitAt.IsZero() {
+		t.Errorf("unread entry: %d total hits, last hit %v", e.TotalHits, e.LastHitAt)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// withTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Writes that touch more than one table, or more than one
+// row that must agree, go through here so a crash or error part way leaves
+// the database as it was rather than half updated. Errors from fn are
+// returned as is, so fn should prefix its own.
+func (c *Cache) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cache: begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cache: commit: %w", err)
+	}
+	return nil
+}
+
+// execer is the part of *sql.DB and *sql.Tx used to create the schema.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
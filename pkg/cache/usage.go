@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// UsageLimit caps how many units of a usage kind may be consumed within a
+// rolling time window.
+type UsageLimit struct {
+	Window time.Duration
+	Max    int
+}
+
+const createUsageSQL = `
+	CREATE TABLE IF NOT EXISTS usage (
+		kind TEXT    NOT NULL,
+		at   INTEGER NOT NULL,
+		n    INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS usage_kind_at ON usage (kind, at);`
+
+// Reserve atomically consumes up to n units of kind without exceeding any
+// of the given limits, and returns the number of units granted (possibly 0).
+// Usage is persisted, so budgets survive process restarts. Limits with a
+// non-positive Max are ignored; when none remain, n is granted without
+// recording anything, since rows nothing would ever prune are just growth.
+func (c *Cache) Reserve(kind string, n int, limits []UsageLimit) (int, error) {
+	return c.ReserveContext(context.Background(), kind, n, limits)
+}
+
+// ReserveContext is like Reserve but gives up when ctx is done, granting
+// nothing.
+func (c *Cache) ReserveContext(ctx context.Context, kind string, n int, limits []UsageLimit) (int, error) {
+	if !slices.ContainsFunc(limits, func(l UsageLimit) bool { return l.Max > 0 }) {
+		return n, nil
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	granted := n
+	var longest time.Duration
+	for _, l := range limits {
+		if l.Max <= 0 {
+			continue
+		}
+		if l.Window > longest {
+			longest = l.Window
+		}
+		var used int
+		err := tx.QueryRowContext(ctx,
+			"SELECT COALESCE(SUM(n), 0) FROM usage WHERE kind = ? AND at > ?",
+			kind, now.Add(-l.Window).Unix(),
+		).Scan(&used)
+		if err != nil {
+			return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+		}
+		granted = min(granted, l.Max-used)
+	}
+	if granted <= 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO usage (kind, at, n) VALUES (?, ?, ?)", kind, now.Unix(), granted); err != nil {
+		return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+	}
+	// Rows older than the longest window can never count again.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM usage WHERE kind = ? AND at <= ?", kind, now.Add(-longest).Unix()); err != nil {
+		return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+	}
+	return granted, nil
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	c, err := New(tempDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+
+	limits := []UsageLimit{
+		{Window: time.Hour, Max: 5},
+		{Window: 24 * time.Hour, Max: 100},
+	}
+
+	got, err := c.Reserve("serp:google", 3, limits)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if got != 3 {
+		t.Fatalf("granted = %d, want 3", got)
+	}
+
+	// Only 2 remain in the hourly window.
+	got, _ = c.Reserve("serp:google", 3, limits)
+	if got != 2 {
+		t.Fatalf("granted = %d, want 2 (partial)", got)
+	}
+
+	got, _ = c.Reserve("serp:google", 1, limits)
+	if got != 0 {
+		t.Fatalf("granted = %d, want 0 (exhausted)", got)
+	}
+
+	// Other kinds are tracked independently.
+	got, _ = c.Reserve("serp:duckduckgo", 1, limits)
+	if got != 1 {
+		t.Fatalf("granted = %d, want 1 for a different kind", got)
+	}
+
+	// No limits means unlimited.
+	got, _ = c.Reserve("serp:google", 50, nil)
+	if got != 50 {
+		t.Fatalf("granted = %d, want 50 with no limits", got)
+	}
+
+	// Unlimited reservations are not recorded.
+	c.Reserve("pages", 10, []UsageLimit{{Window: time.Hour, Max: 0}})
+	var rows int
+	if err := c.db.QueryRow("SELECT COUNT(*) FROM usage WHERE kind = 'pages'").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("usage rows = %d after unlimited reservation, want 0", rows)
+	}
+}
+
+func TestReservePersists(t *testing.T) {
+	path := tempDB(t)
+	limits := []UsageLimit{{Window: time.Hour, Max: 2}}
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.Reserve("pages", 2, limits)
+	c.Close()
+
+	c, err = New(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer c.Close()
+
+	got, err := c.Reserve("pages", 1, limits)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if got != 0 {
+		t.Fatalf("granted = %d after restart, want 0", got)
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one search for the audit log: who asked, what was
+// asked, and every page fetched on the caller's behalf.
+type AuditRecord struct {
+	Time      time.Time   `json:"time"`
+	SearchID  uint64      `json:"search_id"`
+	Key       string      `json:"key,omitempty"` // fingerprint of the caller's API key, never the key itself
+	Query     string      `json:"query"`
+	Site      string      `json:"site,omitempty"`
+	FromCache bool        `json:"from_cache,omitempty"`
+	Pages     []AuditPage `json:"pages,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// AuditPage is one page fetch in an AuditRecord.
+type AuditPage struct {
+	URL   string `json:"url"`
+	Error string `json:"error,omitempty"`
+}
+
+// Auditor records searches. Config.Auditor enables it; Guardrails can make
+// it mandatory.
+type Auditor interface {
+	Audit(ctx context.Context, rec AuditRecord) error
+}
+
+// AuditorFunc adapts a function to Auditor.
+type AuditorFunc func(ctx context.Context, rec AuditRecord) error
+
+// Audit calls f.
+func (f AuditorFunc) Audit(ctx context.Context, rec AuditRecord) error {
+	return f(ctx, rec)
+}
+
+// NewJSONAuditor returns an Auditor writing each record to w as one line
+// of JSON. Writes are serialized, so w need not be safe for concurrent
+// use.
+func NewJSONAuditor(w io.Writer) Auditor {
+	var mu sync.Mutex
+	return AuditorFunc(func(_ context.Context, rec AuditRecord) error {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, err = w.Write(append(line, '\n'))
+		return err
+	})
+}
+
+// audit writes the record of a finished search, if an Auditor is set. The
+// returned error replaces the search's when Guardrails.RequireAudit is set
+// and is dropped otherwise.
+func (e *Engine) audit(ctx context.Context, run *eventRun, opts SearchOptions, result SearchResult, searchErr error) error {
+	if e.config.Auditor == nil {
+		return nil
+	}
+	rec := AuditRecord{
+		Time:      time.Now(),
+		SearchID:  run.id,
+		Key:       keyFingerprint(opts.Key),
+		Query:     run.query,
+		Site:      opts.Site,
+		FromCache: result.FromCache,
+	}
+	for _, p := range run.fetched() {
+		ap := AuditPage{URL: p.URL}
+		if p.Err != nil {
+			ap.Error = p.Err.Error()
+		}
+		rec.Pages = append(rec.Pages, ap)
+	}
+	if searchErr != nil {
+		rec.Error = searchErr.Error()
+	}
+	// Record even when the caller has gone away: the pages were fetched.
+	err := e.config.Auditor.Audit(context.WithoutCancel(ctx), rec)
+	if err != nil && e.config.Guardrails != nil && e.config.Guardrails.RequireAudit {
+		return fmt.Errorf("engine: audit: %w: %w", ErrGuardrail, err)
+	}
+	return nil
+}
+
+// keyFingerprint identifies an API key in logs without revealing it.
+func keyFingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%x", h[:6])
+}
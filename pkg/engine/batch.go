@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// DefaultBatchConcurrency is how many SearchBatch queries run at once when
+// Config.BatchConcurrency is 0.
+const DefaultBatchConcurrency = 4
+
+// BatchResult is the outcome of one query of a SearchBatch.
+type BatchResult struct {
+	Query  string
+	Result SearchResult
+	Err    error
+}
+
+// SearchBatch searches each query with opts, up to
+// Config.BatchConcurrency at a time, returning one BatchResult per query in
+// order. It suits agents that split a task into sub-questions whose
+// results overlap: a page found by several queries is fetched once and
+// reused by the others, marked Cached in their Pages and trimmed to the
+// paragraphs that mention their own query, and a query repeated in the
+// batch is searched once. The searches are otherwise ordinary ones:
+// they share the engine's rate limiter and count against its budgets, and
+// each fails on its own without stopping the rest.
+func (e *Engine) SearchBatch(ctx context.Context, queries []string, opts SearchOptions) []BatchResult {
+	out := make([]BatchResult, len(queries))
+	if len(queries) == 0 {
+		return out
+	}
+	ctx = context.WithValue(ctx, batchKey{}, &batchPages{})
+
+	first := make(map[string]int, len(queries)) // query to its first index
+	sem := make(chan struct{}, e.batchConcurrency())
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		out[i].Query = q
+		if _, ok := first[q]; ok {
+			continue
+		}
+		first[q] = i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				out[i].Err = fmt.Errorf("engine: batch: %w", ctx.Err())
+				return
+			}
+			out[i].Result, out[i].Err = e.SearchWithOptions(ctx, q, opts)
+		}()
+	}
+	wg.Wait()
+	for i, q := range queries {
+		if j := first[q]; j != i {
+			out[i].Result, out[i].Err = out[j].Result, out[j].Err
+		}
+	}
+	return out
+}
+
+func (e *Engine) batchConcurrency() int {
+	if e.config.BatchConcurrency <= 0 {
+		return DefaultBatchConcurrency
+	}
+	return e.config.BatchConcurrency
+}
+
+type batchKey struct{}
+
+// batchPages holds the pages fetched by one SearchBatch, so each URL is
+// fetched once however many of its queries find it.
+type batchPages struct {
+	mu    sync.Mutex
+	pages map[string]*batchPage
+}
+
+// batchPage is a page one query of the batch is fetching; done closes once
+// page is set.
+type batchPage struct {
+	done chan struct{}
+	page scraper.ScrapedPage
+}
+
+// batchFrom returns the pages of the SearchBatch ctx belongs to, or nil.
+func batchFrom(ctx context.Context) *batchPages {
+	b, _ := ctx.Value(batchKey{}).(*batchPages)
+	return b
+}
+
+// claim returns url's entry and whether the caller is the first to ask for
+// it, and so must fetch it and call set.
+func (b *batchPages) claim(url string) (*batchPage, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bp, ok := b.pages[url]; ok {
+		return bp, false
+	}
+	if b.pages == nil {
+		b.pages = make(map[string]*batchPage)
+	}
+	bp := &batchPage{done: make(chan struct{})}
+	b.pages[url] = bp
+	return bp, true
+}
+
+func (bp *batchPage) set(p scraper.ScrapedPage) {
+	bp.page = p
+	close(bp.done)
+}
+
+// wait returns the page once the query fetching it is done.
+func (bp *batchPage) wait(ctx context.Context, url string) scraper.ScrapedPage {
+	select {
+	case <-bp.done:
+		return bp.page
+	case <-ctx.Done():
+		return scraper.ScrapedPage{URL: url, Err: fmt.Errorf("engine: wait for %s: %w", url, ctx.Err())}
+	}
+}
+
+// trimToQuery keeps the paragraphs of a page's text that contain one of
+// terms, each with the nearest heading above it, and whole sections under
+// headings that contain one, so a page shared across a batch carries what
+// the query reusing it asked about. Code blocks count as one paragraph.
+// The text is returned whole if no paragraph matches or the kept ones are
+// shorter than minChars, since the search still ranked the page for the
+// query.
+func trimToQuery(content string, terms []string, minChars int) string {
+	if len(terms) == 0 {
+		return content
+	}
+	var (
+		blocks  []string
+		current []string
+		inFence bool
+	)
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+
+	keep := make([]bool, len(blocks))
+	heading, inMatch, matched := -1, false, false
+	for i, b := range blocks {
+		isHeading := strings.HasPrefix(b, "#") && !strings.Contains(b, "\n")
+		if isHeading {
+			heading, inMatch = i, false
+		}
+		if inMatch || containsTerm(b, terms) {
+			keep[i], matched = true, true
+			inMatch = inMatch || isHeading
+			if heading >= 0 {
+				keep[heading] = true
+			}
+		}
+	}
+	if !matched {
+		return content
+	}
+	var kept []string
+	for i, b := range blocks {
+		if keep[i] {
+			kept = append(kept, b)
+		}
+	}
+	trimmed := strings.Join(kept, "\n\n")
+	if len(trimmed) < minChars {
+		return content
+	}
+	return trimmed
+}
+
+// containsTerm reports whether s contains one of terms, ignoring case.
+func containsTerm(s string, terms []string) bool {
+	lower := strings.ToLower(s)
+	for _, t := range terms {
+		if strings.Contains(lower, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// ErrCircuitOpen means a search engine or site failed too often in a row
+// and is being skipped until its cooldown ends.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Breaker defaults, used when BreakerPolicy leaves them at zero.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = time.Minute
+)
+
+// BreakerPolicy sets when a search engine or site is skipped. After
+// Threshold consecutive failures its breaker opens: requests to it fail at
+// once with ErrCircuitOpen for Cooldown, after which one request is let
+// through to probe it. A success closes the breaker; a failure reopens it
+// for another Cooldown.
+//
+// A search whose engine's breaker is open falls back to the other engine,
+// and fails only if both are open. Result pages on an open site fail
+// without a fetch, leaving the rest of the search's pages unaffected.
+// Failures are errors other than the caller's own cancellation: for
+// engines, blocks, bad statuses and network errors; for sites, network
+// errors, timeouts and 5xx responses, but not 4xx, robots.txt or noindex.
+type BreakerPolicy struct {
+	Threshold int           // consecutive failures that open a breaker; 0 uses DefaultBreakerThreshold
+	Cooldown  time.Duration // how long an open breaker skips requests; 0 uses DefaultBreakerCooldown
+}
+
+func (p *BreakerPolicy) threshold() int {
+	if p.Threshold <= 0 {
+		return DefaultBreakerThreshold
+	}
+	return p.Threshold
+}
+
+func (p *BreakerPolicy) cooldown() time.Duration {
+	if p.Cooldown <= 0 {
+		return DefaultBreakerCooldown
+	}
+	return p.Cooldown
+}
+
+// breakers tracks consecutive failures per key, "engine:" plus a canonical
+// engine name or "site:" plus a site.
+type breakers struct {
+	mu    sync.Mutex
+	state map[string]*breaker
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time // zero while closed
+}
+
+// allow reports whether a request for key may go ahead. Once an open
+// breaker's cooldown ends, one caller is let through and the breaker
+// stays open for the rest until that caller reports back.
+func (b *breakers) allow(p *BreakerPolicy, key string, now time.Time) bool {
+	if p == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.state[key]
+	if s == nil || s.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(s.openUntil) {
+		return false
+	}
+	s.openUntil = now.Add(p.cooldown())
+	return true
+}
+
+// report records the outcome of a request for key, returning true if it
+// opened the breaker.
+func (b *breakers) report(p *BreakerPolicy, key string, failed bool, now time.Time) bool {
+	if p == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		delete(b.state, key)
+		return false
+	}
+	if b.state == nil {
+		b.state = make(map[string]*breaker)
+	}
+	s := b.state[key]
+	if s == nil {
+		s = &breaker{}
+		b.state[key] = s
+	}
+	s.failures++
+	if s.failures < p.threshold() {
+		return false
+	}
+	wasClosed := s.openUntil.IsZero()
+	s.openUntil = now.Add(p.cooldown())
+	return wasClosed
+}
+
+// open returns the open breakers and when each cools down.
+func (b *breakers) open(now time.Time) map[string]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]time.Time)
+	for key, s := range b.state {
+		if !s.openUntil.IsZero() && now.Before(s.openUntil) {
+			out[key] = s.openUntil
+		}
+	}
+	return out
+}
+
+// pickEngine returns engine, or the other engine if engine's breaker is
+// open and the other's is not.
+func (e *Engine) pickEngine(engine string) (string, error) {
+	p, now := e.config.Breaker, time.Now()
+	if e.circuits.allow(p, "engine:"+search.CanonicalEngine(engine), now) {
+		return engine, nil
+	}
+	engine = search.CanonicalEngine(engine)
+	alt := search.EngineDuckDuckGo
+	if engine == search.EngineDuckDuckGo {
+		alt = search.EngineGoogle
+	}
+	if e.circuits.allow(p, "engine:"+alt, now) {
+		e.logger().Info("search engine skipped", "engine", engine, "fallback", alt)
+		return alt, nil
+	}
+	return "", fmt.Errorf("engine: search engines %s and %s: %w", engine, alt, ErrCircuitOpen)
+}
+
+// reportEngine records a results page request to engine.
+func (e *Engine) reportEngine(ctx context.Context, engine string, err error) {
+	if ctx.Err() != nil {
+		return // the caller gave up; the engine may be fine
+	}
+	engine = search.CanonicalEngine(engine)
+	if e.circuits.report(e.config.Breaker, "engine:"+engine, err != nil, time.Now()) {
+		e.logger().Warn("circuit opened", "engine", engine, "cooldown", e.config.Breaker.cooldown(), "err", err)
+	}
+}
+
+// siteOpen returns ErrCircuitOpen if rawURL's site is being skipped.
+func (e *Engine) siteOpen(rawURL string) error {
+	site := siteKey(rawURL)
+	if e.circuits.allow(e.config.Breaker, "site:"+site, time.Now()) {
+		return nil
+	}
+	return fmt.Errorf("engine: %s: %w", site, ErrCircuitOpen)
+}
+
+// reportSite records a page fetch from p's site.
+func (e *Engine) reportSite(ctx context.Context, p scraper.ScrapedPage) {
+	if ctx.Err() != nil {
+		return
+	}
+	site := siteKey(p.URL)
+	if e.circuits.report(e.config.Breaker, "site:"+site, siteFailure(p), time.Now()) {
+		e.logger().Warn("circuit opened", "site", site, "cooldown", e.config.Breaker.cooldown(), "err", p.Err)
+	}
+}
+
+// siteFailure reports whether p failed in a way that suggests its site is
+// down, rather than that the page is missing or off limits.
+func siteFailure(p scraper.ScrapedPage) bool {
+	switch {
+	case p.Err == nil:
+		return false
+	case errors.Is(p.Err, scraper.ErrRobotsDisallowed), errors.Is(p.Err, scraper.ErrNoindex), errors.Is(p.Err, scraper.ErrPrivateAddress):
+		return false
+	}
+	return p.Status == 0 || p.Status >= 500
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/search"
+)
+
+// ErrBudgetExhausted is returned when a request would exceed a configured
+// macro budget. Callers should back off until the rolling window frees up.
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// Budget caps upstream traffic over rolling windows. Usage is persisted in
+// the cache database and shared by every entry point using that database.
+// Zero values mean unlimited. The per-engine maps accept aliases such as
+// "ddg"; names that are not engines are ignored rather than taken for
+// Google.
+type Budget struct {
+	SearchesPerHour map[string]int // max SERP requests per engine per rolling hour
+	SearchesPerDay  map[string]int // max SERP requests per engine per rolling day
+	PagesPerDay     int            // max scraped pages per rolling day, across engines
+}
+
+const pagesUsageKind = "pages"
+
+// reserveSearch consumes one SERP request from the engine's budget.
+func (e *Engine) reserveSearch(ctx context.Context, engine string) error {
+	b := e.config.Budget
+	engine = search.CanonicalEngine(engine)
+	limits := []cache.UsageLimit{
+		{Window: time.Hour, Max: b.SearchesPerHour[engine]},
+		{Window: 24 * time.Hour, Max: b.SearchesPerDay[engine]},
+	}
+	granted, err := e.cache.ReserveContext(ctx, "serp:"+engine, 1, limits)
+	if err != nil {
+		return fmt.Errorf("engine: %w", err)
+	}
+	if granted == 0 {
+		return fmt.Errorf("engine: %s search budget: %w", engine, ErrBudgetExhausted)
+	}
+	return nil
+}
+
+// reservePages consumes up to n pages from the daily scrape budget and
+// returns how many may be scraped.
+func (e *Engine) reservePages(ctx context.Context, n int) (int, error) {
+	limits := []cache.UsageLimit{{Window: 24 * time.Hour, Max: e.config.Budget.PagesPerDay}}
+	granted, err := e.cache.ReserveContext(ctx, pagesUsageKind, n, limits)
+	if err != nil {
+		return 0, fmt.Errorf("engine: %w", err)
+	}
+	if granted == 0 {
+		return 0, fmt.Errorf("engine: page budget: %w", ErrBudgetExhausted)
+	}
+	return granted, nil
+}
+
+// canonicalCounts returns m keyed by canonical engine name, so budgets are
+// looked up directly, along with the names left out as not engines.
+func canonicalCounts(m map[string]int) (map[string]int, []string) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]int, len(m))
+	var unknown []string
+	for name, n := range m {
+		if !validEngineName(name) {
+			unknown = append(unknown, name)
+			continue
+		}
+		out[search.CanonicalEngine(name)] = n
+	}
+	return out, unknown
+}
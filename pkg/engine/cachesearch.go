@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// Cache search limits for SearchCache.
+const (
+	DefaultCacheSearchLimit = 10
+	MaxCacheSearchLimit     = 50
+)
+
+// ErrNoCacheSearch means the engine's Store does not implement
+// ContentSearcher.
+var ErrNoCacheSearch = errors.New("store does not support full-text search")
+
+// SearchCache answers query from material already in the cache, without
+// searching the web: it returns the cached entries whose query or content
+// contain every word of query, best first, with a matching snippet each.
+// limit 0 uses DefaultCacheSearchLimit and larger values are capped to
+// MaxCacheSearchLimit. With a KeyScope, entries stored by other scopes, and
+// site searches, whose keys the stored query does not derive, are left out.
+func (e *Engine) SearchCache(ctx context.Context, query string, limit int) ([]cache.Match, error) {
+	cs, ok := e.cache.(ContentSearcher)
+	if !ok {
+		return nil, fmt.Errorf("engine: %w", ErrNoCacheSearch)
+	}
+	if limit <= 0 {
+		limit = DefaultCacheSearchLimit
+	}
+	limit = min(limit, MaxCacheSearchLimit)
+	matches, err := cs.SearchContent(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	if e.config.KeyScope == "" {
+		return matches, nil
+	}
+	kept := matches[:0]
+	for _, m := range matches {
+		if e.key(m.Query) == m.QueryHash {
+			kept = append(kept, m)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// citeParagraphs ends every paragraph of section text with a marker such
+// as " [S2]", where 2 is the section's 1-based index in Sources, so answers
+// built from the text can attribute each claim. Headers, metadata lines,
+// separators, the instant answer, code blocks and table rows get no marker.
+func citeParagraphs(content string) string {
+	lines := strings.Split(content, "\n")
+	section := 0
+	inHeader, inFence := false, false
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			inFence = !inFence
+			continue
+		case inFence:
+			continue
+		case strings.HasPrefix(line, "## "):
+			section++
+			inHeader = true
+			continue
+		case section == 0 || strings.TrimSpace(line) == "" || line == "---":
+			continue
+		case inHeader && hasMetaLabel(line):
+			continue
+		}
+		inHeader = false
+		if strings.HasPrefix(trimmed, "|") {
+			continue
+		}
+		// Mark the last line of the paragraph.
+		if i+1 == len(lines) || strings.TrimSpace(lines[i+1]) == "" {
+			lines[i] = fmt.Sprintf("%s [S%d]", line, section)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,26 @@
+package engine
+
+import "fmt"
+
+// CountLimits lets a front end (the HTTP API, the MCP server) narrow the
+// engine's result count defaults for its own clients. Zero fields defer to
+// Config.DefaultCount and Config.MaxCount.
+type CountLimits struct {
+	Default int // count used when the client does not ask for one
+	Max     int // larger client counts are rejected
+}
+
+// Resolve validates a client-supplied count, where 0 means unset, and
+// returns the count to pass in SearchOptions. The engine still applies its
+// own cap to the result.
+func (l CountLimits) Resolve(n int) (int, error) {
+	switch {
+	case n < 0:
+		return 0, fmt.Errorf("count must be positive, got %d", n)
+	case n == 0:
+		return l.Default, nil
+	case l.Max > 0 && n > l.Max:
+		return 0, fmt.Errorf("count %d exceeds the maximum of %d", n, l.Max)
+	}
+	return n, nil
+}
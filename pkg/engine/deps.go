@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Store persists consolidated results and budget usage. *cache.Cache
+// implements it; enginetest.MemoryStore is an in-memory stand-in. Methods
+// on the search path take the request context so a caller that has already
+// given up does not wait on the database.
+type Store interface {
+	GetContext(ctx context.Context, queryHash string) (string, bool, error)
+	SetContext(ctx context.Context, queryHash, content string, meta cache.Meta) error
+	Clear(queryHash string) error
+	Pin(queryHash, query string) error
+	Unpin(queryHash string) error
+	Pinned() ([]cache.PinnedEntry, error)
+	ReserveContext(ctx context.Context, kind string, n int, limits []cache.UsageLimit) (int, error)
+}
+
+// HealthChecker is implemented by stores that can report on their own
+// storage. *cache.Cache does.
+type HealthChecker interface {
+	Health(ctx context.Context) (cache.Health, error)
+}
+
+// EntryReader is implemented by stores that can return whole entries, with
+// their metadata and even past their TTL. Cache hits report the metadata,
+// and Config.ServeStale needs it. *cache.Cache does.
+type EntryReader interface {
+	Lookup(ctx context.Context, queryHash string) (cache.Entry, bool, error)
+}
+
+// ExpiringLister is implemented by stores that can list popular entries
+// nearing expiry, which Config.Refresh needs. *cache.Cache does.
+type ExpiringLister interface {
+	Expiring(ctx context.Context, within time.Duration, minHits int) ([]cache.Entry, error)
+}
+
+// PageStore is implemented by stores that can keep scraped pages for reuse
+// by later searches, which Config.PageTTL needs. Pages are opaque bytes to
+// the store. *cache.Cache does.
+type PageStore interface {
+	GetPage(ctx context.Context, key string) ([]byte, bool, error)
+	PutPage(ctx context.Context, key string, page []byte, ttl time.Duration) error
+}
+
+// ContentSearcher is implemented by stores with a full-text index over
+// cached content, which SearchCache needs. *cache.Cache does.
+type ContentSearcher interface {
+	SearchContent(ctx context.Context, q string, limit int) ([]cache.Match, error)
+}
+
+// PopularityLister is implemented by stores that count reads over each
+// entry's lifetime, which TopQueries needs. *cache.Cache does.
+type PopularityLister interface {
+	Top(ctx context.Context, limit int) ([]cache.Entry, error)
+}
+
+// Searcher fetches result links for a query from the named search engine.
+type Searcher interface {
+	Search(ctx context.Context, query string, count int, engine string) ([]search.Result, *search.InstantAnswer, error)
+}
+
+// SearcherFunc adapts a function to Searcher.
+type SearcherFunc func(ctx context.Context, query string, count int, engine string) ([]search.Result, *search.InstantAnswer, error)
+
+// Search calls f.
+func (f SearcherFunc) Search(ctx context.Context, query string, count int, engine string) ([]search.Result, *search.InstantAnswer, error) {
+	return f(ctx, query, count, engine)
+}
+
+// Scraper fetches and extracts result pages, returning one ScrapedPage per
+// URL in order.
+type Scraper interface {
+	Scrape(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage
+}
+
+// ScraperFunc adapts a function to Scraper.
+type ScraperFunc func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage
+
+// Scrape calls f.
+func (f ScraperFunc) Scrape(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+	return f(ctx, urls, opts)
+}
+
+// Service is the engine surface used by the HTTP and MCP servers. *Engine
+// implements it; enginetest.Fake is a deterministic double.
+type Service interface {
+	SearchWithOptions(ctx context.Context, query string, opts SearchOptions) (SearchResult, error)
+	SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(StreamUpdate)) (SearchResult, error)
+	FetchWithOptions(ctx context.Context, rawURL string, opts SearchOptions) (SearchResult, error)
+	DeepResearch(ctx context.Context, query string, opts ResearchOptions) (ResearchReport, error)
+	ClearCache(query string) error
+	Pin(ctx context.Context, query string) error
+	Unpin(query string) error
+	Pinned() ([]cache.PinnedEntry, error)
+	Stats() Stats
+	SelfCheck(ctx context.Context, engines []string, probes int) ([]search.CheckResult, error)
+	Health(ctx context.Context) (cache.Health, error)
+	SearchCache(ctx context.Context, query string, limit int) ([]cache.Match, error)
+	TopQueries(ctx context.Context, limit int) ([]cache.Entry, error)
+	SearchHistory(ctx context.Context, match string, limit int) ([]cache.HistoryRecord, error)
+	ReplayHistory(ctx context.Context, id int64, opts SearchOptions) (SearchResult, error)
+}
+
+var _ Service = (*Engine)(nil)
+
+func (e *Engine) searcher() Searcher {
+	if e.config.Searcher != nil {
+		return e.config.Searcher
+	}
+	return SearcherFunc(func(ctx context.Context, query string, count int, engine string) ([]search.Result, *search.InstantAnswer, error) {
+		return search.SearchWithLimiter(ctx, e.limiter, query, count, engine)
+	})
+}
+
+func (e *Engine) scraper() Scraper {
+	if e.config.Scraper != nil {
+		return e.config.Scraper
+	}
+	return ScraperFunc(scraper.ScrapeWithOptions)
+}
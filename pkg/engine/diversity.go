@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/user/glsi/pkg/search"
+)
+
+// diversityOverfetch is how many SERP candidates are requested per wanted
+// result when a per-host cap is active, so capped hosts can be backfilled.
+const diversityOverfetch = 3
+
+// maxPerHost resolves the per-host cap for a call; 0 means unlimited.
+func (e *Engine) maxPerHost(opts SearchOptions) int {
+	if opts.MaxPerHost > 0 {
+		return opts.MaxPerHost
+	}
+	return e.config.MaxPerHost
+}
+
+// stripTracking removes tracking parameters from result URLs, so the same
+// page is scraped, cached and shown under one URL, and drops results that
+// then repeat an earlier one.
+func stripTracking(results []search.Result) []search.Result {
+	seen := make(map[string]bool)
+	out := make([]search.Result, 0, len(results))
+	for _, r := range results {
+		r.URL = search.StripTracking(r.URL)
+		if key := dedupKey(r.URL); !seen[key] {
+			seen[key] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// diversify keeps results in SERP order, skipping any whose site already has
+// maxPerHost results, until count results are selected. If the candidates
+// run out first, fewer than count are returned.
+func diversify(results []search.Result, count, maxPerHost int) []search.Result {
+	if maxPerHost <= 0 {
+		if len(results) > count {
+			results = results[:count]
+		}
+		return results
+	}
+	perSite := make(map[string]int)
+	var out []search.Result
+	for _, r := range results {
+		if len(out) >= count {
+			break
+		}
+		site := siteKey(r.URL)
+		if perSite[site] >= maxPerHost {
+			continue
+		}
+		perSite[site]++
+		out = append(out, r)
+	}
+	return out
+}
+
+// siteKey groups URLs by registrable domain, so blog.example.com and
+// www.example.com count against the same cap.
+func siteKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
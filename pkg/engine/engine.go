@@ -0,0 +1,1027 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Errors returned by SearchWithOptions, wrapped with query details.
+var (
+	// ErrNoResults means the search engine returned no results.
+	ErrNoResults = errors.New("no search results")
+	// ErrScrapeFailed means every result page failed to scrape or was empty.
+	ErrScrapeFailed = errors.New("all pages failed to scrape")
+	// ErrNoSummarizer means summarization was requested but none is configured.
+	ErrNoSummarizer = errors.New("no summarizer configured")
+	// ErrSummarizeFailed means the summarizer returned an error.
+	ErrSummarizeFailed = errors.New("summarization failed")
+)
+
+// Result count defaults, used when Config leaves them at zero.
+const (
+	DefaultCount    = 5  // results scraped when a call does not ask for a count
+	DefaultMaxCount = 20 // hard cap on the results scraped per call
+)
+
+// MaxScrapeTimeout is the largest per-call SearchOptions.ScrapeTimeout that
+// front ends accept from clients.
+const MaxScrapeTimeout = 60 * time.Second
+
+// MaxTimeout is the largest per-call SearchOptions.Timeout that front ends
+// accept from clients.
+const MaxTimeout = 5 * time.Minute
+
+// Summarizer condenses consolidated content into a compact answer.
+type Summarizer interface {
+	Summarize(ctx context.Context, query, content string) (string, error)
+}
+
+// Config holds engine-level configuration.
+type Config struct {
+	SearchEngine     string                   // "google" or "duckduckgo"
+	RateLimit        time.Duration            // default delay between requests to the same search engine
+	RateLimits       map[string]time.Duration // per-engine overrides of RateLimit, e.g. {"google": 2 * time.Second}
+	RateBurst        int                      // SERP requests to one engine let through back to back before its rate limit applies; 0 or 1 allows none
+	Budget           Budget                   // macro request budgets; zero means unlimited
+	MaxPerHost       int                      // max scraped results per site; 0 means unlimited
+	IncludeSponsored bool                     // scrape sponsored SERP results, tagged in Sources; false drops them
+	DefaultCount     int                      // results scraped when SearchOptions.Count is 0; 0 uses DefaultCount
+	MaxCount         int                      // larger counts are capped to this; 0 uses DefaultMaxCount, negative means no cap
+	MaxSections      int                      // most sections consolidated from the scraped pages, keeping the best; 0 means no cap
+	MaxContentBytes  int                      // largest consolidated content stored, shortening every page by the same share; 0 means no cap
+	Depth            int                      // default SearchOptions.Depth: 1 follows relevant links on result pages, 0 does not
+	FollowBudget     int                      // most linked pages followed per search; 0 uses DefaultFollowBudget, negative disables
+	BatchConcurrency int                      // SearchBatch queries run at once; 0 uses DefaultBatchConcurrency
+	ScrapeTimeout    time.Duration            // per-page fetch timeout; 0 uses scraper.DefaultTimeout
+	HostDelay        time.Duration            // pause between fetches from one host; 0 uses scraper.DefaultHostDelay, negative disables
+	ScrapeRetries    int                      // refetches of transiently failing pages; 0 uses scraper.DefaultRetries, negative disables
+	MaxBodyBytes     int64                    // bytes read per page; 0 uses scraper.DefaultMaxBodyBytes, negative means unlimited
+	GitHubToken      string                   // token for GitHub API reads of repo, file and issue URLs; empty reads anonymously
+	ArchiveFallback  bool                     // refetch paywalled, consent-walled and geo-blocked pages from the Wayback Machine
+	ArchiveDeadLinks bool                     // read pages that are gone (404, 410, refused, unresolvable) from the Wayback Machine
+	GeoProxy         string                   // proxy in another region for geo-blocked pages, tried before the archive
+	ScrapeProxy      string                   // proxy for page fetches, or scraper.ProxyDirect; empty follows HTTPS_PROXY/HTTP_PROXY
+	DomainProxies    map[string]string        // per-domain overrides of ScrapeProxy
+	ScrapeCookies    bool                     // keep cookies across redirects and pages within one call's scrape
+	ConditionalFetch bool                     // revalidate refetched pages with ETag/Last-Modified; needs a Store implementing scraper.FetchCache
+	RespectNoindex   bool                     // leave out pages marked noindex or noarchive; always on with Guardrails.RespectRobots
+	ServeStale       bool                     // return expired entries flagged Stale and refresh them in the background; needs a Store implementing EntryReader
+	PageTTL          time.Duration            // reuse scraped pages this long across searches, Force included; 0 disables; needs a Store implementing PageStore
+	History          bool                     // record every search and fetch for SearchHistory and ReplayHistory; needs a Store implementing HistoryStore
+	HistoryLimit     int                      // most history records kept, oldest dropped first; 0 uses DefaultHistoryLimit
+
+	// AllowPrivate lets pages be fetched from loopback, private and
+	// link-local addresses. By default they are refused, so links in
+	// search results cannot reach internal services; AllowedNets exempts
+	// particular internal networks instead. See scraper.Options.BlockPrivate.
+	AllowPrivate bool
+	AllowedNets  []netip.Prefix
+
+	Extractor         string            // default extraction backend ("readability", "density", "auto" or a registered name)
+	DomainExtractors  map[string]string // per-domain extraction backend overrides
+	FallbackExtractor string            // backend tried when extraction comes up short; empty uses scraper.ExtractorDensity
+	MinContentChars   int               // text length below which the fallback runs; 0 uses scraper.DefaultMinContentChars, negative disables
+	MinPageChars      int               // text length below which a result page is skipped and a further result scraped instead; 0 disables
+
+	Renderer scraper.Renderer // headless browser for JS-heavy pages; nil disables rendering
+	Render   string           // default render mode ("never", "auto", "always")
+
+	Refresh *RefreshPolicy // keeps popular entries warm when RunRefresher runs; nil disables it
+
+	Features *Features // gates risky capabilities per deployment and API key; nil leaves them at their defaults
+
+	// Keys derives cache keys from queries; nil uses SHA256Keys. KeyScope,
+	// such as a tenant ID or salt, is mixed into every key, so engines
+	// sharing one Store under different scopes never read each other's
+	// entries. Pinned and a full ClearCache still span the whole Store.
+	Keys     KeyDeriver
+	KeyScope string
+
+	Output string // default page text format ("text", "markdown")
+
+	// SectionTemplate, if set, renders each section of results asked for
+	// in the default content format, in place of the "## Title — URL"
+	// header, metadata lines and "---" separators; see SectionData.
+	// Content is still cached in that layout, and Sources parsed from it,
+	// so the template applies to cache hits too and can change freely.
+	SectionTemplate *template.Template
+
+	Summarizer Summarizer // used when SearchOptions.Summarize is set; nil disables it
+
+	// Classifier, if set, labels each query with an Intent before the
+	// search, and IntentRoutes fills the options the caller left unset
+	// from the intent's route. Nil IntentRoutes uses DefaultIntentRoutes.
+	Classifier   Classifier
+	IntentRoutes map[Intent]IntentRoute
+
+	// Guardrails, if set, are compliance limits no call can override.
+	// Auditor, if set, records every search; Guardrails can require it.
+	Guardrails *Guardrails
+	Auditor    Auditor
+
+	Breaker *BreakerPolicy // skips search engines and sites after repeated failures; nil disables
+
+	Hooks []Hook // callbacks run at fixed points of every search, in order
+
+	Metrics Metrics      // counts searches and pages and times each stage; nil records nothing
+	Logger  *slog.Logger // logs each search and page, and failures the pipeline otherwise absorbs; nil logs nothing
+
+	Searcher Searcher      // source of result links; nil uses the search package
+	Scraper  Scraper       // page fetcher; nil uses the scraper package
+	Sitemaps SitemapReader // sitemap source for SearchOptions.Site; nil uses the scraper package
+}
+
+// SearchOptions holds per-call parameters for SearchWithOptions.
+type SearchOptions struct {
+	Count      int    // number of results to scrape; 0 uses Config.DefaultCount
+	Force      bool   // bypass the cache
+	Engine     string // search engine for this call; empty uses Config.SearchEngine
+	Extractor  string // extraction backend for this call; empty uses Config.Extractor
+	Render     string // render mode for this call; empty uses Config.Render
+	Output     string // page text format for this call; empty uses Config.Output
+	Format     string // layout of Content: ContentMarkdown (the default), ContentPlain or ContentJSON; ignored with Summarize
+	MaxPerHost int    // max results per site for this call; 0 uses Config.MaxPerHost
+
+	// Site, a host or URL such as "go.dev" or "https://example.com/docs/",
+	// searches that one site instead of the web: the pages in its sitemap
+	// whose paths match the query are scraped, best match first. Results
+	// are cached apart from web searches for the same query.
+	Site string
+
+	// Depth 1 also scrapes the most relevant same-site links on the result
+	// pages, up to Config.FollowBudget of them, so thin landing pages lead
+	// to the real content. 0 uses Config.Depth; negative disables.
+	Depth int
+
+	// FollowLinks caps the links a Depth 1 call follows below
+	// Config.FollowBudget; 0 follows the full budget.
+	FollowLinks int
+
+	// MaxSections caps the sections consolidated for this call, keeping
+	// the highest-quality pages; 0 uses Config.MaxSections. Count still
+	// sets how many pages are scraped, so a larger Count over-fetches to
+	// ride out failed or thin pages without growing the output.
+	MaxSections int
+
+	ScrapeTimeout time.Duration // per-page fetch timeout for this call; 0 uses Config.ScrapeTimeout
+	Timeout       time.Duration // time limit for the whole call, on top of ctx's deadline; nearing it returns a Partial result
+	TTL           time.Duration // how long the result stays cached; 0 uses the intent route's, or the Store's default
+
+	// MaxAge accepts a cached result only if it was stored less than
+	// MaxAge ago; an older one is searched again and replaced, as with
+	// Force. 0 accepts any entry the cache still holds.
+	MaxAge time.Duration
+
+	Cite      bool // end each paragraph of Content with an [Sn] marker, n indexing Sources from 1
+	Summarize bool // condense Content with Config.Summarizer; the cache keeps the full text
+
+	Key string // caller's API key, for per-key feature flags; empty for deployment-wide flags
+}
+
+// SearchResult holds the output of a search pipeline run.
+type SearchResult struct {
+	Content     string       // consolidated text from scraped pages
+	ResultCount int          // number of pages successfully scraped
+	FromCache   bool         // true if the result was served from cache
+	Stale       bool         // served from cache past its TTL while a refresh runs in the background
+	Partial     bool         // the deadline neared mid-scrape: Content holds only the pages finished in time and was not cached
+	Engine      string       // search engine the links came from, "site" for site searches or "url" for Fetch; empty for entries cached before it was kept
+	URLs        []string     // pages consolidated into Content, in order; nil for entries cached before they were kept
+	CachedAt    time.Time    // when a cached result was stored; zero for fresh results
+	Summarized  bool         // true if Content is a summary of the consolidated text
+	Intent      Intent       // the query's intent, if Config.Classifier is set
+	Sources     []Source     // metadata and text summary of each section
+	Pages       []PageInfo   // per-page details of a fresh scrape; nil for cache hits
+	Failed      []FailedPage // pages of a fresh scrape left out as unreadable or too short, with why; nil for cache hits
+	Timings     Timings      // where the call spent its time
+}
+
+// Timings breaks down the time a search call took. Phases a call skipped,
+// such as everything after a cache hit, are zero.
+type Timings struct {
+	SERP          time.Duration // search engine request and parsing (or sitemap read), excluding RateLimitWait
+	RateLimitWait time.Duration // waiting for the search engine's rate limit
+	Scrape        time.Duration // fetching and extracting result pages
+	Consolidate   time.Duration // selecting and joining sections
+	Cache         time.Duration // cache lookup and write
+	Total         time.Duration // the whole call, including summarization
+}
+
+// PageInfo describes the outcome of scraping one result page.
+type PageInfo struct {
+	URL          string
+	Published    scraper.PublishDate // zero if no date could be determined
+	Timings      scraper.Timings
+	Status       int    // HTTP status of the page's last response, 0 if none
+	Bytes        int64  // body bytes read
+	Words        int    // words of text extracted
+	Blocked      string // scraper.BlockPaywall or BlockConsent if a wall hid the page
+	Archive      string // Wayback Machine snapshot used instead, if any
+	Via          string // scraper.ViaGeoProxy or ViaArchive if a blocked or dead page was read another way
+	Revalidated  bool   // the server answered 304 and a stored body was reused
+	Cached       bool   // reused from the page cache, or from another query of the same SearchBatch, without a fetch
+	FinalURL     string // where redirects ended, if known and different from URL
+	Trimmed      bool   // scraped fine but cut by the section cap
+	Truncated    bool   // text shortened to fit Config.MaxContentBytes
+	FollowedFrom string // result page whose link led here, for pages found by link following
+	Duplicate    bool   // same final URL as a higher-ranked page, so left out
+	Skipped      bool   // text shorter than Config.MinPageChars, so left out
+	Err          error
+}
+
+// Engine orchestrates the search → scrape → cache pipeline.
+type Engine struct {
+	cache    Store
+	config   Config
+	limiter  *search.RateLimiter // paces this engine's SERP requests
+	events   eventBus            // lifecycle events for Subscribe
+	circuits breakers            // per engine and site, for Config.Breaker
+
+	refreshMu  sync.Mutex
+	refreshing map[string]bool // cache keys with a background refresh running
+}
+
+// New creates a new Engine with the given cache and configuration. Each
+// Engine paces its own SERP requests with a token bucket per search
+// engine, shared by its concurrent calls; engines in one process do not
+// share rate limits.
+func New(c Store, cfg Config) *Engine {
+	hourly, unknownHourly := canonicalCounts(cfg.Budget.SearchesPerHour)
+	daily, unknownDaily := canonicalCounts(cfg.Budget.SearchesPerDay)
+	cfg.Budget.SearchesPerHour, cfg.Budget.SearchesPerDay = hourly, daily
+	e := &Engine{cache: c, config: cfg, limiter: search.NewBurstRateLimiter(cfg.rateLimits(), cfg.RateBurst)}
+	for name := range cfg.RateLimits {
+		if !validEngineName(name) {
+			e.logger().Warn("rate limit for unknown search engine ignored", "engine", name)
+		}
+	}
+	for _, name := range append(unknownHourly, unknownDaily...) {
+		e.logger().Warn("search budget for unknown search engine ignored", "engine", name)
+	}
+	return e
+}
+
+// count resolves a requested result count against DefaultCount and
+// MaxCount.
+func (c Config) count(n int) int {
+	if n <= 0 {
+		n = c.DefaultCount
+		if n <= 0 {
+			n = DefaultCount
+		}
+	}
+	limit := c.MaxCount
+	if limit == 0 {
+		limit = DefaultMaxCount
+	}
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	return n
+}
+
+// validEngineName reports whether name is a search engine or alias; unlike
+// search.ValidEngine it does not accept the empty default.
+func validEngineName(name string) bool {
+	return strings.TrimSpace(name) != "" && search.ValidEngine(name)
+}
+
+// rateLimits resolves RateLimit and RateLimits into a per-engine map.
+// Names that are not engines are left out rather than taken for Google.
+func (c Config) rateLimits() map[string]time.Duration {
+	limits := map[string]time.Duration{
+		search.EngineGoogle:     c.RateLimit,
+		search.EngineDuckDuckGo: c.RateLimit,
+	}
+	for name, d := range c.RateLimits {
+		if validEngineName(name) {
+			limits[search.CanonicalEngine(name)] = d
+		}
+	}
+	return limits
+}
+
+// Search executes the full pipeline: hash → cache check → search → scrape →
+// consolidate → upsert → return.
+//
+// If force is true the cache is bypassed and a fresh scrape is performed.
+func (e *Engine) Search(ctx context.Context, query string, count int, force bool) (SearchResult, error) {
+	return e.SearchWithOptions(ctx, query, SearchOptions{Count: count, Force: force})
+}
+
+// SearchWithOptions is like Search but takes per-call options. Options that
+// affect extraction only apply to fresh scrapes, not to cache hits;
+// citation markers and summarization apply to both. Markers are added
+// before summarizing, so the summary can carry them through. If ctx's
+// deadline nears while pages are still being scraped, the result holds the
+// pages finished in time and is marked Partial. Each call emits lifecycle
+// events to Subscribe's subscribers.
+func (e *Engine) SearchWithOptions(ctx context.Context, query string, opts SearchOptions) (SearchResult, error) {
+	return e.searchWithOptions(ctx, query, opts, nil)
+}
+
+// searchWithOptions is SearchWithOptions, passing progress to stream if it
+// is not nil.
+func (e *Engine) searchWithOptions(ctx context.Context, query string, opts SearchOptions, stream func(StreamUpdate)) (result SearchResult, err error) {
+	start := time.Now()
+	opts, intent := e.route(query, opts)
+	run := e.startRun(query, opts)
+	run.stream = stream
+	defer func() {
+		result.Timings.Total = time.Since(start)
+		if aerr := e.audit(ctx, run, opts, result, err); aerr != nil {
+			result, err = SearchResult{}, aerr
+		}
+		e.recordHistory(ctx, run, opts, result, err)
+		run.finish(result, err)
+	}()
+
+	if query, opts, err = e.preSearch(ctx, query, opts); err != nil {
+		return SearchResult{}, err
+	}
+	if !search.ValidEngine(opts.Engine) {
+		return SearchResult{}, fmt.Errorf("engine: unknown search engine %q", opts.Engine)
+	}
+	if opts.Site != "" {
+		if err := scraper.ValidSite(opts.Site); err != nil {
+			return SearchResult{}, fmt.Errorf("engine: %w", err)
+		}
+		if !e.config.Features.Enabled(FeatureCrawlSite, opts.Key) {
+			return SearchResult{}, fmt.Errorf("engine: site search %s: %w", opts.Site, ErrFeatureDisabled)
+		}
+	}
+
+	if opts.Summarize && e.config.Summarizer == nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", ErrNoSummarizer)
+	}
+	if !ValidContentFormat(opts.Format) {
+		return SearchResult{}, fmt.Errorf("engine: unknown content format %q", opts.Format)
+	}
+	if opts.Render != "" && opts.Render != scraper.RenderNever && !e.config.Features.Enabled(FeatureRender, opts.Key) {
+		return SearchResult{}, fmt.Errorf("engine: render %s: %w", opts.Render, ErrFeatureDisabled)
+	}
+	sctx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	result, err = e.search(sctx, query, opts, run)
+	if err != nil {
+		return result, err
+	}
+	result.Intent = intent
+	return e.finishContent(ctx, query, opts, result)
+}
+
+// finishContent applies the options that shape a result's content after
+// it is cached: source markers, then a summary, the content format or
+// Config.SectionTemplate.
+func (e *Engine) finishContent(ctx context.Context, query string, opts SearchOptions, result SearchResult) (SearchResult, error) {
+	if opts.Cite {
+		result.Content = citeParagraphs(result.Content)
+	}
+	if opts.Summarize {
+		return e.summarize(ctx, query, result)
+	}
+	var (
+		content string
+		err     error
+	)
+	if opts.Format == "" && e.config.SectionTemplate != nil {
+		content, err = templateContent(e.config.SectionTemplate, query, result.Content)
+	} else {
+		content, err = formatContent(result.Content, opts.Format)
+	}
+	if err != nil {
+		return SearchResult{}, err
+	}
+	result.Content = content
+	return result, nil
+}
+
+// summarize replaces result's content with Config.Summarizer's summary of
+// it for query.
+func (e *Engine) summarize(ctx context.Context, query string, result SearchResult) (SearchResult, error) {
+	summary, err := e.config.Summarizer.Summarize(ctx, query, result.Content)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w: %w", ErrSummarizeFailed, err)
+	}
+	result.Content = summary
+	result.Summarized = true
+	return result, nil
+}
+
+// search runs the cache → search → scrape → consolidate pipeline. The
+// context is checked between phases, so a caller whose deadline has passed
+// does not spend budget or upstream requests on results it cannot receive.
+func (e *Engine) search(ctx context.Context, query string, opts SearchOptions, run *eventRun) (SearchResult, error) {
+	hash := e.key(cacheQuery(query, opts))
+	run.setHash(hash)
+	count := e.config.count(opts.Count)
+	var tm Timings
+
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	if err := e.guardQuery(query); err != nil {
+		return SearchResult{}, err
+	}
+
+	// 1. Cache check (skip when force is set). A stale entry is returned
+	// as is while a background search replaces it.
+	if !opts.Force {
+		cacheStart := time.Now()
+		entry, hit, err := e.lookup(ctx, hash, opts.MaxAge)
+		tm.Cache = time.Since(cacheStart)
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("engine: cache get: %w", err)
+		}
+		if hit {
+			if entry.Stale {
+				e.refresh(ctx, hash, query, opts)
+			}
+			run.emit(Event{Kind: EventCacheHit})
+			result := cachedResult(entry)
+			result.Timings = tm
+			return result, nil
+		}
+	}
+
+	// 2. Search — scrape search-engine results page, or for a site search
+	// read the site's sitemap.
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	var (
+		results    []search.Result
+		answer     *search.InstantAnswer
+		usedEngine = siteEngine
+	)
+	if opts.Site != "" {
+		serpStart := time.Now()
+		var err error
+		results, err = e.siteResults(ctx, query, opts, count+e.spares(count))
+		tm.SERP = time.Since(serpStart)
+		if err != nil {
+			return SearchResult{}, err
+		}
+	} else {
+		engineName := e.config.SearchEngine
+		if opts.Engine != "" {
+			engineName = opts.Engine
+		}
+		engineName, err := e.pickEngine(engineName)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		usedEngine = search.CanonicalEngine(engineName)
+		if err := e.reserveSearch(ctx, engineName); err != nil {
+			return SearchResult{}, err
+		}
+		// With a per-host cap, over-fetch candidates to backfill capped sites.
+		maxPerHost := e.maxPerHost(opts)
+		candidates := count + e.spares(count)
+		if maxPerHost > 0 {
+			candidates *= diversityOverfetch
+		}
+		var wait search.WaitRecorder
+		serpStart := time.Now()
+		results, answer, err = e.searcher().Search(search.WithLogger(search.WithWaitRecorder(ctx, &wait), run.log), query, candidates, engineName)
+		e.reportEngine(ctx, engineName, err)
+		tm.RateLimitWait = wait.Total()
+		tm.SERP = time.Since(serpStart) - tm.RateLimitWait
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("engine: search: %w", err)
+		}
+		if !e.config.IncludeSponsored {
+			results = search.Organic(results)
+		}
+		results = diversify(stripTracking(results), count+e.spares(count), maxPerHost)
+	}
+	results, err := e.postSearch(ctx, query, results)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if len(results) == 0 {
+		return SearchResult{}, fmt.Errorf("engine: %w for %q", ErrNoResults, query)
+	}
+	// Results past count are spares, scraped only in place of thin pages.
+	var spares []search.Result
+	if len(results) > count {
+		results, spares = results[:count:count], results[count:]
+	}
+
+	// 3. Scrape all result URLs concurrently, within the page budget.
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	allowed, err := e.reservePages(ctx, len(results))
+	if err != nil {
+		return SearchResult{}, err
+	}
+	results = results[:allowed]
+	run.emit(Event{Kind: EventSERPParsed, Results: len(results)})
+	urls := make([]string, len(results))
+	sponsored := make(map[string]bool)
+	for i, r := range results {
+		urls[i] = r.URL
+	}
+	for _, r := range append(results, spares...) {
+		if r.Sponsored {
+			sponsored[r.URL] = true
+		}
+	}
+	run.streamResults(results, sponsored)
+	scrapeStart := time.Now()
+	scrapeCtx, cancel := scrapeContext(ctx)
+	defer cancel()
+	pages := e.scrape(scrapeCtx, urls, opts, run)
+	pages, err = e.replaceThin(scrapeCtx, pages, spares, opts, run)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	followed, followedFrom, err := e.follow(scrapeCtx, query, pages, opts, run)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	followStart := len(pages)
+	pages = append(pages, followed...)
+	tm.Scrape = time.Since(scrapeStart)
+
+	// 4. Consolidate into a single text block. Pages that failed because
+	// the deadline passed are the caller's timeout, not a scrape failure.
+	// If only the scrapes ran out of time, the pages that finished are
+	// returned as a partial result.
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	partial := scrapeCtx.Err() != nil
+	consolidateStart := time.Now()
+	skipped := e.thinPages(pages)
+	dups := duplicates(pages, skipped)
+	dropped := maps.Clone(dups)
+	maps.Copy(dropped, skipped)
+	kept, trimmed := selectSections(pages, dropped, sponsored, e.maxSections(opts))
+	var prefix string
+	if answer != nil {
+		prefix = formatInstantAnswer(answer)
+	}
+	limit := e.config.MaxContentBytes
+	if limit > 0 {
+		limit = max(limit-len(prefix), 1)
+	}
+	content, resultCount, truncated := fitContent(kept, sponsored, limit)
+	if content == "" && partial {
+		return SearchResult{}, fmt.Errorf("engine: %w", context.DeadlineExceeded)
+	}
+	if content == "" {
+		return SearchResult{}, fmt.Errorf("engine: %w for %q", ErrScrapeFailed, query)
+	}
+	content, err = e.postConsolidate(ctx, query, prefix+content)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	tm.Consolidate = time.Since(consolidateStart)
+
+	// 5. Upsert into cache. Partial results are not cached; the pages that
+	// finished are in the page cache, if enabled, for the next try.
+	var keptURLs []string
+	for _, p := range kept {
+		if usable(p) {
+			keptURLs = append(keptURLs, pageURL(p))
+		}
+	}
+	if !partial {
+		meta := cache.Meta{Query: query, Engine: usedEngine, ResultCount: resultCount, URLs: keptURLs, TTL: opts.TTL}
+		cacheStart := time.Now()
+		if err := e.cache.SetContext(ctx, hash, content, meta); err != nil {
+			return SearchResult{}, fmt.Errorf("engine: cache set: %w", err)
+		}
+		tm.Cache += time.Since(cacheStart)
+		run.emit(Event{Kind: EventCacheWrite})
+	}
+
+	infos := make([]PageInfo, len(pages))
+	for i, p := range pages {
+		infos[i] = pageInfo(p)
+		infos[i].Cached = run.wasCached(p.URL)
+		infos[i].Trimmed = trimmed[i]
+		infos[i].Truncated = truncated[p.URL] && !trimmed[i] && !dropped[i]
+		infos[i].Duplicate = dups[i]
+		infos[i].Skipped = skipped[i]
+	}
+	for i, from := range followedFrom {
+		infos[followStart+i].FollowedFrom = from
+	}
+
+	return SearchResult{
+		Content:     content,
+		ResultCount: resultCount,
+		FromCache:   false,
+		Partial:     partial,
+		Engine:      usedEngine,
+		URLs:        keptURLs,
+		Sources:     parseSources(content),
+		Pages:       infos,
+		Failed:      failedPages(pages, infos),
+		Timings:     tm,
+	}, nil
+}
+
+// siteEngine is SearchResult.Engine for site searches.
+const siteEngine = "site"
+
+// lookup reads the entry cached under hash. A Store implementing
+// EntryReader supplies the entry's metadata, and with Config.ServeStale an
+// expired entry is a hit marked Stale rather than a miss. Other stores only
+// supply the content of fresh entries. With maxAge, entries stored longer
+// ago are misses; since other stores cannot tell an entry's age, every
+// lookup in them misses.
+func (e *Engine) lookup(ctx context.Context, hash string, maxAge time.Duration) (cache.Entry, bool, error) {
+	er, ok := e.cache.(EntryReader)
+	if !ok {
+		if maxAge > 0 {
+			return cache.Entry{}, false, nil
+		}
+		content, hit, err := e.cache.GetContext(ctx, hash)
+		return cache.Entry{Content: content}, hit, err
+	}
+	entry, ok, err := er.Lookup(ctx, hash)
+	if err != nil || !ok || (entry.Stale && !e.config.ServeStale) {
+		return cache.Entry{}, false, err
+	}
+	if maxAge > 0 && time.Since(entry.UpdatedAt) > maxAge {
+		return cache.Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// cachedResult builds the result of a cache hit. Entries stored before
+// their metadata was kept get their section count from the content.
+func cachedResult(entry cache.Entry) SearchResult {
+	count := entry.ResultCount
+	if count == 0 {
+		count = countSections(entry.Content)
+	}
+	return SearchResult{
+		Content:     entry.Content,
+		ResultCount: count,
+		FromCache:   true,
+		Stale:       entry.Stale,
+		Engine:      entry.Engine,
+		URLs:        entry.URLs,
+		CachedAt:    entry.UpdatedAt,
+		Sources:     parseSources(entry.Content),
+	}
+}
+
+// scrape fetches urls, emitting EventPageScraped as each page finishes.
+// Scrapers that ignore scraper.Options.OnPage get their events once the
+// whole batch returns.
+func (e *Engine) scrape(ctx context.Context, urls []string, opts SearchOptions, run *eventRun) []scraper.ScrapedPage {
+	var (
+		mu       sync.Mutex
+		reported = make([]bool, len(urls))
+	)
+	report := func(i int, p scraper.ScrapedPage) {
+		mu.Lock()
+		seen := reported[i]
+		reported[i] = true
+		mu.Unlock()
+		if !seen {
+			info := pageInfo(p)
+			info.Cached = run.wasCached(p.URL)
+			run.record(info)
+			run.countPage(info)
+			run.logPage(info)
+			run.emit(Event{Kind: EventPageScraped, Page: &info})
+			run.streamPage(p, info)
+		}
+	}
+
+	// Pages over a guardrail fail without a fetch and pages in the page
+	// cache are reused, as are pages another query of the same batch is
+	// fetching; the rest are scraped together and put back in place.
+	sopts := e.scrapeOptions(opts)
+	sopts.Logger = run.log
+	ps, cachePages := e.pageStore()
+	batch := batchFrom(ctx)
+	pages := make([]scraper.ScrapedPage, len(urls))
+	var (
+		fetch  []int // indexes into urls
+		owned  = make(map[int]*batchPage)
+		shared = make(map[int]*batchPage)
+	)
+	for i, u := range urls {
+		if err := e.preScrape(ctx, u); err != nil {
+			pages[i] = scraper.ScrapedPage{URL: u, Err: err}
+			report(i, pages[i])
+			continue
+		}
+		if err := e.siteOpen(u); err != nil {
+			pages[i] = scraper.ScrapedPage{URL: u, Err: err}
+			report(i, pages[i])
+			continue
+		}
+		if err := e.guardPage(ctx, u); err != nil {
+			pages[i] = scraper.ScrapedPage{URL: u, Err: err}
+			report(i, pages[i])
+			continue
+		}
+		if cachePages {
+			if p, ok := e.cachedPage(ctx, ps, u, sopts); ok {
+				pages[i] = p
+				run.markCached(u)
+				report(i, p)
+				continue
+			}
+		}
+		if batch != nil {
+			bp, first := batch.claim(u)
+			if !first {
+				shared[i] = bp
+				continue
+			}
+			owned[i] = bp
+		}
+		fetch = append(fetch, i)
+	}
+	if len(fetch) > 0 {
+		fetchURLs := make([]string, len(fetch))
+		for j, i := range fetch {
+			fetchURLs[j] = urls[i]
+		}
+		sopts.OnPage = func(j int, p scraper.ScrapedPage) { report(fetch[j], p) }
+		for j, p := range e.scraper().Scrape(ctx, fetchURLs, sopts) {
+			i := fetch[j]
+			pages[i] = p
+			if bp := owned[i]; bp != nil {
+				bp.set(p)
+			}
+			report(i, p)
+			e.reportSite(ctx, p)
+			if cachePages {
+				e.storePage(ctx, ps, p, sopts)
+			}
+		}
+	}
+	// Waiting only after this call's own fetches are shared means two
+	// queries that each fetch a page the other needs cannot deadlock. A
+	// page fetched for another query is trimmed to what this one asked.
+	for i, bp := range shared {
+		p := bp.wait(ctx, urls[i])
+		if p.Err == nil {
+			run.markCached(urls[i])
+			p.Content = trimToQuery(p.Content, queryTerms(run.query), e.config.MinPageChars)
+		}
+		pages[i] = p
+		report(i, p)
+	}
+	return pages
+}
+
+// pageInfo summarizes a scraped page for SearchResult.Pages and events.
+func pageInfo(p scraper.ScrapedPage) PageInfo {
+	info := PageInfo{URL: p.URL, Published: p.Published, Timings: p.Timings, Status: p.Status, Bytes: p.Bytes, Words: p.Words, Blocked: p.BlockReason, Archive: p.ArchiveURL, Via: p.Via, Revalidated: p.Revalidated, Err: p.Err}
+	if final := search.StripTracking(p.FinalURL); final != p.URL {
+		info.FinalURL = final
+	}
+	return info
+}
+
+// scrapeOptions merges engine configuration with per-call options.
+func (e *Engine) scrapeOptions(opts SearchOptions) scraper.Options {
+	extractor := e.config.Extractor
+	if opts.Extractor != "" {
+		extractor = opts.Extractor
+	}
+	render := e.config.Render
+	if opts.Render != "" {
+		render = opts.Render
+	}
+	if !e.config.Features.Enabled(FeatureRender, opts.Key) {
+		// A default render mode falls back to static fetches rather than
+		// failing calls that never asked for rendering.
+		render = scraper.RenderNever
+	}
+	output := e.config.Output
+	if opts.Output != "" {
+		output = opts.Output
+	}
+	timeout := e.config.ScrapeTimeout
+	if opts.ScrapeTimeout > 0 {
+		timeout = opts.ScrapeTimeout
+	}
+	var fetchCache scraper.FetchCache
+	if fc, ok := e.cache.(scraper.FetchCache); ok && e.config.ConditionalFetch {
+		fetchCache = fc
+	}
+	return scraper.Options{
+		Extractor:         extractor,
+		DomainExtractors:  e.config.DomainExtractors,
+		Links:             e.depth(opts) > 0,
+		FallbackExtractor: e.config.FallbackExtractor,
+		MinContentChars:   e.config.MinContentChars,
+		Timeout:           timeout,
+		HostDelay:         e.config.HostDelay,
+		Retries:           e.config.ScrapeRetries,
+		MaxBodyBytes:      e.config.MaxBodyBytes,
+		GitHubToken:       e.config.GitHubToken,
+		ArchiveFallback:   e.config.ArchiveFallback && e.config.Features.Enabled(FeatureArchive, opts.Key),
+		ArchiveDeadLinks:  e.config.ArchiveDeadLinks && e.config.Features.Enabled(FeatureArchive, opts.Key),
+		RespectRobots:     e.config.Guardrails != nil && e.config.Guardrails.RespectRobots,
+		RespectNoindex:    e.config.RespectNoindex || (e.config.Guardrails != nil && e.config.Guardrails.RespectRobots),
+		Proxy:             e.config.ScrapeProxy,
+		DomainProxies:     e.config.DomainProxies,
+		GeoProxy:          e.config.GeoProxy,
+		Cookies:           e.config.ScrapeCookies,
+		BlockPrivate:      !e.config.AllowPrivate,
+		AllowedNets:       e.config.AllowedNets,
+		FetchCache:        fetchCache,
+		Renderer:          e.config.Renderer,
+		Render:            render,
+		Output:            output,
+		Logger:            e.config.Logger,
+	}
+}
+
+// Stats holds runtime statistics about the engine's upstream providers.
+type Stats struct {
+	Engines  map[string]search.EngineStats // SERP outcomes keyed by search engine
+	Scrape   scraper.ScrapeStats           // aggregate page fetch timings
+	Searches *SearchStats                  // from Config.Metrics if it implements MetricsReader, else nil
+
+	// OpenCircuits lists the search engines ("engine:google") and sites
+	// ("site:example.com") Config.Breaker is skipping, with when each will
+	// be tried again.
+	OpenCircuits map[string]time.Time
+}
+
+// Stats returns a snapshot of runtime statistics. High blocked/empty counts
+// for an engine usually mean the egress IP has been flagged; scrape timings
+// show whether slow pages spend their time in DNS, connecting, the server,
+// or extraction.
+func (e *Engine) Stats() Stats {
+	stats := Stats{Engines: search.Stats(), Scrape: scraper.Stats(), OpenCircuits: e.circuits.open(time.Now())}
+	if mr, ok := e.config.Metrics.(MetricsReader); ok {
+		s := searchStats(mr)
+		stats.Searches = &s
+	}
+	return stats
+}
+
+// SelfCheck runs canary searches against engines, paced by this engine's
+// rate limits, to tell whether the egress IP is blocked or throttled. Each
+// probe is an ordinary search to the engine: it is refused while the
+// engine's circuit breaker is open, counts against the search budgets, and
+// its outcome feeds the breaker. Refused probes are not sent, and an engine
+// with none sent gets search.VerdictSkipped.
+func (e *Engine) SelfCheck(ctx context.Context, engines []string, probes int) ([]search.CheckResult, error) {
+	checks, err := search.SelfCheckWith(ctx, func(ctx context.Context, query string, count int, engine string) ([]search.Result, error) {
+		if !e.circuits.allow(e.config.Breaker, "engine:"+engine, time.Now()) {
+			return nil, fmt.Errorf("engine: search engine %s: %w: %w", engine, search.ErrProbeSkipped, ErrCircuitOpen)
+		}
+		if err := e.reserveSearch(ctx, engine); err != nil {
+			if errors.Is(err, ErrBudgetExhausted) {
+				err = fmt.Errorf("%w: %w", search.ErrProbeSkipped, err)
+			}
+			return nil, err
+		}
+		results, _, err := e.searcher().Search(ctx, query, count, engine)
+		e.reportEngine(ctx, engine, err)
+		return results, err
+	}, engines, probes)
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	return checks, nil
+}
+
+// Probe fetches rawURL once with this engine's page-fetch settings (its
+// proxies, timeout and retries) bypassing the cache and guardrails, to check
+// that the deployment can reach the web. The page's Err reports a failure.
+func (e *Engine) Probe(ctx context.Context, rawURL string) scraper.ScrapedPage {
+	return e.scraper().Scrape(ctx, []string{rawURL}, e.scrapeOptions(SearchOptions{}))[0]
+}
+
+// Features returns the engine's feature flags, which may be nil.
+func (e *Engine) Features() *Features {
+	return e.config.Features
+}
+
+// Metrics returns the engine's Config.Metrics, which may be nil.
+func (e *Engine) Metrics() Metrics {
+	return e.config.Metrics
+}
+
+// ErrNoHealth means the engine's Store does not implement HealthChecker.
+var ErrNoHealth = errors.New("store does not report health")
+
+// Health reports on the cache database: its integrity, size and the free
+// space left on its volume.
+func (e *Engine) Health(ctx context.Context) (cache.Health, error) {
+	hc, ok := e.cache.(HealthChecker)
+	if !ok {
+		return cache.Health{}, fmt.Errorf("engine: %w", ErrNoHealth)
+	}
+	h, err := hc.Health(ctx)
+	if err != nil {
+		return h, fmt.Errorf("engine: %w", err)
+	}
+	return h, nil
+}
+
+// ClearCache removes cached entries.
+// If query is empty, all unpinned entries are flushed; otherwise only the
+// matching entry is deleted, failing with cache.ErrPinned if it is pinned.
+func (e *Engine) ClearCache(query string) error {
+	hash := ""
+	if query != "" {
+		hash = e.key(query)
+	}
+	if err := e.cache.Clear(hash); err != nil {
+		return fmt.Errorf("engine: clear cache: %w", err)
+	}
+	return nil
+}
+
+// consolidate joins scraped page texts, each headed by its title, source URL
+// and language and, when known, its publish date, author, site name and
+// description. Pages whose URL is in sponsored are marked as ads. Blocked
+// pages are skipped unless a geo proxy or archived copy got past the block,
+// since their text is the wall rather than the article.
+// It returns the consolidated text and the number of pages successfully included.
+func consolidate(pages []scraper.ScrapedPage, sponsored map[string]bool) (string, int) {
+	var b strings.Builder
+	count := 0
+	for _, p := range pages {
+		if !usable(p) {
+			continue
+		}
+		if count > 0 {
+			b.WriteString(sectionSep)
+		}
+		count++
+		b.WriteString(sectionHeader(p))
+		b.WriteString("\n\n")
+		if meta := sectionMeta(p, sponsored[p.URL]); meta != "" {
+			b.WriteString(meta)
+			b.WriteString("\n\n")
+		}
+		b.WriteString(escapeSectionText(strings.TrimSpace(p.Content)))
+	}
+	return b.String(), count
+}
+
+// formatPublished renders a publish date, noting how it was estimated when
+// it did not come from explicit page metadata.
+func formatPublished(d scraper.PublishDate) string {
+	date := d.Time.Format("2006-01-02")
+	if d.Source == scraper.DateSourceMetadata {
+		return date
+	}
+	return fmt.Sprintf("%s (estimated from %s, %s confidence)", date, d.Source, d.Confidence)
+}
+
+// formatInstantAnswer renders an engine instant answer as a summary block to
+// prepend to consolidated content. It deliberately avoids a "## " header so
+// countSections keeps reporting only scraped pages.
+func formatInstantAnswer(a *search.InstantAnswer) string {
+	var b strings.Builder
+	if a.Heading != "" {
+		fmt.Fprintf(&b, "**Instant answer: %s**\n\n", a.Heading)
+	} else {
+		b.WriteString("**Instant answer**\n\n")
+	}
+	b.WriteString(escapeSectionText(a.Text))
+	if a.URL != "" {
+		fmt.Fprintf(&b, "\n\nSource: %s", a.URL)
+	}
+	b.WriteString("\n\n---\n\n")
+	return b.String()
+}
+
+// countSections counts the number of "## " section headers in cached content.
+// This is used to derive a result count from previously cached responses.
+func countSections(content string) int {
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,838 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+var errDummy = fmt.Errorf("dummy error")
+
+func TestQueryHash(t *testing.T) {
+	queryHash := func(q string) string { return SHA256Keys.DeriveKey("", q) }
+	// Same query, different casing/whitespace → same hash.
+	h1 := queryHash("Golang concurrency")
+	h2 := queryHash("  golang concurrency  ")
+	h3 := queryHash("GOLANG CONCURRENCY")
+
+	if h1 != h2 {
+		t.Fatalf("hash mismatch: %q vs %q", h1, h2)
+	}
+	if h1 != h3 {
+		t.Fatalf("hash mismatch: %q vs %q", h1, h3)
+	}
+
+	// Different queries → different hash.
+	h4 := queryHash("different query")
+	if h1 == h4 {
+		t.Fatal("different queries should produce different hashes")
+	}
+
+	// Unscoped keys are the plain SHA-256 of the normalized query; changing
+	// them would orphan existing caches.
+	if h1 != "48864ae5144782c2ccfbed33fa5d0b0499a572bb578a823baa864692d4a3caef" {
+		t.Errorf("unscoped key = %s", h1)
+	}
+	// Scopes partition the key space.
+	if a, b := SHA256Keys.DeriveKey("tenant-a", "golang concurrency"), SHA256Keys.DeriveKey("tenant-b", "golang concurrency"); a == b || a == h1 {
+		t.Errorf("scoped keys %s, %s collide with each other or with %s", a, b, h1)
+	}
+}
+
+func TestTokenSetKeys(t *testing.T) {
+	same := [][2]string{
+		{"golang mutex tutorial", "tutorial on golang mutexes"},
+		{"How to use Go channels?", "go channel use"},
+		{"python classes", "Python class"},
+		{"c++ libraries", "library for C++"},
+		{"node.js streams", "Streams in node.js."},
+	}
+	for _, p := range same {
+		if a, b := TokenSetKeys.DeriveKey("", p[0]), TokenSetKeys.DeriveKey("", p[1]); a != b {
+			t.Errorf("%q and %q: different keys (%q vs %q)", p[0], p[1], TokenSet(p[0]), TokenSet(p[1]))
+		}
+	}
+	different := [][2]string{
+		{"c++ tutorial", "c# tutorial"},
+		{"golang mutex", "golang mutex tutorial"},
+		{"status codes", "statu code"},
+		{cacheQuery("go mutex", SearchOptions{Site: "go.dev"}), "go mutex site go.dev"},
+		{cacheQuery("go mutex", SearchOptions{Site: "go.dev"}), cacheQuery("go mutex", SearchOptions{Site: "dev.go"})},
+	}
+	for _, p := range different {
+		if TokenSetKeys.DeriveKey("", p[0]) == TokenSetKeys.DeriveKey("", p[1]) {
+			t.Errorf("%q and %q share a key", p[0], p[1])
+		}
+	}
+	// Queries of only stop words keep their default keys.
+	if TokenSetKeys.DeriveKey("", "The ") != SHA256Keys.DeriveKey("", "the") || TokenSetKeys.DeriveKey("", "the") == TokenSetKeys.DeriveKey("", "a") {
+		t.Error("a query of only stop words did not fall back to its default key")
+	}
+	if a, b := cacheQuery("Go mutexes", SearchOptions{Site: "go.dev"}), cacheQuery("mutex go", SearchOptions{Site: "go.dev"}); TokenSetKeys.DeriveKey("", a) != TokenSetKeys.DeriveKey("", b) {
+		t.Error("rephrased site searches of one site have different keys")
+	}
+	if TokenSetKeys.DeriveKey("a", "go") == TokenSetKeys.DeriveKey("b", "go") {
+		t.Error("scopes share a key")
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		pages     []scraper.ScrapedPage
+		want      string
+		wantCount int
+	}{
+		{
+			name:      "empty",
+			pages:     nil,
+			want:      "",
+			wantCount: 0,
+		},
+		{
+			name: "all_errors",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Err: errDummy},
+			},
+			want:      "",
+			wantCount: 0,
+		},
+		{
+			name: "blocked_pages",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "We value your privacy", Blocked: true, BlockReason: scraper.BlockConsent},
+				{URL: "http://b.com", Content: "Archived story", Blocked: true, BlockReason: scraper.BlockPaywall, ArchiveURL: "https://web.archive.org/web/1id_/http://b.com", Via: scraper.ViaArchive},
+			},
+			want:      "## http://b.com\n\nRetrieved via: archive\n\nArchived story",
+			wantCount: 1,
+		},
+		{
+			name: "single_page",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "Hello"},
+			},
+			want:      "## http://a.com\n\nHello",
+			wantCount: 1,
+		},
+		{
+			name: "multiple_pages",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "First"},
+				{URL: "http://b.com", Content: "Second"},
+			},
+			want:      "## http://a.com\n\nFirst\n\n---\n\n## http://b.com\n\nSecond",
+			wantCount: 2,
+		},
+		{
+			name: "skip_errors_and_empty",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "OK"},
+				{URL: "http://b.com", Err: errDummy},
+				{URL: "http://c.com", Content: "  "},
+				{URL: "http://d.com", Content: "Also OK"},
+			},
+			want:      "## http://a.com\n\nOK\n\n---\n\n## http://d.com\n\nAlso OK",
+			wantCount: 2,
+		},
+		{
+			name: "publish_dates",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "A", Published: scraper.PublishDate{
+					Time: time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC), Source: scraper.DateSourceMetadata, Confidence: scraper.DateConfidenceHigh,
+				}},
+				{URL: "http://b.com/2020/01/02/x", Content: "B", Published: scraper.PublishDate{
+					Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Source: scraper.DateSourceURL, Confidence: scraper.DateConfidenceMedium,
+				}},
+			},
+			want: "## http://a.com\n\nPublished: 2024-03-15\n\nA\n\n---\n\n" +
+				"## http://b.com/2020/01/02/x\n\nPublished: 2020-01-02 (estimated from url, medium confidence)\n\nB",
+			wantCount: 2,
+		},
+		{
+			name: "page_metadata",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.com", Content: "A", Author: "Jane Doe", SiteName: "A News", Description: "About A."},
+				{URL: "http://b.com", Content: "B", SiteName: "B Blog"},
+			},
+			want: "## http://a.com\n\nAuthor: Jane Doe\nSite: A News\nDescription: About A.\n\nA\n\n---\n\n" +
+				"## http://b.com\n\nSite: B Blog\n\nB",
+			wantCount: 2,
+		},
+		{
+			name: "title_and_language",
+			pages: []scraper.ScrapedPage{
+				{URL: "http://a.de/x", Title: "Ein  Titel\n", Language: "de", Content: "Hallo"},
+				{URL: "http://b.com", Language: "en", Content: "Hi"},
+			},
+			want:      "## Ein Titel — http://a.de/x (de)\n\nHallo\n\n---\n\n## http://b.com (en)\n\nHi",
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotCount := consolidate(tt.pages, nil)
+			if got != tt.want {
+				t.Errorf("consolidate() =\n%q\nwant\n%q", got, tt.want)
+			}
+			if gotCount != tt.wantCount {
+				t.Errorf("consolidate() count = %d, want %d", gotCount, tt.wantCount)
+			}
+		})
+	}
+}
+
+func BenchmarkConsolidate(b *testing.B) {
+	// Ten 20 KB pages approximates a typical count=10 scrape.
+	para := strings.Repeat("Goroutines and channels compose into pipelines. ", 400)
+	pages := make([]scraper.ScrapedPage, 10)
+	for i := range pages {
+		pages[i] = scraper.ScrapedPage{
+			URL:     fmt.Sprintf("https://example.com/article/%d", i),
+			Content: "\n\n  " + para + "\n\n",
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		consolidate(pages, nil)
+	}
+}
+
+func TestFormatInstantAnswer(t *testing.T) {
+	a := &search.InstantAnswer{
+		Heading: "Go (programming language)",
+		Text:    "Go is a statically typed, compiled language.",
+		URL:     "https://en.wikipedia.org/wiki/Go_(programming_language)",
+	}
+	got := formatInstantAnswer(a)
+	want := "**Instant answer: Go (programming language)**\n\n" +
+		"Go is a statically typed, compiled language.\n\n" +
+		"Source: https://en.wikipedia.org/wiki/Go_(programming_language)\n\n---\n\n"
+	if got != want {
+		t.Errorf("formatInstantAnswer() =\n%q\nwant\n%q", got, want)
+	}
+
+	// The summary must not be counted as a scraped section.
+	content := got + "## http://a.com\n\nHello"
+	if n := countSections(content); n != 1 {
+		t.Errorf("countSections = %d, want 1", n)
+	}
+}
+
+func TestDiversify(t *testing.T) {
+	results := []search.Result{
+		{URL: "https://blog.example.com/a"},
+		{URL: "https://www.example.com/b"},
+		{URL: "https://example.com/c"},
+		{URL: "https://other.org/d"},
+		{URL: "https://example.co.uk/e"},
+		{URL: "https://news.example.co.uk/f"},
+		{URL: "https://third.net/g"},
+	}
+	urls := func(rs []search.Result) string {
+		var s []string
+		for _, r := range rs {
+			s = append(s, r.URL[strings.LastIndex(r.URL, "/")+1:])
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		name       string
+		count      int
+		maxPerHost int
+		want       string
+	}{
+		{"unlimited", 3, 0, "a,b,c"},
+		{"one_per_site", 3, 1, "a,d,e"},
+		{"two_per_site", 5, 2, "a,b,d,e,f"},
+		{"candidates_exhausted", 10, 1, "a,d,e,g"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := urls(diversify(results, tt.count, tt.maxPerHost)); got != tt.want {
+				t.Errorf("diversify = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinksToFollow(t *testing.T) {
+	landing := scraper.ScrapedPage{URL: "https://go.dev/", Content: "Build simple, secure, scalable systems with Go.", Links: []scraper.Link{
+		{URL: "https://go.dev/about", Text: "About"},
+		{URL: "https://go.dev/doc/tutorial/generics", Text: "Tutorial: Getting started with generics"},
+		{URL: "https://go.dev/blog/", Text: "Generics news"},
+		{URL: "https://example.com/generics-tutorial", Text: "Generics tutorial elsewhere"},
+		{URL: "https://go.dev/blog/intro-generics", Text: "Already a result"},
+	}}
+	failed := scraper.ScrapedPage{URL: "https://broken.example/", Err: errDummy, Links: []scraper.Link{
+		{URL: "https://broken.example/generics-tutorial", Text: "Generics tutorial"},
+	}}
+	article := scraper.ScrapedPage{URL: "https://go.dev/blog/intro-generics", Content: "Go 1.18 adds type parameters."}
+	pages := []scraper.ScrapedPage{landing, failed, article}
+
+	urls, parents := linksToFollow("go generics tutorial", pages, 5)
+	wantURLs := []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/"}
+	if !reflect.DeepEqual(urls, wantURLs) || !reflect.DeepEqual(parents, []int{0, 0}) {
+		t.Errorf("linksToFollow = %v, %v, want %v from page 0", urls, parents, wantURLs)
+	}
+	if urls, _ := linksToFollow("go generics tutorial", pages, 1); !reflect.DeepEqual(urls, wantURLs[:1]) {
+		t.Errorf("budget 1: linksToFollow = %v, want %v", urls, wantURLs[:1])
+	}
+	if urls, _ := linksToFollow("go", pages, 5); urls != nil {
+		t.Errorf("no usable terms: linksToFollow = %v, want none", urls)
+	}
+}
+
+func TestRuleClassifier(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Intent
+	}{
+		{"github.com", IntentNavigational},
+		{"GitHub login", IntentNavigational},
+		{"pizza near me", IntentLocal},
+		{"latest Go release news", IntentNews},
+		{"transformer attention paper arxiv", IntentAcademic},
+		{"NullPointerException when calling getString", IntentCode},
+		{"golang generics tutorial", IntentCode},
+		{"what does ctx.Done() return", IntentCode},
+		{"how tall is the eiffel tower", IntentInformational},
+		{"", IntentInformational},
+	}
+	for _, tt := range tests {
+		if got := (RuleClassifier{}).Classify(tt.query); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSections(t *testing.T) {
+	prose := strings.Repeat("A full sentence of article prose that runs well past forty characters. ", 3)
+	pages := []scraper.ScrapedPage{
+		{URL: "https://ad.example/a", Content: prose + prose},
+		{URL: "https://b.example/b", Content: "Home\nAbout\nLogin"},
+		{URL: "https://c.example/c", Err: errDummy},
+		{URL: "https://d.example/d", Content: prose},
+		{URL: "https://e.example/e", Content: prose + prose},
+	}
+	sponsored := map[string]bool{"https://ad.example/a": true}
+	urls := func(ps []scraper.ScrapedPage) string {
+		var s []string
+		for _, p := range ps {
+			s = append(s, p.URL[strings.LastIndex(p.URL, "/")+1:])
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		n        int
+		want     string
+		wantTrim string
+	}{
+		{0, "a,b,c,d,e", ""},
+		{4, "a,b,c,d,e", ""},
+		{2, "c,d,e", "a,b"},
+		{3, "b,c,d,e", "a"}, // organic beats sponsored, however thin
+		{1, "c,e", "a,b,d"},
+	}
+	for _, tt := range tests {
+		kept, trimmed := selectSections(pages, nil, sponsored, tt.n)
+		var trim []string
+		for i := range pages {
+			if trimmed[i] {
+				trim = append(trim, urls(pages[i:i+1]))
+			}
+		}
+		if got := urls(kept); got != tt.want {
+			t.Errorf("n=%d: kept %s, want %s", tt.n, got, tt.want)
+		}
+		if got := strings.Join(trim, ","); got != tt.wantTrim {
+			t.Errorf("n=%d: trimmed %s, want %s", tt.n, got, tt.wantTrim)
+		}
+	}
+}
+
+func TestFitContent(t *testing.T) {
+	long := strings.Repeat("Long page sentence. ", 400)
+	short := strings.Repeat("Short page sentence. ", 40)
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com/long", Title: "Long", Content: long},
+		{URL: "https://b.com/short", Title: "Short", Content: short},
+	}
+
+	full, n, truncated := fitContent(pages, nil, 0)
+	if n != 2 || truncated != nil {
+		t.Fatalf("no cap: n = %d, truncated = %v", n, truncated)
+	}
+
+	limit := len(full) / 2
+	got, n, truncated := fitContent(pages, nil, limit)
+	if len(got) > limit {
+		t.Errorf("len = %d, want <= %d", len(got), limit)
+	}
+	if n != 2 || !truncated["https://a.com/long"] || !truncated["https://b.com/short"] {
+		t.Errorf("n = %d, truncated = %v; want both pages kept and shortened", n, truncated)
+	}
+	if c := strings.Count(got, truncatedMarker); c != 2 {
+		t.Errorf("%d truncation markers, want 2", c)
+	}
+	// Both pages lose the same share, so the short page keeps most of a
+	// proportional slice rather than being cut off by the long one.
+	longKept := strings.Count(got, "Long page sentence.")
+	shortKept := strings.Count(got, "Short page sentence.")
+	if shortKept < 10 || longKept < 10*shortKept-40 {
+		t.Errorf("kept %d long and %d short sentences, want both trimmed in proportion", longKept, shortKept)
+	}
+	for _, part := range strings.Split(got, truncatedMarker)[:2] {
+		if !strings.HasSuffix(strings.TrimSpace(part), ".") {
+			t.Errorf("cut mid-sentence: ...%q", part[max(len(part)-30, 0):])
+		}
+	}
+
+	// Headers alone overflow: fall back to a hard cut at a rune boundary.
+	if got, _, _ := fitContent(pages, nil, 10); len(got) > 10 {
+		t.Errorf("tiny limit: len = %d", len(got))
+	}
+}
+
+func TestExtractiveSummarizer(t *testing.T) {
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com/mutex", Title: "Mutexes", Content: "A mutex guards shared state between goroutines. " +
+			"The weather was pleasant that day in the office. Lock the mutex before touching the map.\n\nSee also."},
+		{URL: "https://b.com/sync", Title: "Sync", Content: "Package sync provides a mutex type for goroutines. " +
+			"Our newsletter arrives every second Tuesday of the month."},
+	}
+	content, _ := consolidate(pages, nil)
+
+	got, err := ExtractiveSummarizer{Sentences: 3}.Summarize(context.Background(), "golang mutex goroutines", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- A mutex guards shared state between goroutines. (https://a.com/mutex)\n" +
+		"- Lock the mutex before touching the map. (https://a.com/mutex)\n" +
+		"- Package sync provides a mutex type for goroutines. (https://b.com/sync)"
+	if got != want {
+		t.Errorf("summary =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := (ExtractiveSummarizer{}).Summarize(context.Background(), "q", "## Empty — https://c.com\n\nToo short."); !errors.Is(err, ErrNothingToSummarize) {
+		t.Errorf("no sentences: err = %v", err)
+	}
+}
+
+func TestFormatContent(t *testing.T) {
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com/x", Title: "Alpha", Language: "en", Author: "Ann", Content: "First para.\n\n## Not a header"},
+		{URL: "https://b.com/y", Content: "Second page."},
+	}
+	content, _ := consolidate(pages, nil)
+	content = formatInstantAnswer(&search.InstantAnswer{Heading: "Alpha", Text: "An answer."}) + content
+
+	if got, _ := formatContent(content, ContentMarkdown); got != content {
+		t.Errorf("markdown changed the content")
+	}
+
+	plain, err := formatContent(content, ContentPlain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Instant answer: Alpha\n\nAn answer.\n\n\n" +
+		"Alpha\nhttps://a.com/x\nAuthor: Ann\n\nFirst para.\n\n## Not a header\n\n\n" +
+		"https://b.com/y\n\nSecond page."
+	if plain != want {
+		t.Errorf("plain =\n%q\nwant\n%q", plain, want)
+	}
+
+	js, err := formatContent(content, ContentJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc contentDoc
+	if err := json.Unmarshal([]byte(js), &doc); err != nil {
+		t.Fatalf("json: %v\n%s", err, js)
+	}
+	if doc.Answer != "Instant answer: Alpha\n\nAn answer." || len(doc.Sections) != 2 ||
+		doc.Sections[0].Title != "Alpha" || doc.Sections[0].Language != "en" || doc.Sections[0].Author != "Ann" ||
+		doc.Sections[0].Text != "First para.\n\n## Not a header" || doc.Sections[1].URL != "https://b.com/y" {
+		t.Errorf("json = %+v", doc)
+	}
+
+	if _, err := formatContent(content, "yaml"); err == nil || ValidContentFormat("yaml") {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestTemplateContent(t *testing.T) {
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com/x", Title: "Alpha", Author: "Ann", Content: "First para.\n\n## Not a header"},
+		{URL: "https://b.com/y", Content: "Second page."},
+	}
+	content, _ := consolidate(pages, nil)
+	content = formatInstantAnswer(&search.InstantAnswer{Heading: "Alpha", Text: "An answer."}) + content
+
+	tmpl := template.Must(template.New("section").Parse(
+		"[{{.N}}/{{.Count}}] {{or .Title .URL}}{{with .Author}} by {{.}}{{end}}\n{{.Text}}\n{{if lt .N .Count}}\n{{end}}"))
+	got, err := templateContent(tmpl, "q", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Instant answer: Alpha\n\nAn answer.\n\n" +
+		"[1/2] Alpha by Ann\nFirst para.\n\n## Not a header\n\n" +
+		"[2/2] https://b.com/y\nSecond page.\n"
+	if got != want {
+		t.Errorf("templated =\n%q\nwant\n%q", got, want)
+	}
+
+	bad := template.Must(template.New("section").Parse("{{.Missing}}"))
+	if _, err := templateContent(bad, "q", content); err == nil {
+		t.Error("template error not returned")
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	meta := scraper.PublishDate{
+		Time:       time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		Source:     scraper.DateSourceMetadata,
+		Confidence: scraper.DateConfidenceHigh,
+	}
+	estimated := scraper.PublishDate{
+		Time:       time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC),
+		Source:     scraper.DateSourceURL,
+		Confidence: scraper.DateConfidenceMedium,
+	}
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.de/x", Title: "Go — Einführung", Language: "de", Content: "Hallo"},
+		{URL: "https://b.com/y", Content: "No title or language", Published: estimated},
+		{URL: "https://c.fr/z", Title: "Bonjour", Content: "Salut", Published: meta,
+			Author: "Marie Curie", SiteName: "Le Site", Description: "Un\nrésumé"},
+		{URL: "https://d.uk/w", Content: "Regional story", Blocked: true, BlockReason: scraper.BlockGeo, Via: scraper.ViaGeoProxy},
+	}
+	content, _ := consolidate(pages, nil)
+	got := parseSources(formatInstantAnswer(&search.InstantAnswer{Text: "42"}) + content)
+
+	want := []Source{
+		{Title: "Go — Einführung", URL: "https://a.de/x", Language: "de", Excerpt: "Hallo", TextLength: 5},
+		{URL: "https://b.com/y", Published: estimated, Excerpt: "No title or language", TextLength: 20},
+		{Title: "Bonjour", URL: "https://c.fr/z", Published: meta,
+			Author: "Marie Curie", SiteName: "Le Site", Description: "Un résumé", Excerpt: "Salut", TextLength: 5},
+		{URL: "https://d.uk/w", Via: scraper.ViaGeoProxy, Excerpt: "Regional story", TextLength: 14},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sources, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSectionLikePageText(t *testing.T) {
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com", Title: "A", Content: "Intro\n## Installation\nPublished: 2020-01-01\nAuthor: Someone\nSteps"},
+		{URL: "https://b.com", Content: "## Only a heading"},
+	}
+	content, n := consolidate(pages, nil)
+	if n != 2 {
+		t.Fatalf("consolidate count = %d, want 2", n)
+	}
+	if got := countSections(content); got != 2 {
+		t.Errorf("countSections = %d, want 2:\n%s", got, content)
+	}
+	got := parseSources(content)
+	want := []Source{
+		{Title: "A", URL: "https://a.com", TextLength: 65,
+			Excerpt: "Intro ## Installation Published: 2020-01-01 Author: Someone Steps"},
+		{URL: "https://b.com", Excerpt: "## Only a heading", TextLength: 17},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sources, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if !strings.Contains(content, "\n ## Installation\n") {
+		t.Errorf("page heading not escaped:\n%s", content)
+	}
+}
+
+func TestConfigCount(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		n    int
+		want int
+	}{
+		{Config{}, 0, DefaultCount},
+		{Config{}, 100, DefaultMaxCount},
+		{Config{DefaultCount: 3}, 0, 3},
+		{Config{MaxCount: 4}, 10, 4},
+		{Config{DefaultCount: 10, MaxCount: 4}, 0, 4},
+		{Config{MaxCount: -1}, 100, 100},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.count(tt.n); got != tt.want {
+			t.Errorf("%+v.count(%d) = %d, want %d", tt.cfg, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalCounts(t *testing.T) {
+	got, unknown := canonicalCounts(map[string]int{"ddg": 3, "Google": 5, "gogle": 1, "bing": 1})
+	want := map[string]int{search.EngineDuckDuckGo: 3, search.EngineGoogle: 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("canonicalCounts = %v, want %v", got, want)
+	}
+	if len(unknown) != 2 {
+		t.Errorf("unknown = %v, want gogle and bing", unknown)
+	}
+	if got, _ := canonicalCounts(nil); got != nil {
+		t.Errorf("canonicalCounts(nil) = %v, want nil", got)
+	}
+}
+
+func TestConfigRateLimits(t *testing.T) {
+	c := Config{RateLimit: time.Second, RateLimits: map[string]time.Duration{
+		"ddg":   2 * time.Second,
+		"bing":  10 * time.Second, // a typo or unsupported engine must not replace Google's limit
+		"gogle": 10 * time.Second,
+	}}
+	want := map[string]time.Duration{search.EngineGoogle: time.Second, search.EngineDuckDuckGo: 2 * time.Second}
+	if got := c.rateLimits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("rateLimits = %v, want %v", got, want)
+	}
+}
+
+func TestCountLimitsResolve(t *testing.T) {
+	l := CountLimits{Default: 3, Max: 8}
+	for n, want := range map[int]int{0: 3, 1: 1, 8: 8} {
+		if got, err := l.Resolve(n); err != nil || got != want {
+			t.Errorf("Resolve(%d) = %d, %v; want %d", n, got, err, want)
+		}
+	}
+	for _, n := range []int{-1, 9} {
+		if _, err := l.Resolve(n); err == nil {
+			t.Errorf("Resolve(%d) should fail", n)
+		}
+	}
+	if got, err := (CountLimits{}).Resolve(50); err != nil || got != 50 {
+		t.Errorf("zero limits Resolve(50) = %d, %v; the engine caps instead", got, err)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"short  text\n", 20, "short text"},
+		{"the quick brown fox jumps", 12, "the quick…"},
+		{"élan vital über alles", 11, "élan vital…"},
+		{"unbreakable", 5, "unbr…"},
+	}
+	for _, tt := range tests {
+		if got := excerpt(tt.text, tt.n); got != tt.want {
+			t.Errorf("excerpt(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSources(t *testing.T) {
+	sources := []Source{
+		{URL: "https://a.com", Title: "A \"quoted\" title", Excerpt: "Line one", TextLength: 8,
+			Published: scraper.PublishDate{Time: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)}},
+		{URL: "https://b.com"},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatCSV, "url,title,date,excerpt,text_length\n" +
+			"https://a.com,\"A \"\"quoted\"\" title\",2024-03-15,Line one,8\n" +
+			"https://b.com,,,,0\n"},
+		{FormatJSONL, `{"url":"https://a.com","title":"A \"quoted\" title","date":"2024-03-15","excerpt":"Line one","text_length":8}` + "\n" +
+			`{"url":"https://b.com","title":"","date":"","excerpt":"","text_length":0}` + "\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := WriteSources(&b, tt.format, sources); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s =\n%s\nwant\n%s", tt.format, b.String(), tt.want)
+		}
+	}
+	if err := WriteSources(io.Discard, "xml", sources); err == nil {
+		t.Error("unknown format: want error")
+	}
+}
+
+func TestCiteParagraphs(t *testing.T) {
+	pages := []scraper.ScrapedPage{
+		{URL: "https://a.com", Title: "A", Author: "Jane", Content: "First claim.\nStill first.\n\nSecond claim.\n\n```go\nx := 1\n\ny := 2\n```\n\n| a | b |\n| --- | --- |"},
+		{URL: "https://b.com", Content: "Only claim.\n---\n## Escaped"},
+	}
+	content, _ := consolidate(pages, nil)
+	content = formatInstantAnswer(&search.InstantAnswer{Text: "42"}) + content
+
+	want := "**Instant answer**\n\n42\n\n---\n\n" +
+		"## A — https://a.com\n\nAuthor: Jane\n\n" +
+		"First claim.\nStill first. [S1]\n\nSecond claim. [S1]\n\n```go\nx := 1\n\ny := 2\n```\n\n| a | b |\n| --- | --- |\n\n---\n\n" +
+		"## https://b.com\n\nOnly claim.\n---\n ## Escaped [S2]"
+	if got := citeParagraphs(content); got != want {
+		t.Errorf("citeParagraphs =\n%s\nwant\n%s", got, want)
+	}
+	if got := countSections(citeParagraphs(content)); got != 2 {
+		t.Errorf("countSections after citing = %d, want 2", got)
+	}
+}
+
+func TestTrimToQuery(t *testing.T) {
+	content := "Intro to Go.\n\n## Generics\n\nType parameters arrive.\n\n## Channels\n\nSend and receive.\n\n```go\nch := make(chan int)\n\nch <- 1\n```"
+	tests := []struct {
+		query    string
+		minChars int
+		want     string
+	}{
+		{"go generics", 0, "## Generics\n\nType parameters arrive."},
+		{"go channels", 0, "## Channels\n\nSend and receive.\n\n```go\nch := make(chan int)\n\nch <- 1\n```"},
+		{"go parameters intro", 0, "Intro to Go.\n\n## Generics\n\nType parameters arrive."},
+		{"rust", 0, content},
+		{"go", 0, content},
+		{"go generics", 100, content},
+	}
+	for _, tt := range tests {
+		if got := trimToQuery(content, queryTerms(tt.query), tt.minChars); got != tt.want {
+			t.Errorf("trimToQuery(%q, %d) =\n%s\nwant\n%s", tt.query, tt.minChars, got, tt.want)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	var nilFeatures *Features
+	if !nilFeatures.Enabled(FeatureRender, "") {
+		t.Error("nil Features: render disabled, want its default")
+	}
+
+	f, err := NewFeatures(map[string]bool{FeatureRender: false}, map[string]map[string]bool{"k1": {FeatureRender: true}})
+	if err != nil {
+		t.Fatalf("NewFeatures: %v", err)
+	}
+	for _, tt := range []struct {
+		key  string
+		want bool
+	}{{"", false}, {"k1", true}, {"unknown", false}} {
+		if got := f.Enabled(FeatureRender, tt.key); got != tt.want {
+			t.Errorf("Enabled(render, %q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	if err := f.Reset(FeatureRender, "k1"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if f.Enabled(FeatureRender, "k1") {
+		t.Error("k1 still enabled after Reset")
+	}
+	if s := f.Snapshot(); len(s.Keys) != 0 || s.Defaults[FeatureRender] {
+		t.Errorf("Snapshot = %+v", s)
+	}
+	if err := f.Reset(FeatureRender, ""); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if !f.Enabled(FeatureRender, "") {
+		t.Error("render disabled after deployment Reset, want its default")
+	}
+
+	if err := f.Set("teleport", "", true); err == nil {
+		t.Error("Set(unknown feature) succeeded")
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	got, err := ParseFeatures("render=off")
+	if err != nil || got[FeatureRender] {
+		t.Errorf("ParseFeatures(render=off) = %v, %v", got, err)
+	}
+	keys, err := ParseKeyFeatures("k1:render=on, k2:render=false")
+	if err != nil || !keys["k1"][FeatureRender] || keys["k2"][FeatureRender] || len(keys) != 2 {
+		t.Errorf("ParseKeyFeatures = %v, %v", keys, err)
+	}
+	for _, bad := range []string{"render", "render=maybe", "teleport=on"} {
+		if _, err := ParseFeatures(bad); err == nil {
+			t.Errorf("ParseFeatures(%q) succeeded", bad)
+		}
+	}
+	if _, err := ParseKeyFeatures("render=on"); err == nil {
+		t.Error("ParseKeyFeatures without a key succeeded")
+	}
+}
+
+func TestBreakers(t *testing.T) {
+	p := &BreakerPolicy{Threshold: 2, Cooldown: time.Minute}
+	var b breakers
+	now := time.Now()
+
+	b.report(p, "k", true, now)
+	if !b.allow(p, "k", now) {
+		t.Fatal("open after one failure")
+	}
+	if !b.report(p, "k", true, now) {
+		t.Error("second failure did not report opening")
+	}
+	if b.allow(p, "k", now.Add(30*time.Second)) {
+		t.Error("allowed during cooldown")
+	}
+
+	// After the cooldown one probe goes through; a failed probe reopens
+	// the breaker without reporting it as newly opened.
+	later := now.Add(2 * time.Minute)
+	if !b.allow(p, "k", later) || b.allow(p, "k", later) {
+		t.Error("want exactly one probe after the cooldown")
+	}
+	if b.report(p, "k", true, later) {
+		t.Error("failed probe reported as newly opened")
+	}
+	if _, ok := b.open(later)["k"]; !ok {
+		t.Error("not open after a failed probe")
+	}
+	b.report(p, "k", false, later)
+	if !b.allow(p, "k", later) || len(b.open(later)) != 0 {
+		t.Error("success did not close the breaker")
+	}
+
+	if !b.allow(nil, "k", now) || b.report(nil, "k", true, now) {
+		t.Error("nil policy should never open")
+	}
+}
+
+func TestSiteFailure(t *testing.T) {
+	tests := []struct {
+		page scraper.ScrapedPage
+		want bool
+	}{
+		{scraper.ScrapedPage{Status: 200}, false},
+		{scraper.ScrapedPage{Err: errDummy}, true},
+		{scraper.ScrapedPage{Status: 503, Err: errDummy}, true},
+		{scraper.ScrapedPage{Status: 404, Err: errDummy}, false},
+		{scraper.ScrapedPage{Err: fmt.Errorf("x: %w", scraper.ErrRobotsDisallowed)}, false},
+	}
+	for _, tt := range tests {
+		if got := siteFailure(tt.page); got != tt.want {
+			t.Errorf("siteFailure(%d, %v) = %v, want %v", tt.page.Status, tt.page.Err, got, tt.want)
+		}
+	}
+}
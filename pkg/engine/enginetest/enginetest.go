@@ -0,0 +1,792 @@
+// Package enginetest provides deterministic test doubles for code built on
+// the engine: a Fake engine.Service, and in-memory Store, Searcher and
+// Scraper implementations for running the real pipeline without network
+// access or SQLite files.
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Call records one method call on a Fake.
+type Call struct {
+	Method string // "SearchWithOptions", "SearchStream", "DeepResearch", "ClearCache", "Pin", "Unpin", "Pinned", "Stats", "SelfCheck", "Health", "SearchCache", "TopQueries", "SearchHistory", "ReplayHistory" or "FetchWithOptions"
+	Query  string
+	Opts   engine.SearchOptions
+}
+
+// Fake is a deterministic engine.Service. Searches return Results[query],
+// matched case-insensitively with whitespace collapsed; unknown queries fail
+// with engine.ErrNoResults. The zero value is ready to use.
+type Fake struct {
+	Results map[string]engine.SearchResult
+	Err     error                          // when set, every method that can fail returns it
+	Checks  []search.CheckResult           // returned by SelfCheck
+	Store   cache.Health                   // returned by Health
+	Matches []cache.Match                  // returned by SearchCache, up to its limit
+	Top     []cache.Entry                  // returned by TopQueries, up to its limit
+	History []cache.HistoryRecord          // listed by SearchHistory, newest first, and replayed by ReplayHistory
+	Pages   map[string]engine.SearchResult // returned by FetchWithOptions, keyed by exact URL
+
+	mu     sync.Mutex
+	calls  []Call
+	pinned map[string]bool
+}
+
+var _ engine.Service = (*Fake)(nil)
+
+// Failing returns a Fake whose every call fails with err.
+func Failing(err error) *Fake {
+	return &Fake{Err: err}
+}
+
+// Calls returns the calls made so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+func (f *Fake) record(c Call) {
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+	f.mu.Unlock()
+}
+
+func (f *Fake) lookup(query string) (engine.SearchResult, bool) {
+	want := normalize(query)
+	for q, r := range f.Results {
+		if normalize(q) == want {
+			return r, true
+		}
+	}
+	return engine.SearchResult{}, false
+}
+
+// SearchWithOptions returns the canned result for query. With
+// opts.Summarize the result is marked Summarized but otherwise unchanged.
+func (f *Fake) SearchWithOptions(ctx context.Context, query string, opts engine.SearchOptions) (engine.SearchResult, error) {
+	f.record(Call{Method: "SearchWithOptions", Query: query, Opts: opts})
+	if f.Err != nil {
+		return engine.SearchResult{}, f.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return engine.SearchResult{}, fmt.Errorf("engine: search: %w", err)
+	}
+	r, ok := f.lookup(query)
+	if !ok {
+		return engine.SearchResult{}, fmt.Errorf("engine: %w for %q", engine.ErrNoResults, query)
+	}
+	r.Summarized = opts.Summarize
+	return r, nil
+}
+
+// SearchStream returns what SearchWithOptions does, first passing fn one
+// StreamResults update listing the result's Sources, then one StreamPage
+// update per source with its title and its entry in the result's Pages,
+// if any. Streamed pages have no Content. Failed searches make no calls.
+func (f *Fake) SearchStream(ctx context.Context, query string, opts engine.SearchOptions, fn func(engine.StreamUpdate)) (engine.SearchResult, error) {
+	f.record(Call{Method: "SearchStream", Query: query, Opts: opts})
+	if f.Err != nil {
+		return engine.SearchResult{}, f.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return engine.SearchResult{}, fmt.Errorf("engine: search: %w", err)
+	}
+	r, ok := f.lookup(query)
+	if !ok {
+		return engine.SearchResult{}, fmt.Errorf("engine: %w for %q", engine.ErrNoResults, query)
+	}
+	r.Summarized = opts.Summarize
+
+	results := make([]search.Result, len(r.Sources))
+	for i, src := range r.Sources {
+		results[i] = search.Result{URL: src.URL, Title: src.Title, Sponsored: src.Sponsored}
+	}
+	fn(engine.StreamUpdate{Kind: engine.StreamResults, Results: results})
+	for _, src := range r.Sources {
+		page := &engine.StreamedPage{PageInfo: engine.PageInfo{URL: src.URL}, Title: src.Title}
+		for _, info := range r.Pages {
+			if info.URL == src.URL {
+				page.PageInfo = info
+			}
+		}
+		fn(engine.StreamUpdate{Kind: engine.StreamPage, Page: page})
+	}
+	return r, nil
+}
+
+// FetchWithOptions returns the canned page for rawURL. Anything but an
+// http or https URL fails with engine.ErrInvalidURL, and unknown URLs
+// with engine.ErrScrapeFailed.
+func (f *Fake) FetchWithOptions(ctx context.Context, rawURL string, opts engine.SearchOptions) (engine.SearchResult, error) {
+	f.record(Call{Method: "FetchWithOptions", Query: rawURL, Opts: opts})
+	if f.Err != nil {
+		return engine.SearchResult{}, f.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return engine.SearchResult{}, fmt.Errorf("engine: fetch: %w", err)
+	}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return engine.SearchResult{}, fmt.Errorf("engine: %w %q", engine.ErrInvalidURL, rawURL)
+	}
+	r, ok := f.Pages[rawURL]
+	if !ok {
+		return engine.SearchResult{}, fmt.Errorf("engine: %w for %q", engine.ErrScrapeFailed, rawURL)
+	}
+	return r, nil
+}
+
+// DeepResearch returns a one-round report of the canned result for query,
+// with no follow-ups. The Call records opts.Search.
+func (f *Fake) DeepResearch(ctx context.Context, query string, opts engine.ResearchOptions) (engine.ResearchReport, error) {
+	f.record(Call{Method: "DeepResearch", Query: query, Opts: opts.Search})
+	if f.Err != nil {
+		return engine.ResearchReport{}, f.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return engine.ResearchReport{}, fmt.Errorf("engine: search: %w", err)
+	}
+	r, ok := f.lookup(query)
+	if !ok {
+		return engine.ResearchReport{}, fmt.Errorf("engine: %w for %q", engine.ErrNoResults, query)
+	}
+	return engine.ResearchReport{
+		Query:   query,
+		Rounds:  []engine.ResearchRound{{Steps: []engine.ResearchStep{{Query: query, Result: r}}}},
+		Pages:   r.ResultCount,
+		Content: fmt.Sprintf("# Round 1: search %q\n\n%s", query, r.Content),
+		Sources: r.Sources,
+	}, nil
+}
+
+// ClearCache fails with cache.ErrPinned for pinned queries; canned results
+// are never removed.
+func (f *Fake) ClearCache(query string) error {
+	f.record(Call{Method: "ClearCache", Query: query})
+	if f.Err != nil {
+		return f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if query != "" && f.pinned[normalize(query)] {
+		return fmt.Errorf("engine: cache clear: %w", cache.ErrPinned)
+	}
+	return nil
+}
+
+// Pin marks query pinned; it fails like a search if query has no result.
+func (f *Fake) Pin(ctx context.Context, query string) error {
+	f.record(Call{Method: "Pin", Query: query})
+	if f.Err != nil {
+		return f.Err
+	}
+	if _, ok := f.lookup(query); !ok {
+		return fmt.Errorf("engine: %w for %q", engine.ErrNoResults, query)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pinned == nil {
+		f.pinned = map[string]bool{}
+	}
+	f.pinned[normalize(query)] = true
+	return nil
+}
+
+// Unpin fails with cache.ErrNotFound if query is not pinned.
+func (f *Fake) Unpin(query string) error {
+	f.record(Call{Method: "Unpin", Query: query})
+	if f.Err != nil {
+		return f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.pinned[normalize(query)] {
+		return fmt.Errorf("engine: %w", cache.ErrNotFound)
+	}
+	delete(f.pinned, normalize(query))
+	return nil
+}
+
+// Pinned lists pinned queries sorted by name.
+func (f *Fake) Pinned() ([]cache.PinnedEntry, error) {
+	f.record(Call{Method: "Pinned"})
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []cache.PinnedEntry
+	for q := range f.pinned {
+		r, _ := f.lookup(q)
+		out = append(out, cache.PinnedEntry{Query: q, Size: len(r.Content)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Query < out[j].Query })
+	return out, nil
+}
+
+// Stats returns zero statistics.
+func (f *Fake) Stats() engine.Stats {
+	f.record(Call{Method: "Stats"})
+	return engine.Stats{}
+}
+
+// SelfCheck returns Checks without making any requests. Unknown engine
+// names are rejected as the real engine does.
+func (f *Fake) SelfCheck(ctx context.Context, engines []string, probes int) ([]search.CheckResult, error) {
+	f.record(Call{Method: "SelfCheck"})
+	for _, name := range engines {
+		if !search.ValidEngine(name) {
+			return nil, fmt.Errorf("enginetest: self-check %q: %w", name, search.ErrUnknownEngine)
+		}
+	}
+	return f.Checks, nil
+}
+
+// Health returns Store, or Err when set.
+func (f *Fake) Health(ctx context.Context) (cache.Health, error) {
+	f.record(Call{Method: "Health"})
+	if f.Err != nil {
+		return cache.Health{}, f.Err
+	}
+	return f.Store, nil
+}
+
+// SearchCache returns up to limit of Matches, whatever the query.
+func (f *Fake) SearchCache(ctx context.Context, query string, limit int) ([]cache.Match, error) {
+	f.record(Call{Method: "SearchCache", Query: query})
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if limit > 0 && len(f.Matches) > limit {
+		return f.Matches[:limit], nil
+	}
+	return f.Matches, nil
+}
+
+// TopQueries returns up to limit of Top.
+func (f *Fake) TopQueries(ctx context.Context, limit int) ([]cache.Entry, error) {
+	f.record(Call{Method: "TopQueries"})
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if limit > 0 && len(f.Top) > limit {
+		return f.Top[:limit], nil
+	}
+	return f.Top, nil
+}
+
+// SearchHistory returns up to limit of History whose query contains
+// match, ignoring case.
+func (f *Fake) SearchHistory(ctx context.Context, match string, limit int) ([]cache.HistoryRecord, error) {
+	f.record(Call{Method: "SearchHistory", Query: match})
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	var out []cache.HistoryRecord
+	for _, rec := range f.History {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(rec.Query), strings.ToLower(match)) {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+// ReplayHistory replays the record of History with id through
+// FetchWithOptions or SearchWithOptions, whose call is recorded too. Unknown
+// IDs fail with cache.ErrNotFound.
+func (f *Fake) ReplayHistory(ctx context.Context, id int64, opts engine.SearchOptions) (engine.SearchResult, error) {
+	f.record(Call{Method: "ReplayHistory", Opts: opts})
+	if f.Err != nil {
+		return engine.SearchResult{}, f.Err
+	}
+	for _, rec := range f.History {
+		if rec.ID != id {
+			continue
+		}
+		if rec.Kind == "fetch" {
+			return f.FetchWithOptions(ctx, rec.Query, opts)
+		}
+		if opts.Site == "" {
+			opts.Site = rec.Site
+		}
+		return f.SearchWithOptions(ctx, rec.Query, opts)
+	}
+	return engine.SearchResult{}, fmt.Errorf("engine: history record %d: %w", id, cache.ErrNotFound)
+}
+
+func normalize(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// StaticSearcher is an engine.Searcher returning canned links. Queries are
+// matched like Fake's; unknown queries return no results.
+type StaticSearcher struct {
+	Results map[string][]search.Result
+	Answers map[string]*search.InstantAnswer // optional instant answers
+	Err     error                            // returned for every query when set
+}
+
+// Search returns up to count canned results for query.
+func (s StaticSearcher) Search(ctx context.Context, query string, count int, _ string) ([]search.Result, *search.InstantAnswer, error) {
+	if s.Err != nil {
+		return nil, nil, s.Err
+	}
+	var results []search.Result
+	var answer *search.InstantAnswer
+	for q, r := range s.Results {
+		if normalize(q) == normalize(query) {
+			results = r
+		}
+	}
+	for q, a := range s.Answers {
+		if normalize(q) == normalize(query) {
+			answer = a
+		}
+	}
+	if len(results) > count {
+		results = results[:count]
+	}
+	return results, answer, nil
+}
+
+// StaticScraper is an engine.Scraper serving canned pages by URL. URLs
+// without a page fail with an error.
+type StaticScraper struct {
+	Pages map[string]scraper.ScrapedPage
+}
+
+// Page returns a successfully scraped page with the given title and content.
+func Page(title, content string) scraper.ScrapedPage {
+	return scraper.ScrapedPage{Title: title, Content: content, Attempts: 1}
+}
+
+// Scrape returns the canned page for each URL, in order.
+func (s StaticScraper) Scrape(ctx context.Context, urls []string, _ scraper.Options) []scraper.ScrapedPage {
+	pages := make([]scraper.ScrapedPage, len(urls))
+	for i, u := range urls {
+		p, ok := s.Pages[u]
+		if !ok {
+			p = scraper.ScrapedPage{Err: fmt.Errorf("enginetest: no page for %s", u)}
+		}
+		p.URL = u
+		pages[i] = p
+	}
+	return pages
+}
+
+// MemoryStore is an in-memory engine.Store and engine.EntryReader.
+// Entries expire after their Meta.TTL, or TTL if that is zero, unless
+// pinned; with neither set they never expire. Budgets
+// are enforced over rolling windows like the SQLite cache. The zero value
+// is ready to use.
+type MemoryStore struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*memEntry
+	usage   map[string][]usageEvent
+	pages   map[string]memPage
+	history []cache.HistoryRecord // oldest first
+	lastID  int64
+}
+
+type memPage struct {
+	data    []byte
+	expires time.Time
+}
+
+type memEntry struct {
+	content string
+	meta    cache.Meta
+	pinned  bool
+	updated time.Time
+	hits    int
+	total   int       // hits over the entry's lifetime
+	lastHit time.Time // zero if never read
+}
+
+type usageEvent struct {
+	at time.Time
+	n  int
+}
+
+var (
+	_ engine.Store            = (*MemoryStore)(nil)
+	_ engine.EntryReader      = (*MemoryStore)(nil)
+	_ engine.ExpiringLister   = (*MemoryStore)(nil)
+	_ engine.PageStore        = (*MemoryStore)(nil)
+	_ engine.ContentSearcher  = (*MemoryStore)(nil)
+	_ engine.PopularityLister = (*MemoryStore)(nil)
+	_ engine.HistoryStore     = (*MemoryStore)(nil)
+)
+
+// Get returns the content cached under queryHash, unless it has expired.
+func (m *MemoryStore) Get(queryHash string) (string, bool, error) {
+	e, ok, _ := m.Lookup(context.Background(), queryHash)
+	if !ok || e.Stale {
+		return "", false, nil
+	}
+	return e.Content, true, nil
+}
+
+// Lookup returns the entry stored under queryHash even if it has expired,
+// marking it Stale, and counts a hit.
+func (m *MemoryStore) Lookup(ctx context.Context, queryHash string) (cache.Entry, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.Entry{}, false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[queryHash]
+	if !ok {
+		return cache.Entry{}, false, nil
+	}
+	entry := m.entry(queryHash, e)
+	e.hits++
+	e.total++
+	e.lastHit = time.Now()
+	return entry, true, nil
+}
+
+// Expiring lists unpinned entries within the given time of their TTL, or
+// past it, that were read at least minHits times since they were stored,
+// most read first. Entries without a TTL never expire.
+func (m *MemoryStore) Expiring(ctx context.Context, within time.Duration, minHits int) ([]cache.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("cache: list expiring: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []cache.Entry
+	for h, e := range m.entries {
+		if ttl := m.ttl(e); ttl > 0 && !e.pinned && e.meta.Query != "" && e.hits >= minHits && time.Since(e.updated) >= ttl-within {
+			out = append(out, m.entry(h, e))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hits > out[j].Hits })
+	return out, nil
+}
+
+// ttl returns how long e stays fresh, or 0 if it never expires.
+func (m *MemoryStore) ttl(e *memEntry) time.Duration {
+	if e.meta.TTL > 0 {
+		return e.meta.TTL
+	}
+	return m.TTL
+}
+
+// entry converts e for Lookup and Expiring. m.mu must be held.
+func (m *MemoryStore) entry(queryHash string, e *memEntry) cache.Entry {
+	entry := cache.Entry{
+		Meta:      e.meta,
+		QueryHash: queryHash,
+		Content:   e.content,
+		UpdatedAt: e.updated,
+		Pinned:    e.pinned,
+		Hits:      e.hits,
+		TotalHits: e.total,
+		LastHitAt: e.lastHit,
+	}
+	if ttl := m.ttl(e); ttl > 0 {
+		entry.ExpiresAt = e.updated.Add(ttl)
+		entry.Stale = !e.pinned && time.Since(e.updated) > ttl
+	}
+	return entry
+}
+
+// Set stores content and meta under queryHash, keeping any pin and
+// restarting the hit count. An empty meta.Query keeps the one already
+// stored.
+func (m *MemoryStore) Set(queryHash, content string, meta cache.Meta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = map[string]*memEntry{}
+	}
+	e, ok := m.entries[queryHash]
+	if !ok {
+		e = &memEntry{}
+		m.entries[queryHash] = e
+	}
+	e.content, e.updated, e.hits = content, time.Now(), 0
+	if meta.Query == "" {
+		meta.Query = e.meta.Query
+	}
+	e.meta = meta
+	return nil
+}
+
+// Clear removes one unpinned entry, or every unpinned entry when queryHash
+// is empty, along with the stored pages.
+func (m *MemoryStore) Clear(queryHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if queryHash == "" {
+		for h, e := range m.entries {
+			if !e.pinned {
+				delete(m.entries, h)
+			}
+		}
+		m.pages = nil
+		return nil
+	}
+	if e, ok := m.entries[queryHash]; ok && e.pinned {
+		return fmt.Errorf("cache: clear %q: %w", queryHash, cache.ErrPinned)
+	}
+	delete(m.entries, queryHash)
+	return nil
+}
+
+// Pin exempts an entry from Clear.
+func (m *MemoryStore) Pin(queryHash, query string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[queryHash]
+	if !ok {
+		return fmt.Errorf("cache: pin %q: %w", queryHash, cache.ErrNotFound)
+	}
+	e.pinned, e.meta.Query = true, query
+	return nil
+}
+
+// Unpin reverses Pin.
+func (m *MemoryStore) Unpin(queryHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[queryHash]
+	if !ok {
+		return fmt.Errorf("cache: unpin %q: %w", queryHash, cache.ErrNotFound)
+	}
+	e.pinned = false
+	return nil
+}
+
+// Pinned lists pinned entries, most recently updated first.
+func (m *MemoryStore) Pinned() ([]cache.PinnedEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []cache.PinnedEntry
+	for h, e := range m.entries {
+		if e.pinned {
+			out = append(out, cache.PinnedEntry{QueryHash: h, Query: e.meta.Query, UpdatedAt: e.updated, Size: len(e.content)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+// GetContext is like Get but fails once ctx is done, as the SQLite cache
+// does.
+func (m *MemoryStore) GetContext(ctx context.Context, queryHash string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, fmt.Errorf("cache: get %q: %w", queryHash, err)
+	}
+	return m.Get(queryHash)
+}
+
+// SetContext is like Set but fails once ctx is done.
+func (m *MemoryStore) SetContext(ctx context.Context, queryHash, content string, meta cache.Meta) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cache: set %q: %w", queryHash, err)
+	}
+	return m.Set(queryHash, content, meta)
+}
+
+// Top lists up to limit entries with a query by lifetime hits, most read
+// first and most recently read among equals.
+func (m *MemoryStore) Top(ctx context.Context, limit int) ([]cache.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("cache: top: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []cache.Entry
+	for h, e := range m.entries {
+		if e.meta.Query != "" {
+			entry := m.entry(h, e)
+			entry.Content = ""
+			out = append(out, entry)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalHits != out[j].TotalHits {
+			return out[i].TotalHits > out[j].TotalHits
+		}
+		return out[i].LastHitAt.After(out[j].LastHitAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// SearchContent returns entries whose query or content contain every word
+// of q, ignoring case, most recently stored first. The snippet is the
+// start of the content; there is no ranking.
+func (m *MemoryStore) SearchContent(ctx context.Context, q string, limit int) ([]cache.Match, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("cache: search content: %w", err)
+	}
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []cache.Match
+	for h, e := range m.entries {
+		text := strings.ToLower(e.meta.Query + " " + e.content)
+		found := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		entry := m.entry(h, e)
+		snippet := e.content
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		out = append(out, cache.Match{QueryHash: h, Query: e.meta.Query, Snippet: snippet, UpdatedAt: e.updated, Stale: entry.Stale})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// AddHistory appends rec with the next ID, keeping the newest keep
+// records if keep > 0.
+func (m *MemoryStore) AddHistory(ctx context.Context, rec cache.HistoryRecord, keep int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("cache: add history: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	rec.ID = m.lastID
+	m.history = append(m.history, rec)
+	if keep > 0 && len(m.history) > keep {
+		m.history = append([]cache.HistoryRecord(nil), m.history[len(m.history)-keep:]...)
+	}
+	return rec.ID, nil
+}
+
+// History lists the records f selects, newest first; see cache.Cache.History.
+func (m *MemoryStore) History(ctx context.Context, f cache.HistoryFilter) ([]cache.HistoryRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("cache: history: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	match := strings.ToLower(f.Match)
+	var out []cache.HistoryRecord
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+		rec := m.history[i]
+		if rec.Scope == f.Scope && strings.Contains(strings.ToLower(rec.Query), match) {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+// HistoryRecord returns the record with id, if it is still kept.
+func (m *MemoryStore) HistoryRecord(ctx context.Context, id int64) (cache.HistoryRecord, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.HistoryRecord{}, false, fmt.Errorf("cache: history record %d: %w", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range m.history {
+		if rec.ID == id {
+			return rec, true, nil
+		}
+	}
+	return cache.HistoryRecord{}, false, nil
+}
+
+// GetPage returns the page stored under key, unless it has expired.
+func (m *MemoryStore) GetPage(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, fmt.Errorf("cache: get page %q: %w", key, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.pages[key]
+	if !ok || !time.Now().Before(p.expires) {
+		return nil, false, nil
+	}
+	return p.data, true, nil
+}
+
+// PutPage stores page under key for ttl.
+func (m *MemoryStore) PutPage(ctx context.Context, key string, page []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cache: put page %q: %w", key, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pages == nil {
+		m.pages = map[string]memPage{}
+	}
+	m.pages[key] = memPage{data: page, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// ReserveContext is like Reserve but grants nothing once ctx is done.
+func (m *MemoryStore) ReserveContext(ctx context.Context, kind string, n int, limits []cache.UsageLimit) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("cache: reserve %s: %w", kind, err)
+	}
+	return m.Reserve(kind, n, limits)
+}
+
+// Reserve grants up to n units of kind within limits; see cache.Cache.Reserve.
+func (m *MemoryStore) Reserve(kind string, n int, limits []cache.UsageLimit) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	granted := n
+	for _, l := range limits {
+		if l.Max <= 0 {
+			continue
+		}
+		used := 0
+		for _, ev := range m.usage[kind] {
+			if now.Sub(ev.at) < l.Window {
+				used += ev.n
+			}
+		}
+		granted = min(granted, max(l.Max-used, 0))
+	}
+	if granted > 0 {
+		if m.usage == nil {
+			m.usage = map[string][]usageEvent{}
+		}
+		m.usage[kind] = append(m.usage[kind], usageEvent{at: now, n: granted})
+	}
+	return granted, nil
+}
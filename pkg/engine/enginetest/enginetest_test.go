@@ -0,0 +1,1865 @@
+package enginetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
+	"github.com/user/glsi/pkg/metrics"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+func TestPipelineWithStubs(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics": {
+			{URL: "https://go.dev/doc/tutorial/generics"},
+			{URL: "https://example.com/broken"},
+		},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/doc/tutorial/generics": Page("Generics tutorial", "Type parameters let functions work over many types."),
+	}}
+	store := &MemoryStore{}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages})
+	ctx := context.Background()
+
+	result, err := eng.Search(ctx, "Go Generics", 5, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.FromCache || result.ResultCount != 1 || !strings.Contains(result.Content, "Type parameters") {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(result.Sources) != 1 || result.Sources[0].Title != "Generics tutorial" {
+		t.Errorf("Sources = %+v", result.Sources)
+	}
+	if len(result.Pages) != 2 || result.Pages[1].Err == nil {
+		t.Errorf("Pages = %+v, want the unknown URL to fail", result.Pages)
+	}
+
+	result, err = eng.Search(ctx, "go generics", 5, false)
+	if err != nil || !result.FromCache {
+		t.Fatalf("second Search = %+v, %v; want cache hit", result, err)
+	}
+
+	if _, err := eng.Search(ctx, "unknown", 5, false); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("unknown query: err = %v, want ErrNoResults", err)
+	}
+}
+
+func TestPipelineCacheMeta(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {
+		{URL: "https://a.example/"}, {URL: "https://broken.example/"}, {URL: "https://b.example/"},
+	}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Alpha."),
+		"https://b.example/": Page("B", "## Not a header\nBeta."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, SearchEngine: "ddg"})
+	ctx := context.Background()
+
+	fresh, err := eng.Search(ctx, "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := eng.Search(ctx, "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://a.example/", "https://b.example/"}
+	for _, r := range []engine.SearchResult{fresh, cached} {
+		if r.Engine != search.EngineDuckDuckGo || r.ResultCount != 2 || !reflect.DeepEqual(r.URLs, want) {
+			t.Errorf("result from cache %v: engine %q, %d results, URLs %q", r.FromCache, r.Engine, r.ResultCount, r.URLs)
+		}
+	}
+	if !fresh.CachedAt.IsZero() || cached.CachedAt.IsZero() {
+		t.Errorf("CachedAt = %v fresh, %v cached; want only the hit stamped", fresh.CachedAt, cached.CachedAt)
+	}
+}
+
+func TestPipelineEntryTTL(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"election": {{URL: "https://a.example/"}},
+		"q":        {{URL: "https://a.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Alpha.")}}
+	store := &MemoryStore{TTL: 24 * time.Hour}
+	eng := engine.New(store, engine.Config{
+		Searcher: searcher,
+		Scraper:  pages,
+		Classifier: engine.ClassifierFunc(func(q string) engine.Intent {
+			if q == "election" {
+				return engine.IntentNews
+			}
+			return engine.IntentInformational
+		}),
+	})
+	ctx := context.Background()
+
+	expiresIn := func(query string) time.Duration {
+		t.Helper()
+		e, ok, err := store.Lookup(ctx, engine.SHA256Keys.DeriveKey("", query))
+		if err != nil || !ok {
+			t.Fatalf("Lookup(%q) = %v, %v", query, ok, err)
+		}
+		return time.Until(e.ExpiresAt).Round(time.Minute)
+	}
+
+	// News is cached for an hour by its default route; other queries get
+	// the store's TTL unless the call sets one.
+	for _, q := range []string{"election", "q"} {
+		if _, err := eng.Search(ctx, q, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := expiresIn("election"); got != time.Hour {
+		t.Errorf("news entry expires in %v, want 1h", got)
+	}
+	if got := expiresIn("q"); got != 24*time.Hour {
+		t.Errorf("informational entry expires in %v, want 24h", got)
+	}
+	if _, err := eng.SearchWithOptions(ctx, "election", engine.SearchOptions{Force: true, TTL: 10 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if got := expiresIn("election"); got != 10*time.Minute {
+		t.Errorf("explicit TTL: entry expires in %v, want 10m", got)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"a": {{URL: "https://a.example/"}},
+		"b": {{URL: "https://a.example/"}},
+		"c": {{URL: "https://a.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Alpha.")}}
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Searcher:     searcher,
+		Scraper:      pages,
+		SearchEngine: search.EngineGoogle,
+		Budget:       engine.Budget{SearchesPerHour: map[string]int{"google": 2}},
+	})
+	ctx := context.Background()
+
+	queries, err := engine.ReadQueries(strings.NewReader("# warm these\na\n\n  missing  \na\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(queries, []string{"a", "missing", "a"}) {
+		t.Fatalf("ReadQueries = %q", queries)
+	}
+	wr, err := eng.Warm(ctx, queries)
+	if err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+	if wr != (engine.WarmResult{Searched: 1, Cached: 1, Failed: 1}) {
+		t.Errorf("Warm = %+v, want 1 searched, 1 cached, 1 failed", wr)
+	}
+
+	// The budget allowed two searches and both are spent, so the pass
+	// stops at the first query that needs a third.
+	wr, err = eng.Warm(ctx, []string{"a", "b", "c"})
+	if !errors.Is(err, engine.ErrBudgetExhausted) || wr != (engine.WarmResult{Cached: 1}) {
+		t.Errorf("Warm over budget = %+v, %v", wr, err)
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go mutex":   {{URL: "https://a.example/"}},
+		"go channel": {{URL: "https://b.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Mutexes guard goroutines."),
+		"https://b.example/": Page("B", "Channels connect goroutines."),
+	}}
+	store := &MemoryStore{}
+	tenantA := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, KeyScope: "a"})
+	tenantB := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, KeyScope: "b"})
+	ctx := context.Background()
+
+	if _, err := tenantA.Search(ctx, "go mutex", 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tenantB.Search(ctx, "go channel", 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each tenant only finds its own entries; the shared store holds both.
+	got, err := tenantA.SearchCache(ctx, "goroutines", 0)
+	if err != nil {
+		t.Fatalf("SearchCache: %v", err)
+	}
+	if len(got) != 1 || got[0].Query != "go mutex" || !strings.Contains(got[0].Snippet, "Mutexes") {
+		t.Errorf("tenant a: SearchCache = %+v", got)
+	}
+	if all, _ := store.SearchContent(ctx, "goroutines", 10); len(all) != 2 {
+		t.Errorf("store: %d matches, want 2", len(all))
+	}
+}
+
+func TestTopQueries(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"hot":  {{URL: "https://a.example/"}},
+		"cold": {{URL: "https://a.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Alpha.")}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	ctx := context.Background()
+
+	for _, q := range []string{"hot", "cold", "hot", "hot"} {
+		if _, err := eng.Search(ctx, q, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A forced refresh restarts the entry's hits but not its lifetime count.
+	if _, err := eng.SearchWithOptions(ctx, "hot", engine.SearchOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	top, err := eng.TopQueries(ctx, 0)
+	if err != nil {
+		t.Fatalf("TopQueries: %v", err)
+	}
+	if len(top) != 2 || top[0].Query != "hot" || top[0].TotalHits != 2 || top[0].Hits != 0 || top[1].Query != "cold" {
+		t.Errorf("TopQueries = %+v", top)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://go.dev/doc": Page("Docs", "Documentation.")}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: StaticSearcher{}, Scraper: pages})
+	ctx := context.Background()
+
+	result, err := eng.Fetch(ctx, "https://Go.dev/doc?utm_source=x#intro")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.FromCache || result.ResultCount != 1 || result.Engine != "url" || !strings.Contains(result.Content, "Documentation.") || len(result.Pages) != 1 {
+		t.Errorf("Fetch = %+v", result)
+	}
+	// The normalized URL is cached apart from a search for the same text.
+	result, err = eng.Fetch(ctx, "https://go.dev/doc")
+	if err != nil || !result.FromCache {
+		t.Errorf("second Fetch = %+v, %v; want a cache hit", result, err)
+	}
+	if _, err := eng.Search(ctx, "https://go.dev/doc", 1, false); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("search for the URL: err = %v, want ErrNoResults rather than the fetched page", err)
+	}
+
+	if _, err := eng.Fetch(ctx, "go.dev/doc"); !errors.Is(err, engine.ErrInvalidURL) {
+		t.Errorf("relative URL: err = %v, want ErrInvalidURL", err)
+	}
+	if _, err := eng.Fetch(ctx, "https://missing.example/"); !errors.Is(err, engine.ErrScrapeFailed) {
+		t.Errorf("failed page: err = %v, want ErrScrapeFailed", err)
+	}
+
+	if _, err := eng.FetchWithOptions(ctx, "https://go.dev/doc", engine.SearchOptions{Summarize: true}); !errors.Is(err, engine.ErrNoSummarizer) {
+		t.Errorf("summarize without a summarizer: err = %v", err)
+	}
+	summarizer := engine.SummarizerFunc(func(ctx context.Context, query, content string) (string, error) {
+		return fmt.Sprintf("summary of %d bytes for %q", len(content), query), nil
+	})
+	summarized := engine.New(&MemoryStore{}, engine.Config{Scraper: pages, Summarizer: summarizer})
+	result, err = summarized.FetchWithOptions(ctx, "https://go.dev/doc", engine.SearchOptions{Summarize: true})
+	if err != nil || !result.Summarized || !strings.HasSuffix(result.Content, `for ""`) {
+		t.Errorf("summarized Fetch = %+v, %v", result, err)
+	}
+
+	budgeted := engine.New(&MemoryStore{}, engine.Config{Scraper: pages, Budget: engine.Budget{PagesPerDay: 1}})
+	budgeted.Fetch(ctx, "https://go.dev/doc")
+	if _, err := budgeted.FetchWithOptions(ctx, "https://go.dev/doc", engine.SearchOptions{Force: true}); !errors.Is(err, engine.ErrBudgetExhausted) {
+		t.Errorf("over budget: err = %v, want ErrBudgetExhausted", err)
+	}
+}
+
+func TestFetchFollowLinks(t *testing.T) {
+	landing := Page("Generics", "Generics in Go.")
+	landing.Description = "An introduction to generics."
+	landing.Links = []scraper.Link{
+		{URL: "https://go.dev/about", Text: "About"},
+		{URL: "https://go.dev/doc/tutorial/generics", Text: "Tutorial: Getting started with generics"},
+		{URL: "https://go.dev/blog/intro-generics", Text: "An Introduction To Generics"},
+		{URL: "https://example.com/generics", Text: "Generics elsewhere"},
+	}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/generics":              landing,
+		"https://go.dev/doc/tutorial/generics": Page("Getting started with generics", "Declare a generic function."),
+		"https://go.dev/blog/intro-generics":   Page("An Introduction To Generics", "Type parameters."),
+		"https://example.com/generics":         Page("Elsewhere", "Another site."),
+	}}
+	ctx := context.Background()
+
+	// Config.Depth applies to searches only.
+	eng := engine.New(&MemoryStore{}, engine.Config{Scraper: pages, Depth: 1})
+	result, err := eng.Fetch(ctx, "https://go.dev/generics")
+	if err != nil || result.ResultCount != 1 {
+		t.Fatalf("Fetch = %+v, %v; want the page alone", result, err)
+	}
+
+	result, err = eng.FetchWithOptions(ctx, "https://go.dev/generics", engine.SearchOptions{Depth: 1, FollowLinks: 1})
+	if err != nil {
+		t.Fatalf("FetchWithOptions: %v", err)
+	}
+	if result.FromCache || result.ResultCount != 2 || len(result.Pages) != 2 || len(result.URLs) != 2 {
+		t.Fatalf("following one link = %+v, want a fresh result with two pages", result)
+	}
+	if p := result.Pages[1]; p.URL != "https://go.dev/blog/intro-generics" || p.FollowedFrom != "https://go.dev/generics" {
+		t.Errorf("Pages[1] = %+v, want the best-matching same-site link", p)
+	}
+	if strings.Contains(result.Content, "Another site.") {
+		t.Error("followed a link to another site")
+	}
+	if again, err := eng.FetchWithOptions(ctx, "https://go.dev/generics", engine.SearchOptions{Depth: 1, FollowLinks: 1}); err != nil || !again.FromCache || again.ResultCount != 2 {
+		t.Errorf("second follow = %+v, %v; want a cache hit", again, err)
+	}
+
+	// The follow links count against the page budget; past it the page
+	// is returned alone.
+	budgeted := engine.New(&MemoryStore{}, engine.Config{Scraper: pages, Budget: engine.Budget{PagesPerDay: 2}})
+	result, err = budgeted.FetchWithOptions(ctx, "https://go.dev/generics", engine.SearchOptions{Depth: 1})
+	if err != nil || result.ResultCount != 2 {
+		t.Errorf("over budget = %+v, %v; want the page and one followed link", result, err)
+	}
+}
+
+func TestPipelineSponsored(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {
+			{URL: "https://ads.example/buy", Sponsored: true},
+			{URL: "https://go.dev/doc"},
+		},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://ads.example/buy": Page("Buy now", "Limited offer."),
+		"https://go.dev/doc":      Page("Docs", "Documentation."),
+	}}
+	ctx := context.Background()
+
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	result, err := eng.Search(ctx, "q", 5, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.ResultCount != 1 || strings.Contains(result.Content, "Limited offer") {
+		t.Errorf("default config scraped the ad: %+v", result)
+	}
+
+	eng = engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, IncludeSponsored: true})
+	result, err = eng.Search(ctx, "q", 5, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Sources) != 2 || !result.Sources[0].Sponsored || result.Sources[1].Sponsored {
+		t.Errorf("Sources = %+v, want only the ad tagged", result.Sources)
+	}
+	// The tag survives the cache.
+	result, err = eng.Search(ctx, "q", 5, false)
+	if err != nil || !result.FromCache || len(result.Sources) != 2 || !result.Sources[0].Sponsored {
+		t.Errorf("cached Sources = %+v, %v", result.Sources, err)
+	}
+}
+
+func TestPipelineHonorsDeadline(t *testing.T) {
+	var searched, scraped int
+	searcher := engine.SearcherFunc(func(ctx context.Context, query string, count int, name string) ([]search.Result, *search.InstantAnswer, error) {
+		searched++
+		return []search.Result{{URL: "https://example.com/slow"}}, nil, nil
+	})
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		scraped++
+		<-ctx.Done() // every fetch runs out the clock
+		return []scraper.ScrapedPage{{URL: urls[0], Err: ctx.Err()}}
+	})
+	store := &MemoryStore{}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages})
+
+	// An expired context short-circuits before any upstream request.
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := eng.Search(expired, "q", 5, true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expired: err = %v, want context.Canceled", err)
+	}
+	if searched != 0 || scraped != 0 {
+		t.Fatalf("searched %d, scraped %d times with an expired context", searched, scraped)
+	}
+
+	// A deadline that passes while scraping is reported as such, not as a
+	// scrape failure.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := eng.Search(ctx, "q", 5, true)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, engine.ErrScrapeFailed) {
+		t.Errorf("deadline during scrape: err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestPipelinePartial(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://fast.example/"}, {URL: "https://slow.example/"}},
+	}}
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			if u == "https://slow.example/" {
+				<-ctx.Done()
+				out[i] = scraper.ScrapedPage{URL: u, Err: ctx.Err()}
+				continue
+			}
+			out[i] = Page("Fast", "Finished in time.")
+			out[i].URL = u
+		}
+		return out
+	})
+	store := &MemoryStore{}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages})
+
+	// The scrapes stop short of the caller's deadline, leaving time to
+	// return the page that finished.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result, err := eng.Search(ctx, "q", 5, true)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !result.Partial || result.ResultCount != 1 || !strings.Contains(result.Content, "Finished in time.") {
+		t.Errorf("result = %+v, want a partial result with the fast page", result)
+	}
+	if ctx.Err() != nil {
+		t.Error("search returned after the caller's deadline")
+	}
+	if top, _ := store.Top(context.Background(), 10); len(top) != 0 {
+		t.Errorf("cached %+v, want partial results left out of the cache", top)
+	}
+}
+
+func TestMemoryStorePinning(t *testing.T) {
+	var m MemoryStore
+	m.Set("a", "alpha", cache.Meta{})
+	m.Set("b", "beta", cache.Meta{})
+	if err := m.Pin("a", "query a"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if err := m.Pin("missing", "q"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Pin missing: err = %v", err)
+	}
+	if err := m.Clear(""); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if _, hit, _ := m.Get("a"); !hit {
+		t.Error("pinned entry flushed")
+	}
+	if _, hit, _ := m.Get("b"); hit {
+		t.Error("unpinned entry survived flush")
+	}
+	if err := m.Clear("a"); !errors.Is(err, cache.ErrPinned) {
+		t.Errorf("Clear pinned: err = %v", err)
+	}
+	pins, _ := m.Pinned()
+	if len(pins) != 1 || pins[0].Query != "query a" || pins[0].Size != 5 {
+		t.Errorf("Pinned = %+v", pins)
+	}
+}
+
+func TestMemoryStoreReserve(t *testing.T) {
+	var m MemoryStore
+	limits := []cache.UsageLimit{{Window: 1 << 40, Max: 3}}
+	for _, tt := range []struct{ n, want int }{{2, 2}, {2, 1}, {1, 0}} {
+		if got, _ := m.Reserve("pages", tt.n, limits); got != tt.want {
+			t.Errorf("Reserve(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+	if got, _ := m.Reserve("pages", 5, nil); got != 5 {
+		t.Errorf("unlimited Reserve = %d, want 5", got)
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := &Fake{Results: map[string]engine.SearchResult{
+		"golang": {Content: "## Go\n\nA language.", ResultCount: 1},
+	}}
+	ctx := context.Background()
+
+	r, err := f.SearchWithOptions(ctx, " GoLang ", engine.SearchOptions{Summarize: true})
+	if err != nil || r.ResultCount != 1 || !r.Summarized {
+		t.Fatalf("SearchWithOptions = %+v, %v", r, err)
+	}
+	if _, err := f.SearchWithOptions(ctx, "rust", engine.SearchOptions{}); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("unknown query: err = %v", err)
+	}
+	if err := f.Pin(ctx, "golang"); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if err := f.ClearCache("golang"); !errors.Is(err, cache.ErrPinned) {
+		t.Errorf("ClearCache pinned: err = %v", err)
+	}
+	if err := f.Unpin("rust"); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Unpin missing: err = %v", err)
+	}
+	if calls := f.Calls(); len(calls) != 5 || calls[0].Query != " GoLang " || !calls[0].Opts.Summarize {
+		t.Errorf("Calls = %+v", calls)
+	}
+
+	f.Pages = map[string]engine.SearchResult{"https://go.dev/": {Content: "## Go", ResultCount: 1}}
+	if r, err := f.FetchWithOptions(ctx, "https://go.dev/", engine.SearchOptions{}); err != nil || r.ResultCount != 1 {
+		t.Errorf("FetchWithOptions = %+v, %v", r, err)
+	}
+	if _, err := f.FetchWithOptions(ctx, "go.dev", engine.SearchOptions{}); !errors.Is(err, engine.ErrInvalidURL) {
+		t.Errorf("FetchWithOptions relative URL: err = %v", err)
+	}
+
+	f.Results["go"] = engine.SearchResult{ResultCount: 1, Sources: []engine.Source{{Title: "Go", URL: "https://go.dev/"}}}
+	var kinds []engine.StreamKind
+	if _, err := f.SearchStream(ctx, "go", engine.SearchOptions{}, func(u engine.StreamUpdate) { kinds = append(kinds, u.Kind) }); err != nil || len(kinds) != 2 || kinds[1] != engine.StreamPage {
+		t.Errorf("SearchStream = %v, updates %v", err, kinds)
+	}
+
+	boom := errors.New("boom")
+	failing := Failing(boom)
+	if _, err := failing.SearchWithOptions(ctx, "golang", engine.SearchOptions{}); !errors.Is(err, boom) {
+		t.Errorf("Failing search: err = %v", err)
+	}
+	if _, err := failing.Pinned(); !errors.Is(err, boom) {
+		t.Errorf("Failing Pinned: err = %v", err)
+	}
+}
+
+func TestPipelineFeatureFlags(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://go.dev/doc"}},
+	}}
+	var render string
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		render = opts.Render
+		return []scraper.ScrapedPage{Page("Docs", "Documentation.")}
+	})
+	features, err := engine.NewFeatures(
+		map[string]bool{engine.FeatureRender: false},
+		map[string]map[string]bool{"beta": {engine.FeatureRender: true}},
+	)
+	if err != nil {
+		t.Fatalf("NewFeatures: %v", err)
+	}
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Searcher: searcher, Scraper: pages, Render: scraper.RenderAuto, Features: features,
+	})
+	ctx := context.Background()
+
+	// Asking for rendering where it is off fails...
+	_, err = eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Render: scraper.RenderAlways})
+	if !errors.Is(err, engine.ErrFeatureDisabled) {
+		t.Fatalf("render=always: err = %v, want ErrFeatureDisabled", err)
+	}
+	// ...while the configured default quietly drops to static fetches.
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Force: true}); err != nil {
+		t.Fatalf("default render: %v", err)
+	}
+	if render != scraper.RenderNever {
+		t.Errorf("default render mode = %q, want %q", render, scraper.RenderNever)
+	}
+
+	// A key with an override gets rendering.
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Force: true, Render: scraper.RenderAlways, Key: "beta"}); err != nil {
+		t.Fatalf("render=always with key: %v", err)
+	}
+	if render != scraper.RenderAlways {
+		t.Errorf("render mode with key = %q, want %q", render, scraper.RenderAlways)
+	}
+
+	// Flags change at runtime.
+	if err := features.Set(engine.FeatureRender, "", true); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Force: true, Render: scraper.RenderAlways}); err != nil {
+		t.Errorf("render=always after enabling: %v", err)
+	}
+}
+
+func TestPipelineFeatureFlagsResearch(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://go.dev/doc"}},
+	}}
+	features, err := engine.NewFeatures(
+		map[string]bool{engine.FeatureResearch: false},
+		map[string]map[string]bool{"beta": {engine.FeatureResearch: true}},
+	)
+	if err != nil {
+		t.Fatalf("NewFeatures: %v", err)
+	}
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Searcher: searcher, Scraper: StaticScraper{Pages: map[string]scraper.ScrapedPage{
+			"https://go.dev/doc": Page("Docs", "Documentation."),
+		}}, Features: features,
+	})
+	ctx := context.Background()
+
+	if _, err := eng.DeepResearch(ctx, "q", engine.ResearchOptions{}); !errors.Is(err, engine.ErrFeatureDisabled) {
+		t.Errorf("DeepResearch: err = %v, want ErrFeatureDisabled", err)
+	}
+	if _, err := eng.DeepResearch(ctx, "q", engine.ResearchOptions{Depth: -1, Search: engine.SearchOptions{Key: "beta"}}); err != nil {
+		t.Errorf("DeepResearch with key: %v", err)
+	}
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{}); err != nil {
+		t.Errorf("search with research off: %v", err)
+	}
+}
+
+func TestPipelineFeatureFlagsSite(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://go.dev/doc"}},
+	}}
+	features, err := engine.NewFeatures(map[string]bool{engine.FeatureCrawlSite: false}, nil)
+	if err != nil {
+		t.Fatalf("NewFeatures: %v", err)
+	}
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Searcher: searcher, Scraper: StaticScraper{Pages: map[string]scraper.ScrapedPage{
+			"https://go.dev/doc": Page("Docs", "Documentation."),
+		}}, Features: features,
+	})
+	ctx := context.Background()
+
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Site: "go.dev"}); !errors.Is(err, engine.ErrFeatureDisabled) {
+		t.Errorf("site search: err = %v, want ErrFeatureDisabled", err)
+	}
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{}); err != nil {
+		t.Errorf("plain search with crawl_site off: %v", err)
+	}
+}
+
+func TestPipelineEvents(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics": {
+			{URL: "https://go.dev/doc/tutorial/generics"},
+			{URL: "https://example.com/broken"},
+		},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/doc/tutorial/generics": Page("Generics tutorial", "Type parameters let functions work over many types."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	events, cancel := eng.Subscribe(0)
+	ctx := context.Background()
+
+	eng.Search(ctx, "go generics", 5, false)
+	eng.Search(ctx, "go generics", 5, false)
+	eng.Search(ctx, "unknown", 5, false)
+	cancel()
+
+	var got []string
+	ids := map[uint64]bool{}
+	for ev := range events {
+		ids[ev.SearchID] = true
+		s := string(ev.Kind)
+		switch ev.Kind {
+		case engine.EventSERPParsed, engine.EventSearchDone:
+			s += fmt.Sprintf("(%d)", ev.Results)
+		case engine.EventPageScraped:
+			s += fmt.Sprintf("(%s ok=%v)", ev.Page.URL, ev.Page.Err == nil)
+		case engine.EventError:
+			if !errors.Is(ev.Err, engine.ErrNoResults) {
+				t.Errorf("error event = %v, want ErrNoResults", ev.Err)
+			}
+		}
+		got = append(got, s)
+	}
+	// The StaticScraper ignores OnPage, so page events arrive in URL order
+	// once the batch returns.
+	want := []string{
+		"search_started", "serp_parsed(2)",
+		"page_scraped(https://go.dev/doc/tutorial/generics ok=true)",
+		"page_scraped(https://example.com/broken ok=false)",
+		"cache_write", "search_done(1)",
+		"search_started", "cache_hit", "search_done(1)",
+		"search_started", "error",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(ids) != 3 {
+		t.Errorf("search IDs = %v, want 3 distinct", ids)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics": {
+			{URL: "https://go.dev/doc/tutorial/generics", Title: "Tutorial"},
+			{URL: "https://example.com/broken"},
+		},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/doc/tutorial/generics": Page("Generics tutorial", "Type parameters let functions work over many types."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	ctx := context.Background()
+
+	var got []engine.StreamUpdate
+	result, err := eng.SearchStream(ctx, "go generics", engine.SearchOptions{}, func(u engine.StreamUpdate) {
+		got = append(got, u)
+	})
+	if err != nil || result.ResultCount != 1 {
+		t.Fatalf("SearchStream = %+v, %v", result, err)
+	}
+	if len(got) != 3 || got[0].Kind != engine.StreamResults || len(got[0].Results) != 2 || got[0].Results[0].Title != "Tutorial" {
+		t.Fatalf("updates = %+v, want results then two pages", got)
+	}
+	ok, broken := got[1].Page, got[2].Page
+	if got[1].Kind != engine.StreamPage || ok.Title != "Generics tutorial" || !strings.Contains(ok.Content, "## Generics tutorial — https://go.dev/doc/tutorial/generics") || !strings.Contains(ok.Content, "Type parameters") {
+		t.Errorf("first page = %+v", ok)
+	}
+	if got[2].Kind != engine.StreamPage || broken.URL != "https://example.com/broken" || broken.Err == nil || broken.Content != "" {
+		t.Errorf("failed page = %+v", broken)
+	}
+
+	// A cache hit streams nothing.
+	got = nil
+	result, err = eng.SearchStream(ctx, "go generics", engine.SearchOptions{}, func(u engine.StreamUpdate) {
+		got = append(got, u)
+	})
+	if err != nil || !result.FromCache || len(got) != 0 {
+		t.Errorf("cached SearchStream = %+v, %v; updates %+v", result, err, got)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://a.example/"}, {URL: "https://broken.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Alpha."),
+	}}
+	reg := metrics.New("glsi")
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, Metrics: reg})
+	ctx := context.Background()
+
+	eng.Search(ctx, "q", 5, false)
+	eng.Search(ctx, "q", 5, false)
+	eng.Search(ctx, "unknown", 5, false)
+	eng.Fetch(ctx, "https://a.example/")
+
+	if got := reg.Counter(engine.MetricSearches, "kind", "fetch", "outcome", "fresh"); got != 1 {
+		t.Errorf("fresh fetches = %v, want 1", got)
+	}
+	s := eng.Stats().Searches
+	if s == nil {
+		t.Fatal("Stats().Searches = nil with a metrics.Registry")
+	}
+	if s.Searches != 4 || s.CacheHits != 1 || s.Errors != 1 || s.HitRatio() != 0.25 {
+		t.Errorf("searches = %+v, hit ratio %v", s, s.HitRatio())
+	}
+	if s.Pages != 3 || s.PagesFailed != 1 || s.PageSuccessRate() < 0.66 || s.PageSuccessRate() > 0.67 {
+		t.Errorf("pages = %+v, success rate %v", s, s.PageSuccessRate())
+	}
+	if _, ok := s.Stages["total"]; !ok {
+		t.Errorf("stages = %v, want a total", s.Stages)
+	}
+
+	if engine.New(&MemoryStore{}, engine.Config{}).Stats().Searches != nil {
+		t.Error("Stats().Searches set without Metrics")
+	}
+}
+
+func TestPipelineLogging(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {{URL: "https://a.example/"}, {URL: "https://broken.example/"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Alpha."),
+	}}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, SearchEngine: "ddg", Logger: logger})
+	ctx := context.Background()
+
+	eng.Search(ctx, "q", 5, false)
+	eng.Search(ctx, "unknown", 5, false)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	find := func(msg string) string {
+		for _, l := range lines {
+			if strings.Contains(l, `msg="`+msg+`"`) {
+				return l
+			}
+		}
+		t.Errorf("no %q log in:\n%s", msg, buf.String())
+		return ""
+	}
+	if l := find("page scraped"); !strings.Contains(l, "level=DEBUG") || !strings.Contains(l, "url=https://a.example/") {
+		t.Errorf("page log = %s", l)
+	}
+	if l := find("page failed"); !strings.Contains(l, "level=WARN") || !strings.Contains(l, "url=https://broken.example/") {
+		t.Errorf("failed page log = %s", l)
+	}
+	if l := find("search done"); !strings.Contains(l, "search_id=1 query_hash=") || !strings.Contains(l, "engine=duckduckgo results=1 from_cache=false") {
+		t.Errorf("search log = %s", l)
+	}
+	if l := find("search failed"); !strings.Contains(l, "search_id=2") || !strings.Contains(l, "no search results") {
+		t.Errorf("failure log = %s", l)
+	}
+}
+
+func TestPipelineBreaker(t *testing.T) {
+	searcher := engine.SearcherFunc(func(ctx context.Context, query string, count int, name string) ([]search.Result, *search.InstantAnswer, error) {
+		if name == search.EngineGoogle {
+			return nil, nil, search.ErrBlocked
+		}
+		return []search.Result{{URL: "https://a.example/" + query}, {URL: "https://down.example/" + query}}, nil, nil
+	})
+	var downFetches atomic.Int32
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			if strings.HasPrefix(u, "https://down.example/") {
+				downFetches.Add(1)
+				out[i] = scraper.ScrapedPage{URL: u, Status: 503, Err: errors.New("unexpected status 503")}
+				continue
+			}
+			out[i] = Page("A", "Alpha.")
+			out[i].URL = u
+		}
+		return out
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, SearchEngine: "google",
+		Breaker: &engine.BreakerPolicy{Threshold: 2, Cooldown: time.Hour}})
+	ctx := context.Background()
+
+	// Two blocked searches open Google's breaker; the third falls back.
+	for _, q := range []string{"q1", "q2"} {
+		if _, err := eng.Search(ctx, q, 5, false); !errors.Is(err, search.ErrBlocked) {
+			t.Fatalf("%s: err = %v, want ErrBlocked", q, err)
+		}
+	}
+	for _, q := range []string{"q3", "q4", "q5"} {
+		result, err := eng.Search(ctx, q, 5, false)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if result.Engine != search.EngineDuckDuckGo {
+			t.Errorf("%s: engine = %q, want the fallback", q, result.Engine)
+		}
+	}
+
+	// down.example failed on q3 and q4, so q5 skipped it without a fetch.
+	if n := downFetches.Load(); n != 2 {
+		t.Errorf("down.example fetched %d times, want 2", n)
+	}
+	result, _ := eng.Search(ctx, "q6", 5, false)
+	if err := result.Pages[1].Err; !errors.Is(err, engine.ErrCircuitOpen) {
+		t.Errorf("down.example page err = %v, want ErrCircuitOpen", err)
+	}
+	open := eng.Stats().OpenCircuits
+	if _, ok := open["engine:google"]; !ok || len(open) != 2 {
+		t.Errorf("OpenCircuits = %v, want engine:google and site:down.example", open)
+	}
+	if _, ok := open["site:down.example"]; !ok {
+		t.Errorf("OpenCircuits = %v, want site:down.example", open)
+	}
+}
+
+func TestSelfCheckBudgetAndBreaker(t *testing.T) {
+	var sent atomic.Int32
+	searcher := engine.SearcherFunc(func(ctx context.Context, query string, count int, engineName string) ([]search.Result, *search.InstantAnswer, error) {
+		sent.Add(1)
+		if engineName == search.EngineDuckDuckGo {
+			return nil, nil, search.ErrBlocked
+		}
+		return []search.Result{{URL: "https://en.wikipedia.org/"}}, nil, nil
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher,
+		Budget:  engine.Budget{SearchesPerHour: map[string]int{"google": 1}},
+		Breaker: &engine.BreakerPolicy{Threshold: 1, Cooldown: time.Hour}})
+	ctx := context.Background()
+
+	checks, err := eng.SelfCheck(ctx, nil, 2)
+	if err != nil {
+		t.Fatalf("SelfCheck: %v", err)
+	}
+	google, ddg := checks[0], checks[1]
+	if google.Verdict != search.VerdictClean || len(google.Probes) != 2 || !errors.Is(google.Probes[1].Err, engine.ErrBudgetExhausted) {
+		t.Errorf("google = %+v, want one clean probe, then the budget spent", google)
+	}
+	if ddg.Verdict != search.VerdictBlocked {
+		t.Errorf("duckduckgo = %+v, want blocked", ddg)
+	}
+	if n := sent.Load(); n != 2 {
+		t.Errorf("%d probes sent, want 2", n)
+	}
+
+	// The probes spent Google's budget and opened DuckDuckGo's breaker, so
+	// searches and further checks are refused without a request.
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Engine: "google"}); !errors.Is(err, engine.ErrBudgetExhausted) {
+		t.Errorf("search after the check: err = %v, want ErrBudgetExhausted", err)
+	}
+	checks, err = eng.SelfCheck(ctx, nil, 2)
+	if err != nil {
+		t.Fatalf("second SelfCheck: %v", err)
+	}
+	for _, c := range checks {
+		if c.Verdict != search.VerdictSkipped || len(c.Probes) != 1 || !errors.Is(c.Probes[0].Err, search.ErrProbeSkipped) {
+			t.Errorf("second check of %s = %+v, want skipped", c.Engine, c)
+		}
+	}
+	if !errors.Is(checks[1].Probes[0].Err, engine.ErrCircuitOpen) {
+		t.Errorf("duckduckgo probe err = %v, want ErrCircuitOpen", checks[1].Probes[0].Err)
+	}
+	if n := sent.Load(); n != 2 {
+		t.Errorf("%d probes sent after the second check, want 2", n)
+	}
+}
+
+func TestPipelineHooks(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"golang": {
+		{URL: "https://a.example/"}, {URL: "https://b.example/"}, {URL: "https://c.example/"},
+	}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Alpha by alice@example.com."),
+		"https://b.example/": Page("B", "Beta."),
+		"https://c.example/": Page("C", "Gamma."),
+	}}
+	errBlocked := errors.New("host blocked")
+	var preScraped []string
+	hooks := []engine.Hook{
+		{
+			PreSearch: func(ctx context.Context, query string, opts engine.SearchOptions) (string, engine.SearchOptions, error) {
+				if query == "forbidden" {
+					return "", opts, engine.ErrGuardrail
+				}
+				return strings.TrimSpace(strings.ReplaceAll(query, "alice@example.com", "")), opts, nil
+			},
+			PostSearch: func(ctx context.Context, query string, results []search.Result) ([]search.Result, error) {
+				var out []search.Result
+				for i := len(results) - 1; i >= 0; i-- {
+					if results[i].URL != "https://c.example/" {
+						out = append(out, results[i])
+					}
+				}
+				return out, nil
+			},
+			PreScrape: func(ctx context.Context, url string) error {
+				preScraped = append(preScraped, url)
+				if url == "https://b.example/" {
+					return errBlocked
+				}
+				return nil
+			},
+		},
+		{
+			PostConsolidate: func(ctx context.Context, query, content string) (string, error) {
+				return strings.ReplaceAll(content, "alice@example.com", "[email]"), nil
+			},
+		},
+	}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, Hooks: hooks})
+	ctx := context.Background()
+
+	result, err := eng.Search(ctx, "golang alice@example.com", 5, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Pages) != 2 || result.Pages[0].URL != "https://b.example/" || !errors.Is(result.Pages[0].Err, errBlocked) {
+		t.Errorf("Pages = %+v, want b.example refused then a.example", result.Pages)
+	}
+	if strings.Contains(result.Content, "alice@example.com") || !strings.Contains(result.Content, "Alpha by [email].") {
+		t.Errorf("Content = %q, want the address scrubbed", result.Content)
+	}
+	if strings.Join(preScraped, " ") != "https://b.example/ https://a.example/" {
+		t.Errorf("PreScrape saw %v", preScraped)
+	}
+
+	// The scrubbed query is the cache key, and the cache holds the scrubbed
+	// content.
+	result, err = eng.Search(ctx, "golang", 5, false)
+	if err != nil || !result.FromCache || !strings.Contains(result.Content, "[email]") {
+		t.Errorf("cached Search = %+v, %v", result, err)
+	}
+
+	if _, err := eng.Search(ctx, "forbidden", 5, false); !errors.Is(err, engine.ErrGuardrail) {
+		t.Errorf("refused query: err = %v, want ErrGuardrail", err)
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics":   {{URL: "https://go.dev/doc/"}, {URL: "https://a.example/generics"}},
+		"go channels":   {{URL: "https://go.dev/doc/"}, {URL: "https://b.example/channels"}},
+		"go interfaces": {{URL: "https://go.dev/doc/"}},
+	}}
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			mu.Lock()
+			fetches[u]++
+			mu.Unlock()
+			out[i] = Page(u, "Text of "+u+".")
+			out[i].URL = u
+		}
+		return out
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, BatchConcurrency: 2})
+
+	queries := []string{"go generics", "go channels", "go interfaces", "go generics", "go nothing"}
+	results := eng.SearchBatch(context.Background(), queries, engine.SearchOptions{})
+	if len(results) != len(queries) {
+		t.Fatalf("got %d results, want %d", len(results), len(queries))
+	}
+	for i, r := range results[:4] {
+		if r.Query != queries[i] || r.Err != nil || !strings.Contains(r.Result.Content, "Text of https://go.dev/doc/.") {
+			t.Errorf("results[%d] = %+v", i, r)
+		}
+	}
+	if !errors.Is(results[4].Err, engine.ErrNoResults) {
+		t.Errorf("results[4].Err = %v, want ErrNoResults", results[4].Err)
+	}
+	for u, n := range fetches {
+		if n != 1 {
+			t.Errorf("%s fetched %d times, want once", u, n)
+		}
+	}
+	if len(fetches) != 3 {
+		t.Errorf("fetched %v, want 3 pages", fetches)
+	}
+
+	// The shared page was fetched by one query and reused by the others.
+	var reused int
+	for _, r := range results[:3] {
+		for _, p := range r.Result.Pages {
+			if p.URL == "https://go.dev/doc/" && p.Cached {
+				reused++
+			}
+		}
+	}
+	if reused != 2 {
+		t.Errorf("shared page reused by %d queries, want 2", reused)
+	}
+}
+
+func TestSearchBatchTrimsSharedPages(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics": {{URL: "https://go.dev/doc/"}},
+		"go channels": {{URL: "https://go.dev/doc/"}},
+	}}
+	text := "## Generics\n\nType parameters for generics.\n\n## Channels\n\nChannels connect goroutines."
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://go.dev/doc/": Page("Docs", text)}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, BatchConcurrency: 1})
+
+	results := eng.SearchBatch(context.Background(), []string{"go generics", "go channels"}, engine.SearchOptions{})
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("%q: %v", r.Query, r.Err)
+		}
+	}
+	// The query that fetched the page keeps all of it; the one reusing it
+	// gets only the sections it asked about.
+	fetcher, reuser := results[0], results[1]
+	if fetcher.Result.Pages[0].Cached {
+		fetcher, reuser = reuser, fetcher
+	}
+	if c := fetcher.Result.Content; !strings.Contains(c, "Type parameters") || !strings.Contains(c, "connect goroutines") {
+		t.Errorf("%q fetched the page, content = %q, want all of it", fetcher.Query, c)
+	}
+	want, other := "Type parameters", "connect goroutines"
+	if reuser.Query == "go channels" {
+		want, other = other, want
+	}
+	if c := reuser.Result.Content; !strings.Contains(c, want) || strings.Contains(c, other) {
+		t.Errorf("%q reused the page, content = %q, want only its own section", reuser.Query, c)
+	}
+}
+
+func TestDeepResearch(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go generics":                 {{URL: "https://a.example/generics"}, {URL: "https://b.example/generics"}},
+		"go generics type parameters": {{URL: "https://a.example/generics"}, {URL: "https://d.example/params"}},
+	}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/generics":          Page("A", "Intro.\n\n## Type parameters\n\nFunctions take them.\n\n### Constraints\n\nSee the [generics tutorial](https://c.example/generics-tutorial)."),
+		"https://b.example/generics":          Page("B", "## Type Parameters\n\nMore.\n\n## See also\n\n[Home](https://b.example/)"),
+		"https://d.example/params":            Page("D", "Parameters in depth."),
+		"https://c.example/generics-tutorial": Page("C", "A tutorial."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+
+	report, err := eng.DeepResearch(context.Background(), "go generics", engine.ResearchOptions{Depth: 1, MaxPages: 10})
+	if err != nil {
+		t.Fatalf("DeepResearch: %v", err)
+	}
+	if len(report.Rounds) != 2 {
+		t.Fatalf("got %d rounds, want 2", len(report.Rounds))
+	}
+	// The heading on both pages leads, then the other; the link matching
+	// the query is followed, the one on the See also list is not.
+	var got []string
+	for _, s := range report.Rounds[1].Steps {
+		got = append(got, s.Query+s.URL)
+	}
+	want := []string{"go generics Type parameters", "go generics Constraints", "https://c.example/generics-tutorial"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round 2 = %q, want %q", got, want)
+	}
+	if err := report.Rounds[1].Steps[1].Err; !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("follow-up without results: err = %v, want ErrNoResults", err)
+	}
+	for _, s := range []string{`# Round 1: search "go generics"`, `# Round 2: search "go generics Type parameters"`, "# Round 2: link https://c.example/generics-tutorial", "Parameters in depth.", "A tutorial."} {
+		if !strings.Contains(report.Content, s) {
+			t.Errorf("Content lacks %q:\n%s", s, report.Content)
+		}
+	}
+	if len(report.Sources) != 4 {
+		t.Errorf("Sources = %+v, want a, b, d and c once each", report.Sources)
+	}
+	if report.Pages != 5 {
+		t.Errorf("Pages = %d, want 5", report.Pages)
+	}
+
+	// The page budget ends the research after the first round.
+	report, err = eng.DeepResearch(context.Background(), "go generics", engine.ResearchOptions{MaxPages: 2})
+	if err != nil || len(report.Rounds) != 1 {
+		t.Errorf("MaxPages 2: %d rounds, %v; want 1", len(report.Rounds), err)
+	}
+	if _, err := eng.DeepResearch(context.Background(), "go nothing", engine.ResearchOptions{}); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("failed first search: err = %v, want ErrNoResults", err)
+	}
+}
+
+func TestPipelineThinPages(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {
+		{URL: "https://a.example/"}, {URL: "https://b.example/"}, {URL: "https://c.example/"}, {URL: "https://d.example/"}, {URL: "https://e.example/"},
+	}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Accept cookies"),
+		"https://b.example/": Page("B", "A page with plenty of text."),
+		"https://c.example/": Page("C", "Home | Links"),
+		"https://d.example/": Page("D", "Another page with enough text."),
+		"https://e.example/": Page("E", "A spare nobody needed to read."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, MinPageChars: 20})
+
+	result, err := eng.Search(context.Background(), "q", 2, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.ResultCount != 2 || !reflect.DeepEqual(result.URLs, []string{"https://b.example/", "https://d.example/"}) {
+		t.Errorf("ResultCount = %d, URLs = %v; want b and d", result.ResultCount, result.URLs)
+	}
+	var skipped, scraped []string
+	for _, p := range result.Pages {
+		scraped = append(scraped, p.URL)
+		if p.Skipped {
+			skipped = append(skipped, p.URL)
+		}
+	}
+	if !reflect.DeepEqual(skipped, []string{"https://a.example/", "https://c.example/"}) {
+		t.Errorf("skipped %v, want a and c", skipped)
+	}
+	if len(scraped) != 4 {
+		t.Errorf("scraped %v, want the spare e left alone", scraped)
+	}
+}
+
+func TestPipelineFailedPages(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {
+		{URL: "https://a.example/"}, {URL: "https://b.example/"}, {URL: "https://c.example/"}, {URL: "https://d.example/"},
+	}}}
+	gone := scraper.ScrapedPage{Status: 404, Err: errors.New("HTTP 404")}
+	robots := scraper.ScrapedPage{Err: fmt.Errorf("fetch: %w", scraper.ErrRobotsDisallowed)}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "A page with plenty of text."),
+		"https://b.example/": gone,
+		"https://c.example/": robots,
+		"https://d.example/": Page("D", "Home"),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, MinPageChars: 10})
+	ctx := context.Background()
+
+	result, err := eng.Search(ctx, "q", 4, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var got []string
+	for _, f := range result.Failed {
+		got = append(got, f.URL+" "+f.Reason)
+	}
+	want := []string{"https://b.example/ HTTP 404", "https://c.example/ robots.txt", "https://d.example/ too short"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Failed = %q, want %q", got, want)
+	}
+	if f := result.Failed[1]; !errors.Is(f.Err, scraper.ErrRobotsDisallowed) {
+		t.Errorf("Failed[1].Err = %v, want the scrape error", f.Err)
+	}
+
+	result, err = eng.Search(ctx, "q", 4, false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if !result.FromCache || result.Failed != nil {
+		t.Errorf("cache hit: FromCache = %v, Failed = %v; want a hit without Failed", result.FromCache, result.Failed)
+	}
+}
+
+func TestPipelineHistory(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"golang": {{URL: "https://go.dev/"}}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://go.dev/": Page("Go", "A language.")}}
+	store := &MemoryStore{}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, SearchEngine: "duckduckgo", History: true})
+	ctx := context.Background()
+
+	eng.Search(ctx, "golang", 1, false)
+	eng.Search(ctx, "golang", 1, false)
+	eng.Search(ctx, "rust", 1, false)
+	eng.Fetch(ctx, "https://go.dev/")
+
+	records, err := eng.SearchHistory(ctx, "", 0)
+	if err != nil {
+		t.Fatalf("SearchHistory: %v", err)
+	}
+	var got []string
+	for _, r := range records {
+		got = append(got, fmt.Sprintf("%s %s %s %d %v %v", r.Kind, r.Query, r.Engine, r.ResultCount, r.FromCache, r.Error != ""))
+	}
+	want := []string{
+		"fetch https://go.dev/ url 1 false false",
+		"search rust duckduckgo 0 false true",
+		"search golang duckduckgo 1 true false",
+		"search golang duckduckgo 1 false false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("history:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if records, _ := eng.SearchHistory(ctx, "GOLANG", 1); len(records) != 1 || records[0].Query != "golang" || !records[0].FromCache {
+		t.Errorf("SearchHistory(GOLANG, 1) = %+v, want the latest golang search", records)
+	}
+
+	first := records[len(records)-1]
+	result, err := eng.ReplayHistory(ctx, first.ID, engine.SearchOptions{Force: true})
+	if err != nil {
+		t.Fatalf("ReplayHistory: %v", err)
+	}
+	if result.FromCache || result.ResultCount != 1 {
+		t.Errorf("replay: FromCache = %v, ResultCount = %d; want a fresh search", result.FromCache, result.ResultCount)
+	}
+	if records, _ := eng.SearchHistory(ctx, "", 1); records[0].Query != "golang" || records[0].FromCache {
+		t.Errorf("latest record = %+v, want the replay", records[0])
+	}
+	if _, err := eng.ReplayHistory(ctx, 99, engine.SearchOptions{}); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("ReplayHistory(99) = %v, want ErrNotFound", err)
+	}
+
+	scoped := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, KeyScope: "tenant", History: true})
+	if records, _ := scoped.SearchHistory(ctx, "", 0); len(records) != 0 {
+		t.Errorf("other scope sees %d records", len(records))
+	}
+	if _, err := scoped.ReplayHistory(ctx, first.ID, engine.SearchOptions{}); !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("ReplayHistory of another scope = %v, want ErrNotFound", err)
+	}
+
+	off := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	off.Search(ctx, "golang", 1, false)
+	if records, _ := off.SearchHistory(ctx, "", 0); len(records) != 0 {
+		t.Errorf("history recorded without Config.History: %+v", records)
+	}
+}
+
+func TestPipelineSectionTemplate(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {{URL: "https://a.example/"}, {URL: "https://b.example/"}}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://a.example/": Page("A", "Alpha."),
+		"https://b.example/": Page("B", "Beta."),
+	}}
+	tmpl := template.Must(template.New("section").Parse("<{{.URL}}> {{.Title}}: {{.Text}}\n"))
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, SectionTemplate: tmpl})
+	ctx := context.Background()
+
+	want := "<https://a.example/> A: Alpha.\n<https://b.example/> B: Beta.\n"
+	for _, label := range []string{"fresh", "cache hit"} {
+		result, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Count: 2})
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		if result.Content != want {
+			t.Errorf("%s: content = %q, want %q", label, result.Content, want)
+		}
+		if len(result.Sources) != 2 || result.Sources[1].Title != "B" {
+			t.Errorf("%s: sources = %+v, want both pages", label, result.Sources)
+		}
+	}
+
+	result, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Count: 2, Format: engine.ContentMarkdown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.Content, "## A — https://a.example/") {
+		t.Errorf("explicit markdown = %q, want the cached layout", result.Content)
+	}
+}
+
+func TestPipelineMaxAge(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {{URL: "https://a.example/"}}}}
+	var fetches atomic.Int32
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		fetches.Add(1)
+		p := Page("A", "Alpha.")
+		p.URL = urls[0]
+		return []scraper.ScrapedPage{p}
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	ctx := context.Background()
+
+	if _, err := eng.Search(ctx, "q", 1, false); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	result, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{MaxAge: time.Hour})
+	if err != nil || !result.FromCache {
+		t.Errorf("young enough: %+v, %v; want a cache hit", result, err)
+	}
+	result, err = eng.SearchWithOptions(ctx, "q", engine.SearchOptions{MaxAge: 10 * time.Millisecond})
+	if err != nil || result.FromCache || fetches.Load() != 2 {
+		t.Errorf("too old: FromCache = %v, %d fetches, %v; want a fresh search", result.FromCache, fetches.Load(), err)
+	}
+	// The fresh result replaced the entry.
+	result, err = eng.SearchWithOptions(ctx, "q", engine.SearchOptions{MaxAge: time.Minute})
+	if err != nil || !result.FromCache {
+		t.Errorf("after refresh: %+v, %v; want a cache hit", result, err)
+	}
+}
+
+func TestPipelineServeStale(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {{URL: "https://a.example/"}}}}
+	var version atomic.Int32
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		p := Page("A", fmt.Sprintf("Version %d.", version.Add(1)))
+		p.URL = urls[0]
+		return []scraper.ScrapedPage{p}
+	})
+	store := &MemoryStore{TTL: time.Millisecond}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, ServeStale: true})
+	ctx := context.Background()
+
+	if _, err := eng.Search(ctx, "q", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	events, cancel := eng.Subscribe(0)
+	defer cancel()
+	result, err := eng.Search(ctx, "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.FromCache || !result.Stale || !strings.Contains(result.Content, "Version 1.") {
+		t.Fatalf("result = %+v, want the stale first version", result)
+	}
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev := <-events:
+			done = ev.Kind == engine.EventCacheWrite
+		case <-timeout:
+			t.Fatal("no background refresh")
+		}
+	}
+	if e, _, _ := store.Lookup(ctx, engine.SHA256Keys.DeriveKey("", "q")); !strings.Contains(e.Content, "Version 2.") {
+		t.Errorf("refreshed content = %q", e.Content)
+	}
+}
+
+func TestRefreshPopular(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"hot":  {{URL: "https://a.example/"}},
+		"cold": {{URL: "https://a.example/"}},
+	}}
+	var scrapes atomic.Int32
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		scrapes.Add(1)
+		p := Page("A", "Some text.")
+		p.URL = urls[0]
+		return []scraper.ScrapedPage{p}
+	})
+	// Every entry is within two hours of a one-hour TTL.
+	store := &MemoryStore{TTL: time.Hour}
+	policy := &engine.RefreshPolicy{Within: 2 * time.Hour, MinHits: 2}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, Refresh: policy})
+	ctx := context.Background()
+
+	for _, q := range []string{"hot", "hot", "hot", "cold", "cold"} {
+		if _, err := eng.Search(ctx, q, 5, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scrapes.Store(0)
+	if n, err := eng.RefreshPopular(ctx); n != 1 || err != nil || scrapes.Load() != 1 {
+		t.Fatalf("RefreshPopular = %d, %v with %d scrapes; want only hot refreshed", n, err, scrapes.Load())
+	}
+	// The refresh restarted hot's count.
+	if n, err := eng.RefreshPopular(ctx); n != 0 || err != nil {
+		t.Errorf("second RefreshPopular = %d, %v; want 0", n, err)
+	}
+
+	eng = engine.New(store, engine.Config{Searcher: searcher, Scraper: pages})
+	if _, err := eng.RefreshPopular(ctx); !errors.Is(err, engine.ErrNoRefresh) {
+		t.Errorf("unconfigured: err = %v, want ErrNoRefresh", err)
+	}
+}
+
+func TestPipelinePageCache(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"first":  {{URL: "https://a.example/"}, {URL: "https://shared.example/"}},
+		"second": {{URL: "https://shared.example/"}, {URL: "https://b.example/"}},
+	}}
+	var fetched []string
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		fetched = append(fetched, urls...)
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			out[i] = Page("Page "+u, "Text of "+u)
+			out[i].URL = u
+		}
+		return out
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, PageTTL: time.Hour})
+	ctx := context.Background()
+
+	if _, err := eng.Search(ctx, "first", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	fetched = nil
+	result, err := eng.Search(ctx, "second", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://b.example/"}; !reflect.DeepEqual(fetched, want) {
+		t.Errorf("fetched %q, want %q", fetched, want)
+	}
+	if !result.Pages[0].Cached || result.Pages[1].Cached || !strings.Contains(result.Content, "Text of https://shared.example/") {
+		t.Errorf("result = %+v, want the shared page reused", result)
+	}
+
+	// Forcing a search reruns it without refetching.
+	fetched = nil
+	if _, err := eng.Search(ctx, "first", 5, true); err != nil || len(fetched) != 0 {
+		t.Errorf("forced search fetched %q, %v", fetched, err)
+	}
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {{URL: "https://a.example/"}}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Some text.")}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+	events, cancel := eng.Subscribe(1)
+	defer cancel()
+
+	// Nobody reads, yet the search must not block.
+	if _, err := eng.Search(context.Background(), "q", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-events; ev.Kind != engine.EventSearchStarted {
+		t.Errorf("first event = %s", ev.Kind)
+	}
+	if eng.DroppedEvents() != 4 {
+		t.Errorf("DroppedEvents = %d, want 4", eng.DroppedEvents())
+	}
+}
+
+func TestPipelineFinalURL(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"q": {
+			{URL: "https://t.example/abc"},
+			{URL: "https://go.dev/blog/intro-generics"},
+			{URL: "https://other.example/"},
+		},
+	}}
+	article := Page("An Introduction To Generics", "Go 1.18 adds type parameters.")
+	article.FinalURL = "https://go.dev/blog/intro-generics#top"
+	direct := article
+	direct.FinalURL = "https://go.dev/blog/intro-generics"
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://t.example/abc":              article,
+		"https://go.dev/blog/intro-generics": direct,
+		"https://other.example/":             Page("Other", "Unrelated text."),
+	}}
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+
+	result, err := eng.Search(context.Background(), "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultCount != 2 || len(result.Sources) != 2 {
+		t.Fatalf("result = %+v, want the duplicate dropped", result)
+	}
+	if got := result.Sources[0].URL; got != "https://go.dev/blog/intro-generics#top" {
+		t.Errorf("first source URL = %q, want the shortened link's destination", got)
+	}
+	if p := result.Pages[0]; p.URL != "https://t.example/abc" || p.FinalURL != "https://go.dev/blog/intro-generics#top" || p.Duplicate {
+		t.Errorf("Pages[0] = %+v", p)
+	}
+	if p := result.Pages[1]; !p.Duplicate || p.FinalURL != "" {
+		t.Errorf("Pages[1] = %+v, want a duplicate with no redirect", p)
+	}
+}
+
+func TestPipelineDepth(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"generics tutorial": {{URL: "https://go.dev/"}}}}
+	landing := Page("The Go Programming Language", "Build simple, secure, scalable systems with Go.")
+	landing.Links = []scraper.Link{
+		{URL: "https://go.dev/about", Text: "About"},
+		{URL: "https://go.dev/doc/tutorial/generics", Text: "Tutorial: Getting started with generics"},
+	}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/":                      landing,
+		"https://go.dev/doc/tutorial/generics": Page("Getting started with generics", "Declare a generic function."),
+	}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		configDepth int
+		depth       int
+		wantCount   int
+	}{
+		{"off by default", 0, 0, 1},
+		{"per call", 0, 1, 2},
+		{"config default", 1, 0, 2},
+		{"disabled per call", 1, -1, 1},
+	}
+	for _, tt := range tests {
+		eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages, Depth: tt.configDepth})
+		result, err := eng.SearchWithOptions(ctx, "generics tutorial", engine.SearchOptions{Depth: tt.depth})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.ResultCount != tt.wantCount || len(result.Pages) != tt.wantCount {
+			t.Fatalf("%s: ResultCount = %d with %d pages, want %d", tt.name, result.ResultCount, len(result.Pages), tt.wantCount)
+		}
+		if tt.wantCount == 2 {
+			if p := result.Pages[1]; p.URL != "https://go.dev/doc/tutorial/generics" || p.FollowedFrom != "https://go.dev/" {
+				t.Errorf("%s: Pages[1] = %+v, want the tutorial followed from the landing page", tt.name, p)
+			}
+		}
+	}
+}
+
+func TestPipelineSite(t *testing.T) {
+	var sites []string
+	sitemaps := engine.SitemapReaderFunc(func(ctx context.Context, site string, _ scraper.Options) ([]string, error) {
+		sites = append(sites, site)
+		return []string{"https://go.dev/doc/install", "https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics"}, nil
+	})
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{
+		"https://go.dev/doc/tutorial/generics": Page("Tutorial: generics", "Declare a generic function."),
+		"https://go.dev/blog/intro-generics":   Page("An Introduction To Generics", "Go 1.18 adds generics."),
+		"https://web.example/generics":         Page("Generics on the web", "Elsewhere."),
+	}}
+	searcher := StaticSearcher{Results: map[string][]search.Result{"generics tutorial": {{URL: "https://web.example/generics"}}}}
+	store := &MemoryStore{}
+	eng := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, Sitemaps: sitemaps, MaxPerHost: 1})
+	ctx := context.Background()
+
+	result, err := eng.SearchWithOptions(ctx, "generics tutorial", engine.SearchOptions{Site: "go.dev", Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The per-host cap does not apply within a single site.
+	if result.ResultCount != 2 || result.Pages[0].URL != "https://go.dev/doc/tutorial/generics" {
+		t.Fatalf("site search: ResultCount = %d, pages = %+v", result.ResultCount, result.Pages)
+	}
+	if len(sites) != 1 || sites[0] != "go.dev" {
+		t.Errorf("sitemaps read = %q, want [go.dev]", sites)
+	}
+
+	// A web search for the same query has its own cache entry.
+	web, err := eng.SearchWithOptions(ctx, "generics tutorial", engine.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web.FromCache || !strings.Contains(web.Content, "Elsewhere.") {
+		t.Errorf("web search after site search: FromCache = %v, Content = %q", web.FromCache, web.Content)
+	}
+	again, err := eng.SearchWithOptions(ctx, "generics tutorial", engine.SearchOptions{Site: "go.dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.FromCache || again.Content != result.Content {
+		t.Errorf("repeat site search: FromCache = %v", again.FromCache)
+	}
+
+	if _, err := eng.SearchWithOptions(ctx, "kubernetes", engine.SearchOptions{Site: "go.dev"}); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("no matching pages: err = %v, want ErrNoResults", err)
+	}
+	noSitemap := engine.New(&MemoryStore{}, engine.Config{Scraper: pages, Sitemaps: engine.SitemapReaderFunc(func(context.Context, string, scraper.Options) ([]string, error) {
+		return nil, fmt.Errorf("sitemap go.dev: %w", scraper.ErrNoSitemap)
+	})})
+	if _, err := noSitemap.SearchWithOptions(ctx, "generics", engine.SearchOptions{Site: "go.dev"}); !errors.Is(err, engine.ErrNoResults) {
+		t.Errorf("no sitemap: err = %v, want ErrNoResults", err)
+	}
+	if _, err := eng.SearchWithOptions(ctx, "generics", engine.SearchOptions{Site: "ftp://go.dev"}); err == nil {
+		t.Error("invalid site: want an error")
+	}
+}
+
+func TestPipelineGuardrails(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{
+		"go tutorial":   {{URL: "https://go.dev/a"}, {URL: "https://go.dev/b"}, {URL: "https://tour.golang.org/"}},
+		"pizza near me": {{URL: "https://pizza.example/"}},
+	}}
+	var robots []bool
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		robots = append(robots, opts.RespectRobots)
+		out := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			out[i] = Page("Page", "Text of "+u)
+			out[i].URL = u
+		}
+		return out
+	})
+	var log bytes.Buffer
+	cfg := engine.Config{
+		Searcher: searcher,
+		Scraper:  pages,
+		Auditor:  engine.NewJSONAuditor(&log),
+		Guardrails: &engine.Guardrails{
+			DomainPagesPerHour: 1,
+			RespectRobots:      true,
+			DisallowedIntents:  []engine.Intent{engine.IntentLocal},
+		},
+	}
+	eng := engine.New(&MemoryStore{}, cfg)
+	ctx := context.Background()
+
+	// One page per site per hour: go.dev/b is refused, golang.org is not.
+	result, err := eng.SearchWithOptions(ctx, "go tutorial", engine.SearchOptions{Key: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultCount != 2 || !errors.Is(result.Pages[1].Err, engine.ErrGuardrail) {
+		t.Errorf("ResultCount = %d, Pages[1].Err = %v; want 2 and ErrGuardrail", result.ResultCount, result.Pages[1].Err)
+	}
+	if len(robots) != 1 || !robots[0] {
+		t.Errorf("RespectRobots passed to scraper = %v, want [true]", robots)
+	}
+
+	// Disallowed verticals are refused even without a configured classifier.
+	if _, err := eng.SearchWithOptions(ctx, "pizza near me", engine.SearchOptions{}); !errors.Is(err, engine.ErrGuardrail) {
+		t.Errorf("local query: err = %v, want ErrGuardrail", err)
+	}
+
+	var recs []engine.AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var rec engine.AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("audit records = %d, want 2:\n%s", len(recs), log.String())
+	}
+	if r := recs[0]; r.Query != "go tutorial" || len(r.Pages) != 3 || r.Key == "" || strings.Contains(log.String(), "secret") {
+		t.Errorf("first audit record = %+v", r)
+	}
+	if r := recs[1]; r.Query != "pizza near me" || !strings.Contains(r.Error, "guardrail") {
+		t.Errorf("second audit record = %+v", r)
+	}
+
+	// A required audit log fails closed.
+	cfg.Guardrails = &engine.Guardrails{RequireAudit: true}
+	cfg.Auditor = nil
+	if _, err := engine.New(&MemoryStore{}, cfg).SearchWithOptions(ctx, "go tutorial", engine.SearchOptions{}); !errors.Is(err, engine.ErrGuardrail) {
+		t.Errorf("no auditor: err = %v, want ErrGuardrail", err)
+	}
+	cfg.Auditor = engine.AuditorFunc(func(context.Context, engine.AuditRecord) error { return errors.New("disk full") })
+	if _, err := engine.New(&MemoryStore{}, cfg).SearchWithOptions(ctx, "go tutorial", engine.SearchOptions{}); !errors.Is(err, engine.ErrGuardrail) {
+		t.Errorf("failing auditor: err = %v, want ErrGuardrail", err)
+	}
+	cfg.Guardrails = nil
+	if _, err := engine.New(&MemoryStore{}, cfg).SearchWithOptions(ctx, "go tutorial", engine.SearchOptions{}); err != nil {
+		t.Errorf("failing optional auditor: err = %v, want the search to succeed", err)
+	}
+}
+
+func TestPipelineIntentRouting(t *testing.T) {
+	type call struct {
+		count  int
+		engine string
+	}
+	var calls []call
+	searcher := engine.SearcherFunc(func(ctx context.Context, query string, count int, name string) ([]search.Result, *search.InstantAnswer, error) {
+		calls = append(calls, call{count, name})
+		return []search.Result{{URL: "https://a.example/"}}, nil, nil
+	})
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Some text.")}}
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Searcher:     searcher,
+		Scraper:      pages,
+		SearchEngine: search.EngineGoogle,
+		Classifier:   engine.ClassifierFunc(func(string) engine.Intent { return engine.IntentNews }),
+		IntentRoutes: map[engine.Intent]engine.IntentRoute{
+			engine.IntentNews: {Engine: search.EngineDuckDuckGo, Count: 7},
+		},
+	})
+	ctx := context.Background()
+
+	result, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Intent != engine.IntentNews || calls[0] != (call{7, search.EngineDuckDuckGo}) {
+		t.Errorf("routed: intent = %q, search = %+v", result.Intent, calls[0])
+	}
+
+	// Options the caller sets win over the route.
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Force: true, Count: 2, Engine: search.EngineGoogle}); err != nil {
+		t.Fatal(err)
+	}
+	if calls[1] != (call{2, search.EngineGoogle}) {
+		t.Errorf("explicit options: search = %+v", calls[1])
+	}
+
+	if _, err := eng.SearchWithOptions(ctx, "q", engine.SearchOptions{Engine: "altavista"}); err == nil {
+		t.Error("unknown engine: want an error")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var got scraper.Options
+	fetch := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		got = opts
+		return []scraper.ScrapedPage{Page("Example Domain", "This domain is for use in examples.")}
+	})
+	// Guardrails that would refuse any search do not stop a probe.
+	eng := engine.New(&MemoryStore{}, engine.Config{
+		Scraper:     fetch,
+		ScrapeProxy: "http://proxy.internal:3128",
+		Guardrails:  &engine.Guardrails{RequireAudit: true},
+	})
+
+	page := eng.Probe(context.Background(), "https://example.com/")
+	if page.Err != nil {
+		t.Fatalf("Probe: %v", page.Err)
+	}
+	if got.Proxy != "http://proxy.internal:3128" {
+		t.Errorf("Probe scraped with Proxy = %q, want the configured proxy", got.Proxy)
+	}
+}
+
+func TestPipelineTrackingParams(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {
+		{URL: "https://a.example/post?id=1&utm_source=newsletter"},
+		{URL: "https://a.example/post?fbclid=IwAR0x&id=1"},
+		{URL: "https://b.example/"},
+	}}}
+	var scraped []string
+	fetch := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		scraped = append(scraped, urls...)
+		pages := make([]scraper.ScrapedPage, len(urls))
+		for i, u := range urls {
+			pages[i] = Page("Page "+u, "Some text.")
+			pages[i].URL = u
+		}
+		if len(pages) > 1 {
+			// b.example redirects back with a tracking parameter.
+			pages[1].FinalURL = "https://b.example/home?utm_medium=redirect"
+		}
+		return pages
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: fetch})
+
+	result, err := eng.Search(context.Background(), "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://a.example/post?id=1", "https://b.example/"}; !reflect.DeepEqual(scraped, want) {
+		t.Errorf("scraped %q, want %q", scraped, want)
+	}
+	if strings.Contains(result.Content, "utm_") || strings.Contains(result.Content, "fbclid") {
+		t.Errorf("Content shows tracking parameters:\n%s", result.Content)
+	}
+	if got := result.Pages[1].FinalURL; got != "https://b.example/home" {
+		t.Errorf("Pages[1].FinalURL = %q, want it without utm_medium", got)
+	}
+}
+
+func TestPipelineKeyScope(t *testing.T) {
+	searcher := StaticSearcher{Results: map[string][]search.Result{"q": {{URL: "https://a.example/"}}}}
+	pages := StaticScraper{Pages: map[string]scraper.ScrapedPage{"https://a.example/": Page("A", "Some text.")}}
+	store := &MemoryStore{}
+	tenant := func(scope string) *engine.Engine {
+		return engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, KeyScope: scope})
+	}
+	ctx := context.Background()
+
+	if _, err := tenant("acme").Search(ctx, "q", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := tenant("acme").Search(ctx, "Q", 5, false); err != nil || !r.FromCache {
+		t.Errorf("same scope: %+v, %v; want a cache hit", r, err)
+	}
+	if r, err := tenant("globex").Search(ctx, "q", 5, false); err != nil || r.FromCache {
+		t.Errorf("other scope: %+v, %v; want a fresh search", r, err)
+	}
+
+	// A custom deriver sees the scope and the raw query.
+	var got []string
+	custom := engine.New(store, engine.Config{Searcher: searcher, Scraper: pages, KeyScope: "acme",
+		Keys: engine.KeyDeriverFunc(func(scope, query string) string {
+			got = append(got, scope+"/"+query)
+			return scope + ":" + engine.NormalizeQuery(query)
+		}),
+	})
+	if _, err := custom.Search(ctx, " Q ", 5, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "acme/ Q " {
+		t.Errorf("deriver calls = %q", got)
+	}
+	if _, ok, _ := store.Get("acme:q"); !ok {
+		t.Error("result not stored under the custom key")
+	}
+}
+
+func TestPipelineTimings(t *testing.T) {
+	searcher := engine.SearcherFunc(func(ctx context.Context, query string, count int, name string) ([]search.Result, *search.InstantAnswer, error) {
+		time.Sleep(10 * time.Millisecond)
+		return []search.Result{{URL: "https://a.example/"}}, nil, nil
+	})
+	pages := engine.ScraperFunc(func(ctx context.Context, urls []string, opts scraper.Options) []scraper.ScrapedPage {
+		time.Sleep(20 * time.Millisecond)
+		return []scraper.ScrapedPage{Page("A", "Some text.")}
+	})
+	eng := engine.New(&MemoryStore{}, engine.Config{Searcher: searcher, Scraper: pages})
+
+	result, err := eng.Search(context.Background(), "q", 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := result.Timings
+	if tm.SERP < 10*time.Millisecond || tm.Scrape < 20*time.Millisecond || tm.RateLimitWait != 0 {
+		t.Errorf("timings = %+v, want SERP >= 10ms and Scrape >= 20ms", tm)
+	}
+	if sum := tm.SERP + tm.RateLimitWait + tm.Scrape + tm.Consolidate + tm.Cache; tm.Total < sum {
+		t.Errorf("Total %v < sum of phases %v", tm.Total, sum)
+	}
+
+	result, err = eng.Search(context.Background(), "q", 5, false)
+	if err != nil || !result.FromCache {
+		t.Fatalf("second search = %+v, %v", result, err)
+	}
+	if tm := result.Timings; tm.SERP != 0 || tm.Scrape != 0 || tm.Total < tm.Cache {
+		t.Errorf("cache hit timings = %+v", tm)
+	}
+}
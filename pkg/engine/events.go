@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind names a pipeline lifecycle event.
+type EventKind string
+
+// Events emitted by SearchWithOptions and Pin, in pipeline order. A search
+// ends with exactly one EventSearchDone or EventError.
+const (
+	EventSearchStarted EventKind = "search_started" // Query and Options are set
+	EventCacheHit      EventKind = "cache_hit"      // the cached result will be returned
+	EventSERPParsed    EventKind = "serp_parsed"    // Results is the number of links to scrape
+	EventPageScraped   EventKind = "page_scraped"   // Page is set; Page.Err if the fetch failed
+	EventCacheWrite    EventKind = "cache_write"    // the consolidated result was stored
+	EventSearchDone    EventKind = "search_done"    // Results is the number of sections returned
+	EventError         EventKind = "error"          // Err is the error the search returned
+)
+
+// Event is one step of a search. Events from concurrent searches
+// interleave on a subscription; SearchID tells them apart.
+type Event struct {
+	Kind     EventKind
+	SearchID uint64 // unique per search within the Engine, starting at 1
+	Query    string
+	Time     time.Time
+
+	Options SearchOptions // EventSearchStarted only
+	Results int           // see the EventKind constants
+	Page    *PageInfo     // EventPageScraped only
+	Err     error         // EventError only
+}
+
+// DefaultEventBuffer is the subscription buffer used when Subscribe is
+// given a size of zero or less.
+const DefaultEventBuffer = 64
+
+// eventBus fans events out to subscribers. Sends never block: a subscriber
+// whose buffer is full misses the event, so a slow consumer cannot stall
+// searches.
+type eventBus struct {
+	mu      sync.RWMutex
+	subs    map[*subscription]struct{}
+	nextID  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+type subscription struct {
+	ch chan Event
+}
+
+// Subscribe returns a channel of the engine's pipeline events and a
+// function that ends the subscription and closes the channel. buffer sizes
+// the channel; zero or less uses DefaultEventBuffer. Events that arrive
+// while the buffer is full are dropped and counted in DroppedEvents, so
+// consumers should drain the channel promptly.
+func (e *Engine) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	sub := &subscription{ch: make(chan Event, buffer)}
+	b := &e.events
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// DroppedEvents returns how many events were dropped across all
+// subscriptions because their buffers were full.
+func (e *Engine) DroppedEvents() uint64 {
+	return e.events.dropped.Load()
+}
+
+// publish delivers ev to every subscriber without blocking.
+func (b *eventBus) publish(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// active reports whether anyone is subscribed.
+func (b *eventBus) active() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs) > 0
+}
+
+// eventRun stamps the events of one search with its ID and query and
+// counts it in Config.Metrics. With an
+// Auditor configured it also keeps the pages fetched, for the audit record.
+// It notes which pages came from the page cache and, for SearchStream,
+// passes results and pages on as they arrive.
+type eventRun struct {
+	bus     *eventBus
+	id      uint64
+	query   string
+	kind    string  // "search" or "fetch", for metrics
+	metrics Metrics // Config.Metrics, or nil
+
+	log    *slog.Logger // Config.Logger, tagged with the search's ID and, once known, its query hash
+	engine string       // where the links come from, until the result says
+	start  time.Time
+
+	audit  bool
+	mu     sync.Mutex
+	pages  []PageInfo
+	cached map[string]bool // URLs reused from the page cache
+
+	stream    func(StreamUpdate) // SearchStream's callback, or nil
+	streamMu  sync.Mutex         // serializes calls to stream
+	sponsored map[string]bool    // sponsored result URLs, for streamed pages
+}
+
+// startRun assigns a search its ID and emits EventSearchStarted.
+func (e *Engine) startRun(query string, opts SearchOptions) *eventRun {
+	id := e.events.nextID.Add(1)
+	r := &eventRun{bus: &e.events, id: id, query: query, kind: "search", metrics: e.config.Metrics, audit: e.config.Auditor != nil,
+		log: e.logger().With("search_id", id), engine: e.searchEngine(opts), start: time.Now()}
+	r.emit(Event{Kind: EventSearchStarted, Options: opts})
+	return r
+}
+
+// emit fills in ev's search ID, query and time and publishes it. It is a
+// no-op without subscribers.
+func (r *eventRun) emit(ev Event) {
+	if !r.bus.active() {
+		return
+	}
+	ev.SearchID, ev.Query, ev.Time = r.id, r.query, time.Now()
+	r.bus.publish(ev)
+}
+
+// record keeps a fetched page for the audit record.
+func (r *eventRun) record(info PageInfo) {
+	if !r.audit {
+		return
+	}
+	r.mu.Lock()
+	r.pages = append(r.pages, info)
+	r.mu.Unlock()
+}
+
+// markCached notes that url was reused from the page cache.
+func (r *eventRun) markCached(url string) {
+	r.mu.Lock()
+	if r.cached == nil {
+		r.cached = make(map[string]bool)
+	}
+	r.cached[url] = true
+	r.mu.Unlock()
+}
+
+// wasCached reports whether markCached was called for url.
+func (r *eventRun) wasCached(url string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cached[url]
+}
+
+// fetched returns the pages kept by record.
+func (r *eventRun) fetched() []PageInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pages
+}
+
+// finish emits EventError if err is set, else EventSearchDone, and
+// records the call in the run's Metrics.
+func (r *eventRun) finish(result SearchResult, err error) {
+	r.countSearch(result, err)
+	r.logSearch(result, err)
+	if err != nil {
+		r.emit(Event{Kind: EventError, Err: err})
+		return
+	}
+	r.emit(Event{Kind: EventSearchDone, Results: result.ResultCount})
+}
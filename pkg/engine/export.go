@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Export formats for WriteSources.
+const (
+	FormatCSV   = "csv"   // header row, then one row per page
+	FormatJSONL = "jsonl" // one JSON object per page and line
+)
+
+// ValidFormat reports whether name is a known export format.
+func ValidFormat(name string) bool {
+	return name == FormatCSV || name == FormatJSONL
+}
+
+// exportColumns is the CSV header; it matches exportRow's JSON keys.
+var exportColumns = []string{"url", "title", "date", "excerpt", "text_length"}
+
+// exportRow is one page of an export.
+type exportRow struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	Date       string `json:"date"` // YYYY-MM-DD, empty if unknown
+	Excerpt    string `json:"excerpt"`
+	TextLength int    `json:"text_length"`
+}
+
+func newExportRow(src Source) exportRow {
+	row := exportRow{URL: src.URL, Title: src.Title, Excerpt: src.Excerpt, TextLength: src.TextLength}
+	if !src.Published.IsZero() {
+		row.Date = src.Published.Time.Format("2006-01-02")
+	}
+	return row
+}
+
+// WriteSources writes one record per source to w in the given export
+// format, for loading results into spreadsheets and data pipelines.
+func WriteSources(w io.Writer, format string, sources []Source) error {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(exportColumns)
+		for _, src := range sources {
+			row := newExportRow(src)
+			cw.Write([]string{row.URL, row.Title, row.Date, row.Excerpt, strconv.Itoa(row.TextLength)})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("engine: write csv: %w", err)
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, src := range sources {
+			if err := enc.Encode(newExportRow(src)); err != nil {
+				return fmt.Errorf("engine: write jsonl: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("engine: unknown export format %q", format)
+	}
+	return nil
+}
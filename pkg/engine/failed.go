@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// Reasons a page failed, for FailedPage.Reason. Pages whose HTTP status
+// was an error carry "HTTP <status>" instead.
+const (
+	FailTimeout     = "timeout"         // the page or the call ran out of time
+	FailRobots      = "robots.txt"      // disallowed by the site's robots.txt
+	FailNoindex     = "noindex"         // the page opts out of indexing
+	FailPrivate     = "private address" // the URL resolves to a private network
+	FailCircuitOpen = "circuit open"    // the site failed too often lately to try
+	FailGuardrail   = "guardrail"       // refused by a guardrail
+	FailBlocked     = "blocked"         // a wall hid the text; "blocked: <scraper.BlockPaywall or BlockConsent>" when known
+	FailEmpty       = "no text"         // fetched fine but no text was extracted
+	FailThin        = "too short"       // text shorter than Config.MinPageChars
+	FailError       = "error"           // any other fetch or extraction error
+)
+
+// FailedPage is a page a fresh search scraped but could not use, with why,
+// so callers can tell why ResultCount is lower than asked for and retry
+// single links with Fetch.
+type FailedPage struct {
+	URL    string
+	Reason string // one of the Fail constants, or "HTTP <status>"
+	Err    error  // the scrape error, if any
+}
+
+// failedPages lists the pages that were not usable or were too short, in
+// order; infos holds their PageInfo.
+func failedPages(pages []scraper.ScrapedPage, infos []PageInfo) []FailedPage {
+	var failed []FailedPage
+	for i, info := range infos {
+		if reason := failReason(pages[i], info); reason != "" {
+			failed = append(failed, FailedPage{URL: info.URL, Reason: reason, Err: info.Err})
+		}
+	}
+	return failed
+}
+
+// failReason classifies why p was not used, or returns "" if it was, or
+// was only trimmed, truncated or a duplicate.
+func failReason(p scraper.ScrapedPage, info PageInfo) string {
+	err := p.Err
+	switch {
+	case err == nil && p.Blocked && p.Via == "":
+		if p.BlockReason != "" {
+			return FailBlocked + ": " + p.BlockReason
+		}
+		return FailBlocked
+	case err == nil && info.Skipped:
+		return FailThin
+	case err == nil && strings.TrimSpace(p.Content) == "":
+		return FailEmpty
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return FailTimeout
+	case errors.Is(err, scraper.ErrRobotsDisallowed):
+		return FailRobots
+	case errors.Is(err, scraper.ErrNoindex):
+		return FailNoindex
+	case errors.Is(err, scraper.ErrPrivateAddress):
+		return FailPrivate
+	case errors.Is(err, ErrCircuitOpen):
+		return FailCircuitOpen
+	case errors.Is(err, ErrGuardrail):
+		return FailGuardrail
+	case info.Status >= 400:
+		return fmt.Sprintf("HTTP %d", info.Status)
+	}
+	return FailError
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Feature flags gating risky or expensive capabilities.
+const (
+	FeatureRender    = "render"     // headless browser rendering
+	FeatureArchive   = "archive"    // Wayback Machine fallback for unreadable pages
+	FeatureResearch  = "research"   // DeepResearch's multi-round searches and fetches
+	FeatureCrawlSite = "crawl_site" // site searches, which crawl a site's sitemap
+)
+
+// featureDefaults lists every known feature and whether it is on when a
+// deployment does not configure it. Capabilities that predate flags default
+// to on so upgrading changes nothing.
+var featureDefaults = map[string]bool{
+	FeatureRender:    true,
+	FeatureArchive:   true,
+	FeatureResearch:  true,
+	FeatureCrawlSite: true,
+}
+
+// ErrFeatureDisabled means a call asked for a capability that is switched
+// off for this deployment or API key.
+var ErrFeatureDisabled = errors.New("feature disabled")
+
+// FeatureNames returns the known feature names, sorted.
+func FeatureNames() []string {
+	return slices.Sorted(maps.Keys(featureDefaults))
+}
+
+// ValidFeature reports whether name is a known feature.
+func ValidFeature(name string) bool {
+	_, ok := featureDefaults[name]
+	return ok
+}
+
+// Features holds feature flags for a deployment, with per-API-key
+// overrides, and can be changed while the server runs. A nil *Features
+// leaves every feature at its default. It is safe for concurrent use.
+type Features struct {
+	mu       sync.RWMutex
+	defaults map[string]bool            // deployment-wide settings
+	keys     map[string]map[string]bool // per-key overrides of defaults
+}
+
+// NewFeatures returns flags with the given deployment-wide settings and
+// per-key overrides; either may be nil. Unknown feature names are an error.
+func NewFeatures(defaults map[string]bool, keys map[string]map[string]bool) (*Features, error) {
+	f := &Features{defaults: make(map[string]bool), keys: make(map[string]map[string]bool)}
+	for name, on := range defaults {
+		if err := f.Set(name, "", on); err != nil {
+			return nil, err
+		}
+	}
+	for key, flags := range keys {
+		for name, on := range flags {
+			if err := f.Set(name, key, on); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// Enabled reports whether feature is on for callers presenting key. A key
+// without an override for the feature, including the empty key, gets the
+// deployment-wide setting.
+func (f *Features) Enabled(feature, key string) bool {
+	if f == nil {
+		return featureDefaults[feature]
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if on, ok := f.keys[key][feature]; ok {
+		return on
+	}
+	if on, ok := f.defaults[feature]; ok {
+		return on
+	}
+	return featureDefaults[feature]
+}
+
+// Set switches feature on or off deployment-wide when key is empty, and for
+// callers presenting key otherwise.
+func (f *Features) Set(feature, key string, on bool) error {
+	if !ValidFeature(feature) {
+		return fmt.Errorf("engine: unknown feature %q", feature)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" {
+		f.defaults[feature] = on
+		return nil
+	}
+	if f.keys[key] == nil {
+		f.keys[key] = make(map[string]bool)
+	}
+	f.keys[key][feature] = on
+	return nil
+}
+
+// Reset drops key's override of feature, or the deployment-wide setting
+// when key is empty, so the next level down applies again.
+func (f *Features) Reset(feature, key string) error {
+	if !ValidFeature(feature) {
+		return fmt.Errorf("engine: unknown feature %q", feature)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" {
+		delete(f.defaults, feature)
+		return nil
+	}
+	delete(f.keys[key], feature)
+	if len(f.keys[key]) == 0 {
+		delete(f.keys, key)
+	}
+	return nil
+}
+
+// FeatureSnapshot is a copy of the flags at one point in time.
+type FeatureSnapshot struct {
+	Defaults map[string]bool            // effective deployment-wide value of every known feature
+	Keys     map[string]map[string]bool // per-key overrides only
+}
+
+// Snapshot returns a copy of the current flags.
+func (f *Features) Snapshot() FeatureSnapshot {
+	s := FeatureSnapshot{Defaults: make(map[string]bool), Keys: make(map[string]map[string]bool)}
+	for name := range featureDefaults {
+		s.Defaults[name] = f.Enabled(name, "")
+	}
+	if f == nil {
+		return s
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for key, flags := range f.keys {
+		s.Keys[key] = maps.Clone(flags)
+	}
+	return s
+}
+
+// ParseFeatures parses a "feature=on,feature=off" list. Values are on/off
+// or anything strconv.ParseBool accepts.
+func ParseFeatures(s string) (map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want feature=on|off", part)
+		}
+		name = strings.TrimSpace(name)
+		if !ValidFeature(name) {
+			return nil, fmt.Errorf("%q: unknown feature %q", part, name)
+		}
+		on, err := parseSwitch(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		m[name] = on
+	}
+	return m, nil
+}
+
+// ParseKeyFeatures parses per-key overrides written as a
+// "key:feature=on,key:feature=off" list.
+func ParseKeyFeatures(s string) (map[string]map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		key, flag, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q: want key:feature=on|off", part)
+		}
+		flags, err := ParseFeatures(flag)
+		if err != nil {
+			return nil, err
+		}
+		if m[key] == nil {
+			m[key] = make(map[string]bool)
+		}
+		maps.Copy(m[key], flags)
+	}
+	return m, nil
+}
+
+// parseSwitch accepts on/off alongside strconv.ParseBool's values.
+func parseSwitch(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
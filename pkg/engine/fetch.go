@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// ErrInvalidURL means Fetch was given something other than an absolute
+// http or https URL.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// fetchEngine is SearchResult.Engine for fetched pages.
+const fetchEngine = "url"
+
+// fetchQuery is what a fetched page is cached under. The NUL keeps it
+// apart from every search query.
+func fetchQuery(link string) string {
+	return "\x00url:" + link
+}
+
+// normalizeFetchURL checks that rawURL is an absolute http or https URL
+// and drops its fragment and tracking parameters, so links copied from
+// different places share a cache entry.
+func normalizeFetchURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("engine: %w %q", ErrInvalidURL, rawURL)
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	return search.StripTracking(u.String()), nil
+}
+
+// Fetch reads the page at rawURL, as FetchWithOptions does with default
+// options.
+func (e *Engine) Fetch(ctx context.Context, rawURL string) (SearchResult, error) {
+	return e.FetchWithOptions(ctx, rawURL, SearchOptions{})
+}
+
+// FetchWithOptions reads one page through the same pipeline as a search's
+// result pages: the page cache, extraction, guardrails, the page budget
+// and the audit log. The result is one section, cached under the URL
+// apart from any search, so opening a link from earlier results again is
+// free until it expires. Force, MaxAge, Extractor, Render, Output, Format,
+// ScrapeTimeout, TTL, Cite, Summarize, Key, Depth and FollowLinks apply;
+// the other options are ignored. The summarizer is given an empty query.
+// Expired entries are fetched again rather than served stale.
+//
+// Depth 1 also reads the page's same-site links that best match its title
+// and description, up to FollowLinks and Config.FollowBudget of them, for
+// landing pages that only link to the real content. They are scraped like
+// a search's followed links, within the page budget, robots.txt and the
+// per-host rate limits, and add a section each. Config.Depth does not
+// apply to fetches.
+func (e *Engine) FetchWithOptions(ctx context.Context, rawURL string, opts SearchOptions) (result SearchResult, err error) {
+	start := time.Now()
+	link, uerr := normalizeFetchURL(rawURL)
+	if uerr != nil {
+		link = rawURL
+	}
+	run := e.startRun(link, opts)
+	run.kind, run.engine = "fetch", fetchEngine
+	defer func() {
+		result.Timings.Total = time.Since(start)
+		if aerr := e.audit(ctx, run, opts, result, err); aerr != nil {
+			result, err = SearchResult{}, aerr
+		}
+		e.recordHistory(ctx, run, opts, result, err)
+		run.finish(result, err)
+	}()
+
+	if uerr != nil {
+		return SearchResult{}, uerr
+	}
+	if opts.Summarize && e.config.Summarizer == nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", ErrNoSummarizer)
+	}
+	if !ValidContentFormat(opts.Format) {
+		return SearchResult{}, fmt.Errorf("engine: unknown content format %q", opts.Format)
+	}
+	if opts.Render != "" && opts.Render != scraper.RenderNever && !e.config.Features.Enabled(FeatureRender, opts.Key) {
+		return SearchResult{}, fmt.Errorf("engine: render %s: %w", opts.Render, ErrFeatureDisabled)
+	}
+	if opts.Depth == 0 {
+		opts.Depth = -1
+	}
+	result, err = e.fetch(ctx, link, opts, run)
+	if err != nil {
+		return result, err
+	}
+	return e.finishContent(ctx, "", opts, result)
+}
+
+// fetch runs the cache → scrape → consolidate pipeline for one page and
+// the links it follows. A fetch that follows links is cached apart from
+// one that does not.
+func (e *Engine) fetch(ctx context.Context, link string, opts SearchOptions, run *eventRun) (SearchResult, error) {
+	query := fetchQuery(link)
+	if e.depth(opts) > 0 {
+		query += fmt.Sprintf("\x00follow:%d", e.followBudget(opts))
+	}
+	hash := e.key(query)
+	run.setHash(hash)
+	var tm Timings
+
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	if err := e.guardAudit(); err != nil {
+		return SearchResult{}, err
+	}
+
+	if !opts.Force {
+		cacheStart := time.Now()
+		entry, hit, err := e.lookup(ctx, hash, opts.MaxAge)
+		tm.Cache = time.Since(cacheStart)
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("engine: cache get: %w", err)
+		}
+		if hit && !entry.Stale {
+			run.emit(Event{Kind: EventCacheHit})
+			result := cachedResult(entry)
+			result.Timings = tm
+			return result, nil
+		}
+	}
+
+	if _, err := e.reservePages(ctx, 1); err != nil {
+		return SearchResult{}, err
+	}
+	scrapeStart := time.Now()
+	pages := e.scrape(ctx, []string{link}, opts, run)
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	p := pages[0]
+	if p.Err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w for %q: %w", ErrScrapeFailed, link, p.Err)
+	}
+	followed, followedFrom, err := e.follow(ctx, p.Title+" "+p.Description, pages, opts, run)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	pages = append(pages, followed...)
+	tm.Scrape = time.Since(scrapeStart)
+	if err := ctx.Err(); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+
+	consolidateStart := time.Now()
+	content, count, truncated := fitContent(pages, nil, e.config.MaxContentBytes)
+	if content == "" {
+		return SearchResult{}, fmt.Errorf("engine: %w for %q", ErrScrapeFailed, link)
+	}
+	content, err = e.postConsolidate(ctx, link, content)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	tm.Consolidate = time.Since(consolidateStart)
+
+	urls := []string{pageURL(p)}
+	for _, f := range followed {
+		if usable(f) {
+			urls = append(urls, pageURL(f))
+		}
+	}
+	meta := cache.Meta{Query: link, Engine: fetchEngine, ResultCount: count, URLs: urls, TTL: opts.TTL}
+	cacheStart := time.Now()
+	if err := e.cache.SetContext(ctx, hash, content, meta); err != nil {
+		return SearchResult{}, fmt.Errorf("engine: cache set: %w", err)
+	}
+	tm.Cache += time.Since(cacheStart)
+	run.emit(Event{Kind: EventCacheWrite})
+
+	infos := make([]PageInfo, len(pages))
+	for i, p := range pages {
+		infos[i] = pageInfo(p)
+		infos[i].Cached = run.wasCached(p.URL)
+		infos[i].Truncated = truncated[p.URL]
+	}
+	for i, from := range followedFrom {
+		infos[1+i].FollowedFrom = from
+	}
+	return SearchResult{
+		Content:     content,
+		ResultCount: count,
+		Engine:      fetchEngine,
+		URLs:        urls,
+		Sources:     parseSources(content),
+		Pages:       infos,
+		Failed:      failedPages(pages[1:], infos[1:]),
+		Timings:     tm,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// DefaultFollowBudget is how many linked pages a search with Depth 1
+// follows when Config.FollowBudget is zero.
+const DefaultFollowBudget = 3
+
+// depth resolves the link-follow depth for a call: 0 or 1, since only
+// links on the result pages themselves are followed.
+func (e *Engine) depth(opts SearchOptions) int {
+	d := opts.Depth
+	if d == 0 {
+		d = e.config.Depth
+	}
+	return min(max(d, 0), 1)
+}
+
+// followBudget resolves Config.FollowBudget, lowered to opts.FollowLinks
+// if that is set; 0 or less disables following.
+func (e *Engine) followBudget(opts SearchOptions) int {
+	budget := e.config.FollowBudget
+	if budget == 0 {
+		budget = DefaultFollowBudget
+	}
+	if opts.FollowLinks > 0 {
+		budget = min(budget, opts.FollowLinks)
+	}
+	return max(budget, 0)
+}
+
+// follow scrapes the links chosen by linksToFollow when the call's depth is
+// 1. The pages count against the page budget like any other; if it is used
+// up, the search goes on without them. It returns the pages and, for each,
+// the URL of the result page that linked to it.
+func (e *Engine) follow(ctx context.Context, query string, pages []scraper.ScrapedPage, opts SearchOptions, run *eventRun) ([]scraper.ScrapedPage, []string, error) {
+	if e.depth(opts) < 1 || ctx.Err() != nil {
+		return nil, nil, nil
+	}
+	urls, parents := linksToFollow(query, pages, e.followBudget(opts))
+	if len(urls) == 0 {
+		return nil, nil, nil
+	}
+	allowed, err := e.reservePages(ctx, len(urls))
+	if errors.Is(err, ErrBudgetExhausted) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	urls = urls[:allowed]
+	followed := e.scrape(ctx, urls, opts, run)
+	from := make([]string, len(followed))
+	for i := range followed {
+		from[i] = pages[parents[i]].URL
+	}
+	return followed, from, nil
+}
+
+// followCandidate is a link worth following, found on pages[parent].
+type followCandidate struct {
+	url    string
+	parent int
+	score  int
+}
+
+// linksToFollow picks up to budget links from the usable pages to scrape
+// next: links to the same site as the page they are on, whose anchor text
+// or URL contains at least one query term. More matching terms rank higher,
+// then the parent's rank, then document order. Links to pages already
+// scraped are skipped. It returns the URLs and, for each, the index of the
+// page it was found on.
+func linksToFollow(query string, pages []scraper.ScrapedPage, budget int) ([]string, []int) {
+	terms := queryTerms(query)
+	if budget <= 0 || len(terms) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		seen[dedupKey(p.URL)] = true
+		if p.FinalURL != "" {
+			seen[dedupKey(p.FinalURL)] = true
+		}
+	}
+	var cands []followCandidate
+	for i, p := range pages {
+		if !usable(p) {
+			continue
+		}
+		site := siteKey(pageURL(p))
+		for _, l := range p.Links {
+			key := dedupKey(l.URL)
+			if seen[key] || siteKey(l.URL) != site {
+				continue
+			}
+			if score := matchedTerms(terms, l); score > 0 {
+				seen[key] = true
+				cands = append(cands, followCandidate{url: search.StripTracking(l.URL), parent: i, score: score})
+			}
+		}
+	}
+	sort.SliceStable(cands, func(a, b int) bool { return cands[a].score > cands[b].score })
+	if len(cands) > budget {
+		cands = cands[:budget]
+	}
+	urls, parents := make([]string, len(cands)), make([]int, len(cands))
+	for i, c := range cands {
+		urls[i], parents[i] = c.url, c.parent
+	}
+	return urls, parents
+}
+
+// queryTerms splits a query into lowercase words of three or more
+// characters; shorter ones ("go", "of") match too many links.
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(w)) >= 3 && !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// matchedTerms counts the terms found in l's anchor text or URL path. The
+// host is left out, since on a same-site link it says nothing about the
+// target.
+func matchedTerms(terms []string, l scraper.Link) int {
+	hay := strings.ToLower(l.Text)
+	if u, err := url.Parse(l.URL); err == nil {
+		hay += " " + strings.ToLower(u.Path)
+	}
+	n := 0
+	for _, t := range terms {
+		if strings.Contains(hay, t) {
+			n++
+		}
+	}
+	return n
+}
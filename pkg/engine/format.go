@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Content formats for SearchOptions.Format.
+const (
+	ContentMarkdown = "markdown" // "## Title — URL" section headers and "---" separators, as cached
+	ContentPlain    = "plain"    // the same text without Markdown structure
+	ContentJSON     = "json"     // a JSON document with one object per section
+)
+
+// ValidContentFormat reports whether name is a known content format. The
+// empty string selects ContentMarkdown.
+func ValidContentFormat(name string) bool {
+	switch name {
+	case "", ContentMarkdown, ContentPlain, ContentJSON:
+		return true
+	}
+	return false
+}
+
+// contentDoc is the ContentJSON rendering of consolidated content.
+type contentDoc struct {
+	Answer   string           `json:"answer,omitempty"` // the search engine's instant answer, if any
+	Sections []contentSection `json:"sections"`
+}
+
+type contentSection struct {
+	Title       string `json:"title,omitempty"`
+	URL         string `json:"url"`
+	Language    string `json:"language,omitempty"`
+	Published   string `json:"published,omitempty"` // YYYY-MM-DD
+	Author      string `json:"author,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Sponsored   bool   `json:"sponsored,omitempty"`
+	Via         string `json:"retrieved_via,omitempty"`
+	Text        string `json:"text"`
+}
+
+// formatContent renders consolidated content in format. Content is always
+// cached as ContentMarkdown, so any format can be served from a cache hit.
+func formatContent(content, format string) (string, error) {
+	switch format {
+	case "", ContentMarkdown:
+		return content, nil
+	case ContentPlain:
+		return plainContent(content), nil
+	case ContentJSON:
+		b, err := json.Marshal(jsonContent(content))
+		if err != nil {
+			return "", fmt.Errorf("engine: format json: %w", err)
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("engine: unknown content format %q", format)
+}
+
+// preamble returns the text before the first section, such as an instant
+// answer, without Markdown emphasis or its closing separator.
+func preamble(content string) string {
+	end := strings.Index(content, "\n## ")
+	if strings.HasPrefix(content, "## ") {
+		end = 0
+	}
+	if end < 0 {
+		return ""
+	}
+	text := strings.TrimSpace(content[:end])
+	text = strings.TrimSpace(strings.TrimSuffix(text, strings.TrimSpace(sectionSep)))
+	return strings.ReplaceAll(text, "**", "")
+}
+
+func jsonContent(content string) contentDoc {
+	sources, texts := parseSections(content)
+	doc := contentDoc{Answer: preamble(content), Sections: make([]contentSection, len(sources))}
+	for i, src := range sources {
+		s := contentSection{
+			Title:       src.Title,
+			URL:         src.URL,
+			Language:    src.Language,
+			Author:      src.Author,
+			SiteName:    src.SiteName,
+			Description: src.Description,
+			Sponsored:   src.Sponsored,
+			Via:         src.Via,
+			Text:        texts[i],
+		}
+		if !src.Published.IsZero() {
+			s.Published = src.Published.Time.Format("2006-01-02")
+		}
+		doc.Sections[i] = s
+	}
+	return doc
+}
+
+// plainContent drops the Markdown structure: each section starts with its
+// title and URL on lines of their own, followed by its metadata lines, and
+// sections are separated by two blank lines.
+func plainContent(content string) string {
+	sources, texts := parseSections(content)
+	var parts []string
+	if answer := preamble(content); answer != "" {
+		parts = append(parts, answer)
+	}
+	for i, src := range sources {
+		var lines []string
+		if src.Title != "" {
+			lines = append(lines, src.Title)
+		}
+		lines = append(lines, src.URL)
+		if src.Sponsored {
+			lines = append(lines, sponsoredLine)
+		}
+		if !src.Published.IsZero() {
+			lines = append(lines, "Published: "+formatPublished(src.Published))
+		}
+		for _, f := range []struct{ label, value string }{
+			{authorLabel, src.Author},
+			{siteLabel, src.SiteName},
+			{descriptionLabel, src.Description},
+			{viaLabel, src.Via},
+		} {
+			if f.value != "" {
+				lines = append(lines, f.label+f.value)
+			}
+		}
+		parts = append(parts, strings.Join(lines, "\n")+"\n\n"+texts[i])
+	}
+	return strings.Join(parts, "\n\n\n")
+}
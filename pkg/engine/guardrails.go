@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// ErrGuardrail means a deployment guardrail refused a search or a page.
+var ErrGuardrail = errors.New("blocked by guardrail")
+
+// Guardrails are deployment-wide limits on web access for compliance
+// teams. Unlike the rest of Config, nothing per call can relax them: no
+// SearchOptions field, API key or feature flag. A nil Config.Guardrails
+// turns them all off.
+type Guardrails struct {
+	// DomainPagesPerHour caps the pages scraped from any one site per
+	// rolling hour, across every caller sharing the cache database. Pages
+	// over the cap fail with ErrGuardrail. 0 is unlimited.
+	DomainPagesPerHour int
+
+	// RespectRobots skips pages the site's robots.txt disallows, and
+	// leaves out pages marked noindex or noarchive, whatever the call asks
+	// for.
+	RespectRobots bool
+
+	// DisallowedIntents refuses queries classified as any of these
+	// verticals, e.g. IntentLocal. Queries are classified with
+	// Config.Classifier, or RuleClassifier if it is nil.
+	DisallowedIntents []Intent
+
+	// RequireAudit refuses every search while Config.Auditor is nil, and
+	// fails searches whose audit record cannot be written.
+	RequireAudit bool
+}
+
+// guardAudit refuses calls while an audit log is required but missing.
+func (e *Engine) guardAudit() error {
+	if g := e.config.Guardrails; g != nil && g.RequireAudit && e.config.Auditor == nil {
+		return fmt.Errorf("engine: audit log required but not configured: %w", ErrGuardrail)
+	}
+	return nil
+}
+
+// guardQuery applies the call-level guardrails to query.
+func (e *Engine) guardQuery(query string) error {
+	g := e.config.Guardrails
+	if g == nil {
+		return nil
+	}
+	if err := e.guardAudit(); err != nil {
+		return err
+	}
+	if len(g.DisallowedIntents) == 0 {
+		return nil
+	}
+	classifier := e.config.Classifier
+	if classifier == nil {
+		classifier = RuleClassifier{}
+	}
+	if intent := classifier.Classify(query); slices.Contains(g.DisallowedIntents, intent) {
+		return fmt.Errorf("engine: %s queries: %w", intent, ErrGuardrail)
+	}
+	return nil
+}
+
+// guardPage applies the per-site page cap to rawURL, consuming one page of
+// its site's hourly allowance if there is one left.
+func (e *Engine) guardPage(ctx context.Context, rawURL string) error {
+	g := e.config.Guardrails
+	if g == nil || g.DomainPagesPerHour <= 0 {
+		return nil
+	}
+	site := siteKey(rawURL)
+	limits := []cache.UsageLimit{{Window: time.Hour, Max: g.DomainPagesPerHour}}
+	granted, err := e.cache.ReserveContext(ctx, "site:"+site, 1, limits)
+	if err != nil {
+		return fmt.Errorf("engine: %w", err)
+	}
+	if granted == 0 {
+		return fmt.Errorf("engine: %s hourly page limit: %w", site, ErrGuardrail)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Search history limits.
+const (
+	DefaultHistoryLimit = 10000 // records kept when Config.HistoryLimit is 0
+	DefaultHistoryList  = 20    // records SearchHistory lists when limit is 0
+	MaxHistoryList      = 500   // larger SearchHistory limits are capped to this
+)
+
+// ErrNoHistory means the engine's Store does not implement HistoryStore.
+var ErrNoHistory = errors.New("store does not keep history")
+
+// HistoryStore is implemented by stores that can keep a log of searches
+// and fetches, which Config.History needs. *cache.Cache does.
+type HistoryStore interface {
+	AddHistory(ctx context.Context, rec cache.HistoryRecord, keep int) (int64, error)
+	History(ctx context.Context, f cache.HistoryFilter) ([]cache.HistoryRecord, error)
+	HistoryRecord(ctx context.Context, id int64) (cache.HistoryRecord, bool, error)
+}
+
+// recordHistory adds a finished search or fetch to the history, with
+// Config.History. The caller's query is recorded, as for audit records;
+// failures to record are logged and otherwise ignored.
+func (e *Engine) recordHistory(ctx context.Context, run *eventRun, opts SearchOptions, result SearchResult, searchErr error) {
+	hs, ok := e.cache.(HistoryStore)
+	if !e.config.History || !ok {
+		return
+	}
+	rec := cache.HistoryRecord{
+		Time:        time.Now(),
+		Scope:       e.config.KeyScope,
+		Kind:        run.kind,
+		Query:       run.query,
+		Site:        opts.Site,
+		Engine:      result.Engine,
+		ResultCount: result.ResultCount,
+		FromCache:   result.FromCache,
+	}
+	if rec.Engine == "" {
+		rec.Engine = run.engine
+	}
+	if searchErr != nil {
+		rec.Error = searchErr.Error()
+	}
+	keep := e.config.HistoryLimit
+	if keep <= 0 {
+		keep = DefaultHistoryLimit
+	}
+	if _, err := hs.AddHistory(context.WithoutCancel(ctx), rec, keep); err != nil {
+		e.logger().Warn("history write failed", "search_id", run.id, "err", err)
+	}
+}
+
+// SearchHistory lists recorded searches and fetches, newest first: all of
+// them, or with match those whose query or URL contains it, ignoring case.
+// limit 0 uses DefaultHistoryList and larger values are capped to
+// MaxHistoryList. With a KeyScope, only this scope's records are listed.
+func (e *Engine) SearchHistory(ctx context.Context, match string, limit int) ([]cache.HistoryRecord, error) {
+	hs, ok := e.cache.(HistoryStore)
+	if !ok {
+		return nil, fmt.Errorf("engine: %w", ErrNoHistory)
+	}
+	if limit <= 0 {
+		limit = DefaultHistoryList
+	}
+	limit = min(limit, MaxHistoryList)
+	records, err := hs.History(ctx, cache.HistoryFilter{Scope: e.config.KeyScope, Match: match, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	return records, nil
+}
+
+// ReplayHistory runs the recorded search or fetch with id again with opts,
+// on the engine and site it used unless opts sets them. The replay is an
+// ordinary call: served from the cache unless opts.Force or opts.MaxAge say
+// otherwise, and recorded in the history itself. A record of another
+// KeyScope is not found.
+func (e *Engine) ReplayHistory(ctx context.Context, id int64, opts SearchOptions) (SearchResult, error) {
+	hs, ok := e.cache.(HistoryStore)
+	if !ok {
+		return SearchResult{}, fmt.Errorf("engine: %w", ErrNoHistory)
+	}
+	rec, ok, err := hs.HistoryRecord(ctx, id)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("engine: %w", err)
+	}
+	if !ok || rec.Scope != e.config.KeyScope {
+		return SearchResult{}, fmt.Errorf("engine: history record %d: %w", id, cache.ErrNotFound)
+	}
+	if rec.Kind == "fetch" {
+		return e.FetchWithOptions(ctx, rec.Query, opts)
+	}
+	if opts.Site == "" {
+		opts.Site = rec.Site
+	}
+	if opts.Engine == "" && search.ValidEngine(rec.Engine) {
+		opts.Engine = rec.Engine
+	}
+	return e.SearchWithOptions(ctx, rec.Query, opts)
+}
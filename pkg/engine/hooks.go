@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/glsi/pkg/search"
+)
+
+// Hook holds callbacks run at fixed points of every search, so embedders
+// can filter, rewrite, rank or audit searches without changing the engine.
+// Any field may be nil. The hooks in Config.Hooks run in order, each seeing
+// the previous one's output. An error from PreSearch, PostSearch or
+// PostConsolidate stops the search and is returned wrapped; wrap
+// ErrGuardrail in it for front ends to report a refusal rather than an
+// internal error.
+type Hook struct {
+	// PreSearch runs before the cache lookup and may rewrite the query
+	// and options, for example to scrub personal data from the query
+	// before it is cached or sent anywhere. The rewritten query is the
+	// one cached and searched; events and audit records keep the
+	// caller's. It is not run for Fetch.
+	PreSearch func(ctx context.Context, query string, opts SearchOptions) (string, SearchOptions, error)
+
+	// PostSearch runs on the links from the results page, or sitemap, in
+	// rank order before any is scraped, and may drop, reorder or add to
+	// them. It is not run for cache hits.
+	PostSearch func(ctx context.Context, query string, results []search.Result) ([]search.Result, error)
+
+	// PreScrape runs before each page is fetched or read from the page
+	// cache: result pages, followed links and Fetch alike. An error
+	// fails that page with it, without a fetch; the search goes on.
+	PreScrape func(ctx context.Context, url string) error
+
+	// PostConsolidate runs on the consolidated Markdown before it is
+	// cached, so cache hits serve its output too. For Fetch, query is
+	// the URL.
+	PostConsolidate func(ctx context.Context, query, content string) (string, error)
+}
+
+// preSearch runs the PreSearch hooks.
+func (e *Engine) preSearch(ctx context.Context, query string, opts SearchOptions) (string, SearchOptions, error) {
+	for _, h := range e.config.Hooks {
+		if h.PreSearch == nil {
+			continue
+		}
+		var err error
+		if query, opts, err = h.PreSearch(ctx, query, opts); err != nil {
+			return "", SearchOptions{}, fmt.Errorf("engine: pre-search hook: %w", err)
+		}
+	}
+	return query, opts, nil
+}
+
+// postSearch runs the PostSearch hooks.
+func (e *Engine) postSearch(ctx context.Context, query string, results []search.Result) ([]search.Result, error) {
+	for _, h := range e.config.Hooks {
+		if h.PostSearch == nil {
+			continue
+		}
+		var err error
+		if results, err = h.PostSearch(ctx, query, results); err != nil {
+			return nil, fmt.Errorf("engine: post-search hook: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// preScrape runs the PreScrape hooks for url.
+func (e *Engine) preScrape(ctx context.Context, url string) error {
+	for _, h := range e.config.Hooks {
+		if h.PreScrape == nil {
+			continue
+		}
+		if err := h.PreScrape(ctx, url); err != nil {
+			return fmt.Errorf("engine: pre-scrape hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// postConsolidate runs the PostConsolidate hooks.
+func (e *Engine) postConsolidate(ctx context.Context, query, content string) (string, error) {
+	for _, h := range e.config.Hooks {
+		if h.PostConsolidate == nil {
+			continue
+		}
+		var err error
+		if content, err = h.PostConsolidate(ctx, query, content); err != nil {
+			return "", fmt.Errorf("engine: post-consolidate hook: %w", err)
+		}
+	}
+	return content, nil
+}
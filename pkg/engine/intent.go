@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// Intent labels what a query is after.
+type Intent string
+
+// Intents assigned by a Classifier.
+const (
+	IntentNavigational  Intent = "navigational"  // a specific site, e.g. "github login"
+	IntentInformational Intent = "informational" // general knowledge; the fallback
+	IntentNews          Intent = "news"          // recent events
+	IntentCode          Intent = "code"          // programming questions and errors
+	IntentAcademic      Intent = "academic"      // papers and research
+	IntentLocal         Intent = "local"         // places near the user
+)
+
+// ValidIntent reports whether i is one of the Intent constants.
+func ValidIntent(i Intent) bool {
+	switch i {
+	case IntentNavigational, IntentInformational, IntentNews, IntentCode, IntentAcademic, IntentLocal:
+		return true
+	}
+	return false
+}
+
+// Classifier labels queries with an intent. Config.Classifier enables the
+// stage; RuleClassifier is the built-in one.
+type Classifier interface {
+	Classify(query string) Intent
+}
+
+// ClassifierFunc adapts a function to Classifier.
+type ClassifierFunc func(query string) Intent
+
+// Classify calls f.
+func (f ClassifierFunc) Classify(query string) Intent {
+	return f(query)
+}
+
+// IntentRoute holds the options a search with a given intent gets. Each
+// field only fills an option the caller left zero, so explicit parameters
+// always win.
+type IntentRoute struct {
+	Engine      string // search engine, e.g. search.EngineDuckDuckGo
+	Count       int
+	MaxPerHost  int
+	MaxSections int
+	Depth       int
+	Extractor   string
+	Output      string
+	TTL         time.Duration // how long results stay cached; 0 uses the Store's default
+}
+
+// DefaultIntentRoutes returns the routes used when Config.IntentRoutes is
+// nil: navigational queries scrape the few top hits and follow their
+// links, news spreads results across outlets and is cached for an hour,
+// code keeps Markdown structure, academic pages get the best of both
+// extractors and are cached for a week, and local queries go to Google.
+// Informational queries keep the defaults.
+func DefaultIntentRoutes() map[Intent]IntentRoute {
+	return map[Intent]IntentRoute{
+		IntentNavigational: {Count: 3, Depth: 1},
+		IntentNews:         {MaxPerHost: 1, TTL: time.Hour},
+		IntentCode:         {Output: scraper.OutputMarkdown, MaxPerHost: 2},
+		IntentAcademic:     {Extractor: scraper.ExtractorAuto, TTL: 7 * 24 * time.Hour},
+		IntentLocal:        {Engine: search.EngineGoogle},
+	}
+}
+
+// route classifies query and fills the options its intent's route sets.
+// Without a Classifier it returns opts unchanged and no intent.
+func (e *Engine) route(query string, opts SearchOptions) (SearchOptions, Intent) {
+	if e.config.Classifier == nil {
+		return opts, ""
+	}
+	intent := e.config.Classifier.Classify(query)
+	routes := e.config.IntentRoutes
+	if routes == nil {
+		routes = DefaultIntentRoutes()
+	}
+	r := routes[intent]
+	if opts.Engine == "" {
+		opts.Engine = r.Engine
+	}
+	if opts.Count == 0 {
+		opts.Count = r.Count
+	}
+	if opts.MaxPerHost == 0 {
+		opts.MaxPerHost = r.MaxPerHost
+	}
+	if opts.MaxSections == 0 {
+		opts.MaxSections = r.MaxSections
+	}
+	if opts.Depth == 0 {
+		opts.Depth = r.Depth
+	}
+	if opts.Extractor == "" {
+		opts.Extractor = r.Extractor
+	}
+	if opts.Output == "" {
+		opts.Output = r.Output
+	}
+	if opts.TTL == 0 {
+		opts.TTL = r.TTL
+	}
+	return opts, intent
+}
+
+// RuleClassifier is a keyword and pattern classifier needing no model or
+// network. Rules are checked from the most specific intent down, and
+// queries matching none are informational.
+type RuleClassifier struct{}
+
+var (
+	// rxDomain matches a bare host such as "go.dev" or "news.ycombinator.com".
+	rxDomain = regexp.MustCompile(`^(?:[a-z0-9-]+\.)+[a-z]{2,}$`)
+	// rxCodeSyntax matches call parentheses, scope operators, arrows and
+	// file names with source extensions.
+	rxCodeSyntax = regexp.MustCompile(`\w\(\)|::|=>|->|\.(?:go|py|js|ts|rs|java|cpp|rb|php|cs|kt|swift)\b`)
+	// rxErrorName matches exception and error type names like
+	// "NullPointerException" or "TypeError".
+	rxErrorName = regexp.MustCompile(`\b[A-Z]\w*(?:Exception|Error)\b`)
+)
+
+var (
+	navigationalWords = []string{"login", "log in", "sign in", "signin", "homepage", "home page", "official site", "official website", "website"}
+	localWords        = []string{"near me", "nearby", "open now", "directions to", "closest", "opening hours"}
+	newsWords         = []string{"news", "latest", "breaking", "today", "yesterday", "this week", "headlines", "announced", "announcement"}
+	academicWords     = []string{"paper", "papers", "journal", "arxiv", "doi", "study", "studies", "peer-reviewed", "meta-analysis", "thesis", "research", "citation"}
+	codeWords         = []string{
+		"golang", "python", "javascript", "typescript", "rust", "java", "kotlin", "c++", "c#", "ruby", "php", "swift", "sql",
+		"npm", "pip", "cargo", "docker", "kubernetes", "git", "regex", "api", "sdk",
+		"error", "exception", "stack trace", "segfault", "compile", "compiler", "syntax", "undefined", "null pointer",
+		"function", "method", "struct", "class", "goroutine", "async", "await",
+	}
+)
+
+// Classify implements Classifier.
+func (RuleClassifier) Classify(query string) Intent {
+	q := NormalizeQuery(query)
+	switch {
+	case q == "":
+		return IntentInformational
+	case rxDomain.MatchString(q) || hasPhrase(q, navigationalWords):
+		return IntentNavigational
+	case hasPhrase(q, localWords):
+		return IntentLocal
+	case hasPhrase(q, newsWords):
+		return IntentNews
+	case hasPhrase(q, academicWords):
+		return IntentAcademic
+	case rxCodeSyntax.MatchString(q) || rxErrorName.MatchString(query) || hasPhrase(q, codeWords):
+		return IntentCode
+	}
+	return IntentInformational
+}
+
+// hasPhrase reports whether q contains one of phrases as whole words.
+func hasPhrase(q string, phrases []string) bool {
+	padded := " " + strings.Join(strings.FieldsFunc(q, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '?' || r == '!' || r == ':' || r == ';' || r == '"'
+	}), " ") + " "
+	for _, p := range phrases {
+		if strings.Contains(padded, " "+p+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// KeyDeriver turns a query into the key its result is cached under. scope
+// is Config.KeyScope. Embedders that partition one Store between tenants
+// can supply their own derivation, e.g. an HMAC keyed per tenant.
+type KeyDeriver interface {
+	DeriveKey(scope, query string) string
+}
+
+// KeyDeriverFunc adapts a function to KeyDeriver.
+type KeyDeriverFunc func(scope, query string) string
+
+// DeriveKey calls f.
+func (f KeyDeriverFunc) DeriveKey(scope, query string) string {
+	return f(scope, query)
+}
+
+// SHA256Keys is the default KeyDeriver: the hex SHA-256 of the normalized
+// query, preceded by scope and a NUL byte when scope is set. Unscoped keys
+// are the same as before key derivation was configurable, so existing
+// caches stay valid.
+var SHA256Keys KeyDeriver = KeyDeriverFunc(sha256Key)
+
+func sha256Key(scope, query string) string {
+	s := NormalizeQuery(query)
+	if scope != "" {
+		s = scope + "\x00" + s
+	}
+	h := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("%x", h)
+}
+
+// NormalizeQuery lowercases and trims a query, so queries differing only
+// in case or surrounding space share a cache entry. Custom KeyDerivers
+// should apply it too.
+func NormalizeQuery(query string) string {
+	return strings.TrimSpace(strings.ToLower(query))
+}
+
+// TokenSetKeys is a KeyDeriver for fuzzy cache matching: queries with the
+// same set of content words share a key whatever their word order, stop
+// words, punctuation and plurals, so "golang mutex tutorial" and "tutorial
+// on golang mutexes" hit one entry. Queries made only of stop words fall
+// back to SHA256Keys. Its keys differ from SHA256Keys', so switching
+// starts from a cold cache.
+var TokenSetKeys KeyDeriver = KeyDeriverFunc(tokenSetKey)
+
+func tokenSetKey(scope, query string) string {
+	// Site searches append a NUL and the site, which must match exactly;
+	// only the words before it are matched loosely.
+	words, suffix, cut := strings.Cut(query, "\x00")
+	if set := TokenSet(words); set != "" {
+		words = set
+	}
+	if cut {
+		words += "\x00" + suffix
+	}
+	return sha256Key(scope, words)
+}
+
+// stopWords are left out of token sets, since rephrasings add and drop
+// them freely.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "do": true, "does": true, "for": true, "from": true,
+	"how": true, "i": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "what": true, "with": true,
+}
+
+// TokenSet returns the sorted, deduplicated content words of query,
+// singularized and joined by spaces, as TokenSetKeys matches them. Letters,
+// digits, "+", "#" and inner dots make up words, so "c++" and "node.js"
+// survive.
+func TokenSet(query string) string {
+	words := strings.FieldsFunc(NormalizeQuery(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#' && r != '.'
+	})
+	var set []string
+	for _, w := range words {
+		w = strings.Trim(w, ".")
+		if w == "" || stopWords[w] {
+			continue
+		}
+		set = append(set, singular(w))
+	}
+	slices.Sort(set)
+	return strings.Join(slices.Compact(set), " ")
+}
+
+// singular strips common English plural endings from w. It only needs to
+// map a plural and its singular to the same word, not to a real one.
+func singular(w string) string {
+	switch {
+	case len(w) <= 3:
+		return w
+	case strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "xes"), strings.HasSuffix(w, "ches"),
+		strings.HasSuffix(w, "shes"), strings.HasSuffix(w, "zes"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") &&
+		!strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// key derives the cache key for query with the configured KeyDeriver and
+// KeyScope.
+func (e *Engine) key(query string) string {
+	keys := e.config.Keys
+	if keys == nil {
+		keys = SHA256Keys
+	}
+	return keys.DeriveKey(e.config.KeyScope, query)
+}
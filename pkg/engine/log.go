@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/user/glsi/pkg/search"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger resolves Config.Logger.
+func (e *Engine) logger() *slog.Logger {
+	if e.config.Logger == nil {
+		return discardLogger
+	}
+	return e.config.Logger
+}
+
+// searchEngine is the engine a search with opts takes its links from.
+func (e *Engine) searchEngine(opts SearchOptions) string {
+	if opts.Site != "" {
+		return siteEngine
+	}
+	if opts.Engine != "" {
+		return search.CanonicalEngine(opts.Engine)
+	}
+	return search.CanonicalEngine(e.config.SearchEngine)
+}
+
+// setHash tags the run's logs with the cache key of its query, once the
+// pipeline has derived it.
+func (r *eventRun) setHash(hash string) {
+	r.log = r.log.With("query_hash", hash)
+}
+
+// logSearch logs a finished call: a warning if it failed, otherwise its
+// outcome and where its time went.
+func (r *eventRun) logSearch(result SearchResult, err error) {
+	engine := r.engine
+	if result.Engine != "" {
+		engine = result.Engine
+	}
+	if err != nil {
+		r.log.Warn("search failed", "kind", r.kind, "engine", engine, "duration", time.Since(r.start), "err", err)
+		return
+	}
+	t := result.Timings
+	r.log.Info("search done", "kind", r.kind, "engine", engine, "results", result.ResultCount,
+		"from_cache", result.FromCache, "stale", result.Stale, "partial", result.Partial, "duration", time.Since(r.start),
+		"serp", t.SERP, "rate_limit_wait", t.RateLimitWait, "scrape", t.Scrape, "cache", t.Cache)
+}
+
+// logPage logs a scraped page: a warning if it failed, otherwise a debug
+// line.
+func (r *eventRun) logPage(info PageInfo) {
+	if info.Err != nil {
+		r.log.Warn("page failed", "url", info.URL, "status", info.Status, "duration", info.Timings.Total, "err", info.Err)
+		return
+	}
+	r.log.Debug("page scraped", "url", info.URL, "status", info.Status, "words", info.Words, "bytes", info.Bytes,
+		"cached", info.Cached, "via", info.Via, "duration", info.Timings.Total)
+}
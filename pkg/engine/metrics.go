@@ -0,0 +1,131 @@
+package engine
+
+import "time"
+
+// Metrics receives the engine's counters and latency samples, named by the
+// Metric constants. Labels are name, value pairs. Implementations must be
+// safe for concurrent use; metrics.Registry keeps them in memory and
+// exports them for Prometheus.
+type Metrics interface {
+	Inc(name string, labels ...string)
+	Observe(name string, seconds float64, labels ...string)
+}
+
+// MetricsReader is implemented by Metrics that can read back what they
+// recorded, as metrics.Registry does. Stats summarizes them in Searches.
+type MetricsReader interface {
+	Counter(name string, labels ...string) float64
+	Histogram(name string, labels ...string) (count uint64, sum float64)
+}
+
+// Metrics recorded in Config.Metrics.
+const (
+	MetricSearches = "searches_total"         // labels kind (search or fetch) and outcome (hit, stale, fresh, partial or error)
+	MetricPages    = "pages_total"            // label outcome (ok, failed or cached)
+	MetricStage    = "stage_duration_seconds" // histogram; label stage (serp, rate_limit_wait, scrape, consolidate, cache or total)
+)
+
+var (
+	metricKinds    = []string{"search", "fetch"}
+	metricOutcomes = []string{"hit", "stale", "fresh", "partial", "error"}
+	metricStages   = []string{"serp", "rate_limit_wait", "scrape", "consolidate", "cache", "total"}
+)
+
+// SearchStats summarizes Config.Metrics since the process started.
+type SearchStats struct {
+	Searches    int64                    // searches and fetches, failed ones included
+	CacheHits   int64                    // served from the cache, stale entries included
+	Partial     int64                    // cut short by their deadline
+	Errors      int64                    // failed
+	Pages       int64                    // pages scraped or reused from the page cache
+	PagesFailed int64                    // pages that could not be read
+	PagesCached int64                    // pages reused from the page cache
+	Stages      map[string]time.Duration // mean time in each stage, over the calls that reached it
+}
+
+// HitRatio is the share of searches served from the cache.
+func (s SearchStats) HitRatio() float64 {
+	if s.Searches == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.Searches)
+}
+
+// PageSuccessRate is the share of pages read successfully.
+func (s SearchStats) PageSuccessRate() float64 {
+	if s.Pages == 0 {
+		return 0
+	}
+	return float64(s.Pages-s.PagesFailed) / float64(s.Pages)
+}
+
+// searchStats reads a SearchStats back from m.
+func searchStats(m MetricsReader) SearchStats {
+	var s SearchStats
+	for _, kind := range metricKinds {
+		for _, outcome := range metricOutcomes {
+			n := int64(m.Counter(MetricSearches, "kind", kind, "outcome", outcome))
+			s.Searches += n
+			switch outcome {
+			case "hit", "stale":
+				s.CacheHits += n
+			case "partial":
+				s.Partial += n
+			case "error":
+				s.Errors += n
+			}
+		}
+	}
+	ok := int64(m.Counter(MetricPages, "outcome", "ok"))
+	s.PagesFailed = int64(m.Counter(MetricPages, "outcome", "failed"))
+	s.PagesCached = int64(m.Counter(MetricPages, "outcome", "cached"))
+	s.Pages = ok + s.PagesFailed + s.PagesCached
+	s.Stages = make(map[string]time.Duration)
+	for _, stage := range metricStages {
+		if count, sum := m.Histogram(MetricStage, "stage", stage); count > 0 {
+			s.Stages[stage] = time.Duration(sum / float64(count) * float64(time.Second))
+		}
+	}
+	return s
+}
+
+// countSearch records a finished call in the run's Metrics.
+func (r *eventRun) countSearch(result SearchResult, err error) {
+	if r.metrics == nil {
+		return
+	}
+	outcome := "fresh"
+	switch {
+	case err != nil:
+		outcome = "error"
+	case result.Stale:
+		outcome = "stale"
+	case result.FromCache:
+		outcome = "hit"
+	case result.Partial:
+		outcome = "partial"
+	}
+	r.metrics.Inc(MetricSearches, "kind", r.kind, "outcome", outcome)
+
+	t := result.Timings
+	for i, d := range []time.Duration{t.SERP, t.RateLimitWait, t.Scrape, t.Consolidate, t.Cache, t.Total} {
+		if d > 0 {
+			r.metrics.Observe(MetricStage, d.Seconds(), "stage", metricStages[i])
+		}
+	}
+}
+
+// countPage records a scraped page in the run's Metrics.
+func (r *eventRun) countPage(info PageInfo) {
+	if r.metrics == nil {
+		return
+	}
+	outcome := "ok"
+	switch {
+	case info.Err != nil:
+		outcome = "failed"
+	case info.Cached:
+		outcome = "cached"
+	}
+	r.metrics.Inc(MetricPages, "outcome", outcome)
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// pageKey is the key a page scraped from url with opts is cached under.
+// Options that change what extraction returns are part of it, and so is
+// Config.KeyScope.
+func (e *Engine) pageKey(url string, opts scraper.Options) string {
+	s := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s\x00%v", e.config.KeyScope, url, opts.Extractor, opts.Render, opts.Output, opts.Links)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+// pageStore returns the Store as a PageStore if Config.PageTTL enables the
+// page cache.
+func (e *Engine) pageStore() (PageStore, bool) {
+	ps, ok := e.cache.(PageStore)
+	return ps, ok && e.config.PageTTL > 0
+}
+
+// cachedPage returns the page cached for url, if any. Lookup failures are
+// logged and treated as misses, since the page can still be fetched.
+func (e *Engine) cachedPage(ctx context.Context, ps PageStore, url string, opts scraper.Options) (scraper.ScrapedPage, bool) {
+	data, ok, err := ps.GetPage(ctx, e.pageKey(url, opts))
+	if err != nil {
+		opts.Logger.WarnContext(ctx, "page cache read failed", "url", url, "err", err)
+	}
+	if err != nil || !ok {
+		return scraper.ScrapedPage{}, false
+	}
+	var p scraper.ScrapedPage
+	if err := json.Unmarshal(data, &p); err != nil {
+		opts.Logger.WarnContext(ctx, "page cache entry unreadable", "url", url, "err", err)
+		return scraper.ScrapedPage{}, false
+	}
+	return p, true
+}
+
+// storePage caches a page that scraped successfully. Failures are left for
+// the next search to retry, and storage errors are only logged: the page
+// has been fetched either way.
+func (e *Engine) storePage(ctx context.Context, ps PageStore, p scraper.ScrapedPage, opts scraper.Options) {
+	if p.Err != nil || p.Content == "" {
+		return
+	}
+	p.Timings, p.Attempts, p.Revalidated = scraper.Timings{}, 0, false
+	data, err := json.Marshal(p)
+	if err == nil {
+		err = ps.PutPage(ctx, e.pageKey(p.URL, opts), data, e.config.PageTTL)
+	}
+	if err != nil {
+		opts.Logger.WarnContext(ctx, "page cache write failed", "url", p.URL, "err", err)
+	}
+}
@@ -0,0 +1,23 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// maxPartialReserve caps the time kept back from a search's deadline to
+// consolidate and return the pages scraped so far.
+const maxPartialReserve = 500 * time.Millisecond
+
+// scrapeContext returns the context for a search's scrapes. When ctx has a
+// deadline, it ends a tenth of the time left earlier, up to
+// maxPartialReserve, so a search that runs out of time still has time to
+// return the pages already scraped rather than nothing.
+func scrapeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	reserve := min(time.Until(deadline)/10, maxPartialReserve)
+	return context.WithDeadline(ctx, deadline.Add(-reserve))
+}
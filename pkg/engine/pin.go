@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// Pin keeps query's cached result indefinitely, exempt from TTL expiry and
+// cache flushes. If the query is not cached yet it is searched first.
+func (e *Engine) Pin(ctx context.Context, query string) error {
+	hash := e.key(query)
+	if _, hit, err := e.cache.GetContext(ctx, hash); err != nil {
+		return fmt.Errorf("engine: cache get: %w", err)
+	} else if !hit {
+		opts := SearchOptions{Force: true}
+		run := e.startRun(query, opts)
+		result, err := e.search(ctx, query, opts, run)
+		if aerr := e.audit(ctx, run, opts, result, err); aerr != nil {
+			result, err = SearchResult{}, aerr
+		}
+		run.finish(result, err)
+		if err != nil {
+			return err
+		}
+	}
+	if err := e.cache.Pin(hash, query); err != nil {
+		return fmt.Errorf("engine: %w", err)
+	}
+	return nil
+}
+
+// Unpin returns query's cached result to normal TTL handling.
+func (e *Engine) Unpin(query string) error {
+	if err := e.cache.Unpin(e.key(query)); err != nil {
+		return fmt.Errorf("engine: %w", err)
+	}
+	return nil
+}
+
+// Pinned lists pinned cache entries.
+func (e *Engine) Pinned() ([]cache.PinnedEntry, error) {
+	entries, err := e.cache.Pinned()
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Refresh defaults, used when RefreshPolicy leaves them at zero.
+const (
+	DefaultRefreshInterval = 10 * time.Minute
+	DefaultRefreshWithin   = time.Hour
+	DefaultRefreshMinHits  = 3
+)
+
+// RefreshPolicy keeps popular cache entries warm: entries read at least
+// MinHits times since they were stored are searched again when they are
+// within Within of expiring. Entries nobody reads are left to expire, since
+// a refresh restarts the count.
+type RefreshPolicy struct {
+	Interval time.Duration // time between passes; 0 uses DefaultRefreshInterval
+	Within   time.Duration // how close to expiry an entry is refreshed; 0 uses DefaultRefreshWithin
+	MinHits  int           // reads needed for a refresh; 0 uses DefaultRefreshMinHits
+}
+
+func (p RefreshPolicy) interval() time.Duration {
+	if p.Interval <= 0 {
+		return DefaultRefreshInterval
+	}
+	return p.Interval
+}
+
+func (p RefreshPolicy) within() time.Duration {
+	if p.Within <= 0 {
+		return DefaultRefreshWithin
+	}
+	return p.Within
+}
+
+func (p RefreshPolicy) minHits() int {
+	if p.MinHits <= 0 {
+		return DefaultRefreshMinHits
+	}
+	return p.MinHits
+}
+
+// ErrNoRefresh means Config.Refresh is nil or the engine's Store does not
+// implement ExpiringLister.
+var ErrNoRefresh = errors.New("refresh not configured")
+
+// RefreshPopular searches again, one at a time, every entry that
+// Config.Refresh says to keep warm, and returns how many it refreshed.
+// Entries whose query no longer derives their key, such as site searches
+// or entries from another KeyScope, are skipped, as are entries already
+// being refreshed. A failed search leaves its entry to expire and does not
+// stop the pass.
+func (e *Engine) RefreshPopular(ctx context.Context) (int, error) {
+	lister, ok := e.cache.(ExpiringLister)
+	if e.config.Refresh == nil || !ok {
+		return 0, fmt.Errorf("engine: %w", ErrNoRefresh)
+	}
+	p := *e.config.Refresh
+	entries, err := lister.Expiring(ctx, p.within(), p.minHits())
+	if err != nil {
+		return 0, fmt.Errorf("engine: %w", err)
+	}
+	n := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return n, fmt.Errorf("engine: %w", err)
+		}
+		if e.key(entry.Query) != entry.QueryHash || !e.claimRefresh(entry.QueryHash) {
+			continue
+		}
+		opts, _ := e.route(entry.Query, SearchOptions{})
+		rctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+		err := e.research(rctx, entry.Query, opts)
+		cancel()
+		e.releaseRefresh(entry.QueryHash)
+		if err == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RunRefresher calls RefreshPopular every Config.Refresh.Interval until ctx
+// is done. It returns at once if refreshing is not configured, so callers
+// can start it unconditionally in its own goroutine.
+func (e *Engine) RunRefresher(ctx context.Context) {
+	if _, ok := e.cache.(ExpiringLister); e.config.Refresh == nil || !ok {
+		return
+	}
+	ticker := time.NewTicker(e.config.Refresh.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.RefreshPopular(ctx)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// DeepResearch defaults and limits, used when ResearchOptions leaves them
+// at zero.
+const (
+	DefaultResearchDepth   = 2  // follow-up rounds after the first search
+	MaxResearchDepth       = 4  // larger depths are capped to this
+	DefaultResearchPages   = 20 // pages across all rounds
+	DefaultResearchBreadth = 3  // follow-up queries, and links, per round
+)
+
+// Largest ResearchOptions that front ends accept from clients.
+const (
+	MaxResearchPages   = 50
+	MaxResearchBreadth = 5
+)
+
+// ResearchOptions bounds a DeepResearch run.
+type ResearchOptions struct {
+	Depth    int // follow-up rounds after the first search; 0 uses DefaultResearchDepth, negative runs none
+	MaxPages int // pages scraped or reused across all rounds; 0 uses DefaultResearchPages
+	Breadth  int // most follow-up queries, and most links, per round; 0 uses DefaultResearchBreadth
+
+	// Search holds the options of every search and fetch. Format, Cite
+	// and Summarize are ignored, keeping content in the cached layout
+	// without Config.SectionTemplate, and Output defaults to markdown,
+	// whose headings and links the follow-ups are drawn from.
+	Search SearchOptions
+}
+
+// ResearchStep is one search or fetch of a DeepResearch round.
+type ResearchStep struct {
+	Query  string // the search's query; empty for a followed link
+	URL    string // the followed link; empty for a search
+	Result SearchResult
+	Err    error
+}
+
+// ResearchRound holds the steps of one DeepResearch round, in order.
+type ResearchRound struct {
+	Steps []ResearchStep
+}
+
+// ResearchReport is the outcome of DeepResearch.
+type ResearchReport struct {
+	Query   string
+	Rounds  []ResearchRound // the first holds the search for Query
+	Pages   int             // pages counted against ResearchOptions.MaxPages
+	Content string          // every successful step's content, under a "# Round n" heading each
+	Sources []Source        // every successful step's sources, first occurrence only
+}
+
+// DeepResearch searches query, then runs up to opts.Depth follow-up
+// rounds, each drawn from the round before: searches for query narrowed by
+// the sub-topics its pages' headings name most often, and fetches of the
+// links in their text that best match query. Pages and queries already
+// covered are not repeated, and the rounds stop early once opts.MaxPages
+// pages are spent or nothing new turns up. Each search and fetch is an
+// ordinary one, cached and counted against budgets, and the searches of a
+// round run as a SearchBatch. Only a failed first search fails the call;
+// failed follow-ups are kept in their round with their error. It fails with
+// ErrFeatureDisabled if FeatureResearch is off for opts.Search.Key.
+func (e *Engine) DeepResearch(ctx context.Context, query string, opts ResearchOptions) (ResearchReport, error) {
+	if !e.config.Features.Enabled(FeatureResearch, opts.Search.Key) {
+		return ResearchReport{}, fmt.Errorf("engine: deep research: %w", ErrFeatureDisabled)
+	}
+	depth := opts.Depth
+	switch {
+	case depth == 0:
+		depth = DefaultResearchDepth
+	case depth < 0:
+		depth = 0
+	}
+	depth = min(depth, MaxResearchDepth)
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultResearchPages
+	}
+	breadth := opts.Breadth
+	if breadth <= 0 {
+		breadth = DefaultResearchBreadth
+	}
+	sopts := opts.Search
+	sopts.Format, sopts.Summarize, sopts.Cite = ContentMarkdown, false, false
+	if sopts.Output == "" {
+		sopts.Output = scraper.OutputMarkdown
+	}
+
+	report := ResearchReport{Query: query}
+	first := sopts
+	first.Count = min(e.config.count(sopts.Count), maxPages)
+	result, err := e.SearchWithOptions(ctx, query, first)
+	if err != nil {
+		return ResearchReport{}, err
+	}
+	steps := []ResearchStep{{Query: query, Result: result}}
+	report.Rounds = append(report.Rounds, ResearchRound{Steps: steps})
+	report.Pages += pagesSpent(result)
+
+	seenURLs := make(map[string]bool)
+	seenQueries := map[string]bool{NormalizeQuery(query): true}
+	markSeen := func(steps []ResearchStep) {
+		for _, s := range steps {
+			if s.URL != "" {
+				seenURLs[dedupKey(s.URL)] = true
+			}
+			for _, src := range s.Result.Sources {
+				seenURLs[dedupKey(src.URL)] = true
+			}
+		}
+	}
+	markSeen(steps)
+
+	for round := 1; round <= depth; round++ {
+		left := maxPages - report.Pages
+		if left <= 0 || ctx.Err() != nil {
+			break
+		}
+		queries, links := researchFollowUps(query, steps, seenQueries, seenURLs, breadth)
+		links = links[:min(len(links), left)]
+		left -= len(links)
+		queries = queries[:min(len(queries), left)]
+		if len(queries) == 0 && len(links) == 0 {
+			break
+		}
+		steps = nil
+		if len(queries) > 0 {
+			qopts := sopts
+			qopts.Count = min(e.config.count(sopts.Count), max(left/len(queries), 1))
+			for _, br := range e.SearchBatch(ctx, queries, qopts) {
+				steps = append(steps, ResearchStep{Query: br.Query, Result: br.Result, Err: br.Err})
+			}
+		}
+		for _, link := range links {
+			result, err := e.FetchWithOptions(ctx, link, sopts)
+			steps = append(steps, ResearchStep{URL: link, Result: result, Err: err})
+		}
+		markSeen(steps)
+		for _, s := range steps {
+			report.Pages += pagesSpent(s.Result)
+		}
+		report.Rounds = append(report.Rounds, ResearchRound{Steps: steps})
+	}
+
+	report.Content, report.Sources = researchContent(report.Rounds)
+	return report, nil
+}
+
+// pagesSpent is how many pages a step counts against the research's page
+// budget: those it scraped or, for a cache hit, those it consolidated.
+func pagesSpent(result SearchResult) int {
+	if len(result.Pages) > 0 {
+		return len(result.Pages)
+	}
+	return result.ResultCount
+}
+
+// researchContent joins the content of the successful steps under a
+// heading naming each step's round and query or link, and collects their
+// sources.
+func researchContent(rounds []ResearchRound) (string, []Source) {
+	var (
+		parts   []string
+		sources []Source
+		seen    = make(map[string]bool)
+	)
+	for i, r := range rounds {
+		for _, s := range r.Steps {
+			if s.Err != nil || s.Result.Content == "" {
+				continue
+			}
+			heading := fmt.Sprintf("# Round %d: search %q", i+1, s.Query)
+			if s.URL != "" {
+				heading = fmt.Sprintf("# Round %d: link %s", i+1, s.URL)
+			}
+			parts = append(parts, heading+"\n\n"+s.Result.Content)
+			for _, src := range s.Result.Sources {
+				if key := dedupKey(src.URL); !seen[key] {
+					seen[key] = true
+					sources = append(sources, src)
+				}
+			}
+		}
+	}
+	return strings.Join(parts, sectionSep), sources
+}
+
+var (
+	// rxHeading matches a Markdown heading inside a section's text.
+	rxHeading = regexp.MustCompile(`^\s?#{2,6}\s+(.+?)\s*#*$`)
+	// rxMarkdownLink matches an inline Markdown link to a web page.
+	rxMarkdownLink = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+)
+
+// researchStopHeadings are headings of page furniture rather than
+// sub-topics, lowercased.
+var researchStopHeadings = map[string]bool{
+	"contents": true, "table of contents": true, "navigation": true, "menu": true,
+	"references": true, "see also": true, "further reading": true, "external links": true,
+	"notes": true, "footnotes": true, "comments": true, "related": true, "related articles": true,
+	"share": true, "share this": true, "subscribe": true, "newsletter": true, "footer": true,
+}
+
+// researchFollowUps picks the next round's searches and links from the
+// successful steps of the last one. Searches are query plus a heading from
+// the steps' pages, the headings found on the most pages first; links are
+// those in the pages' text whose anchor text or path matches the most
+// query terms, found on a page of their own site or not. Queries and
+// URLs already covered are skipped, and at most breadth of each are
+// returned.
+func researchFollowUps(query string, steps []ResearchStep, seenQueries, seenURLs map[string]bool, breadth int) ([]string, []string) {
+	type heading struct {
+		text  string
+		pages int
+		order int
+	}
+	var (
+		headings = make(map[string]*heading)
+		terms    = queryTerms(query)
+		cands    []followCandidate
+		picked   = make(map[string]bool)
+		order    int
+	)
+	for _, s := range steps {
+		if s.Err != nil {
+			continue
+		}
+		_, texts := parseSections(s.Result.Content)
+		for _, text := range texts {
+			onPage := make(map[string]bool)
+			for _, line := range strings.Split(text, "\n") {
+				m := rxHeading.FindStringSubmatch(line)
+				if m == nil {
+					continue
+				}
+				t := strings.Join(strings.Fields(strings.Trim(m[1], "*_`")), " ")
+				key := strings.ToLower(t)
+				if n := len([]rune(t)); n < 4 || n > 60 || researchStopHeadings[key] || onPage[key] {
+					continue
+				}
+				onPage[key] = true
+				h := headings[key]
+				if h == nil {
+					h = &heading{text: t, order: order}
+					headings[key] = h
+					order++
+				}
+				h.pages++
+			}
+			for _, m := range rxMarkdownLink.FindAllStringSubmatch(text, -1) {
+				key := dedupKey(m[2])
+				if seenURLs[key] || picked[key] {
+					continue
+				}
+				if score := matchedTerms(terms, scraper.Link{URL: m[2], Text: m[1]}); score > 0 {
+					picked[key] = true
+					cands = append(cands, followCandidate{url: m[2], score: score})
+				}
+			}
+		}
+	}
+
+	ranked := make([]*heading, 0, len(headings))
+	for _, h := range headings {
+		ranked = append(ranked, h)
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if ranked[a].pages != ranked[b].pages {
+			return ranked[a].pages > ranked[b].pages
+		}
+		return ranked[a].order < ranked[b].order
+	})
+	var queries []string
+	for _, h := range ranked {
+		if len(queries) == breadth {
+			break
+		}
+		q := query + " " + h.text
+		if key := NormalizeQuery(q); !seenQueries[key] {
+			seenQueries[key] = true
+			queries = append(queries, q)
+		}
+	}
+
+	sort.SliceStable(cands, func(a, b int) bool { return cands[a].score > cands[b].score })
+	var links []string
+	for _, c := range cands[:min(len(cands), breadth)] {
+		links = append(links, c.url)
+	}
+	return queries, links
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// pageURL is where p's content came from: the end of its redirects if
+// known, else the URL the SERP linked to. Shortened and redirector links
+// thus show their real destination, without the tracking parameters
+// redirects often add.
+func pageURL(p scraper.ScrapedPage) string {
+	if p.FinalURL != "" {
+		return search.StripTracking(p.FinalURL)
+	}
+	return p.URL
+}
+
+// dedupKey normalizes a page URL for duplicate detection: tracking
+// parameters and the fragment are dropped and the host lowercased.
+func dedupKey(rawURL string) string {
+	u, err := url.Parse(search.StripTracking(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// duplicates returns the indexes of usable pages that landed on the same
+// final URL as an earlier usable page, as when a redirector link and a
+// direct link lead to the same article. The first, highest-ranked copy is
+// the one kept. Pages in skip are neither kept nor duplicates.
+func duplicates(pages []scraper.ScrapedPage, skip map[int]bool) map[int]bool {
+	seen := make(map[string]bool)
+	dups := make(map[int]bool)
+	for i, p := range pages {
+		if !usable(p) || skip[i] {
+			continue
+		}
+		key := dedupKey(pageURL(p))
+		if seen[key] {
+			dups[i] = true
+		}
+		seen[key] = true
+	}
+	return dups
+}
+
+// maxSections resolves the section cap for a call; 0 means every usable
+// page is consolidated.
+func (e *Engine) maxSections(opts SearchOptions) int {
+	if opts.MaxSections > 0 {
+		return opts.MaxSections
+	}
+	return e.config.MaxSections
+}
+
+// usable reports whether consolidate would include p.
+func usable(p scraper.ScrapedPage) bool {
+	return p.Err == nil && strings.TrimSpace(p.Content) != "" && (!p.Blocked || p.Via != "")
+}
+
+// selectSections drops the pages in dups and keeps the n best remaining
+// usable pages, in their original rank order, reporting which pages the
+// cap cut. Organic pages beat sponsored ones, then higher scraper.Quality
+// wins; ties keep rank order. n <= 0 means no cap.
+func selectSections(pages []scraper.ScrapedPage, dups map[int]bool, sponsored map[string]bool, n int) ([]scraper.ScrapedPage, map[int]bool) {
+	var idx []int
+	for i, p := range pages {
+		if usable(p) && !dups[i] {
+			idx = append(idx, i)
+		}
+	}
+	cut := make(map[int]bool)
+	if n > 0 && len(idx) > n {
+		score := make(map[int]float64, len(idx))
+		for _, i := range idx {
+			score[i] = scraper.Quality(pages[i].Content)
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			pa, pb := pages[idx[a]], pages[idx[b]]
+			if sponsored[pa.URL] != sponsored[pb.URL] {
+				return !sponsored[pa.URL]
+			}
+			return score[idx[a]] > score[idx[b]]
+		})
+		for _, i := range idx[n:] {
+			cut[i] = true
+		}
+	}
+	if len(cut) == 0 && len(dups) == 0 {
+		return pages, cut
+	}
+	var kept []scraper.ScrapedPage
+	for i, p := range pages {
+		if !cut[i] && !dups[i] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, cut
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// SitemapReader lists the page URLs of a site for SearchOptions.Site.
+type SitemapReader interface {
+	Sitemap(ctx context.Context, site string, opts scraper.Options) ([]string, error)
+}
+
+// SitemapReaderFunc adapts a function to SitemapReader.
+type SitemapReaderFunc func(ctx context.Context, site string, opts scraper.Options) ([]string, error)
+
+// Sitemap calls f.
+func (f SitemapReaderFunc) Sitemap(ctx context.Context, site string, opts scraper.Options) ([]string, error) {
+	return f(ctx, site, opts)
+}
+
+func (e *Engine) sitemaps() SitemapReader {
+	if e.config.Sitemaps != nil {
+		return e.config.Sitemaps
+	}
+	return SitemapReaderFunc(scraper.Sitemap)
+}
+
+// siteResults stands in for the SERP when SearchOptions.Site is set: the
+// site's sitemap URLs whose paths match the query, best first. A site with
+// no sitemap has no results.
+func (e *Engine) siteResults(ctx context.Context, query string, opts SearchOptions, count int) ([]search.Result, error) {
+	urls, err := e.sitemaps().Sitemap(ctx, opts.Site, e.scrapeOptions(opts))
+	if errors.Is(err, scraper.ErrNoSitemap) {
+		return nil, fmt.Errorf("engine: %w for %q: %w", ErrNoResults, query, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	matched := scraper.MatchSitemap(urls, query, count)
+	results := make([]search.Result, len(matched))
+	for i, u := range matched {
+		results[i] = search.Result{URL: u}
+	}
+	return results, nil
+}
+
+// cacheQuery is what a call's result is cached under: the query, plus the
+// site for site searches, so they never share an entry with a web search.
+func cacheQuery(query string, opts SearchOptions) string {
+	if opts.Site == "" {
+		return query
+	}
+	return query + "\x00site:" + opts.Site
+}
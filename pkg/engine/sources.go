@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// titleSep separates a section's title from its URL in the header.
+const titleSep = " — "
+
+// Source describes one consolidated section.
+type Source struct {
+	Title       string // empty if the page had no usable title
+	URL         string
+	Language    string              // ISO 639-1 code, empty if undetected
+	Published   scraper.PublishDate // zero if no date could be determined
+	Author      string
+	SiteName    string
+	Description string
+	Sponsored   bool   // the page was an ad on the results page
+	Via         string // scraper.ViaGeoProxy or ViaArchive if the page was blocked or dead and read another way
+	Excerpt     string // start of the section text, cut at a word boundary
+	TextLength  int    // characters of section text
+}
+
+// excerptChars bounds Source.Excerpt.
+const excerptChars = 200
+
+// sectionSep separates consolidated sections.
+const sectionSep = "\n\n---\n\n"
+
+// Labels of the metadata lines that follow a section header.
+const (
+	authorLabel      = "Author: "
+	siteLabel        = "Site: "
+	descriptionLabel = "Description: "
+	viaLabel         = "Retrieved via: "
+	sponsoredLine    = "Sponsored: yes"
+)
+
+// metaLabels are the line prefixes escapeSectionText guards against.
+var metaLabels = []string{"Published: ", authorLabel, siteLabel, descriptionLabel, viaLabel, "Sponsored: "}
+
+var (
+	// rxLangSuffix matches the trailing language tag of a section header.
+	rxLangSuffix = regexp.MustCompile(` \(([a-z]{2,3})\)$`)
+	// rxPublishedLine matches the line formatPublished renders.
+	rxPublishedLine = regexp.MustCompile(`^Published: (\d{4}-\d{2}-\d{2})(?: \(estimated from ([a-z-]+), ([a-z]+) confidence\))?$`)
+)
+
+// sectionHeader renders "## Title — URL (lang)", omitting unknown parts.
+func sectionHeader(p scraper.ScrapedPage) string {
+	var b strings.Builder
+	b.WriteString("## ")
+	if title := strings.Join(strings.Fields(p.Title), " "); title != "" {
+		b.WriteString(title)
+		b.WriteString(titleSep)
+	}
+	b.WriteString(pageURL(p))
+	if p.Language != "" {
+		fmt.Fprintf(&b, " (%s)", p.Language)
+	}
+	return b.String()
+}
+
+// sectionMeta renders the metadata lines under a section header, one per
+// known field, or "" if nothing is known.
+func sectionMeta(p scraper.ScrapedPage, sponsored bool) string {
+	var lines []string
+	if sponsored {
+		lines = append(lines, sponsoredLine)
+	}
+	if !p.Published.IsZero() {
+		lines = append(lines, "Published: "+formatPublished(p.Published))
+	}
+	for _, f := range []struct{ label, value string }{
+		{authorLabel, p.Author},
+		{siteLabel, p.SiteName},
+		{descriptionLabel, p.Description},
+		{viaLabel, p.Via},
+	} {
+		if v := strings.Join(strings.Fields(f.value), " "); v != "" {
+			lines = append(lines, f.label+v)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeSectionText indents page-text lines that would otherwise read as a
+// section header or metadata line, keeping parseSources and countSections
+// from being fooled by markdown in scraped content. Markdown still renders
+// a heading indented by one space the same way.
+func escapeSectionText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "## ") || hasMetaLabel(line) {
+			lines[i] = " " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasMetaLabel(line string) bool {
+	for _, label := range metaLabels {
+		if strings.HasPrefix(line, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSources recovers section metadata and text from consolidated
+// content, so cached results are as self-describing as fresh ones.
+func parseSources(content string) []Source {
+	sources, _ := parseSections(content)
+	return sources
+}
+
+// parseSections is parseSources that also returns each section's full
+// text, unescaped.
+func parseSections(content string) ([]Source, []string) {
+	var sources []Source
+	var texts []string
+	var body []string
+	for _, line := range strings.Split(content, "\n") {
+		if len(sources) > 0 {
+			src := &sources[len(sources)-1]
+			if m := rxPublishedLine.FindStringSubmatch(line); m != nil {
+				if src.Published.IsZero() {
+					src.Published = parsePublished(m)
+				}
+				continue
+			}
+			if line == sponsoredLine {
+				src.Sponsored = true
+				continue
+			}
+			if setSourceMeta(src, line) {
+				continue
+			}
+		}
+		header, ok := strings.CutPrefix(line, "## ")
+		if !ok {
+			if len(sources) > 0 {
+				body = append(body, unescapeSectionLine(line))
+			}
+			continue
+		}
+		if len(sources) > 0 {
+			// Every section but the last is followed by sectionSep.
+			text := strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), strings.TrimSpace(sectionSep))
+			setSourceText(&sources[len(sources)-1], text)
+			texts = append(texts, strings.TrimSpace(text))
+			body = body[:0]
+		}
+		var src Source
+		if m := rxLangSuffix.FindStringSubmatch(header); m != nil {
+			src.Language = m[1]
+			header = strings.TrimSuffix(header, m[0])
+		}
+		if i := strings.LastIndex(header, titleSep); i >= 0 {
+			src.Title, src.URL = header[:i], header[i+len(titleSep):]
+		} else {
+			src.URL = header
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) > 0 {
+		setSourceText(&sources[len(sources)-1], strings.Join(body, "\n"))
+		texts = append(texts, strings.TrimSpace(strings.Join(body, "\n")))
+	}
+	return sources, texts
+}
+
+// unescapeSectionLine reverses escapeSectionText for one line.
+func unescapeSectionLine(line string) string {
+	if rest, ok := strings.CutPrefix(line, " "); ok && (strings.HasPrefix(rest, "## ") || hasMetaLabel(rest)) {
+		return rest
+	}
+	return line
+}
+
+// setSourceText records a section's text length and excerpt.
+func setSourceText(src *Source, text string) {
+	text = strings.TrimSpace(text)
+	src.TextLength = utf8.RuneCountInString(text)
+	src.Excerpt = excerpt(text, excerptChars)
+}
+
+// excerpt collapses whitespace in text and shortens it to at most n
+// characters, cutting at a word boundary and marking the cut with "…".
+func excerpt(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[:n-1])
+	if i := strings.LastIndex(cut, " "); i > 0 && runes[n-1] != ' ' {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// setSourceMeta fills the field named by a metadata line, reporting whether
+// line was one. The first line for a field wins.
+func setSourceMeta(src *Source, line string) bool {
+	for _, f := range []struct {
+		label string
+		field *string
+	}{
+		{authorLabel, &src.Author},
+		{siteLabel, &src.SiteName},
+		{descriptionLabel, &src.Description},
+		{viaLabel, &src.Via},
+	} {
+		if v, ok := strings.CutPrefix(line, f.label); ok {
+			if *f.field == "" {
+				*f.field = v
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// parsePublished reverses formatPublished from an rxPublishedLine match.
+func parsePublished(m []string) scraper.PublishDate {
+	t, err := time.Parse("2006-01-02", m[1])
+	if err != nil {
+		return scraper.PublishDate{}
+	}
+	if m[2] == "" {
+		return scraper.PublishDate{Time: t, Source: scraper.DateSourceMetadata, Confidence: scraper.DateConfidenceHigh}
+	}
+	return scraper.PublishDate{Time: t, Source: m[2], Confidence: m[3]}
+}
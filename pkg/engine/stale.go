@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// refreshTimeout bounds a background refresh, which has no caller deadline
+// to inherit.
+const refreshTimeout = 2 * time.Minute
+
+// refresh searches query again in the background to replace the stale
+// entry under hash, unless a refresh of it is already running. The search
+// outlives ctx, keeping its values.
+func (e *Engine) refresh(ctx context.Context, hash, query string, opts SearchOptions) {
+	if !e.claimRefresh(hash) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	go func() {
+		defer e.releaseRefresh(hash)
+		defer cancel()
+		e.research(ctx, query, opts)
+	}()
+}
+
+// claimRefresh marks hash as being refreshed, reporting false if it
+// already was.
+func (e *Engine) claimRefresh(hash string) bool {
+	e.refreshMu.Lock()
+	defer e.refreshMu.Unlock()
+	if e.refreshing[hash] {
+		return false
+	}
+	if e.refreshing == nil {
+		e.refreshing = make(map[string]bool)
+	}
+	e.refreshing[hash] = true
+	return true
+}
+
+// releaseRefresh undoes claimRefresh.
+func (e *Engine) releaseRefresh(hash string) {
+	e.refreshMu.Lock()
+	delete(e.refreshing, hash)
+	e.refreshMu.Unlock()
+}
+
+// research runs a forced search that no caller is waiting on, storing its
+// result. It is audited and emits events like any other search.
+func (e *Engine) research(ctx context.Context, query string, opts SearchOptions) error {
+	opts.Force = true
+	run := e.startRun(query, opts)
+	result, err := e.search(ctx, query, opts, run)
+	if aerr := e.audit(ctx, run, opts, result, err); aerr != nil {
+		err = aerr
+	}
+	run.finish(result, err)
+	return err
+}
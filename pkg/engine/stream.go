@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// StreamKind names a SearchStream update.
+type StreamKind string
+
+// Updates passed to a SearchStream callback, in pipeline order.
+const (
+	StreamResults StreamKind = "results" // Results is set: the links about to be scraped, in rank order
+	StreamPage    StreamKind = "page"    // Page is set: one page, as soon as it is scraped
+)
+
+// StreamUpdate is one step of a streamed search.
+type StreamUpdate struct {
+	Kind    StreamKind
+	Results []search.Result // StreamResults only
+	Page    *StreamedPage   // StreamPage only
+}
+
+// StreamedPage is a scraped page as it arrives.
+type StreamedPage struct {
+	PageInfo
+	Title string
+
+	// Content is the page's section as it would appear in
+	// SearchResult.Content, or empty if the page failed or has no usable
+	// text. Duplicates, the section cap and the content size cap are only
+	// applied to the final result.
+	Content string
+}
+
+// SearchStream runs SearchWithOptions, calling fn as the search makes
+// progress: once with the links from the results page (or sitemap), then
+// once per page as each scrape completes, in completion order, including
+// pages reached by link following. Pages stopped by a guardrail or found
+// in the page cache come first. fn is called on one goroutine at a time
+// and should return quickly, since scrapes wait on it. Cache hits make no
+// calls. The returned result is the full consolidated one, as cached.
+func (e *Engine) SearchStream(ctx context.Context, query string, opts SearchOptions, fn func(StreamUpdate)) (SearchResult, error) {
+	return e.searchWithOptions(ctx, query, opts, fn)
+}
+
+// streamResults passes the links to be scraped to the run's stream and
+// notes which are sponsored, so their pages are marked as in Content.
+func (r *eventRun) streamResults(results []search.Result, sponsored map[string]bool) {
+	if r.stream == nil {
+		return
+	}
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+	r.sponsored = sponsored
+	r.stream(StreamUpdate{Kind: StreamResults, Results: append([]search.Result(nil), results...)})
+}
+
+// streamPage passes one scraped page to the run's stream.
+func (r *eventRun) streamPage(p scraper.ScrapedPage, info PageInfo) {
+	if r.stream == nil {
+		return
+	}
+	r.streamMu.Lock()
+	defer r.streamMu.Unlock()
+	sp := &StreamedPage{PageInfo: info, Title: strings.Join(strings.Fields(p.Title), " ")}
+	sp.Content, _ = consolidate([]scraper.ScrapedPage{p}, r.sponsored)
+	r.stream(StreamUpdate{Kind: StreamPage, Page: sp})
+}
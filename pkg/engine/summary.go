@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SummarizerFunc adapts a function to Summarizer.
+type SummarizerFunc func(ctx context.Context, query, content string) (string, error)
+
+// Summarize calls f.
+func (f SummarizerFunc) Summarize(ctx context.Context, query, content string) (string, error) {
+	return f(ctx, query, content)
+}
+
+// DefaultSummarySentences is the number of sentences ExtractiveSummarizer
+// keeps when Sentences is 0.
+const DefaultSummarySentences = 8
+
+// ErrNothingToSummarize means the content had no sentences to pick from.
+var ErrNothingToSummarize = errors.New("no sentences to summarize")
+
+// ExtractiveSummarizer is a Summarizer needing no model or network. It
+// keeps the sentences that share the most words with the query, preferring
+// ones whose words recur across sources and the lead sentence of each
+// page, and lists them in their original order, each followed by its
+// source URL. It suits deployments without a language model.
+type ExtractiveSummarizer struct {
+	Sentences int // sentences kept; 0 uses DefaultSummarySentences
+}
+
+// rxSentenceEnd matches the end of a sentence and the space after it.
+var rxSentenceEnd = regexp.MustCompile(`[.!?]["')\]]*\s+`)
+
+// minSentenceWords skips headings, captions and other fragments.
+const minSentenceWords = 4
+
+type summarySentence struct {
+	text  string
+	url   string
+	words []string
+	lead  bool
+	score float64
+}
+
+// Summarize picks the sentences of content that best answer query.
+func (s ExtractiveSummarizer) Summarize(ctx context.Context, query, content string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	n := s.Sentences
+	if n <= 0 {
+		n = DefaultSummarySentences
+	}
+
+	sources, texts := parseSections(content)
+	var sentences []summarySentence
+	spread := make(map[string]int) // sections each word appears in
+	for i, text := range texts {
+		seen := make(map[string]bool)
+		lead := true
+		for _, para := range strings.Split(text, "\n") {
+			for _, sent := range splitSentences(para) {
+				words := summaryWords(sent)
+				if len(words) < minSentenceWords {
+					continue
+				}
+				sentences = append(sentences, summarySentence{text: sent, url: sources[i].URL, words: words, lead: lead})
+				lead = false
+				for _, w := range words {
+					if !seen[w] {
+						seen[w] = true
+						spread[w]++
+					}
+				}
+			}
+		}
+	}
+	if len(sentences) == 0 {
+		return "", fmt.Errorf("engine: summarize: %w", ErrNothingToSummarize)
+	}
+
+	terms := make(map[string]bool)
+	for _, w := range summaryWords(query) {
+		terms[w] = true
+	}
+	for i := range sentences {
+		st := &sentences[i]
+		matched := make(map[string]bool)
+		common := 0
+		for _, w := range st.words {
+			if terms[w] {
+				matched[w] = true
+			}
+			if spread[w] > 1 {
+				common++
+			}
+		}
+		st.score = float64(len(matched)) + float64(common)/float64(len(st.words))
+		if st.lead {
+			st.score += 0.25
+		}
+	}
+
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sentences[order[a]].score > sentences[order[b]].score })
+	if len(order) > n {
+		order = order[:n]
+	}
+	sort.Ints(order)
+
+	var b strings.Builder
+	for _, i := range order {
+		st := sentences[i]
+		fmt.Fprintf(&b, "- %s (%s)\n", st.text, st.url)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// splitSentences splits a paragraph after each sentence-ending mark.
+func splitSentences(para string) []string {
+	var out []string
+	start := 0
+	for _, loc := range rxSentenceEnd.FindAllStringIndex(para, -1) {
+		if sent := strings.TrimSpace(para[start:loc[1]]); sent != "" {
+			out = append(out, sent)
+		}
+		start = loc[1]
+	}
+	if sent := strings.TrimSpace(para[start:]); sent != "" {
+		out = append(out, sent)
+	}
+	return out
+}
+
+// summaryWords returns the content words of text, lowercased and
+// singularized as TokenSet does, in order and with repeats.
+func summaryWords(text string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[w] {
+			words = append(words, singular(w))
+		}
+	}
+	return words
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// SectionData is what Config.SectionTemplate is executed with, once per
+// consolidated section.
+type SectionData struct {
+	Query       string // the search's query; empty for Fetch
+	N           int    // the section's 1-based index, as in [Sn] source markers
+	Count       int    // sections in the result
+	Title       string // empty if the page had no usable title
+	URL         string
+	Language    string // ISO 639-1 code, empty if undetected
+	Published   string // YYYY-MM-DD, empty if no date could be determined
+	Author      string
+	SiteName    string
+	Description string
+	Sponsored   bool
+	Via         string // scraper.ViaGeoProxy or ViaArchive if the page was read another way
+	Text        string // the section's page text, with source markers if Cite is set
+}
+
+// templateContent renders consolidated content with tmpl: the instant
+// answer, if any, as plain text and a blank line, then tmpl's output for
+// each section, joined as is, so tmpl draws its own separators.
+func templateContent(tmpl *template.Template, query, content string) (string, error) {
+	sources, texts := parseSections(content)
+	var b strings.Builder
+	if answer := preamble(content); answer != "" {
+		b.WriteString(answer)
+		b.WriteString("\n\n")
+	}
+	for i, src := range sources {
+		d := SectionData{
+			Query:       query,
+			N:           i + 1,
+			Count:       len(sources),
+			Title:       src.Title,
+			URL:         src.URL,
+			Language:    src.Language,
+			Author:      src.Author,
+			SiteName:    src.SiteName,
+			Description: src.Description,
+			Sponsored:   src.Sponsored,
+			Via:         src.Via,
+			Text:        texts[i],
+		}
+		if !src.Published.IsZero() {
+			d.Published = src.Published.Time.Format("2006-01-02")
+		}
+		if err := tmpl.Execute(&b, d); err != nil {
+			return "", fmt.Errorf("engine: section template: %w", err)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/glsi/pkg/scraper"
+	"github.com/user/glsi/pkg/search"
+)
+
+// spares is how many results past count a search asks for, to scrape in
+// place of pages too thin to keep: as many again with Config.MinPageChars,
+// else none.
+func (e *Engine) spares(count int) int {
+	if e.config.MinPageChars <= 0 {
+		return 0
+	}
+	return count
+}
+
+// thin reports whether p was read but has less text than
+// Config.MinPageChars, as link farms and cookie walls do, and so is left
+// out of the consolidation.
+func (e *Engine) thin(p scraper.ScrapedPage) bool {
+	n := e.config.MinPageChars
+	return n > 0 && usable(p) && utf8.RuneCountInString(strings.TrimSpace(p.Content)) < n
+}
+
+// thinPages returns the indexes of the thin pages.
+func (e *Engine) thinPages(pages []scraper.ScrapedPage) map[int]bool {
+	skipped := make(map[int]bool)
+	for i, p := range pages {
+		if e.thin(p) {
+			skipped[i] = true
+		}
+	}
+	return skipped
+}
+
+// replaceThin scrapes spare results in place of thin pages, one for each,
+// appending their pages. Spares that come back thin are replaced in turn,
+// until the spares or the page budget run out.
+func (e *Engine) replaceThin(ctx context.Context, pages []scraper.ScrapedPage, spares []search.Result, opts SearchOptions, run *eventRun) ([]scraper.ScrapedPage, error) {
+	need := len(e.thinPages(pages))
+	for need > 0 && len(spares) > 0 && ctx.Err() == nil {
+		allowed, err := e.reservePages(ctx, min(need, len(spares)))
+		if errors.Is(err, ErrBudgetExhausted) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		urls := make([]string, allowed)
+		for i, r := range spares[:allowed] {
+			urls[i] = r.URL
+		}
+		spares = spares[allowed:]
+		more := e.scrape(ctx, urls, opts, run)
+		pages = append(pages, more...)
+		need = len(e.thinPages(more))
+	}
+	return pages, nil
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// Top queries report limits for TopQueries.
+const (
+	DefaultTopQueries = 20
+	MaxTopQueries     = 200
+)
+
+// ErrNoTopQueries means the engine's Store does not implement
+// PopularityLister.
+var ErrNoTopQueries = errors.New("store does not count reads")
+
+// TopQueries reports the most read cached queries, with their lifetime and
+// since-stored read counts and when they were last read, most read first.
+// limit 0 uses DefaultTopQueries and larger values are capped to
+// MaxTopQueries. With a KeyScope, only this scope's entries are listed.
+func (e *Engine) TopQueries(ctx context.Context, limit int) ([]cache.Entry, error) {
+	pl, ok := e.cache.(PopularityLister)
+	if !ok {
+		return nil, fmt.Errorf("engine: %w", ErrNoTopQueries)
+	}
+	if limit <= 0 {
+		limit = DefaultTopQueries
+	}
+	limit = min(limit, MaxTopQueries)
+	entries, err := pl.Top(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("engine: %w", err)
+	}
+	if e.config.KeyScope == "" {
+		return entries, nil
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if e.key(entry.Query) == entry.QueryHash {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
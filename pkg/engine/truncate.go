@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/glsi/pkg/scraper"
+)
+
+// truncatedMarker ends page text shortened to fit Config.MaxContentBytes.
+const truncatedMarker = "[truncated]"
+
+// fitContent consolidates pages into at most limit bytes. When the full
+// consolidation is larger, every page keeps the same share of its text,
+// cut back to a paragraph, sentence or word boundary and marked
+// truncatedMarker, so a long page cannot crowd out the others; headers and
+// metadata are kept whole. Only if the headers alone overflow is the text
+// cut hard at limit. It returns the content, the number of sections and
+// the URLs of the pages that were shortened. limit <= 0 means no cap.
+func fitContent(pages []scraper.ScrapedPage, sponsored map[string]bool, limit int) (string, int, map[string]bool) {
+	content, count := consolidate(pages, sponsored)
+	if limit <= 0 || len(content) <= limit {
+		return content, count, nil
+	}
+
+	total := 0
+	for _, p := range pages {
+		if usable(p) {
+			total += len(strings.TrimSpace(p.Content))
+		}
+	}
+	share := float64(total-(len(content)-limit)) / float64(total)
+	var truncated map[string]bool
+	// Markers and escaping add a little back, so shrink the share by the
+	// overshoot until the result fits. Each round strictly lowers it.
+	for range 8 {
+		if share < 0 {
+			share = 0
+		}
+		fitted := make([]scraper.ScrapedPage, len(pages))
+		truncated = make(map[string]bool)
+		for i, p := range pages {
+			fitted[i] = p
+			if !usable(p) {
+				continue
+			}
+			text := strings.TrimSpace(p.Content)
+			keep := int(float64(len(text)) * share)
+			if keep >= len(text) {
+				continue
+			}
+			fitted[i].Content = trimText(text, keep)
+			truncated[p.URL] = true
+		}
+		content, count = consolidate(fitted, sponsored)
+		if len(content) <= limit || share == 0 {
+			break
+		}
+		share -= float64(len(content)-limit) / float64(total)
+	}
+	if len(content) > limit {
+		content = cutRunes(content, limit)
+	}
+	return content, count, truncated
+}
+
+// trimText shortens text to about n bytes, ending at the last paragraph
+// break, sentence end or space in the second half of the allowance, and
+// appends truncatedMarker. The result is never empty, so the page keeps
+// its section.
+func trimText(text string, n int) string {
+	cut := cutRunes(text, n)
+	for _, sep := range []string{"\n\n", ". ", " "} {
+		if i := strings.LastIndex(cut, sep); i >= n/2 && i > 0 {
+			cut = cut[:i+len(sep)]
+			break
+		}
+	}
+	cut = strings.TrimSpace(cut)
+	if cut == "" {
+		return truncatedMarker
+	}
+	return cut + "\n\n" + truncatedMarker
+}
+
+// cutRunes returns the longest prefix of s of at most n bytes that does
+// not split a UTF-8 sequence.
+func cutRunes(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
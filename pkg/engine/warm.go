@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WarmResult counts the outcome of a Warm pass.
+type WarmResult struct {
+	Searched int // queries searched and cached
+	Cached   int // queries already cached, left as they were
+	Failed   int // queries whose search failed
+}
+
+// Warm searches each query in turn so its result is cached before anyone
+// asks for it, for example when a server starts with a known workload.
+// Queries already cached are not searched again. The searches are ordinary
+// ones: they wait on the rate limiter and count against budgets. A failed
+// query is counted and skipped, but an exhausted budget or a done ctx ends
+// the pass early, returning the counts so far with the error.
+func (e *Engine) Warm(ctx context.Context, queries []string) (WarmResult, error) {
+	var wr WarmResult
+	for _, q := range queries {
+		if err := ctx.Err(); err != nil {
+			return wr, fmt.Errorf("engine: warm: %w", err)
+		}
+		result, err := e.SearchWithOptions(ctx, q, SearchOptions{})
+		switch {
+		case errors.Is(err, ErrBudgetExhausted):
+			return wr, fmt.Errorf("engine: warm %q: %w", q, err)
+		case err != nil:
+			wr.Failed++
+		case result.FromCache:
+			wr.Cached++
+		default:
+			wr.Searched++
+		}
+	}
+	return wr, nil
+}
+
+// ReadQueries reads one query per line from r for Warm, trimming
+// whitespace and skipping blank lines and lines starting with #.
+func ReadQueries(r io.Reader) ([]string, error) {
+	var queries []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("engine: read queries: %w", err)
+	}
+	return queries, nil
+}
@@ -0,0 +1,201 @@
+// Package metrics keeps counters and histograms in memory and writes them
+// in the Prometheus text exposition format. A Registry satisfies
+// engine.Metrics and engine.MetricsReader.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Registry holds metric series. The zero value is not usable; call New.
+type Registry struct {
+	prefix string
+
+	mu       sync.Mutex
+	counters map[string]*counter // keyed by seriesKey
+	hists    map[string]*histogram
+}
+
+type counter struct {
+	name   string
+	labels []string
+	value  float64
+}
+
+type histogram struct {
+	name    string
+	labels  []string
+	buckets []uint64 // per DefaultBuckets bound, not cumulative
+	count   uint64
+	sum     float64
+}
+
+// New returns an empty Registry whose metric names are exported with
+// prefix and an underscore, such as "glsi_searches_total". An empty
+// prefix exports the names as recorded.
+func New(prefix string) *Registry {
+	return &Registry{prefix: prefix, counters: make(map[string]*counter), hists: make(map[string]*histogram)}
+}
+
+// seriesKey identifies a series by its name and label pairs, in order.
+func seriesKey(name string, labels []string) string {
+	return name + "\x00" + strings.Join(labels, "\x00")
+}
+
+// pairs drops a trailing label name without a value.
+func pairs(labels []string) []string {
+	return append([]string(nil), labels[:len(labels)&^1]...)
+}
+
+// Inc adds one to the counter name with the given label name, value pairs.
+func (r *Registry) Inc(name string, labels ...string) {
+	labels = pairs(labels)
+	key := seriesKey(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[key]
+	if !ok {
+		c = &counter{name: name, labels: labels}
+		r.counters[key] = c
+	}
+	c.value++
+}
+
+// Observe records value, in seconds, in the histogram name with the given
+// label name, value pairs.
+func (r *Registry) Observe(name string, value float64, labels ...string) {
+	labels = pairs(labels)
+	key := seriesKey(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.hists[key]
+	if !ok {
+		h = &histogram{name: name, labels: labels, buckets: make([]uint64, len(DefaultBuckets))}
+		r.hists[key] = h
+	}
+	if i := sort.SearchFloat64s(DefaultBuckets, value); i < len(DefaultBuckets) {
+		h.buckets[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// Counter returns the value of a counter, 0 if it was never incremented.
+// Labels must be given in the order they were recorded.
+func (r *Registry) Counter(name string, labels ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[seriesKey(name, pairs(labels))]; ok {
+		return c.value
+	}
+	return 0
+}
+
+// Histogram returns the number and sum of a histogram's observations.
+// Labels must be given in the order they were recorded.
+func (r *Registry) Histogram(name string, labels ...string) (count uint64, sum float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.hists[seriesKey(name, pairs(labels))]; ok {
+		return h.count, h.sum
+	}
+	return 0, 0
+}
+
+// WritePrometheus writes every series in the Prometheus text format,
+// grouped by metric and sorted by name and labels.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	counters := make([]counter, 0, len(r.counters))
+	for _, c := range r.counters {
+		counters = append(counters, *c)
+	}
+	hists := make([]histogram, 0, len(r.hists))
+	for _, h := range r.hists {
+		hc := *h
+		hc.buckets = append([]uint64(nil), h.buckets...)
+		hists = append(hists, hc)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(counters, func(i, j int) bool {
+		return seriesKey(counters[i].name, counters[i].labels) < seriesKey(counters[j].name, counters[j].labels)
+	})
+	sort.Slice(hists, func(i, j int) bool {
+		return seriesKey(hists[i].name, hists[i].labels) < seriesKey(hists[j].name, hists[j].labels)
+	})
+
+	bw := bufio.NewWriter(w)
+	last := ""
+	for _, c := range counters {
+		name := r.name(c.name)
+		if name != last {
+			fmt.Fprintf(bw, "# TYPE %s counter\n", name)
+			last = name
+		}
+		fmt.Fprintf(bw, "%s%s %s\n", name, formatLabels(c.labels), formatValue(c.value))
+	}
+	for _, h := range hists {
+		name := r.name(h.name)
+		if name != last {
+			fmt.Fprintf(bw, "# TYPE %s histogram\n", name)
+			last = name
+		}
+		labels := h.labels[:len(h.labels):len(h.labels)] // append below copies
+		var cumulative uint64
+		for i, bound := range DefaultBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", name, formatLabels(append(labels, "le", formatValue(bound))), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket%s %d\n", name, formatLabels(append(labels, "le", "+Inf")), h.count)
+		fmt.Fprintf(bw, "%s_sum%s %s\n", name, formatLabels(h.labels), formatValue(h.sum))
+		fmt.Fprintf(bw, "%s_count%s %d\n", name, formatLabels(h.labels), h.count)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) name(name string) string {
+	if r.prefix == "" {
+		return name
+	}
+	return r.prefix + "_" + name
+}
+
+// formatLabels renders label pairs as {name="value",...}, or nothing.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
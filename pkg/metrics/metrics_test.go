@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := New("glsi")
+	r.Inc("searches_total", "kind", "search", "outcome", "hit")
+	r.Inc("searches_total", "kind", "search", "outcome", "hit")
+	r.Inc("searches_total", "kind", "search", "outcome", "fresh")
+	r.Inc("odd_total", "label") // a name without a value is dropped
+	r.Observe("stage_duration_seconds", 0.2, "stage", "scrape")
+	r.Observe("stage_duration_seconds", 3, "stage", "scrape")
+	r.Observe("stage_duration_seconds", 60, "stage", "scrape")
+
+	if got := r.Counter("searches_total", "kind", "search", "outcome", "hit"); got != 2 {
+		t.Errorf("Counter = %v, want 2", got)
+	}
+	if got := r.Counter("searches_total", "outcome", "hit", "kind", "search"); got != 0 {
+		t.Errorf("Counter with labels reordered = %v, want 0", got)
+	}
+	if count, sum := r.Histogram("stage_duration_seconds", "stage", "scrape"); count != 3 || sum != 63.2 {
+		t.Errorf("Histogram = %d, %v; want 3, 63.2", count, sum)
+	}
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE glsi_odd_total counter\nglsi_odd_total 1\n",
+		"# TYPE glsi_searches_total counter\n" +
+			`glsi_searches_total{kind="search",outcome="fresh"} 1` + "\n" +
+			`glsi_searches_total{kind="search",outcome="hit"} 2` + "\n",
+		"# TYPE glsi_stage_duration_seconds histogram\n",
+		`glsi_stage_duration_seconds_bucket{stage="scrape",le="0.1"} 0` + "\n",
+		`glsi_stage_duration_seconds_bucket{stage="scrape",le="0.25"} 1` + "\n",
+		`glsi_stage_duration_seconds_bucket{stage="scrape",le="5"} 2` + "\n",
+		`glsi_stage_duration_seconds_bucket{stage="scrape",le="30"} 2` + "\n",
+		`glsi_stage_duration_seconds_bucket{stage="scrape",le="+Inf"} 3` + "\n",
+		`glsi_stage_duration_seconds_sum{stage="scrape"} 63.2` + "\n",
+		`glsi_stage_duration_seconds_count{stage="scrape"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatLabelsEscapes(t *testing.T) {
+	if got, want := formatLabels([]string{"q", "a \"b\"\\\nc"}), `{q="a \"b\"\\\nc"}`; got != want {
+		t.Errorf("formatLabels = %s, want %s", got, want)
+	}
+}
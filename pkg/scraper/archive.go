@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// Wayback Machine endpoints; tests point them at a local server.
+var (
+	waybackAvailable = "https://archive.org/wayback/available"
+	waybackWeb       = "https://web.archive.org/web/"
+)
+
+// latestSnapshot returns the URL of the Wayback Machine's most recent
+// capture of rawURL, in the id_ form that serves the page as archived,
+// without the Wayback toolbar. It returns "" if there is none.
+func latestSnapshot(ctx context.Context, rawURL string, opts Options) (string, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	release, err := acquireHost(ctx, waybackAvailable, hostDelay(opts))
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	lookup := waybackAvailable + "?url=" + url.QueryEscape(rawURL)
+	req, err := http.NewRequestWithContext(withProxy(ctx, opts, lookup), http.MethodGet, lookup, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("wayback lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{Code: resp.StatusCode, URL: req.URL.String()}
+	}
+	var avail struct {
+		Snapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				Status    string `json:"status"`
+				Timestamp string `json:"timestamp"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&avail); err != nil {
+		return "", fmt.Errorf("wayback lookup: %w", err)
+	}
+	c := avail.Snapshots.Closest
+	if !c.Available || c.Status != "200" || c.Timestamp == "" {
+		return "", nil
+	}
+	return waybackWeb + c.Timestamp + "id_/" + rawURL, nil
+}
+
+// deadLink reports whether err means the page is gone rather than briefly
+// unavailable: a 404 or 410, a refused connection, or a host that no longer
+// resolves.
+func deadLink(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.Code == http.StatusNotFound || se.Code == http.StatusGone
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// fetchArchived refetches a blocked or dead page from its latest Wayback
+// Machine snapshot. It returns the snapshot's content with ArchiveURL set,
+// or page unchanged when there is no usable snapshot.
+func fetchArchived(ctx context.Context, page ScrapedPage, opts Options) ScrapedPage {
+	snapshot, err := latestSnapshot(ctx, page.URL, opts)
+	if err != nil || snapshot == "" {
+		logger(opts).DebugContext(ctx, "no archived copy", "url", page.URL, "err", err)
+		return page
+	}
+	release, err := acquireHost(ctx, snapshot, hostDelay(opts))
+	if err != nil {
+		return page
+	}
+	defer release()
+
+	archived := fetchTarget(ctx, page.URL, snapshot, githubTarget{}, opts)
+	if archived.Err != nil || archived.Blocked {
+		logger(opts).DebugContext(ctx, "archived copy unusable", "url", page.URL, "snapshot", snapshot, "err", archived.Err)
+		return page // archived behind the same wall
+	}
+	logger(opts).DebugContext(ctx, "read archived copy", "url", page.URL, "snapshot", snapshot)
+	archived.Blocked, archived.BlockReason = page.Blocked, page.BlockReason
+	archived.ArchiveURL, archived.Via = snapshot, ViaArchive
+	archived.FinalURL = page.FinalURL
+	archived.Attempts = page.Attempts + 1
+	return archived
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reasons reported in ScrapedPage.BlockReason.
+const (
+	BlockPaywall = "paywall" // subscription or metered paywall
+	BlockConsent = "consent" // cookie or privacy consent wall
+	BlockGeo     = "geo"     // HTTP 451 or a "not available in your region" page
+)
+
+// Retrieval paths reported in ScrapedPage.Via for blocked or dead pages
+// whose content was still obtained.
+const (
+	ViaGeoProxy = "geo-proxy" // refetched through Options.GeoProxy
+	ViaArchive  = "archive"   // read from a Wayback Machine snapshot
+)
+
+// maxBlockedTextChars is the most extracted text a walled page yields.
+// Overlay scripts ship on every page of many sites, so markers only count
+// when the extraction came back this short.
+const maxBlockedTextChars = 1500
+
+// wallScanBytes bounds how much of the HTML is searched for markers.
+const wallScanBytes = 512 << 10
+
+// rxNotFree matches schema.org's paywall declaration in JSON-LD.
+var rxNotFree = regexp.MustCompile(`"isaccessibleforfree"\s*:\s*"?false`)
+
+// paywallMarkers, geoMarkers and consentMarkers are lowercase substrings of
+// the HTML (class names, script hosts, overlay copy) left by common paywall
+// and consent-management platforms and by regional block pages.
+var (
+	paywallMarkers = []string{
+		"paywall", "tinypass.com", "piano.io", "tp-modal", "meteredcontent",
+		"subscriber-only", "subscribers only", "subscribe to continue reading",
+		"subscribe to read", "already a subscriber", "to continue reading this article",
+	}
+	geoMarkers = []string{
+		"not available in your country", "not available in your region",
+		"unavailable in your country", "unavailable in your region",
+		"not available in your location", "not available in your area",
+		"content is not available in your", "unable to provide this content in your",
+		"geo-restricted", "geoblocked", "geo-blocked",
+	}
+	consentMarkers = []string{
+		"onetrust", "cookiebot", "didomi", "qc-cmp2", "sp_message_container",
+		"fc-consent-root", "usercentrics", "trustarc", "consent.google.com",
+		"we value your privacy", "before you continue to", "accept all cookies",
+	}
+)
+
+// detectWall reports why a page's content looks hidden behind a paywall,
+// consent overlay or regional block, or "" if it does not. finalURL is
+// where redirects ended, since some sites bounce every visitor to a consent
+// host first.
+func detectWall(html []byte, text, finalURL string) string {
+	if u, err := url.Parse(finalURL); err == nil && strings.HasPrefix(u.Hostname(), "consent.") {
+		return BlockConsent
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(text)) > maxBlockedTextChars {
+		return ""
+	}
+	if len(html) > wallScanBytes {
+		html = html[:wallScanBytes]
+	}
+	lower := bytes.ToLower(html)
+	if containsAny(lower, geoMarkers) {
+		return BlockGeo
+	}
+	if rxNotFree.Match(lower) || containsAny(lower, paywallMarkers) {
+		return BlockPaywall
+	}
+	if containsAny(lower, consentMarkers) {
+		return BlockConsent
+	}
+	return ""
+}
+
+// setBlocked marks page if its HTML shows a paywall, consent wall or
+// regional block.
+func setBlocked(page *ScrapedPage, html []byte, finalURL string) {
+	page.BlockReason = detectWall(html, page.Content, finalURL)
+	page.Blocked = page.BlockReason != ""
+}
+
+func containsAny(b []byte, markers []string) bool {
+	for _, m := range markers {
+		if bytes.Contains(b, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
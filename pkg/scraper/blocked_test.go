@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestDetectWall(t *testing.T) {
+	long := strings.Repeat("A full article paragraph with plenty of words. ", 60)
+	tests := []struct {
+		name     string
+		html     string
+		text     string
+		finalURL string
+		want     string
+	}{
+		{"json-ld paywall", `<script type="application/ld+json">{"isAccessibleForFree": "False"}</script>`, "Teaser.", "", BlockPaywall},
+		{"paywall overlay", `<div class="tp-modal">Subscribe to continue reading</div>`, "Teaser.", "", BlockPaywall},
+		{"consent overlay", `<div id="onetrust-banner-sdk">We value your privacy</div>`, "We value your privacy", "", BlockConsent},
+		{"consent redirect", `<html></html>`, long, "https://consent.yahoo.com/v2/collectConsent", BlockConsent},
+		{"regional block", `<div class="notice">This content is not available in your country.</div>`, "This content is not available in your country.", "", BlockGeo},
+		{"long text with banner script", `<script src="https://cdn.cookielaw.org/onetrust.js"></script>`, long, "", ""},
+		{"short clean page", `<p>Short note.</p>`, "Short note.", "", ""},
+	}
+	for _, tt := range tests {
+		if got := detectWall([]byte(tt.html), tt.text, tt.finalURL); got != tt.want {
+			t.Errorf("%s: detectWall = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveFallback(t *testing.T) {
+	const walled = `<!DOCTYPE html><html><head><title>Story</title></head><body>
+<div class="paywall"><p>Subscribe to continue reading.</p></div></body></html>`
+	archived := fakeArticlePage("Story", "The archived copy carries the full story, which runs on for several sentences about the subject at hand.")
+
+	var srvURL string
+	srvURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.URL.Path == "/story":
+			w.Write([]byte(walled))
+		case r.URL.Path == "/available":
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Query().Get("url") != srvURL+"/story" {
+				w.Write([]byte(`{"archived_snapshots":{}}`))
+				return
+			}
+			w.Write([]byte(`{"archived_snapshots":{"closest":{"available":true,"status":"200","timestamp":"20240102030405"}}}`))
+		case r.URL.Path == "/web/20240102030405id_/"+srvURL+"/story":
+			w.Write([]byte(archived))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer cleanup()
+	origAvail, origWeb := waybackAvailable, waybackWeb
+	waybackAvailable, waybackWeb = srvURL+"/available", srvURL+"/web/"
+	defer func() { waybackAvailable, waybackWeb = origAvail, origWeb }()
+
+	opts := Options{HostDelay: -1, Retries: -1}
+	page := ScrapeWithOptions(context.Background(), []string{srvURL + "/story"}, opts)[0]
+	if page.Err != nil || !page.Blocked || page.BlockReason != BlockPaywall || page.ArchiveURL != "" {
+		t.Fatalf("without fallback: page = %+v, want a blocked paywall page", page)
+	}
+
+	opts.ArchiveFallback = true
+	page = ScrapeWithOptions(context.Background(), []string{srvURL + "/story"}, opts)[0]
+	if page.Err != nil || !strings.Contains(page.Content, "full story") {
+		t.Fatalf("with fallback: page = %+v, want the archived content", page)
+	}
+	if page.URL != srvURL+"/story" || page.ArchiveURL != srvURL+"/web/20240102030405id_/"+srvURL+"/story" || page.BlockReason != BlockPaywall || page.Via != ViaArchive {
+		t.Errorf("with fallback: URL = %q, ArchiveURL = %q, BlockReason = %q, Via = %q", page.URL, page.ArchiveURL, page.BlockReason, page.Via)
+	}
+}
+
+func TestArchiveDeadLinks(t *testing.T) {
+	archived := fakeArticlePage("Old post", "The archived copy of the deleted post still reads in full, with several sentences about the subject.")
+
+	var srvURL string
+	srvURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			http.Error(w, "gone", http.StatusGone)
+		case "/flaky":
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case "/available":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"archived_snapshots":{"closest":{"available":true,"status":"200","timestamp":"20240102030405"}}}`))
+		default:
+			if strings.HasPrefix(r.URL.Path, "/web/20240102030405id_/") {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(archived))
+				return
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer cleanup()
+	origAvail, origWeb := waybackAvailable, waybackWeb
+	waybackAvailable, waybackWeb = srvURL+"/available", srvURL+"/web/"
+	defer func() { waybackAvailable, waybackWeb = origAvail, origWeb }()
+
+	opts := Options{HostDelay: -1, Retries: -1}
+	if page := ScrapeWithOptions(context.Background(), []string{srvURL + "/gone"}, opts)[0]; page.Err == nil {
+		t.Fatalf("without fallback: page = %+v, want an error", page)
+	}
+
+	opts.ArchiveDeadLinks = true
+	page := ScrapeWithOptions(context.Background(), []string{srvURL + "/gone"}, opts)[0]
+	if page.Err != nil || !strings.Contains(page.Content, "deleted post") {
+		t.Fatalf("410 with fallback: page = %+v, want the archived content", page)
+	}
+	if page.Via != ViaArchive || page.ArchiveURL != srvURL+"/web/20240102030405id_/"+srvURL+"/gone" || page.Blocked {
+		t.Errorf("410 with fallback: Via = %q, ArchiveURL = %q, Blocked = %v", page.Via, page.ArchiveURL, page.Blocked)
+	}
+
+	// Temporary failures are not dead links.
+	if page := ScrapeWithOptions(context.Background(), []string{srvURL + "/flaky"}, opts)[0]; page.Err == nil || page.ArchiveURL != "" {
+		t.Errorf("503 with fallback: page = %+v, want the error", page)
+	}
+}
+
+func TestDeadLink(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&statusError{Code: http.StatusNotFound}, true},
+		{&statusError{Code: http.StatusGone}, true},
+		{&statusError{Code: http.StatusServiceUnavailable}, false},
+		{fmt.Errorf("fetch: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{&net.DNSError{Err: "no such host", Name: "gone.example", IsNotFound: true}, true},
+		{&net.DNSError{Err: "timeout", Name: "slow.example", IsTimeout: true}, false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := deadLink(tt.err); got != tt.want {
+			t.Errorf("deadLink(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
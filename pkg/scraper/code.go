@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// codeBlock renders a <pre> as a fenced block tagged with its language, if
+// codeLanguage finds one.
+func codeBlock(n *html.Node) string {
+	code := strings.Trim(textOf(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	fence := "```"
+	if strings.Contains(code, "```") {
+		fence = "~~~"
+	}
+	return fence + codeLanguage(n) + "\n" + code + "\n" + fence
+}
+
+// codeLanguage returns the language hinted by a <pre>, its <code> child or
+// the wrappers highlighters put around it (Sphinx, GitHub), or "" if none
+// is.
+func codeLanguage(pre *html.Node) string {
+	candidates := []*html.Node{pre}
+	if c := pre.FirstChild; c != nil && c.Type == html.ElementNode && c.DataAtom == atom.Code {
+		candidates = append(candidates, c)
+	}
+	for p, i := pre.Parent, 0; p != nil && p.Type == html.ElementNode && i < 2; p, i = p.Parent, i+1 {
+		candidates = append(candidates, p)
+	}
+	for _, n := range candidates {
+		if lang := languageHint(n); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// codeClassPrefixes are class prefixes naming a code block's language, as
+// used by Prism and highlight.js ("language-"), Stack Overflow and
+// prettify ("lang-"), GitHub ("highlight-source-") and Sphinx
+// ("highlight-").
+var codeClassPrefixes = []string{"language-", "lang-", "highlight-source-", "highlight-"}
+
+// languageHint reads a language from n's data-lang attribute, its classes
+// or a SyntaxHighlighter "brush: x" class. Hints meaning "no language" are
+// ignored.
+func languageHint(n *html.Node) string {
+	lang := attr(n, "data-lang")
+	if lang == "" {
+		classes := strings.Fields(attr(n, "class"))
+		for i, class := range classes {
+			if class == "brush:" && i+1 < len(classes) {
+				lang = strings.TrimSuffix(classes[i+1], ";")
+				break
+			}
+			for _, prefix := range codeClassPrefixes {
+				if l, ok := strings.CutPrefix(class, prefix); ok && l != "" {
+					lang = l
+					break
+				}
+			}
+			if lang != "" {
+				break
+			}
+		}
+	}
+	switch lang = strings.ToLower(strings.TrimSpace(lang)); lang {
+	case "none", "default", "text", "plaintext", "nohighlight":
+		return ""
+	}
+	return lang
+}
+
+// textWithCode flattens extracted article HTML to text the way readability
+// does, except that <pre> blocks become fenced code blocks with their line
+// breaks and indentation intact. It returns false if the article has no
+// code blocks, leaving readability's own text in place.
+func textWithCode(src string) (string, bool) {
+	if !strings.Contains(src, "<pre") {
+		return "", false
+	}
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	found := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Pre:
+			if block := codeBlock(n); block != "" {
+				b.WriteString("\n\n" + block + "\n\n")
+				found = true
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return strings.TrimSpace(b.String()), found
+}
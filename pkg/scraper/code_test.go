@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestCodeLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"prism", `<pre><code class="language-go">x</code></pre>`, "go"},
+		{"stack overflow", `<pre class="lang-py s-code-block"><code class="hljs">x</code></pre>`, "py"},
+		{"github", `<div class="highlight highlight-source-rust"><pre>x</pre></div>`, "rust"},
+		{"sphinx", `<div class="highlight-python notranslate"><div class="highlight"><pre>x</pre></div></div>`, "python"},
+		{"syntaxhighlighter", `<pre class="brush: js;">x</pre>`, "js"},
+		{"data attribute", `<pre data-lang="Ruby">x</pre>`, "ruby"},
+		{"no language", `<pre class="lang-none">x</pre>`, ""},
+		{"no hint", `<pre>x</pre>`, ""},
+	}
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := codeLanguage(findPre(doc)); got != tt.want {
+			t.Errorf("%s: codeLanguage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func findPre(n *html.Node) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Pre {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if pre := findPre(c); pre != nil {
+			return pre
+		}
+	}
+	return nil
+}
+
+func TestTextWithCode(t *testing.T) {
+	got, ok := textWithCode("<div><p>Run this:</p><pre><code class=\"language-go\">func main() {\n\tfmt.Println(\"hi\")\n}\n</code></pre><p>Done.</p></div>")
+	want := "Run this:\n\n```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n\nDone."
+	if !ok || got != want {
+		t.Errorf("textWithCode = %q, %v, want %q", got, ok, want)
+	}
+	if _, ok := textWithCode("<p>No code here.</p>"); ok {
+		t.Error("textWithCode reported code blocks in a page without any")
+	}
+}
+
+func TestScrapeKeepsCodeBlocks(t *testing.T) {
+	const page = `<!DOCTYPE html><html><head><title>How do I reverse a list?</title></head><body>
+<article><h1>How do I reverse a list?</h1>
+<p>Python lists have a reverse method that works in place, and slicing with a negative step returns a reversed copy instead.</p>
+<pre class="lang-py s-code-block"><code class="hljs language-python">items = [1, 2, 3]
+items.reverse()
+    print(items)</code></pre>
+<p>Use slicing when the original order must be kept, since reverse changes the list itself and returns None to the caller.</p>
+</article></body></html>`
+	srvURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer cleanup()
+
+	want := "```py\nitems = [1, 2, 3]\nitems.reverse()\n    print(items)\n```"
+	for _, extractor := range []string{ExtractorReadability, ExtractorDensity} {
+		got := ScrapeWithOptions(context.Background(), []string{srvURL}, Options{HostDelay: -1, Retries: -1, Extractor: extractor})[0]
+		if got.Err != nil {
+			t.Fatalf("%s: %v", extractor, got.Err)
+		}
+		if !strings.Contains(got.Content, want) {
+			t.Errorf("%s: content = %q, want it to contain %q", extractor, got.Content, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+// Confidence levels for a detected publish date.
+const (
+	DateConfidenceHigh   = "high"   // explicit page metadata
+	DateConfidenceMedium = "medium" // visible byline phrase or date in the URL
+	DateConfidenceLow    = "low"    // HTTP Last-Modified, often just the render time
+)
+
+// Sources a publish date can be derived from.
+const (
+	DateSourceMetadata     = "metadata"
+	DateSourceText         = "text"
+	DateSourceURL          = "url"
+	DateSourceLastModified = "last-modified"
+)
+
+// PublishDate is a page's publication date along with where it came from
+// and how much it can be trusted.
+type PublishDate struct {
+	Time       time.Time
+	Source     string
+	Confidence string
+}
+
+// IsZero reports whether no date was detected.
+func (d PublishDate) IsZero() bool { return d.Time.IsZero() }
+
+var (
+	// /2024/03/15/ or /2024/3/5/
+	rxURLDatePath = regexp.MustCompile(`/((?:19|20)\d{2})/(\d{1,2})/(\d{1,2})(?:/|$)`)
+	// 2024-03-15 anywhere in the path
+	rxURLDateISO = regexp.MustCompile(`((?:19|20)\d{2})-(\d{2})-(\d{2})`)
+	// /2024/03/ — day unknown, assume the 1st
+	rxURLMonthPath = regexp.MustCompile(`/((?:19|20)\d{2})/(\d{1,2})(?:/|$)`)
+
+	// "Updated" phrases are deliberately not matched: a modification date
+	// is not a publish date.
+	rxPublishedPhrase = regexp.MustCompile(
+		`(?i)\b(?:published|posted)(?:\s+on)?[:\s]+` +
+			`([A-Z][a-z]{2,8}\.? \d{1,2},? \d{4}|\d{1,2} [A-Z][a-z]{2,8}\.? \d{4}|\d{4}-\d{2}-\d{2})`)
+)
+
+var phraseLayouts = []string{
+	"January 2, 2006", "January 2 2006", "Jan 2, 2006", "Jan 2 2006", "Jan. 2, 2006",
+	"2 January 2006", "2 Jan 2006", "2006-01-02",
+}
+
+// phraseScanLimit bounds how much of the extracted text is searched for a
+// "Published on" phrase; bylines sit near the top of an article.
+const phraseScanLimit = 4000
+
+// detectPublishDate returns the best available publish date for a page,
+// preferring explicit metadata and falling back to heuristics.
+func detectPublishDate(article readability.Article, rawURL, lastModified string) PublishDate {
+	if article.PublishedTime != nil && !article.PublishedTime.IsZero() {
+		return PublishDate{Time: *article.PublishedTime, Source: DateSourceMetadata, Confidence: DateConfidenceHigh}
+	}
+	if t, ok := dateFromText(article.TextContent); ok {
+		return PublishDate{Time: t, Source: DateSourceText, Confidence: DateConfidenceMedium}
+	}
+	if t, ok := dateFromURL(rawURL); ok {
+		return PublishDate{Time: t, Source: DateSourceURL, Confidence: DateConfidenceMedium}
+	}
+	if lastModified != "" {
+		if t, err := http.ParseTime(lastModified); err == nil {
+			return PublishDate{Time: t, Source: DateSourceLastModified, Confidence: DateConfidenceLow}
+		}
+	}
+	return PublishDate{}
+}
+
+// dateFromText looks for a visible "Published on <date>" style phrase.
+func dateFromText(text string) (time.Time, bool) {
+	if len(text) > phraseScanLimit {
+		text = text[:phraseScanLimit]
+	}
+	m := rxPublishedPhrase.FindStringSubmatch(text)
+	if m == nil {
+		return time.Time{}, false
+	}
+	raw := strings.Replace(m[1], ",", ", ", 1)
+	raw = strings.Join(strings.Fields(raw), " ")
+	for _, layout := range phraseLayouts {
+		if t, err := time.Parse(layout, raw); err == nil && plausible(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dateFromURL extracts a date embedded in a URL path, as used by most blog
+// and news CMSes.
+func dateFromURL(rawURL string) (time.Time, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	path := u.Path
+
+	fullDate := false
+	for _, rx := range []*regexp.Regexp{rxURLDatePath, rxURLDateISO} {
+		if m := rx.FindStringSubmatch(path); m != nil {
+			fullDate = true
+			if t, ok := makeDate(m[1], m[2], m[3]); ok {
+				return t, true
+			}
+		}
+	}
+	// Fall back to year/month only when no full date was present, so an
+	// invalid day does not silently degrade to the 1st of the month.
+	if fullDate {
+		return time.Time{}, false
+	}
+	if m := rxURLMonthPath.FindStringSubmatch(path); m != nil {
+		if t, ok := makeDate(m[1], m[2], "1"); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func makeDate(year, month, day string) (time.Time, bool) {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	// Reject normalised overflow such as Feb 31 → Mar 3.
+	if t.Day() != d {
+		return time.Time{}, false
+	}
+	return t, plausible(t)
+}
+
+// plausible rejects dates before the web existed or in the future.
+func plausible(t time.Time) bool {
+	return t.Year() >= 1991 && t.Before(time.Now().Add(48*time.Hour))
+}
@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+func TestDetectPublishDate(t *testing.T) {
+	meta := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		article      readability.Article
+		url          string
+		lastModified string
+		want         time.Time
+		wantSource   string
+		wantConf     string
+	}{
+		{
+			name:       "metadata_wins",
+			article:    readability.Article{PublishedTime: &meta, TextContent: "Published on March 3, 2021"},
+			url:        "https://blog.example.com/2020/01/02/post",
+			want:       meta,
+			wantSource: DateSourceMetadata,
+			wantConf:   DateConfidenceHigh,
+		},
+		{
+			name:       "visible_phrase",
+			article:    readability.Article{TextContent: "By Jane Doe. Published on March 3, 2021. Body text."},
+			url:        "https://blog.example.com/post",
+			want:       time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC),
+			wantSource: DateSourceText,
+			wantConf:   DateConfidenceMedium,
+		},
+		{
+			name:       "visible_phrase_day_first",
+			article:    readability.Article{TextContent: "Posted: 14 Feb 2022"},
+			want:       time.Date(2022, 2, 14, 0, 0, 0, 0, time.UTC),
+			wantSource: DateSourceText,
+			wantConf:   DateConfidenceMedium,
+		},
+		{
+			name:    "updated_phrase_ignored",
+			article: readability.Article{TextContent: "Last updated: 14 Feb 2022. Updated on March 3, 2021."},
+			url:     "https://example.com/page",
+		},
+		{
+			name:       "url_path",
+			article:    readability.Article{TextContent: "No date here."},
+			url:        "https://news.example.com/2019/11/05/story-title",
+			want:       time.Date(2019, 11, 5, 0, 0, 0, 0, time.UTC),
+			wantSource: DateSourceURL,
+			wantConf:   DateConfidenceMedium,
+		},
+		{
+			name:       "url_iso",
+			url:        "https://example.com/posts/2018-06-30-release-notes",
+			want:       time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC),
+			wantSource: DateSourceURL,
+			wantConf:   DateConfidenceMedium,
+		},
+		{
+			name:       "url_month_only",
+			url:        "https://example.com/2017/04/slug",
+			want:       time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantSource: DateSourceURL,
+			wantConf:   DateConfidenceMedium,
+		},
+		{
+			name:         "last_modified",
+			url:          "https://example.com/about",
+			lastModified: "Wed, 21 Oct 2015 07:28:00 GMT",
+			want:         time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+			wantSource:   DateSourceLastModified,
+			wantConf:     DateConfidenceLow,
+		},
+		{
+			name: "invalid_url_date_ignored",
+			url:  "https://example.com/2020/02/31/slug",
+		},
+		{
+			name: "nothing",
+			url:  "https://example.com/page",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectPublishDate(tt.article, tt.url, tt.lastModified)
+			if !got.Time.Equal(tt.want) {
+				t.Errorf("Time = %v, want %v", got.Time, tt.want)
+			}
+			if got.Source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", got.Source, tt.wantSource)
+			}
+			if got.Confidence != tt.wantConf {
+				t.Errorf("Confidence = %q, want %q", got.Confidence, tt.wantConf)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestScrapeCompressedBodies(t *testing.T) {
+	page := []byte(fakeArticlePage("Compressed", "Brotli and gzip responses are decoded transparently before extraction."))
+
+	var gz, br bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(page)
+	zw.Close()
+	bw := brotli.NewWriter(&br)
+	bw.Write(page)
+	bw.Close()
+
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Encoding"); got != acceptEncoding {
+			t.Errorf("Accept-Encoding = %q, want %q", got, acceptEncoding)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gz.Bytes())
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(br.Bytes())
+		case "/zstd":
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write(page)
+		default:
+			w.Write(page)
+		}
+	}))
+	defer cleanup()
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/plain", false},
+		{"/gzip", false},
+		{"/br", false},
+		{"/zstd", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p := ScrapeWithOptions(context.Background(), []string{serverURL + tt.path}, Options{HostDelay: -1})[0]
+			if (p.Err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", p.Err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.Contains(p.Content, "decoded transparently") {
+				t.Errorf("content = %q", p.Content)
+			}
+		})
+	}
+}
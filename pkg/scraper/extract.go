@@ -0,0 +1,330 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	readability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+// Extraction backends selectable via Options.
+const (
+	ExtractorReadability = "readability" // go-readability (default)
+	ExtractorDensity     = "density"     // text-density heuristic, trafilatura-style
+	ExtractorAuto        = "auto"        // run both and keep the higher quality result
+)
+
+// DefaultMinContentChars is the extracted text length below which
+// Options.FallbackExtractor is tried, when Options.MinContentChars is zero.
+const DefaultMinContentChars = 250
+
+// Extractor pulls the main article out of an HTML document. TextContent of
+// the returned article is the page text; Title, Content (article HTML),
+// Byline, SiteName, Excerpt and the other metadata are used when set.
+type Extractor interface {
+	Extract(r io.Reader) (readability.Article, error)
+}
+
+// ExtractorFunc adapts a function to Extractor.
+type ExtractorFunc func(r io.Reader) (readability.Article, error)
+
+// Extract calls f(r).
+func (f ExtractorFunc) Extract(r io.Reader) (readability.Article, error) { return f(r) }
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{
+		ExtractorReadability: ExtractorFunc(extract),
+		ExtractorDensity: ExtractorFunc(func(r io.Reader) (readability.Article, error) {
+			text, err := extractDensity(r)
+			return readability.Article{TextContent: text}, err
+		}),
+	}
+)
+
+// RegisterExtractor makes x selectable by name wherever a backend is
+// named: Options.Extractor, DomainExtractors and FallbackExtractor, and so
+// the extractor parameter and GLSI_EXTRACTOR. It panics if name is empty or
+// already taken, including by auto and the pdf, raw and github readers.
+func RegisterExtractor(name string, x Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	switch name {
+	case "", ExtractorAuto, ExtractorPDF, ExtractorRaw, ExtractorGitHub:
+		panic(fmt.Sprintf("scraper: reserved extractor name %q", name))
+	}
+	if _, dup := extractors[name]; dup {
+		panic(fmt.Sprintf("scraper: extractor %q registered twice", name))
+	}
+	extractors[name] = x
+}
+
+// lookupExtractor returns the registered backend called name.
+func lookupExtractor(name string) (Extractor, bool) {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	x, ok := extractors[name]
+	return x, ok
+}
+
+// ValidExtractor reports whether name is a known extraction backend,
+// built in or registered. The empty string selects the default.
+func ValidExtractor(name string) bool {
+	if name == "" || name == ExtractorAuto {
+		return true
+	}
+	_, ok := lookupExtractor(name)
+	return ok
+}
+
+// extractorFor resolves which backend to use for rawURL: a per-domain rule
+// wins over the per-call default, and the most specific (longest) matching
+// domain wins over its parents.
+func extractorFor(opts Options, rawURL string) string {
+	if name, ok := domainRule(opts.DomainExtractors, rawURL); ok {
+		return name
+	}
+	if opts.Extractor == "" {
+		return ExtractorReadability
+	}
+	return opts.Extractor
+}
+
+// domainRule looks up rawURL's host in per-domain rules keyed by domain.
+// A rule covers its subdomains, and the most specific (longest) matching
+// domain wins over its parents.
+func domainRule(rules map[string]string, rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(rules) == 0 {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	best, bestValue := "", ""
+	for domain, value := range rules {
+		domain = strings.ToLower(domain)
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(best) {
+			best, bestValue = domain, value
+		}
+	}
+	return bestValue, best != ""
+}
+
+// extractPage runs the backend chosen for rawURL over an HTML document.
+// If its text is shorter than the minimum content length, the fallback
+// backend runs too and wins when it finds at least that much text, since
+// the page then clearly has content the first backend missed.
+func extractPage(opts Options, rawURL string, data []byte) (readability.Article, string, error) {
+	name := extractorFor(opts, rawURL)
+	article, extractor, err := extractWith(name, bytes.NewReader(data))
+	minChars, fallback := minContentChars(opts), fallbackExtractor(opts)
+	if minChars <= 0 || name == ExtractorAuto || extractor == fallback || contentChars(article.TextContent) >= minChars {
+		return article, extractor, err
+	}
+	alt, altName, altErr := extractWith(fallback, bytes.NewReader(data))
+	if altErr != nil || contentChars(alt.TextContent) < minChars {
+		return article, extractor, err
+	}
+	logger(opts).Debug("extractor fallback", "url", rawURL, "from", extractor, "to", altName)
+	if err == nil {
+		// Keep the first backend's metadata even when its text loses.
+		article.TextContent, article.Content = alt.TextContent, alt.Content
+		return article, altName, nil
+	}
+	return alt, altName, nil
+}
+
+// minContentChars resolves Options.MinContentChars; zero or less disables
+// the fallback.
+func minContentChars(opts Options) int {
+	if opts.MinContentChars == 0 {
+		return DefaultMinContentChars
+	}
+	return max(opts.MinContentChars, 0)
+}
+
+// fallbackExtractor resolves Options.FallbackExtractor.
+func fallbackExtractor(opts Options) string {
+	if opts.FallbackExtractor == "" {
+		return ExtractorDensity
+	}
+	return opts.FallbackExtractor
+}
+
+func contentChars(text string) int {
+	return utf8.RuneCountInString(strings.TrimSpace(text))
+}
+
+// extractWith runs the named backend over an HTML document and returns the
+// resulting article along with the backend that produced it (relevant for
+// auto mode).
+func extractWith(name string, r io.Reader) (readability.Article, string, error) {
+	switch name {
+	case ExtractorAuto:
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return readability.Article{}, "", err
+		}
+		article, rErr := extract(bytes.NewReader(body))
+		text, dErr := extractDensity(bytes.NewReader(body))
+		if rErr != nil && dErr != nil {
+			return readability.Article{}, "", rErr
+		}
+		if rErr != nil || (dErr == nil && Quality(text) > Quality(article.TextContent)) {
+			// Keep readability's metadata even when its text loses.
+			article.TextContent = text
+			return article, ExtractorDensity, nil
+		}
+		return article, ExtractorReadability, nil
+	case "":
+		name = ExtractorReadability
+	}
+	x, ok := lookupExtractor(name)
+	if !ok {
+		return readability.Article{}, "", fmt.Errorf("unknown extractor %q", name)
+	}
+	article, err := x.Extract(r)
+	return article, name, err
+}
+
+// extract runs go-readability over an HTML document. Classes are kept
+// because code blocks carry their language in them.
+func extract(r io.Reader) (readability.Article, error) {
+	p := readability.NewParser()
+	p.KeepClasses = true
+	return p.Parse(r, nil)
+}
+
+var (
+	// rxBoilerplate matches class/id values of page chrome.
+	rxBoilerplate = regexp.MustCompile(`(?i)comment|sidebar|footer|masthead|\bnav|menu|share|social|related|advert|\bads?\b|promo|cookie|banner|newsletter|breadcrumb|popup|modal`)
+	rxSpaces      = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+const (
+	minBlockChars   = 25  // shorter paragraphs are usually captions or UI text
+	maxLinkDensity  = 0.5 // blocks mostly made of links are navigation
+	blockSelector   = "p, pre, li, blockquote, h1, h2, h3, h4, h5, h6, td, dd"
+	removedSelector = "script, style, noscript, iframe, svg, form, nav, header, footer, aside, button, select"
+)
+
+// extractDensity is a trafilatura-inspired extractor: it strips obvious
+// chrome, scores text blocks by length and link density, picks the
+// container holding the most good text, and returns that container's blocks
+// in document order.
+func extractDensity(r io.Reader) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return "", err
+	}
+
+	doc.Find(removedSelector).Remove()
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		attrs := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if rxBoilerplate.MatchString(attrs) && !s.Is("body, html, article, main") {
+			s.Remove()
+		}
+	})
+
+	type block struct {
+		node *html.Node
+		text string
+	}
+	var blocks []block
+	scores := map[*html.Node]int{}
+
+	doc.Find(blockSelector).Each(func(_ int, s *goquery.Selection) {
+		// Skip blocks nested in another block (e.g. p inside li) to avoid
+		// emitting the same text twice.
+		if s.ParentsFiltered(blockSelector).Length() > 0 {
+			return
+		}
+		text := cleanText(s.Text())
+		if s.Is("pre") && text != "" {
+			text = codeBlock(s.Get(0))
+		}
+		isHeading := s.Is("h1, h2, h3, h4, h5, h6, pre")
+		if len(text) < minBlockChars && !(isHeading && text != "") {
+			return
+		}
+		if linkDensity(s, text) > maxLinkDensity {
+			return
+		}
+		node := s.Get(0)
+		blocks = append(blocks, block{node: node, text: text})
+		if node.Parent != nil {
+			scores[node.Parent] += len(text)
+			if node.Parent.Parent != nil {
+				scores[node.Parent.Parent] += len(text) / 2
+			}
+		}
+	})
+	if len(blocks) == 0 {
+		return "", nil
+	}
+
+	var best *html.Node
+	bestScore := -1
+	for n, sc := range scores {
+		if sc > bestScore {
+			best, bestScore = n, sc
+		}
+	}
+
+	var out []string
+	for _, b := range blocks {
+		if isAncestor(best, b.node) {
+			out = append(out, b.text)
+		}
+	}
+	return strings.Join(out, "\n\n"), nil
+}
+
+// linkDensity is the fraction of a block's text that sits inside links.
+func linkDensity(s *goquery.Selection, text string) float64 {
+	if text == "" {
+		return 0
+	}
+	linkChars := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkChars += len(cleanText(a.Text()))
+	})
+	return float64(linkChars) / float64(len(text))
+}
+
+func isAncestor(ancestor, n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanText(s string) string {
+	return strings.TrimSpace(rxSpaces.ReplaceAllString(s, " "))
+}
+
+// Quality scores extracted text: characters in prose-like lines count fully,
+// while short fragments (menus, buttons, captions) count against it.
+func Quality(text string) float64 {
+	score := 0.0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case len(line) >= 80 || (len(line) >= 40 && strings.ContainsAny(line, ".!?")):
+			score += float64(len(line))
+		default:
+			score -= float64(len(line)) * 0.5
+		}
+	}
+	return score
+}
@@ -0,0 +1,207 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+func TestExtractDensityFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	text, err := extractDensity(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extractDensity: %v", err)
+	}
+	if !strings.Contains(text, "Go Concurrency Patterns in Depth") {
+		t.Error("expected article heading in output")
+	}
+	if !strings.Contains(text, "func worker") {
+		t.Error("expected code block in output")
+	}
+	for _, chrome := range []string{"Footer link", "Section 12"} {
+		if strings.Contains(text, chrome) {
+			t.Errorf("output should not contain page chrome %q", chrome)
+		}
+	}
+}
+
+func TestExtractDensityDropsLinkLists(t *testing.T) {
+	page := `<html><body>
+<div id="content">
+<p>This paragraph carries the actual article content that a reader wants to see.</p>
+<p><a href="/a">A link-only paragraph pointing somewhere else on the site</a></p>
+<p>Another substantial paragraph with enough words to be considered real prose.</p>
+</div>
+<div class="sidebar"><p>Sidebar paragraph text that should be removed as boilerplate.</p></div>
+</body></html>`
+
+	text, err := extractDensity(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("extractDensity: %v", err)
+	}
+	want := "This paragraph carries the actual article content that a reader wants to see.\n\n" +
+		"Another substantial paragraph with enough words to be considered real prose."
+	if text != want {
+		t.Errorf("extractDensity =\n%q\nwant\n%q", text, want)
+	}
+}
+
+func TestQuality(t *testing.T) {
+	prose := "Goroutines are lightweight threads managed by the Go runtime, and they are cheap to create."
+	menu := "Home\nAbout\nContact\nBlog\nLogin"
+	if Quality(prose) <= Quality(menu) {
+		t.Errorf("prose quality %v should beat menu quality %v", Quality(prose), Quality(menu))
+	}
+	if Quality(menu) >= 0 {
+		t.Errorf("menu-only text should score negative, got %v", Quality(menu))
+	}
+}
+
+func TestExtractorFor(t *testing.T) {
+	opts := Options{
+		Extractor: ExtractorAuto,
+		DomainExtractors: map[string]string{
+			"example.com":      ExtractorDensity,
+			"docs.example.com": ExtractorReadability,
+			"com":              ExtractorAuto,
+		},
+	}
+	tests := map[string]string{
+		"https://example.com/page":       ExtractorDensity,
+		"https://blog.example.com/page":  ExtractorDensity,
+		"https://docs.example.com/page":  ExtractorReadability,
+		"https://api.docs.example.com/x": ExtractorReadability,
+		"https://notexample.com/page":    ExtractorAuto,
+		"https://notexample.org/page":    ExtractorAuto,
+	}
+	for u, want := range tests {
+		if got := extractorFor(opts, u); got != want {
+			t.Errorf("extractorFor(%q) = %q, want %q", u, got, want)
+		}
+	}
+	if got := extractorFor(Options{}, "https://a.com"); got != ExtractorReadability {
+		t.Errorf("default extractor = %q, want %q", got, ExtractorReadability)
+	}
+}
+
+func TestScrapeWithDensityExtractor(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage(
+			"Density Article",
+			"This is the main content of the density article with plenty of words in it.",
+		)))
+	}))
+	defer cleanup()
+
+	for _, name := range []string{ExtractorDensity, ExtractorAuto} {
+		pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/page"}, Options{Extractor: name})
+		if pages[0].Err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, pages[0].Err)
+		}
+		if !strings.Contains(pages[0].Content, "main content of the density article") {
+			t.Errorf("%s: content = %q", name, pages[0].Content)
+		}
+		if pages[0].Extractor == "" {
+			t.Errorf("%s: Extractor should be recorded", name)
+		}
+	}
+}
+
+func TestValidExtractor(t *testing.T) {
+	for _, name := range []string{"", ExtractorReadability, ExtractorDensity, ExtractorAuto} {
+		if !ValidExtractor(name) {
+			t.Errorf("ValidExtractor(%q) = false", name)
+		}
+	}
+	if ValidExtractor("boilerpipe") {
+		t.Error("ValidExtractor(boilerpipe) = true")
+	}
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	if !ValidExtractor("test-upper") { // registered once per process, even with -count
+		RegisterExtractor("test-upper", ExtractorFunc(func(r io.Reader) (readability.Article, error) {
+			body, err := io.ReadAll(r)
+			return readability.Article{Title: "Upper", TextContent: strings.ToUpper(string(body))}, err
+		}))
+	}
+	article, name, err := extractWith("test-upper", strings.NewReader("<p>hello</p>"))
+	if err != nil || name != "test-upper" || article.TextContent != "<P>HELLO</P>" {
+		t.Errorf("extractWith = %q, %q, %v", article.TextContent, name, err)
+	}
+
+	for _, name := range []string{"", ExtractorAuto, ExtractorPDF, ExtractorReadability, "test-upper"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterExtractor(%q) did not panic", name)
+				}
+			}()
+			RegisterExtractor(name, ExtractorFunc(extract))
+		}()
+	}
+}
+
+func TestExtractPageFallback(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if !ValidExtractor("test-teaser") {
+		RegisterExtractor("test-teaser", ExtractorFunc(func(io.Reader) (readability.Article, error) {
+			return readability.Article{Title: "Teaser title", TextContent: "Only a teaser."}, nil
+		}))
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		want     string
+		wantMeta bool // the teaser's title survives the switch
+	}{
+		{"short text falls back", Options{Extractor: "test-teaser"}, ExtractorDensity, true},
+		{"fallback disabled", Options{Extractor: "test-teaser", MinContentChars: -1}, "test-teaser", true},
+		{"fallback finds too little", Options{Extractor: "test-teaser", MinContentChars: 1 << 20}, "test-teaser", true},
+		{"long enough", Options{Extractor: "test-teaser", MinContentChars: 5}, "test-teaser", true},
+		{"auto skips fallback", Options{Extractor: ExtractorAuto, FallbackExtractor: "test-teaser", MinContentChars: 1 << 20}, ExtractorReadability, false},
+	}
+	for _, tt := range tests {
+		article, name, err := extractPage(tt.opts, "https://example.com/", data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if name != tt.want {
+			t.Errorf("%s: extractor = %q, want %q", tt.name, name, tt.want)
+		}
+		if got := article.Title == "Teaser title"; got != tt.wantMeta {
+			t.Errorf("%s: title = %q", tt.name, article.Title)
+		}
+	}
+}
+
+func BenchmarkExtractDensity(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		b.Fatalf("read fixture: %v", err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := extractDensity(bytes.NewReader(data)); err != nil {
+			b.Fatalf("extractDensity: %v", err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+// ExtractorGitHub is reported in ScrapedPage.Extractor for GitHub repos,
+// files and issues read through the API or raw.githubusercontent.com
+// instead of scraped from the HTML UI.
+const ExtractorGitHub = "github"
+
+// GitHub endpoints; tests point them at a local server.
+var (
+	githubAPI = "https://api.github.com"
+	githubRaw = "https://raw.githubusercontent.com"
+)
+
+// githubReserved are first path segments on github.com that are site pages
+// rather than users or organizations.
+var githubReserved = map[string]bool{
+	"about": true, "collections": true, "enterprise": true, "events": true,
+	"explore": true, "features": true, "login": true, "marketplace": true,
+	"notifications": true, "orgs": true, "pricing": true, "pulls": true,
+	"search": true, "settings": true, "sponsors": true, "topics": true,
+	"trending": true,
+}
+
+type githubKind int
+
+const (
+	githubReadme githubKind = iota + 1 // repo or directory: its README
+	githubBlob                         // file: its raw contents
+	githubIssue                        // issue or pull request: title and description
+)
+
+// githubTarget is a github.com page and where to fetch its content instead.
+type githubTarget struct {
+	kind        githubKind
+	owner, repo string
+	number      int    // issue or pull request number
+	path        string // file or directory within the repo
+	url         string // API or raw URL to fetch
+	accept      string // Accept header for url
+}
+
+// parseGitHubURL recognizes github.com repo, tree, blob, issue and pull
+// request URLs. Other GitHub pages report false and are scraped as usual.
+func parseGitHubURL(rawURL string) (githubTarget, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Host != "github.com" && u.Host != "www.github.com") {
+		return githubTarget{}, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || githubReserved[parts[0]] {
+		return githubTarget{}, false
+	}
+	t := githubTarget{owner: parts[0], repo: strings.TrimSuffix(parts[1], ".git")}
+	repoAPI := githubAPI + "/repos/" + t.owner + "/" + t.repo
+	switch {
+	case len(parts) == 2:
+		t.kind, t.url = githubReadme, repoAPI+"/readme"
+	case parts[2] == "tree" && len(parts) >= 4:
+		// Refs containing slashes are read as a directory; the HTML
+		// fallback covers them.
+		t.kind, t.path = githubReadme, strings.Join(parts[4:], "/")
+		t.url = repoAPI + "/readme"
+		if t.path != "" {
+			t.url += "/" + t.path
+		}
+		t.url += "?ref=" + url.QueryEscape(parts[3])
+	case parts[2] == "blob" && len(parts) >= 5:
+		t.kind, t.path = githubBlob, strings.Join(parts[4:], "/")
+		t.url = githubRaw + "/" + t.owner + "/" + t.repo + "/" + parts[3] + "/" + t.path
+	case (parts[2] == "issues" || parts[2] == "pull") && len(parts) >= 4:
+		n, err := strconv.Atoi(parts[3])
+		if err != nil || n <= 0 {
+			return githubTarget{}, false
+		}
+		t.kind, t.number = githubIssue, n
+		t.url = repoAPI + "/issues/" + parts[3] // pull requests are issues too
+	default:
+		return githubTarget{}, false
+	}
+	switch t.kind {
+	case githubReadme:
+		t.accept = "application/vnd.github.raw"
+	case githubIssue:
+		t.accept = "application/vnd.github+json"
+	}
+	return t, true
+}
+
+// setHeaders adds the Accept header and the token, if any, which lifts the
+// API's anonymous rate limit of 60 requests an hour.
+func (t githubTarget) setHeaders(req *http.Request, token string) {
+	if t.accept != "" {
+		req.Header.Set("Accept", t.accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// extract turns the fetched README, file or issue into an article.
+func (t githubTarget) extract(data []byte, contentType string) (article readability.Article, truncated bool, err error) {
+	repo := t.owner + "/" + t.repo
+	switch t.kind {
+	case githubIssue:
+		var issue struct {
+			Title     string    `json:"title"`
+			Body      string    `json:"body"`
+			CreatedAt time.Time `json:"created_at"`
+			User      struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal(data, &issue); err != nil {
+			return article, false, fmt.Errorf("github issue: %w", err)
+		}
+		article.Title = fmt.Sprintf("%s · %s#%d", issue.Title, repo, t.number)
+		article.TextContent = strings.TrimSpace(issue.Body)
+		if article.TextContent == "" {
+			article.TextContent = issue.Title
+		}
+		article.Byline = issue.User.Login
+		if !issue.CreatedAt.IsZero() {
+			article.PublishedTime = &issue.CreatedAt
+		}
+	case githubBlob:
+		// raw.githubusercontent.com serves every text file as text/plain.
+		if mt, _, _ := mime.ParseMediaType(contentType); mt != "text/plain" {
+			return article, false, fmt.Errorf("github: %s is not a text file (%s)", t.path, contentType)
+		}
+		article, truncated = extractRaw(data, "text/plain")
+		article.Title = t.path + " · " + repo
+	default:
+		article, truncated = extractRaw(data, "text/plain")
+		article.Title = repo
+		if t.path != "" {
+			article.Title = path.Join(repo, t.path)
+		}
+	}
+	article.SiteName = "GitHub"
+	return article, truncated, nil
+}
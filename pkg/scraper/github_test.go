@@ -0,0 +1,122 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseGitHubURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		kind    githubKind
+		wantURL string
+	}{
+		{"https://github.com/golang/go", githubReadme, "https://api.github.com/repos/golang/go/readme"},
+		{"https://www.github.com/golang/go.git", githubReadme, "https://api.github.com/repos/golang/go/readme"},
+		{"https://github.com/golang/go/tree/master/src/net", githubReadme, "https://api.github.com/repos/golang/go/readme/src/net?ref=master"},
+		{"https://github.com/golang/go/blob/master/src/net/http/server.go", githubBlob, "https://raw.githubusercontent.com/golang/go/master/src/net/http/server.go"},
+		{"https://github.com/golang/go/issues/42", githubIssue, "https://api.github.com/repos/golang/go/issues/42"},
+		{"https://github.com/golang/go/pull/7", githubIssue, "https://api.github.com/repos/golang/go/issues/7"},
+		{"https://github.com/golang", 0, ""},
+		{"https://github.com/topics/go", 0, ""},
+		{"https://github.com/golang/go/issues", 0, ""},
+		{"https://github.com/golang/go/wiki/Modules", 0, ""},
+		{"https://gitlab.com/golang/go", 0, ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseGitHubURL(tt.url)
+		if ok != (tt.kind != 0) || got.kind != tt.kind || got.url != tt.wantURL {
+			t.Errorf("parseGitHubURL(%q) = %v %q, %v; want %v %q", tt.url, got.kind, got.url, ok, tt.kind, tt.wantURL)
+		}
+	}
+}
+
+// githubTransport sends github.com requests to the test server under /html,
+// standing in for the real site.
+type githubTransport struct{ srv *url.URL }
+
+func (g githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "github.com" {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host = g.srv.Scheme, g.srv.Host
+		req.URL.Path = "/html" + req.URL.Path
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestScrapeGitHub(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		auth []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/html/") {
+			mu.Lock()
+			auth = append(auth, r.Header.Get("Authorization"))
+			mu.Unlock()
+		}
+		switch r.URL.Path {
+		case "/api/repos/acme/widget/readme":
+			if r.Header.Get("Accept") != "application/vnd.github.raw" {
+				http.Error(w, "want raw", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.github.raw")
+			w.Write([]byte("# Widget\n\nWidget builds widgets from YAML."))
+		case "/api/repos/acme/widget/issues/12":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title":"Crash on empty YAML","body":"Running widget on an empty file panics.","created_at":"2024-03-05T10:00:00Z","user":{"login":"octocat"}}`))
+		case "/raw/acme/widget/main/cmd/main.go":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("package main\n\nfunc main() {}\n"))
+		case "/raw/acme/widget/main/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG"))
+		case "/html/acme/widget/blob/main/logo.png":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(fakeArticlePage("logo.png · acme/widget", "The widget logo, drawn by the design team in 2023. It shows a gear over a stack of YAML files.")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+	origAPI, origRaw := githubAPI, githubRaw
+	githubAPI, githubRaw = srv.URL+"/api", srv.URL+"/raw"
+	defer func() { githubAPI, githubRaw = origAPI, origRaw }()
+
+	ctx := WithHTTPClient(context.Background(), &http.Client{Transport: githubTransport{srvURL}})
+	pages := ScrapeWithOptions(ctx, []string{
+		"https://github.com/acme/widget",
+		"https://github.com/acme/widget/issues/12",
+		"https://github.com/acme/widget/blob/main/cmd/main.go",
+		"https://github.com/acme/widget/blob/main/logo.png",
+	}, Options{HostDelay: -1, Retries: -1, GitHubToken: "t0ken"})
+
+	readme, issue, file, logo := pages[0], pages[1], pages[2], pages[3]
+	if readme.Err != nil || readme.Extractor != ExtractorGitHub || readme.Title != "acme/widget" ||
+		!strings.Contains(readme.Content, "builds widgets from YAML") || readme.SiteName != "GitHub" {
+		t.Errorf("readme = %+v", readme)
+	}
+	if issue.Err != nil || issue.Title != "Crash on empty YAML · acme/widget#12" || issue.Author != "octocat" ||
+		issue.Content != "Running widget on an empty file panics." || issue.Published.Time.Year() != 2024 {
+		t.Errorf("issue = %+v", issue)
+	}
+	if file.Err != nil || file.Title != "cmd/main.go · acme/widget" || !strings.Contains(file.Content, "func main()") {
+		t.Errorf("file = %+v", file)
+	}
+	// Binary files fall back to the HTML page.
+	if logo.Err != nil || logo.Extractor == ExtractorGitHub || !strings.Contains(logo.Content, "widget logo") {
+		t.Errorf("logo = %+v, want the HTML fallback", logo)
+	}
+	for _, a := range auth {
+		if a != "Bearer t0ken" {
+			t.Errorf("Authorization = %q, want the token on every GitHub request", a)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHostDelay is the pause between consecutive fetches from one host
+// when Options.HostDelay is zero.
+const DefaultHostDelay = 500 * time.Millisecond
+
+// maxIdleGates bounds the host gate table; idle gates are pruned beyond it.
+const maxIdleGates = 1024
+
+// hostGate serializes fetches to one host and spaces them by a delay.
+type hostGate struct {
+	sem   chan struct{} // capacity 1: held for the duration of a fetch
+	users int           // callers between gateFor and putGate; guarded by gatesMu
+	mu    sync.Mutex
+	last  time.Time // when the previous fetch finished
+}
+
+var (
+	gatesMu sync.Mutex
+	gates   = map[string]*hostGate{}
+)
+
+// hostDelay resolves the per-host delay; negative disables gating.
+func hostDelay(opts Options) time.Duration {
+	if opts.HostDelay == 0 {
+		return DefaultHostDelay
+	}
+	return opts.HostDelay
+}
+
+// acquireHost waits until rawURL's host is free and its delay has elapsed,
+// then returns a release function. Gates are shared process-wide, so
+// concurrent searches hitting the same site are serialized too.
+func acquireHost(ctx context.Context, rawURL string, delay time.Duration) (release func(), err error) {
+	if delay < 0 {
+		return func() {}, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		// Let the fetch itself report the bad URL.
+		return func() {}, nil
+	}
+	g := gateFor(strings.ToLower(u.Host))
+
+	select {
+	case g.sem <- struct{}{}:
+	case <-ctx.Done():
+		putGate(g)
+		return nil, ctx.Err()
+	}
+
+	g.mu.Lock()
+	wait := time.Until(g.last.Add(delay))
+	g.mu.Unlock()
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			<-g.sem
+			putGate(g)
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		g.mu.Lock()
+		g.last = time.Now()
+		g.mu.Unlock()
+		<-g.sem
+		putGate(g)
+	}, nil
+}
+
+// gateFor returns host's gate, registering the caller as a user so the gate
+// cannot be pruned before it is released with putGate.
+func gateFor(host string) *hostGate {
+	gatesMu.Lock()
+	defer gatesMu.Unlock()
+	g := gates[host]
+	if g == nil {
+		if len(gates) >= maxIdleGates {
+			pruneGates()
+		}
+		g = &hostGate{sem: make(chan struct{}, 1)}
+		gates[host] = g
+	}
+	g.users++
+	return g
+}
+
+// putGate undoes gateFor.
+func putGate(g *hostGate) {
+	gatesMu.Lock()
+	g.users--
+	gatesMu.Unlock()
+}
+
+// pruneGates drops gates that have no users and whose delay window has
+// long passed. Callers must hold gatesMu.
+func pruneGates() {
+	cutoff := time.Now().Add(-time.Minute)
+	for host, g := range gates {
+		g.mu.Lock()
+		idle := g.users == 0 && g.last.Before(cutoff)
+		g.mu.Unlock()
+		if idle {
+			delete(gates, host)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyServer tracks the peak number of in-flight requests and the
+// start time of each.
+type concurrencyServer struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	starts   []time.Time
+}
+
+func (c *concurrencyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.peak {
+		c.peak = c.inFlight
+	}
+	c.starts = append(c.starts, time.Now())
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(fakeArticlePage("Answer", "One of several answers on the same site.")))
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+}
+
+func TestScrapeSerializesSameHost(t *testing.T) {
+	srv := &concurrencyServer{}
+	serverURL, cleanup := setupScrapeServer(t, srv)
+	defer cleanup()
+
+	var urls []string
+	for i := 0; i < 3; i++ {
+		urls = append(urls, fmt.Sprintf("%s/q/%d", serverURL, i))
+	}
+	pages := ScrapeWithOptions(context.Background(), urls, Options{HostDelay: 50 * time.Millisecond})
+	for _, p := range pages {
+		if p.Err != nil {
+			t.Fatalf("unexpected error: %v", p.Err)
+		}
+	}
+
+	if srv.peak != 1 {
+		t.Errorf("peak concurrent requests = %d, want 1", srv.peak)
+	}
+	for i := 1; i < len(srv.starts); i++ {
+		// 20ms handler + 50ms delay between consecutive requests.
+		if gap := srv.starts[i].Sub(srv.starts[i-1]); gap < 65*time.Millisecond {
+			t.Errorf("gap between requests %d and %d = %v, want about 70ms", i-1, i, gap)
+		}
+	}
+	var queued int
+	for _, p := range pages {
+		if p.Timings.Queue > 50*time.Millisecond {
+			queued++
+		}
+	}
+	if queued != 2 {
+		t.Errorf("%d pages report queueing, want 2", queued)
+	}
+}
+
+func TestScrapeHostDelayDisabled(t *testing.T) {
+	srv := &concurrencyServer{}
+	serverURL, cleanup := setupScrapeServer(t, srv)
+	defer cleanup()
+
+	urls := []string{serverURL + "/a", serverURL + "/b", serverURL + "/c"}
+	ScrapeWithOptions(context.Background(), urls, Options{HostDelay: -1})
+	if srv.peak < 2 {
+		t.Errorf("peak concurrent requests = %d, want parallel fetches", srv.peak)
+	}
+}
+
+func TestAcquireHostCancelled(t *testing.T) {
+	release, err := acquireHost(context.Background(), "http://busy.example/", time.Hour)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := acquireHost(ctx, "http://busy.example/other", time.Hour); err == nil {
+		t.Fatal("expected context error while the host is busy")
+	}
+}
+
+func TestPruneGatesKeepsGatesInUse(t *testing.T) {
+	// A gate handed out by gateFor but not yet holding its semaphore must
+	// survive pruning, or a second caller would get a fresh, unlocked gate.
+	g := gateFor("waiting.example")
+	gatesMu.Lock()
+	pruneGates()
+	gatesMu.Unlock()
+	if got := gateFor("waiting.example"); got != g {
+		t.Fatal("gate in use was pruned")
+	}
+	putGate(g)
+	putGate(g)
+
+	gatesMu.Lock()
+	pruneGates()
+	_, ok := gates["waiting.example"]
+	gatesMu.Unlock()
+	if ok {
+		t.Error("unused gate with no recent fetch was not pruned")
+	}
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+
+	readability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxImages bounds ScrapedPage.Images; long galleries add little.
+const maxImages = 20
+
+// setImages fills page.Image from the article's lead image (readability
+// reads og:image and JSON-LD, the raw tags are the fallback) and
+// page.Images from the <img> elements of readability's article HTML. URLs
+// are resolved against the page URL; inline data: images are skipped.
+func setImages(page *ScrapedPage, article readability.Article, meta pageMeta) {
+	base, err := url.Parse(page.URL)
+	if err != nil {
+		return
+	}
+	page.Image = resolveImage(base, article.Image)
+	if page.Image == "" {
+		page.Image = resolveImage(base, meta.image)
+	}
+	if article.Content == "" {
+		return
+	}
+	doc, err := html.Parse(strings.NewReader(article.Content))
+	if err != nil {
+		return
+	}
+	seen := map[string]bool{"": true, page.Image: true}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if len(page.Images) >= maxImages {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+			src := attr(n, "src")
+			if src == "" || strings.HasPrefix(src, "data:") {
+				src = attr(n, "data-src") // lazy-loaded
+			}
+			if u := resolveImage(base, src); !seen[u] {
+				seen[u] = true
+				page.Images = append(page.Images, u)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+// resolveImage makes src absolute against base, returning "" for empty,
+// unparsable and non-http(s) sources.
+func resolveImage(base *url.URL, src string) string {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return ""
+	}
+	u, err := base.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
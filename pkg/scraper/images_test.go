@@ -0,0 +1,49 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScrapeImages(t *testing.T) {
+	body := `The release brings improvements to the toolchain, runtime and standard library in enough detail to pass extraction.</p>
+<p><img src="/img/chart.png" alt="chart"> The chart shows the speedup across benchmarks, which the rest of this paragraph explains at length.</p>
+<p><img src="data:image/gif;base64,R0lGOD" data-src="https://cdn.example/lazy.jpg"><img src="/img/chart.png"><img src="/og.jpg">
+And the lazy image shows the new logo, described here in enough words to be kept.`
+	page := strings.Replace(fakeArticlePage("Release Notes", body), "<head>",
+		`<head><meta property="og:image" content="/og.jpg">`, 1)
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer cleanup()
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/notes"}, Options{})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if pages[0].Image != "" || pages[0].Images != nil {
+		t.Errorf("images collected without Options.Images: %q %q", pages[0].Image, pages[0].Images)
+	}
+
+	for _, extractor := range []string{ExtractorReadability, ExtractorDensity} {
+		pages = ScrapeWithOptions(context.Background(), []string{serverURL + "/notes"}, Options{Images: true, Extractor: extractor})
+		p := pages[0]
+		if p.Err != nil {
+			t.Fatalf("%s: unexpected error: %v", extractor, p.Err)
+		}
+		if p.Image != serverURL+"/og.jpg" {
+			t.Errorf("%s: Image = %q, want the og:image", extractor, p.Image)
+		}
+		want := []string{serverURL + "/img/chart.png", "https://cdn.example/lazy.jpg"}
+		if extractor == ExtractorDensity {
+			want = nil // no article HTML to read
+		}
+		if !slices.Equal(p.Images, want) {
+			t.Errorf("%s: Images = %q, want %q", extractor, p.Images, want)
+		}
+	}
+}
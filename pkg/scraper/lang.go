@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// langScanLimit bounds how much text is examined for language detection.
+const langScanLimit = 4000
+
+// minStopwordHits is how many stopwords a Latin-script text needs before a
+// language is claimed from its stopword profile.
+const minStopwordHits = 5
+
+// stopwords are short, very frequent function words that rarely appear in
+// other languages using the same script.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "with", "for", "are", "this", "was", "be", "on", "you", "not", "have"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "sich", "auch", "auf", "für", "dem", "wird", "sind", "von"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "du", "dans", "que", "pour", "qui", "pas", "sur", "au", "avec", "sont", "ce"},
+	"es": {"el", "los", "las", "y", "es", "del", "que", "una", "por", "con", "para", "como", "su", "se", "al", "más", "pero", "está"},
+	"it": {"il", "di", "che", "è", "della", "per", "una", "gli", "sono", "con", "non", "del", "nel", "anche", "come", "alla", "questo", "più"},
+	"pt": {"o", "os", "que", "é", "do", "da", "em", "um", "uma", "para", "com", "não", "dos", "das", "mais", "como", "ao", "são"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "zijn", "op", "te", "met", "voor", "ook", "wordt", "deze", "maar", "bij"},
+	"sv": {"och", "att", "det", "som", "är", "en", "för", "på", "med", "inte", "av", "den", "till", "har", "om", "ett", "kan", "jag"},
+	"pl": {"i", "w", "nie", "się", "na", "jest", "że", "do", "z", "to", "jak", "dla", "są", "od", "po", "ale", "tak", "przez"},
+}
+
+var stopwordIndex = func() map[string][]string {
+	idx := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// detectLanguage returns an ISO 639-1 code for text, or "" when unsure.
+// Non-Latin scripts are identified by their Unicode script; Latin text by
+// stopword frequency. The page's declared language (<html lang>) is used
+// only when the text itself is inconclusive, since many sites declare "en"
+// regardless of content.
+func detectLanguage(text, declared string) string {
+	if len(text) > langScanLimit {
+		text = text[:langScanLimit]
+	}
+	if lang := scriptLanguage(text); lang != "" {
+		return lang
+	}
+	if lang := stopwordLanguage(text); lang != "" {
+		return lang
+	}
+	return normalizeLang(declared)
+}
+
+// scriptLanguage identifies languages by writing system.
+func scriptLanguage(text string) string {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			counts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				counts["uk"]++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	// Japanese mixes kana with Han characters.
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] > letters/3 {
+		return "ja"
+	}
+	if counts["ru"] > letters/3 && counts["uk"] > 0 {
+		return "uk"
+	}
+	best, bestN := "", 0
+	for lang, n := range counts {
+		if lang != "uk" && lang != "ja" && n > bestN {
+			best, bestN = lang, n
+		}
+	}
+	if bestN > letters/3 {
+		return best
+	}
+	return ""
+}
+
+// stopwordLanguage picks the Latin-script language whose stopwords occur
+// most often, requiring a clear winner.
+func stopwordLanguage(text string) string {
+	hits := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range stopwordIndex[w] {
+			hits[lang]++
+		}
+	}
+	best, bestN, secondN := "", 0, 0
+	for lang, n := range hits {
+		if n > bestN {
+			best, bestN, secondN = lang, n, bestN
+		} else if n > secondN {
+			secondN = n
+		}
+	}
+	if bestN < minStopwordHits || float64(bestN) < 1.5*float64(secondN) {
+		return ""
+	}
+	return best
+}
+
+// normalizeLang reduces a BCP 47 tag such as "en-US" to its primary subtag.
+func normalizeLang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
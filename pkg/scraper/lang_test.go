@@ -0,0 +1,33 @@
+package scraper
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		declared string
+		want     string
+	}{
+		{"english", "The quick brown fox jumps over the lazy dog, and it is not the first time that this has happened to the dog.", "", "en"},
+		{"german", "Die Katze ist nicht auf dem Tisch, und der Hund wird sich auch nicht mit den Kindern beschäftigen.", "en", "de"},
+		{"french", "Le chat est sur la table et les enfants sont dans le jardin avec des amis qui ne sont pas là.", "", "fr"},
+		{"spanish", "El perro y los gatos están en la casa con una familia que es muy grande para su edad.", "", "es"},
+		{"dutch", "De kat is niet op het dak, maar de hond wordt ook met een bal van de buren gezien.", "", "nl"},
+		{"russian", "Москва является столицей России и крупнейшим городом страны.", "en", "ru"},
+		{"ukrainian", "Київ є столицею України і найбільшим містом країни.", "", "uk"},
+		{"japanese", "東京は日本の首都であり、世界最大の都市圏の一つです。", "", "ja"},
+		{"chinese", "北京是中华人民共和国的首都，也是全国的政治和文化中心。", "", "zh"},
+		{"korean", "서울은 대한민국의 수도이자 최대 도시이다.", "", "ko"},
+		{"too_short_uses_declared", "Hello world", "en-GB", "en"},
+		{"unknown", "Lorem ipsum dolor sit amet", "", ""},
+		{"invalid_declared", "", "x1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.text, tt.declared); got != tt.want {
+				t.Errorf("detectLanguage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
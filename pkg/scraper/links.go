@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxLinks bounds ScrapedPage.Links; portal pages can carry thousands.
+const maxLinks = 200
+
+// Link is an anchor found on a scraped page.
+type Link struct {
+	URL  string // absolute, without fragment
+	Text string // anchor text, or its title attribute if the text is empty
+}
+
+// setLinks fills page.Links from the <a href> elements of the whole
+// document, not just the article, since a landing page's way to the real
+// content is often outside whatever readability picked. URLs are resolved
+// against base; fragments are dropped, and links back to the page itself
+// and to non-http(s) targets are skipped.
+func setLinks(page *ScrapedPage, data []byte, base string) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	self := *baseURL
+	self.Fragment, self.RawFragment = "", ""
+	seen := map[string]bool{self.String(): true}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if len(page.Links) >= maxLinks {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			u, err := baseURL.Parse(strings.TrimSpace(attr(n, "href")))
+			if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				u.Fragment, u.RawFragment = "", ""
+				if s := u.String(); !seen[s] {
+					seen[s] = true
+					text := oneLine(textOf(n))
+					if text == "" {
+						text = oneLine(attr(n, "title"))
+					}
+					page.Links = append(page.Links, Link{URL: s, Text: text})
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
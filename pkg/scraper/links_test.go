@@ -0,0 +1,27 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetLinks(t *testing.T) {
+	const doc = `<html><body>
+<nav><a href="/docs/">Docs</a></nav>
+<article>
+<p>See the <a href="tutorial/generics#intro">generics
+  tutorial</a> and the <a href="https://pkg.go.dev/slices" title="slices package"><img src="x.png"></a>.</p>
+<p><a href="#top">Top</a> <a href="mailto:a@example.com">Mail</a> <a href="/docs/">Docs again</a>
+<a href="https://go.dev/learn/">This page</a></p>
+</article></body></html>`
+	var page ScrapedPage
+	setLinks(&page, []byte(doc), "https://go.dev/learn/")
+	want := []Link{
+		{URL: "https://go.dev/docs/", Text: "Docs"},
+		{URL: "https://go.dev/learn/tutorial/generics", Text: "generics tutorial"},
+		{URL: "https://pkg.go.dev/slices", Text: "slices package"},
+	}
+	if !reflect.DeepEqual(page.Links, want) {
+		t.Errorf("Links = %+v, want %+v", page.Links, want)
+	}
+}
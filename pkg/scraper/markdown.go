@@ -0,0 +1,335 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Output formats for Options.Output.
+const (
+	OutputText     = "text"     // flattened plain text (default)
+	OutputMarkdown = "markdown" // Markdown converted from the extracted HTML
+)
+
+// ValidOutput reports whether name is a known output format. The empty
+// string selects the default.
+func ValidOutput(name string) bool {
+	switch name {
+	case "", OutputText, OutputMarkdown:
+		return true
+	}
+	return false
+}
+
+var rxWhitespace = regexp.MustCompile(`[ \t\r\n\f\v]+`)
+
+// toMarkdown converts extracted article HTML to Markdown, keeping headings,
+// lists, links, emphasis, block quotes, tables and code blocks. Images and
+// interactive elements are dropped.
+func toMarkdown(src string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return "", fmt.Errorf("parse html: %w", err)
+	}
+	var w mdWriter
+	w.children(doc)
+	return w.String(), nil
+}
+
+// mdWriter accumulates Markdown. Block elements are separated by one blank
+// line; nested constructs are rendered by a fresh writer and then indented
+// or prefixed as a whole.
+type mdWriter struct {
+	b strings.Builder
+}
+
+// String returns the Markdown with trailing spaces and runs of blank lines
+// removed outside code blocks.
+func (w *mdWriter) String() string {
+	var out []string
+	inFence, blank := false, true
+	for _, line := range strings.Split(w.b.String(), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		isFence := strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+		if inFence && !isFence {
+			out = append(out, line)
+			continue
+		}
+		if isFence {
+			inFence = !inFence
+		}
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+func (w *mdWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+// text writes a text node with HTML whitespace collapsed.
+func (w *mdWriter) text(s string) {
+	s = rxWhitespace.ReplaceAllString(s, " ")
+	if w.atLineStart() || strings.HasSuffix(w.b.String(), " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	w.b.WriteString(s)
+}
+
+// block ends the current block with a blank line.
+func (w *mdWriter) block() {
+	s := w.b.String()
+	switch {
+	case s == "" || strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		w.b.WriteString("\n")
+	default:
+		w.b.WriteString("\n\n")
+	}
+}
+
+// raw writes pre-rendered Markdown as a block of its own.
+func (w *mdWriter) raw(s string) {
+	if s == "" {
+		return
+	}
+	w.block()
+	w.b.WriteString(s)
+	w.block()
+}
+
+func (w *mdWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+func (w *mdWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Svg, atom.Form, atom.Button, atom.Iframe, atom.Img:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		if t := oneLine(inner(n)); t != "" {
+			level := int(n.Data[1] - '0')
+			w.raw(strings.Repeat("#", level) + " " + t)
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer,
+		atom.Figure, atom.Figcaption, atom.Dl, atom.Dt, atom.Dd, atom.Details, atom.Summary:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.b.WriteString("\n")
+	case atom.Hr:
+		w.raw("* * *") // "---" would read as a section separator
+	case atom.Pre:
+		w.raw(codeBlock(n))
+	case atom.Code:
+		w.inline(inlineCode(textOf(n)))
+	case atom.Strong, atom.B:
+		if t := oneLine(inner(n)); t != "" {
+			w.inline("**" + t + "**")
+		}
+	case atom.Em, atom.I:
+		if t := oneLine(inner(n)); t != "" {
+			w.inline("*" + t + "*")
+		}
+	case atom.A:
+		w.inline(link(n))
+	case atom.Ul, atom.Ol:
+		w.raw(list(n))
+	case atom.Blockquote:
+		w.raw(prefixLines(inner(n), "> "))
+	case atom.Table:
+		w.raw(table(n))
+	default:
+		w.children(n)
+	}
+}
+
+// inline writes a rendered inline element.
+func (w *mdWriter) inline(s string) {
+	if s != "" {
+		w.b.WriteString(s)
+	}
+}
+
+// inner renders n's children on their own.
+func inner(n *html.Node) string {
+	var sub mdWriter
+	sub.children(n)
+	return sub.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// textOf returns the raw text under n, keeping whitespace and line breaks.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func inlineCode(s string) string {
+	s = oneLine(s)
+	if s == "" {
+		return ""
+	}
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// link renders an anchor, dropping targets that mean nothing outside the
+// page.
+func link(n *html.Node) string {
+	text := oneLine(inner(n))
+	if text == "" {
+		return ""
+	}
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return text
+	}
+	href = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(href)
+	return "[" + text + "](" + href + ")"
+}
+
+// list renders <ul>/<ol> items, indenting continuation lines under their
+// marker so nested lists stay nested.
+func list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	num := 1
+	if s, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = s
+	}
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		body := inner(c)
+		if body == "" {
+			continue
+		}
+		for strings.Contains(body, "\n\n") {
+			body = strings.ReplaceAll(body, "\n\n", "\n")
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		items = append(items, marker+strings.ReplaceAll(body, "\n", "\n"+strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func prefixLines(s, prefix string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// table renders a table as a pipe table whose first row is the header.
+func table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom == atom.Table {
+				continue // nested tables are not representable
+			}
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					row = append(row, strings.ReplaceAll(oneLine(inner(cell)), "|", `\|`))
+				}
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	var b strings.Builder
+	for i, r := range rows {
+		for len(r) < cols {
+			r = append(r, "")
+		}
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
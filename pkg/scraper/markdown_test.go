@@ -0,0 +1,94 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "headings_and_paragraphs",
+			html: "<h2>Install</h2>\n<p>Run the   <strong>installer</strong>\n and <em>wait</em>.</p>",
+			want: "## Install\n\nRun the **installer** and *wait*.",
+		},
+		{
+			name: "links",
+			html: `<p>See <a href="https://go.dev/doc">the docs</a>, <a href="#top">top</a> and <a href="javascript:void(0)">menu</a>.</p>`,
+			want: "See [the docs](https://go.dev/doc), top and menu.",
+		},
+		{
+			name: "nested_lists",
+			html: `<ul><li>One</li><li>Two<ol start="3"><li>Three</li><li>Four</li></ol></li></ul>`,
+			want: "- One\n- Two\n  3. Three\n  4. Four",
+		},
+		{
+			name: "code",
+			html: "<p>Call <code>fmt.Println</code>:</p><pre><code class=\"language-go\">func main() {\n\n\tfmt.Println(\"hi\")\n}\n</code></pre>",
+			want: "Call `fmt.Println`:\n\n```go\nfunc main() {\n\n\tfmt.Println(\"hi\")\n}\n```",
+		},
+		{
+			name: "blockquote",
+			html: `<blockquote><p>First</p><p>Second</p></blockquote>`,
+			want: "> First\n>\n> Second",
+		},
+		{
+			name: "table",
+			html: `<table><tr><th>Name</th><th>Type</th></tr><tr><td>a|b</td><td>int</td></tr></table>`,
+			want: "| Name | Type |\n| --- | --- |\n| a\\|b | int |",
+		},
+		{
+			name: "dropped_elements",
+			html: `<p>Text<img src="x.png" alt="pic"><script>alert(1)</script></p><hr><p>After</p>`,
+			want: "Text\n\n* * *\n\nAfter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toMarkdown(tt.html)
+			if err != nil {
+				t.Fatalf("toMarkdown: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("toMarkdown =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeMarkdownOutput(t *testing.T) {
+	body := `This article explains the installation steps in enough detail to pass extraction.</p>
+<h2>Steps</h2>
+<ul><li>Download the <a href="https://example.com/pkg">package</a></li><li>Run the installer</li></ul>
+<p>That is all there is to it, and the rest of the article says so at length.`
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Install Guide", body)))
+	}))
+	defer cleanup()
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/guide"}, Options{Output: OutputMarkdown})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if !pages[0].Markdown {
+		t.Fatal("Markdown should be set")
+	}
+	for _, want := range []string{"## Steps", "- Download the [package](https://example.com/pkg)"} {
+		if !strings.Contains(pages[0].Content, want) {
+			t.Errorf("content missing %q:\n%s", want, pages[0].Content)
+		}
+	}
+
+	// The density backend has no HTML to convert and stays plain text.
+	pages = ScrapeWithOptions(context.Background(), []string{serverURL + "/guide"}, Options{Output: OutputMarkdown, Extractor: ExtractorDensity})
+	if pages[0].Err != nil || pages[0].Markdown {
+		t.Errorf("density page = %+v, want plain text", pages[0])
+	}
+}
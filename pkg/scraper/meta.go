@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"io"
+	"strings"
+
+	readability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// pageMeta holds the descriptive tags a page declares in its <head>.
+type pageMeta struct {
+	title       string
+	author      string
+	siteName    string
+	description string
+	image       string
+	robots      string // robots meta tags for every agent or RobotsAgent, joined with commas
+}
+
+// Meta tag names in order of preference, keyed by the field they fill.
+var (
+	metaTitle       = []string{"og:title", "twitter:title"}
+	metaAuthor      = []string{"author", "article:author", "twitter:creator"}
+	metaSiteName    = []string{"og:site_name", "application-name"}
+	metaDescription = []string{"description", "og:description", "twitter:description"}
+	metaImage       = []string{"og:image", "og:image:url", "twitter:image"}
+)
+
+// readMeta collects OpenGraph and standard <meta> tags from an HTML
+// document's head. It stops at <body>, so it stays cheap on large pages.
+func readMeta(r io.Reader) pageMeta {
+	values := map[string]string{}
+	var docTitle strings.Builder
+	var robots []string
+	inTitle := false
+	done := func() pageMeta {
+		m := resolveMeta(values, docTitle.String())
+		m.robots = strings.Join(robots, ",")
+		return m
+	}
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return done()
+		case html.TextToken:
+			if inTitle {
+				docTitle.Write(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return done()
+			case atom.Title:
+				inTitle = tt == html.StartTagToken
+			case atom.Meta:
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "name", "property":
+						key = strings.ToLower(strings.TrimSpace(string(v)))
+					case "content":
+						content = oneLine(string(v))
+					}
+				}
+				if key == "robots" || key == RobotsAgent {
+					robots = append(robots, content)
+				}
+				if _, seen := values[key]; key != "" && content != "" && !seen {
+					values[key] = content
+				}
+			}
+		}
+	}
+}
+
+func resolveMeta(values map[string]string, docTitle string) pageMeta {
+	first := func(keys []string) string {
+		for _, k := range keys {
+			if v := values[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	m := pageMeta{
+		title:       first(metaTitle),
+		siteName:    first(metaSiteName),
+		description: first(metaDescription),
+		image:       first(metaImage),
+	}
+	if m.title == "" {
+		m.title = oneLine(docTitle)
+	}
+	for _, k := range metaAuthor {
+		// article:author is often a profile URL rather than a name.
+		if v := values[k]; v != "" && !strings.Contains(v, "://") {
+			m.author = v
+			break
+		}
+	}
+	return m
+}
+
+// setMeta fills a page's descriptive fields, preferring readability's
+// metadata (which already folds in JSON-LD and OpenGraph) and falling back
+// to the raw tags for backends that produce none. The description comes
+// from the tags only: readability's excerpt falls back to the first
+// paragraph, which repeats the content.
+func setMeta(page *ScrapedPage, article readability.Article, meta pageMeta) {
+	if page.Title == "" {
+		page.Title = meta.title
+	}
+	page.Author = cleanByline(article.Byline)
+	if page.Author == "" {
+		page.Author = meta.author
+	}
+	page.SiteName = oneLine(article.SiteName)
+	if page.SiteName == "" {
+		page.SiteName = meta.siteName
+	}
+	page.Description = meta.description
+}
+
+// cleanByline strips the "By" that bylines usually start with.
+func cleanByline(s string) string {
+	s = oneLine(s)
+	if len(s) > 3 && strings.EqualFold(s[:3], "by ") {
+		s = strings.TrimSpace(s[3:])
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want pageMeta
+	}{
+		{
+			name: "opengraph",
+			html: `<html><head><title>Fallback</title>
+<meta property="og:title" content="Go 1.24 Released">
+<meta property="og:site_name" content="The Go Blog">
+<meta property="og:description" content="What is new &amp; improved.">
+<meta name="author" content="  Jane
+  Doe ">
+</head><body><p>Text</p></body></html>`,
+			want: pageMeta{title: "Go 1.24 Released", author: "Jane Doe", siteName: "The Go Blog", description: "What is new & improved."},
+		},
+		{
+			name: "standard_tags_win_and_first_seen_kept",
+			html: `<head><meta name="description" content="Plain"><meta name="description" content="Second">
+<meta property="og:description" content="Social"></head>`,
+			want: pageMeta{description: "Plain"},
+		},
+		{
+			name: "title_element_and_author_url_skipped",
+			html: `<head><title> Just a title </title><meta property="article:author" content="https://example.com/jane"></head>`,
+			want: pageMeta{title: "Just a title"},
+		},
+		{
+			name: "body_tags_ignored",
+			html: `<head></head><body><meta name="description" content="In body"></body>`,
+			want: pageMeta{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readMeta(strings.NewReader(tt.html)); got != tt.want {
+				t.Errorf("readMeta = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanByline(t *testing.T) {
+	for in, want := range map[string]string{
+		"By Jane Doe":   "Jane Doe",
+		"by  jane":      "jane",
+		"Byron Smith":   "Byron Smith",
+		" Staff Writer": "Staff Writer",
+		"":              "",
+	} {
+		if got := cleanByline(in); got != want {
+			t.Errorf("cleanByline(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestScrapeMetadata(t *testing.T) {
+	body := `The release brings improvements to the toolchain, runtime and standard library in enough detail to pass extraction.`
+	page := strings.Replace(fakeArticlePage("Release Notes", body), "<head>", `<head>
+<meta property="og:site_name" content="Example News">
+<meta name="description" content="Highlights of the release.">
+<meta name="author" content="Jane Doe">`, 1)
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer cleanup()
+
+	for _, extractor := range []string{ExtractorReadability, ExtractorDensity} {
+		pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/notes"}, Options{Extractor: extractor})
+		p := pages[0]
+		if p.Err != nil {
+			t.Fatalf("%s: unexpected error: %v", extractor, p.Err)
+		}
+		if p.Title != "Release Notes" || p.Author != "Jane Doe" || p.SiteName != "Example News" || p.Description != "Highlights of the release." {
+			t.Errorf("%s: metadata = title %q author %q site %q description %q",
+				extractor, p.Title, p.Author, p.SiteName, p.Description)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	readability "github.com/go-shiori/go-readability"
+	"github.com/ledongthuc/pdf"
+)
+
+// ExtractorPDF is reported in ScrapedPage.Extractor for PDF documents.
+const ExtractorPDF = "pdf"
+
+// maxPDFBytes caps how much of a PDF is downloaded; the parser needs the
+// whole file in memory.
+const maxPDFBytes = 20 << 20
+
+// pdfMagic is the signature every PDF file starts with.
+const pdfMagic = "%PDF-"
+
+// isPDF reports whether a response is a PDF, by Content-Type or, for
+// servers that send application/octet-stream, by the file signature.
+func isPDF(contentType string, head []byte) bool {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && mt == "application/pdf" {
+		return true
+	}
+	return bytes.HasPrefix(head, []byte(pdfMagic))
+}
+
+// extractPDF returns the plain text of a PDF along with its title and
+// creation date from the document info dictionary, when present.
+func extractPDF(r io.Reader) (article readability.Article, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxPDFBytes+1))
+	if err != nil {
+		return article, err
+	}
+	if len(data) > maxPDFBytes {
+		return article, fmt.Errorf("pdf larger than %d bytes", maxPDFBytes)
+	}
+
+	// The parser panics on some malformed files.
+	defer func() {
+		if p := recover(); p != nil {
+			article, err = readability.Article{}, fmt.Errorf("parse pdf: %v", p)
+		}
+	}()
+
+	doc, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return article, fmt.Errorf("parse pdf: %w", err)
+	}
+
+	var pages []string
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= doc.NumPage(); i++ {
+		p := doc.Page(i)
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+		text, err := p.GetPlainText(fonts)
+		if err != nil {
+			// Skip unreadable pages rather than losing the whole document.
+			continue
+		}
+		if text = cleanPDFText(text); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	article.TextContent = strings.Join(pages, "\n\n")
+
+	info := doc.Trailer().Key("Info")
+	article.Title = strings.TrimSpace(info.Key("Title").Text())
+	if t, ok := parsePDFDate(info.Key("CreationDate").Text()); ok {
+		article.PublishedTime = &t
+	}
+	return article, nil
+}
+
+// cleanPDFText collapses runs of spaces and drops blank lines.
+func cleanPDFText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = cleanText(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parsePDFDate parses the date portion of a PDF date string such as
+// "D:20210315120000+01'00'".
+func parsePDFDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	if len(s) < 8 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102", s[:8])
+	if err != nil || !plausible(t) {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,134 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makePDF builds a minimal single-page PDF showing each line with the
+// standard Helvetica font, with a correct cross-reference table.
+func makePDF(title string, lines ...string) []byte {
+	var content strings.Builder
+	content.WriteString("BT /F1 12 Tf 72 720 Td 14 TL\n")
+	for _, l := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", l)
+	}
+	content.WriteString("ET")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		fmt.Sprintf("<< /Title (%s) /CreationDate (D:20210315120000Z) >>", title),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	data := makePDF("A Study of Things", "Abstract: we study things.", "Results are significant.")
+	article, err := extractPDF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extractPDF: %v", err)
+	}
+	for _, want := range []string{"we study things", "Results are significant"} {
+		if !strings.Contains(article.TextContent, want) {
+			t.Errorf("text %q missing %q", article.TextContent, want)
+		}
+	}
+	if article.Title != "A Study of Things" {
+		t.Errorf("Title = %q", article.Title)
+	}
+	if article.PublishedTime == nil || !article.PublishedTime.Equal(time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PublishedTime = %v, want 2021-03-15", article.PublishedTime)
+	}
+}
+
+func TestExtractPDFMalformed(t *testing.T) {
+	if _, err := extractPDF(strings.NewReader("%PDF-1.4\nnot really a pdf")); err == nil {
+		t.Fatal("expected error for malformed PDF")
+	}
+}
+
+func TestIsPDF(t *testing.T) {
+	tests := []struct {
+		contentType string
+		head        string
+		want        bool
+	}{
+		{"application/pdf", "", true},
+		{"application/pdf; charset=binary", "", true},
+		{"application/octet-stream", "%PDF-1.7", true},
+		{"text/html; charset=utf-8", "<!DOCTYPE", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := isPDF(tt.contentType, []byte(tt.head)); got != tt.want {
+			t.Errorf("isPDF(%q, %q) = %v, want %v", tt.contentType, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestScrapePDF(t *testing.T) {
+	data := makePDF("Whitepaper", "This whitepaper describes the protocol in detail.")
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/paper.pdf"})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if pages[0].Extractor != ExtractorPDF {
+		t.Errorf("Extractor = %q, want %q", pages[0].Extractor, ExtractorPDF)
+	}
+	if !strings.Contains(pages[0].Content, "describes the protocol") {
+		t.Errorf("content = %q", pages[0].Content)
+	}
+	if pages[0].Published.Source != DateSourceMetadata {
+		t.Errorf("Published = %+v, want metadata date", pages[0].Published)
+	}
+}
+
+func TestScrapeOctetStreamPDFNotTruncated(t *testing.T) {
+	data := makePDF("Spec", "A PDF served without its proper content type.")
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	}))
+	defer cleanup()
+
+	// The HTML body cap is far smaller than the file; the PDF cap applies.
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/spec"}, Options{MaxBodyBytes: 64})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if pages[0].Truncated {
+		t.Error("PDF should not be truncated at the HTML body cap")
+	}
+	if !strings.Contains(pages[0].Content, "without its proper content type") {
+		t.Errorf("content = %q", pages[0].Content)
+	}
+}
@@ -0,0 +1,176 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrPrivateAddress is the error of a fetch refused by Options.BlockPrivate
+// because the host resolves to a loopback, private, link-local or otherwise
+// internal address.
+var ErrPrivateAddress = errors.New("private address")
+
+// sharedAddressSpace is carrier-grade NAT space, internal to providers.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// ParseNets parses a comma-separated list of CIDR prefixes and bare IP
+// addresses, such as "10.1.0.0/16,192.168.1.5", for Options.AllowedNets.
+func ParseNets(s string) ([]netip.Prefix, error) {
+	var nets []netip.Prefix
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !strings.Contains(f, "/") {
+			addr, err := netip.ParseAddr(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", f)
+			}
+			nets = append(nets, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", f)
+		}
+		nets = append(nets, p.Masked())
+	}
+	return nets, nil
+}
+
+// internalAddr reports whether addr is outside the public internet.
+func internalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// checkAddr refuses an internal addr unless a network in allowed holds it.
+func checkAddr(addr netip.Addr, allowed []netip.Prefix) error {
+	addr = addr.Unmap()
+	if !internalAddr(addr) {
+		return nil
+	}
+	for _, p := range allowed {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", addr, ErrPrivateAddress)
+}
+
+// checkHost resolves rawURL's host and refuses it if any address is
+// internal. Dials are checked again, so a host that resolves differently
+// the second time gains nothing; this covers fetches the scraper does not
+// dial itself, such as through a proxy or a headless browser.
+func checkHost(ctx context.Context, rawURL string, allowed []netip.Prefix) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil // the fetch reports the bad URL
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		return checkAddr(addr, allowed)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return nil // the fetch reports the lookup failure
+	}
+	for _, addr := range addrs {
+		if err := checkAddr(addr, allowed); err != nil {
+			return fmt.Errorf("%s: %w", u.Hostname(), err)
+		}
+	}
+	return nil
+}
+
+type (
+	netPolicyKey struct{} // []netip.Prefix allowed when BlockPrivate is set
+	dialProxyKey struct{} // host:port of the proxy a request goes through
+)
+
+// withNetPolicy records Options.BlockPrivate and AllowedNets for requests
+// made with ctx; the transport's dialer reads them back.
+func withNetPolicy(ctx context.Context, opts Options) context.Context {
+	if !opts.BlockPrivate {
+		return ctx
+	}
+	return context.WithValue(ctx, netPolicyKey{}, opts.AllowedNets)
+}
+
+// netPolicy returns the internal networks ctx allows and whether internal
+// addresses are blocked at all.
+func netPolicy(ctx context.Context) ([]netip.Prefix, bool) {
+	allowed, ok := ctx.Value(netPolicyKey{}).([]netip.Prefix)
+	return allowed, ok
+}
+
+// guardedTransport checks the hosts of proxied requests, which the proxy
+// rather than the dialer resolves, and tells the dialer which address is
+// the proxy's: an operator-configured proxy may well be internal.
+type guardedTransport struct {
+	*http.Transport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	allowed, block := netPolicy(req.Context())
+	if !block {
+		return t.Transport.RoundTrip(req)
+	}
+	proxy, err := requestProxy(req)
+	if err != nil || proxy == nil {
+		return t.Transport.RoundTrip(req)
+	}
+	if err := checkHost(req.Context(), req.URL.String(), allowed); err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(req.Context(), dialProxyKey{}, canonicalAddr(proxy))
+	return t.Transport.RoundTrip(req.WithContext(ctx))
+}
+
+// canonicalAddr returns u's host:port, with the scheme's default port.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// guardedDial dials like the default transport, refusing internal
+// addresses after resolution when the request blocks them, so neither DNS
+// tricks nor redirects reach an internal service. The request's proxy is
+// exempt.
+func guardedDial(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		allowed, block := netPolicy(ctx)
+		if !block || ctx.Value(dialProxyKey{}) == addr {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		d := *dialer
+		d.Control = func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return checkAddr(ap.Addr(), allowed)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,94 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseNets(t *testing.T) {
+	nets, err := ParseNets(" 10.1.2.3/16, 192.168.1.5 ,fd00::/8,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.1.0.0/16"),
+		netip.MustParsePrefix("192.168.1.5/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	if !reflect.DeepEqual(nets, want) {
+		t.Errorf("ParseNets = %v, want %v", nets, want)
+	}
+	for _, bad := range []string{"10.0.0.0/33", "intranet", "10.0.0"} {
+		if _, err := ParseNets(bad); err == nil {
+			t.Errorf("ParseNets(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	allowed := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}
+	for addr, ok := range map[string]bool{
+		"93.184.216.34":      true,
+		"2606:2800:220:1::1": true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.0.0.1":           false,
+		"10.1.4.4":           true, // allowed
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"::ffff:127.0.0.1":   false,
+	} {
+		err := checkAddr(netip.MustParseAddr(addr), allowed)
+		if (err == nil) != ok || (err != nil && !errors.Is(err, ErrPrivateAddress)) {
+			t.Errorf("checkAddr(%s) = %v, want ok=%v", addr, err, ok)
+		}
+	}
+}
+
+func TestBlockPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Internal", "This page lives on an internal address and should only be read when allowed.")))
+	}))
+	defer srv.Close()
+	ctx := WithHTTPClient(context.Background(), &http.Client{Transport: newTransport()})
+	opts := Options{HostDelay: -1, Retries: -1, Proxy: ProxyDirect, BlockPrivate: true}
+
+	page := ScrapeWithOptions(ctx, []string{srv.URL + "/a"}, opts)[0]
+	if !errors.Is(page.Err, ErrPrivateAddress) {
+		t.Errorf("blocked: err = %v, want ErrPrivateAddress", page.Err)
+	}
+
+	opts.AllowedNets = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	if page := ScrapeWithOptions(ctx, []string{srv.URL + "/a"}, opts)[0]; page.Err != nil {
+		t.Errorf("allowed: %v", page.Err)
+	}
+
+	// An internal proxy is fine; an internal target behind it is not.
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+	proxy := fakeProxy(t, "egress", &mu, &hosts)
+	opts = Options{HostDelay: -1, Retries: -1, Proxy: proxy.URL, BlockPrivate: true}
+	if page := ScrapeWithOptions(ctx, []string{"http://news.test/a"}, opts)[0]; page.Err != nil {
+		t.Errorf("through internal proxy: %v", page.Err)
+	}
+	if page := ScrapeWithOptions(ctx, []string{"http://169.254.169.254/latest/meta-data/"}, opts)[0]; !errors.Is(page.Err, ErrPrivateAddress) {
+		t.Errorf("metadata through proxy: err = %v, want ErrPrivateAddress", page.Err)
+	}
+	if len(hosts) != 1 {
+		t.Errorf("proxied requests = %q, want only news.test", hosts)
+	}
+}
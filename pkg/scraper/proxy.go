@@ -0,0 +1,84 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyDirect in Options.Proxy or DomainProxies fetches without a proxy,
+// ignoring HTTPS_PROXY and HTTP_PROXY.
+const ProxyDirect = "direct"
+
+// ValidProxy reports whether s can be used as Options.Proxy or a
+// DomainProxies value: empty, ProxyDirect, or an http, https or socks5 URL.
+func ValidProxy(s string) error {
+	_, _, err := parseProxy(s)
+	return err
+}
+
+// parseProxy resolves a proxy setting. set reports whether s decides the
+// proxy at all; a nil URL with set means a direct connection.
+func parseProxy(s string) (u *url.URL, set bool, err error) {
+	switch s {
+	case "":
+		return nil, false, nil
+	case ProxyDirect:
+		return nil, true, nil
+	}
+	u, err = url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, false, fmt.Errorf("invalid proxy %q: want a URL such as http://host:3128", s)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, true, nil
+	}
+	return nil, false, fmt.Errorf("invalid proxy %q: unsupported scheme %q", s, u.Scheme)
+}
+
+// proxyFor returns the proxy setting for a fetch of rawURL: the most
+// specific DomainProxies rule, else Options.Proxy.
+func proxyFor(opts Options, rawURL string) string {
+	if p, ok := domainRule(opts.DomainProxies, rawURL); ok {
+		return p
+	}
+	return opts.Proxy
+}
+
+// fetchViaGeoProxy refetches a geo-blocked page through Options.GeoProxy,
+// overriding every other proxy setting.
+func fetchViaGeoProxy(ctx context.Context, rawURL string, opts Options) ScrapedPage {
+	opts.Proxy, opts.DomainProxies = opts.GeoProxy, nil
+	return fetchWithRetry(ctx, rawURL, opts)
+}
+
+type proxyKey struct{}
+
+// withProxy records the proxy setting for requests made with ctx, along
+// with the internal address policy; the transport reads them back. Keeping
+// the choice in the context lets one pooled transport serve every per-call
+// and per-domain setting.
+func withProxy(ctx context.Context, opts Options, rawURL string) context.Context {
+	ctx = withNetPolicy(ctx, opts)
+	if p := proxyFor(opts, rawURL); p != "" {
+		return context.WithValue(ctx, proxyKey{}, p)
+	}
+	return ctx
+}
+
+// requestProxy is the scraper transport's Proxy func. Requests without a
+// setting follow the environment; an invalid one fails the request.
+func requestProxy(req *http.Request) (*url.URL, error) {
+	if s, ok := req.Context().Value(proxyKey{}).(string); ok {
+		u, set, err := parseProxy(s)
+		if err != nil {
+			return nil, err
+		}
+		if set {
+			return u, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProxyFor(t *testing.T) {
+	opts := Options{
+		Proxy: "http://egress:3128",
+		DomainProxies: map[string]string{
+			"example.org":       ProxyDirect,
+			"intra.example.org": "socks5://inner:1080",
+		},
+	}
+	for rawURL, want := range map[string]string{
+		"https://news.example.com/a":      "http://egress:3128",
+		"https://example.org/a":           ProxyDirect,
+		"https://www.example.org/a":       ProxyDirect,
+		"https://wiki.intra.example.org/": "socks5://inner:1080",
+	} {
+		if got := proxyFor(opts, rawURL); got != want {
+			t.Errorf("proxyFor(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestValidProxy(t *testing.T) {
+	for s, ok := range map[string]bool{
+		"":                     true,
+		ProxyDirect:            true,
+		"http://proxy:3128":    true,
+		"socks5://user:pw@h:1": true,
+		"ftp://proxy:21":       false,
+		"proxy:3128":           false,
+		"http://":              false,
+	} {
+		if err := ValidProxy(s); (err == nil) != ok {
+			t.Errorf("ValidProxy(%q) = %v, want ok=%v", s, err, ok)
+		}
+	}
+}
+
+// fakeProxy is an HTTP forward proxy that answers every request itself,
+// recording the hosts it was asked for.
+func fakeProxy(t *testing.T, name string, mu *sync.Mutex, hosts *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*hosts = append(*hosts, name+" "+r.URL.Host)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Via "+name, "This page was served through the "+name+" proxy, which stands in for the origin server in this test.")))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScrapeThroughProxy(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+	egress := fakeProxy(t, "egress", &mu, &hosts)
+	special := fakeProxy(t, "special", &mu, &hosts)
+	ctx := WithHTTPClient(context.Background(), &http.Client{Transport: newTransport()})
+
+	opts := Options{
+		HostDelay:     -1,
+		Retries:       -1,
+		Proxy:         egress.URL,
+		DomainProxies: map[string]string{"special.test": special.URL},
+	}
+	for _, rawURL := range []string{"http://news.test/a", "http://www.special.test/b"} {
+		page := ScrapeWithOptions(ctx, []string{rawURL}, opts)[0]
+		if page.Err != nil {
+			t.Fatalf("%s: %v", rawURL, page.Err)
+		}
+	}
+	if got := strings.Join(hosts, ", "); got != "egress news.test, special www.special.test" {
+		t.Errorf("proxied requests = %s", got)
+	}
+}
+
+func TestGeoProxyFallback(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unavailable For Legal Reasons", http.StatusUnavailableForLegalReasons)
+	}))
+	defer origin.Close()
+	geo := fakeProxy(t, "geo", &mu, &hosts)
+	ctx := WithHTTPClient(context.Background(), &http.Client{Transport: newTransport()})
+
+	opts := Options{HostDelay: -1, Retries: -1, Proxy: ProxyDirect}
+	page := ScrapeWithOptions(ctx, []string{origin.URL + "/story"}, opts)[0]
+	if page.Err == nil || !page.Blocked || page.BlockReason != BlockGeo || page.Via != "" {
+		t.Fatalf("without geo proxy: page = %+v, want a failed geo-blocked page", page)
+	}
+
+	opts.GeoProxy = geo.URL
+	page = ScrapeWithOptions(ctx, []string{origin.URL + "/story"}, opts)[0]
+	if page.Err != nil || !strings.Contains(page.Content, "geo proxy") {
+		t.Fatalf("with geo proxy: page = %+v, want the proxied content", page)
+	}
+	if page.BlockReason != BlockGeo || page.Via != ViaGeoProxy || page.Attempts != 2 {
+		t.Errorf("with geo proxy: BlockReason = %q, Via = %q, Attempts = %d", page.BlockReason, page.Via, page.Attempts)
+	}
+	if got := strings.Join(hosts, ", "); got != "geo "+strings.TrimPrefix(origin.URL, "http://") {
+		t.Errorf("proxied requests = %s", got)
+	}
+}
@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	readability "github.com/go-shiori/go-readability"
+)
+
+// ExtractorRaw is reported in ScrapedPage.Extractor for JSON, plain text
+// and XML responses, which are returned as text rather than extracted.
+const ExtractorRaw = "raw"
+
+// maxRawBytes caps the text kept from a raw response; API dumps and logs
+// run far past anything useful in a consolidated result.
+const maxRawBytes = 100 << 10
+
+// isRaw reports whether a Content-Type names a text format readability
+// cannot extract from: JSON, plain text and XML, including +json and +xml
+// types such as feeds. XHTML is HTML and is extracted as usual.
+func isRaw(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "application/json", "text/json", "text/plain", "text/markdown", "text/csv",
+		"application/xml", "text/xml":
+		return true
+	case "application/xhtml+xml":
+		return false
+	}
+	return strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+// extractRaw returns a raw response body as text. Valid JSON is
+// pretty-printed; anything longer than maxRawBytes is cut at a line or
+// character boundary, and truncated reports whether that happened.
+func extractRaw(data []byte, contentType string) (article readability.Article, truncated bool) {
+	if mt, _, _ := mime.ParseMediaType(contentType); mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json") {
+		var indented bytes.Buffer
+		if json.Indent(&indented, data, "", "  ") == nil {
+			data = indented.Bytes()
+		}
+	}
+	text := strings.ToValidUTF8(string(data), "�")
+	if len(text) > maxRawBytes {
+		cut := maxRawBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if i := strings.LastIndexByte(text[:cut], '\n'); i > maxRawBytes/2 {
+			cut = i
+		}
+		text, truncated = text[:cut], true
+	}
+	article.TextContent = strings.TrimSpace(text)
+	return article, truncated
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIsRaw(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json; charset=utf-8": true,
+		"text/plain":                      true,
+		"application/xml":                 true,
+		"application/rss+xml":             true,
+		"application/problem+json":        true,
+		"text/html; charset=utf-8":        false,
+		"application/xhtml+xml":           false,
+		"application/pdf":                 false,
+		"":                                false,
+	} {
+		if got := isRaw(contentType); got != want {
+			t.Errorf("isRaw(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestExtractRaw(t *testing.T) {
+	article, truncated := extractRaw([]byte(`{"name":"glsi","tags":["go"]}`), "application/json")
+	if want := "{\n  \"name\": \"glsi\",\n  \"tags\": [\n    \"go\"\n  ]\n}"; article.TextContent != want || truncated {
+		t.Errorf("json = %q (truncated %v), want %q", article.TextContent, truncated, want)
+	}
+
+	// Invalid JSON, e.g. a body cut at MaxBodyBytes, is kept as is.
+	article, _ = extractRaw([]byte(`{"name":`), "application/json")
+	if article.TextContent != `{"name":` {
+		t.Errorf("invalid json = %q", article.TextContent)
+	}
+
+	long := strings.Repeat("ünïcode line\n", maxRawBytes/10)
+	article, truncated = extractRaw([]byte(long), "text/plain")
+	if !truncated || len(article.TextContent) > maxRawBytes || !utf8.ValidString(article.TextContent) ||
+		!strings.HasSuffix(article.TextContent, "ünïcode line") {
+		t.Errorf("long text: truncated %v, %d bytes, ends %q", truncated, len(article.TextContent),
+			article.TextContent[max(0, len(article.TextContent)-20):])
+	}
+}
+
+func TestScrapeRawContent(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/gist":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("package main\n\nfunc main() {}\n"))
+		}
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/api", serverURL + "/gist"})
+	want := []string{"{\n  \"status\": \"ok\"\n}", "package main\n\nfunc main() {}"}
+	for i, p := range pages {
+		if p.Err != nil {
+			t.Fatalf("%s: unexpected error: %v", p.URL, p.Err)
+		}
+		if p.Extractor != ExtractorRaw || p.Content != want[i] {
+			t.Errorf("%s: extractor %q, content %q; want raw %q", p.URL, p.Extractor, p.Content, want[i])
+		}
+	}
+}
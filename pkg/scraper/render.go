@@ -0,0 +1,149 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Render modes for Options.Render.
+const (
+	RenderNever  = "never"  // static fetch only (default)
+	RenderAuto   = "auto"   // render when static extraction yields almost no text
+	RenderAlways = "always" // always render with the headless browser
+)
+
+const (
+	// minStaticTextChars is the extracted length below which RenderAuto
+	// retries a page in the headless browser.
+	minStaticTextChars = 200
+
+	// renderTimeout bounds a single headless render; browsers need far more
+	// time than a plain fetch.
+	renderTimeout = 20 * time.Second
+)
+
+// ValidRenderMode reports whether mode is a known render mode. The empty
+// string selects the default.
+func ValidRenderMode(mode string) bool {
+	switch mode {
+	case "", RenderNever, RenderAuto, RenderAlways:
+		return true
+	}
+	return false
+}
+
+// Renderer loads a URL in a JavaScript-capable browser and returns the
+// rendered DOM as HTML.
+type Renderer interface {
+	Render(ctx context.Context, rawURL string) (string, error)
+}
+
+// ChromeRenderer renders pages by running a local headless Chrome or
+// Chromium binary with --dump-dom.
+type ChromeRenderer struct {
+	Path string   // browser executable
+	Args []string // extra command-line flags
+}
+
+// chromeCandidates are executable names tried by NewChromeRenderer.
+var chromeCandidates = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome",
+}
+
+// NewChromeRenderer returns a renderer for the browser at path, or for the
+// first Chrome/Chromium found on PATH when path is empty.
+func NewChromeRenderer(path string) (*ChromeRenderer, error) {
+	if path != "" {
+		resolved, err := exec.LookPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("scraper: chrome %s: %w", path, err)
+		}
+		return &ChromeRenderer{Path: resolved}, nil
+	}
+	for _, name := range chromeCandidates {
+		if resolved, err := exec.LookPath(name); err == nil {
+			return &ChromeRenderer{Path: resolved}, nil
+		}
+	}
+	return nil, errors.New("scraper: no Chrome or Chromium executable found on PATH")
+}
+
+// Render implements Renderer. Only absolute http(s) URLs are accepted, so a
+// URL can never be read as a browser flag or point at a local file. The
+// browser sandbox stays on; containers that need --no-sandbox must set it
+// in Args.
+func (r *ChromeRenderer) Render(ctx context.Context, rawURL string) (string, error) {
+	if err := checkRenderURL(rawURL); err != nil {
+		return "", err
+	}
+	args := append([]string{
+		"--headless=new",
+		"--disable-gpu",
+		"--hide-scrollbars",
+		"--mute-audio",
+		"--virtual-time-budget=5000", // let client-side rendering settle
+		"--user-agent=" + userAgent,
+		"--dump-dom",
+	}, r.Args...)
+	args = append(args, rawURL)
+
+	cmd := exec.CommandContext(ctx, r.Path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("chrome: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// checkRenderURL rejects anything but an absolute http or https URL.
+func checkRenderURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || strings.HasPrefix(rawURL, "-") ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("chrome: refusing to render %q: not an absolute http(s) URL", rawURL)
+	}
+	return nil
+}
+
+// renderPage loads rawURL with the configured renderer and extracts it.
+func renderPage(ctx context.Context, rawURL string, opts Options) ScrapedPage {
+	page := ScrapedPage{URL: rawURL, Rendered: true}
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	html, err := opts.Renderer.Render(ctx, rawURL)
+	if err != nil {
+		page.Err = fmt.Errorf("render %s: %w", rawURL, err)
+		return page
+	}
+
+	article, extractor, err := extractPage(opts, rawURL, []byte(html))
+	if err != nil {
+		page.Err = fmt.Errorf("extract rendered %s: %w", rawURL, err)
+		return page
+	}
+	setArticle(&page, article, extractor, opts.Output)
+	meta := readMeta(strings.NewReader(html))
+	setMeta(&page, article, meta)
+	if opts.Images {
+		setImages(&page, article, meta)
+	}
+	if opts.Links {
+		setLinks(&page, []byte(html), rawURL)
+	}
+	setBlocked(&page, []byte(html), "")
+	page.Published = detectPublishDate(article, rawURL, "")
+	return page
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeRenderer returns canned HTML instead of launching a browser.
+type fakeRenderer struct {
+	html  string
+	err   error
+	calls int
+}
+
+func (f *fakeRenderer) Render(ctx context.Context, rawURL string) (string, error) {
+	f.calls++
+	return f.html, f.err
+}
+
+// spaShell is what a client-side rendered app serves before JavaScript runs.
+const spaShell = `<!DOCTYPE html><html><head><title>App</title></head><body><div id="root"></div><script src="/app.js"></script></body></html>`
+
+func TestRenderAuto(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(spaShell))
+	}))
+	defer cleanup()
+
+	r := &fakeRenderer{html: fakeArticlePage("Rendered Docs",
+		strings.Repeat("This documentation text only exists after client-side rendering. ", 5))}
+	opts := Options{Renderer: r, Render: RenderAuto}
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/app"}, opts)
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if r.calls != 1 {
+		t.Fatalf("renderer called %d times, want 1", r.calls)
+	}
+	if !pages[0].Rendered {
+		t.Error("page should be marked as rendered")
+	}
+	if !strings.Contains(pages[0].Content, "client-side rendering") {
+		t.Errorf("content = %q, want rendered text", pages[0].Content)
+	}
+}
+
+func TestRenderAutoSkipsRichPages(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Static",
+			strings.Repeat("This static article already has plenty of server-rendered text. ", 5))))
+	}))
+	defer cleanup()
+
+	r := &fakeRenderer{}
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/static"}, Options{Renderer: r, Render: RenderAuto})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if r.calls != 0 {
+		t.Errorf("renderer called %d times for a static page, want 0", r.calls)
+	}
+	if pages[0].Rendered {
+		t.Error("page should not be marked as rendered")
+	}
+}
+
+func TestRenderAutoFallsBackOnRenderError(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(spaShell))
+	}))
+	defer cleanup()
+
+	r := &fakeRenderer{err: errors.New("browser crashed")}
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/app"}, Options{Renderer: r, Render: RenderAuto})
+	if pages[0].Err != nil {
+		t.Fatalf("static result should be kept when rendering fails, got error: %v", pages[0].Err)
+	}
+	if pages[0].Rendered {
+		t.Error("page should not be marked as rendered")
+	}
+}
+
+func TestRenderAlways(t *testing.T) {
+	r := &fakeRenderer{html: fakeArticlePage("Always", "Rendered without any static fetch taking place first.")}
+	// No server: a static fetch would fail.
+	pages := ScrapeWithOptions(context.Background(), []string{"http://127.0.0.1:1/none"}, Options{Renderer: r, Render: RenderAlways})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if !pages[0].Rendered || !strings.Contains(pages[0].Content, "Rendered without") {
+		t.Errorf("unexpected page: %+v", pages[0])
+	}
+}
+
+func TestChromeRendererRejectsNonHTTPURLs(t *testing.T) {
+	// Path is never executed: every URL here must be refused first.
+	r := &ChromeRenderer{Path: "/nonexistent/chrome"}
+	for _, u := range []string{
+		"file:///etc/passwd",
+		"--remote-debugging-port=9222",
+		"-headless",
+		"javascript:alert(1)",
+		"/relative/path",
+		"http://",
+	} {
+		_, err := r.Render(context.Background(), u)
+		if err == nil || !strings.Contains(err.Error(), "refusing to render") {
+			t.Errorf("Render(%q) err = %v, want refusal", u, err)
+		}
+	}
+	if err := checkRenderURL("https://example.com/app"); err != nil {
+		t.Errorf("checkRenderURL(https) = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetries is how many times a transiently failing page is refetched
+// when Options.Retries is zero.
+const DefaultRetries = 2
+
+// Backoff bounds. Tests can shrink them.
+var (
+	retryBaseDelay = 250 * time.Millisecond
+	retryMaxDelay  = 4 * time.Second
+)
+
+// statusError reports a non-200 response.
+type statusError struct {
+	Code       int
+	URL        string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d for %s", e.Code, e.URL)
+}
+
+// retries resolves the retry count; negative disables retries.
+func retries(opts Options) int {
+	if opts.Retries == 0 {
+		return DefaultRetries
+	}
+	return max(opts.Retries, 0)
+}
+
+// fetchWithRetry calls fetchPage, retrying transient failures with
+// exponential backoff. The caller's host gate stays held throughout, so
+// backoff also spaces out requests to the struggling host.
+func fetchWithRetry(ctx context.Context, rawURL string, opts Options) ScrapedPage {
+	n := retries(opts)
+	for attempt := 0; ; attempt++ {
+		page := fetchPage(ctx, rawURL, opts)
+		page.Attempts = attempt + 1
+		if page.Err == nil || attempt >= n || !transient(ctx, page.Err) {
+			return page
+		}
+		var retryAfter time.Duration
+		var se *statusError
+		if errors.As(page.Err, &se) {
+			retryAfter = se.RetryAfter
+		}
+		delay := backoff(attempt, retryAfter)
+		logger(opts).DebugContext(ctx, "retrying page", "url", rawURL, "attempt", attempt+1, "delay", delay, "err", page.Err)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return page
+		}
+	}
+}
+
+// transient reports whether err is worth retrying: timeouts and
+// 429/502/503/504 responses, unless the caller's context is done.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		switch se.Code {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff returns the wait before retry number attempt+1: exponential with
+// jitter, raised to the server's Retry-After, and capped at retryMaxDelay.
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	d = d/2 + rand.N(d/2+1)
+	if retryAfter > d {
+		d = min(retryAfter, retryMaxDelay)
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
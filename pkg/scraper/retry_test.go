@@ -0,0 +1,139 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries shrinks backoff for the duration of a test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	base, maxDelay := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, maxDelay })
+}
+
+func TestScrapeRetries(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name         string
+		failures     int // responses with status before succeeding
+		status       int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"recovers_from_503", 2, http.StatusServiceUnavailable, 0, false, 3},
+		{"recovers_from_429", 1, http.StatusTooManyRequests, 0, false, 2},
+		{"gives_up", 5, http.StatusBadGateway, 0, true, 3},
+		{"configured_count", 3, http.StatusGatewayTimeout, 3, false, 4},
+		{"disabled", 1, http.StatusServiceUnavailable, -1, true, 1},
+		{"not_transient", 1, http.StatusNotFound, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(calls.Add(1)) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(fakeArticlePage("Flaky", "A page served by a host that sometimes fails under load.")))
+			}))
+			defer cleanup()
+
+			page := ScrapeWithOptions(context.Background(), []string{serverURL + "/flaky"}, Options{Retries: tt.retries, HostDelay: -1})[0]
+			if (page.Err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", page.Err, tt.wantErr)
+			}
+			if page.Attempts != tt.wantAttempts {
+				t.Errorf("Attempts = %d, want %d", page.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryLogging(t *testing.T) {
+	fastRetries(t)
+	var calls atomic.Int32
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Flaky", "A page served by a host that sometimes fails under load.")))
+	}))
+	defer cleanup()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	page := ScrapeWithOptions(context.Background(), []string{serverURL + "/flaky"}, Options{HostDelay: -1, Logger: logger})[0]
+	if page.Err != nil {
+		t.Fatal(page.Err)
+	}
+	if logs := buf.String(); !strings.Contains(logs, `msg="retrying page" url=`+serverURL+"/flaky attempt=1") || !strings.Contains(logs, "unexpected status 503") {
+		t.Errorf("logs = %s", logs)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	page := ScrapeWithOptions(ctx, []string{serverURL + "/busy"}, Options{HostDelay: -1})[0]
+	if page.Err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s; backoff should stop when the context is done", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		d := backoff(attempt, 0)
+		want := min(retryBaseDelay<<attempt, retryMaxDelay)
+		if d < want/2 || d > want {
+			t.Errorf("backoff(%d) = %s, want in [%s, %s]", attempt, d, want/2, want)
+		}
+	}
+	if d := backoff(0, 2*time.Second); d != 2*time.Second {
+		t.Errorf("Retry-After not honored: %s", d)
+	}
+	if d := backoff(0, time.Hour); d != retryMaxDelay {
+		t.Errorf("Retry-After not capped: %s", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0}, // in the past
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsAgent is the product token matched against robots.txt User-agent
+// lines when Options.RespectRobots is set. Groups for "*" apply when no
+// group names it.
+const RobotsAgent = "glsi"
+
+// robotsTTL is how long a host's parsed robots.txt is reused.
+const robotsTTL = time.Hour
+
+// maxRobotsBytes bounds how much of a robots.txt is read, as Google does.
+const maxRobotsBytes = 500 << 10
+
+// ErrRobotsDisallowed is the error of a page skipped because the site's
+// robots.txt disallows it.
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// ErrNoindex is the error of a page whose X-Robots-Tag header or robots
+// meta tag opts out of indexing or archiving, when Options.RespectNoindex
+// is set.
+var ErrNoindex = errors.New("page opts out of indexing")
+
+// headerRobots returns the X-Robots-Tag directives that apply to
+// RobotsAgent: those without a user agent and those naming it. A value
+// such as "googlebot: noindex, nofollow" applies to Googlebot only.
+func headerRobots(values []string) []string {
+	var directives []string
+	for _, v := range values {
+		applies := true
+		for _, d := range strings.Split(v, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if name, rest, ok := strings.Cut(d, ":"); ok && name != "unavailable_after" {
+				applies = strings.TrimSpace(name) == RobotsAgent
+				d = strings.TrimSpace(rest)
+			}
+			if applies && d != "" {
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives
+}
+
+// metaRobots splits the content of a robots meta tag into directives.
+func metaRobots(content string) []string {
+	var directives []string
+	for _, d := range strings.Split(content, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			directives = append(directives, d)
+		}
+	}
+	return directives
+}
+
+// optOut returns the first directive among directives that forbids using
+// the page's content ("noindex", "noarchive" or "none"), or "".
+func optOut(directives []string) string {
+	for _, d := range directives {
+		switch d {
+		case "noindex", "noarchive", "none":
+			return d
+		}
+	}
+	return ""
+}
+
+// robotsRules are the Allow and Disallow paths of the group that applies
+// to RobotsAgent.
+type robotsRules struct {
+	allow, disallow []string
+	fetched         time.Time
+}
+
+var (
+	robotsMu    sync.Mutex
+	robotsCache = map[string]robotsRules{} // keyed by scheme://host
+)
+
+// robotsAllowed reports whether robots.txt lets RobotsAgent fetch rawURL.
+// A missing robots.txt (any 4xx) allows everything; one that cannot be
+// read for another reason allows nothing, since compliance is the point.
+// Failures are not cached, so the next page tries again. The caller holds
+// the host's gate, so the robots.txt fetch is paced with the page fetches.
+func robotsAllowed(ctx context.Context, rawURL string, opts Options) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return true, nil // the fetch reports the bad URL
+	}
+	origin := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	robotsMu.Lock()
+	rules, ok := robotsCache[origin]
+	robotsMu.Unlock()
+	if !ok || time.Since(rules.fetched) > robotsTTL {
+		if rules, err = fetchRobots(ctx, origin, opts); err != nil {
+			return false, err
+		}
+		robotsMu.Lock()
+		robotsCache[origin] = rules
+		robotsMu.Unlock()
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allows(path), nil
+}
+
+// fetchRobots downloads and parses origin's robots.txt.
+func fetchRobots(ctx context.Context, origin string, opts Options) (robotsRules, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	robotsURL := origin + "/robots.txt"
+	req, err := http.NewRequestWithContext(withProxy(ctx, opts, robotsURL), http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("fetch %s: %w", robotsURL, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{fetched: time.Now()}, nil
+	case resp.StatusCode != http.StatusOK:
+		return robotsRules{}, &statusError{Code: resp.StatusCode, URL: robotsURL}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+	if err != nil {
+		return robotsRules{}, fmt.Errorf("read %s: %w", robotsURL, err)
+	}
+	rules := parseRobots(data, RobotsAgent)
+	rules.fetched = time.Now()
+	return rules, nil
+}
+
+// parseRobots returns the rules of the groups naming agent, or of the "*"
+// groups if none does. Consecutive User-agent lines share one group.
+func parseRobots(data []byte, agent string) robotsRules {
+	var named, star robotsRules
+	var hasNamed bool
+	var forNamed, forStar, inRules bool
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+		switch field {
+		case "user-agent":
+			if inRules {
+				forNamed, forStar, inRules = false, false, false
+			}
+			switch v := strings.ToLower(value); {
+			case v == "*":
+				forStar = true
+			case v == agent:
+				forNamed, hasNamed = true, true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // "Disallow:" with no path allows everything
+			}
+			if forNamed {
+				named.add(field, value)
+			}
+			if forStar {
+				star.add(field, value)
+			}
+		}
+	}
+	if hasNamed {
+		return named
+	}
+	return star
+}
+
+// add records an Allow or Disallow path.
+func (r *robotsRules) add(field, path string) {
+	if field == "allow" {
+		r.allow = append(r.allow, path)
+	} else {
+		r.disallow = append(r.disallow, path)
+	}
+}
+
+// allows applies the longest matching rule to path; Allow wins ties.
+func (r robotsRules) allows(path string) bool {
+	best, allowed := -1, true
+	for _, p := range r.disallow {
+		if robotsMatch(p, path) && len(p) > best {
+			best, allowed = len(p), false
+		}
+	}
+	for _, p := range r.allow {
+		if robotsMatch(p, path) && len(p) >= best {
+			best, allowed = len(p), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any run of
+// characters and a trailing $ anchors the end, against path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored && rest != "" {
+		// A later occurrence of the last part may still end the path.
+		last := parts[len(parts)-1]
+		return len(parts) > 1 && strings.HasSuffix(path, last)
+	}
+	return true
+}
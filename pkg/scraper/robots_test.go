@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	const robots = `# comments are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/press$
+Disallow: /*.pdf$
+Disallow: /search
+
+User-agent: otherbot
+Disallow: /
+`
+	const named = robots + `
+User-agent: GLSI
+User-agent: somebot
+Disallow: /drafts/
+`
+	tests := []struct {
+		robots, path string
+		want         bool
+	}{
+		{robots, "/", true},
+		{robots, "/private/report", false},
+		{robots, "/private/press", true},
+		{robots, "/private/press/2024", false},
+		{robots, "/files/guide.pdf", false},
+		{robots, "/files/guide.pdf?download=1", true},
+		{robots, "/search?q=go", false},
+		{robots, "/searching", false},
+		{named, "/private/report", true}, // a group naming glsi replaces "*"
+		{named, "/drafts/post", false},
+		{"User-agent: *\nDisallow:\n", "/anything", true},
+		{"", "/anything", true},
+	}
+	for _, tt := range tests {
+		if got := parseRobots([]byte(tt.robots), RobotsAgent).allows(tt.path); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v with robots.txt:\n%s", tt.path, got, tt.want, tt.robots)
+		}
+	}
+}
+
+func TestRespectRobots(t *testing.T) {
+	var robotsStatus atomic.Int32
+	robotsStatus.Store(http.StatusOK)
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			if code := int(robotsStatus.Load()); code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(fakeArticlePage("Page", "Some public text that is long enough to be extracted as the article body.")))
+		}
+	}))
+	defer cleanup()
+	defer func() {
+		robotsMu.Lock()
+		clear(robotsCache)
+		robotsMu.Unlock()
+	}()
+
+	urls := []string{serverURL + "/public/page", serverURL + "/private/page"}
+	pages := ScrapeWithOptions(context.Background(), urls, Options{HostDelay: -1})
+	if pages[1].Err != nil {
+		t.Fatalf("without RespectRobots: err = %v", pages[1].Err)
+	}
+
+	opts := Options{HostDelay: -1, RespectRobots: true}
+	pages = ScrapeWithOptions(context.Background(), urls, opts)
+	if pages[0].Err != nil || !strings.Contains(pages[0].Content, "public text") {
+		t.Errorf("allowed page: %+v", pages[0])
+	}
+	if !errors.Is(pages[1].Err, ErrRobotsDisallowed) {
+		t.Errorf("disallowed page: err = %v, want ErrRobotsDisallowed", pages[1].Err)
+	}
+
+	// An unreadable robots.txt disallows everything until it can be read.
+	robotsMu.Lock()
+	clear(robotsCache)
+	robotsMu.Unlock()
+	robotsStatus.Store(http.StatusServiceUnavailable)
+	if p := ScrapeWithOptions(context.Background(), urls[:1], opts)[0]; p.Err == nil {
+		t.Error("robots.txt 503: want an error")
+	}
+	robotsStatus.Store(http.StatusNotFound)
+	if p := ScrapeWithOptions(context.Background(), urls[1:], opts)[0]; p.Err != nil {
+		t.Errorf("robots.txt 404: err = %v, want the page", p.Err)
+	}
+}
+
+func TestHeaderRobots(t *testing.T) {
+	got := headerRobots([]string{"noarchive", "googlebot: noindex, nofollow", "glsi: none", "unavailable_after: 2030-01-01"})
+	want := []string{"noarchive", "none", "unavailable_after: 2030-01-01"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("headerRobots = %q, want %q", got, want)
+	}
+	if d := optOut(headerRobots([]string{"googlebot: noindex", "nofollow"})); d != "" {
+		t.Errorf("optOut = %q, want none for other agents' noindex", d)
+	}
+}
+
+func TestRespectNoindex(t *testing.T) {
+	body := "Text the site would rather not see indexed, long enough to be extracted as the article."
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		page := fakeArticlePage("Page", body)
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Set("X-Robots-Tag", "noindex")
+		case "/meta":
+			page = strings.Replace(page, "<head>", `<head><meta name="robots" content="NOARCHIVE, nofollow">`, 1)
+		case "/other-agent":
+			w.Header().Set("X-Robots-Tag", "googlebot: noindex")
+			page = strings.Replace(page, "<head>", `<head><meta name="bingbot" content="noindex">`, 1)
+		}
+		w.Write([]byte(page))
+	}))
+	defer cleanup()
+
+	urls := []string{serverURL + "/header", serverURL + "/meta", serverURL + "/other-agent"}
+	for _, p := range ScrapeWithOptions(context.Background(), urls, Options{HostDelay: -1}) {
+		if p.Err != nil {
+			t.Errorf("without RespectNoindex: %s: %v", p.URL, p.Err)
+		}
+	}
+
+	pages := ScrapeWithOptions(context.Background(), urls, Options{HostDelay: -1, RespectNoindex: true})
+	for _, p := range pages[:2] {
+		if !errors.Is(p.Err, ErrNoindex) || p.Content != "" {
+			t.Errorf("%s: err = %v, content = %q; want ErrNoindex and no text", p.URL, p.Err, p.Content)
+		}
+	}
+	if !strings.Contains(pages[1].Err.Error(), "noarchive") {
+		t.Errorf("meta page: err = %v, want the directive named", pages[1].Err)
+	}
+	if p := pages[2]; p.Err != nil || !strings.Contains(p.Content, "rather not") {
+		t.Errorf("other agents' directives: %+v", p)
+	}
+}
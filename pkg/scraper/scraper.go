@@ -0,0 +1,604 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	readability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// DefaultTimeout bounds each page fetch when Options.Timeout is unset.
+const DefaultTimeout = 3 * time.Second
+
+// DefaultMaxBodyBytes caps how much of a page is read when
+// Options.MaxBodyBytes is unset.
+const DefaultMaxBodyBytes = 5 << 20
+
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// FetchCache stores fetched page bodies for conditional refetches.
+// *cache.Cache implements it.
+type FetchCache interface {
+	GetFetch(ctx context.Context, url string) (cache.Fetch, bool, error)
+	PutFetch(ctx context.Context, url string, f cache.Fetch) error
+}
+
+// httpClient is the HTTP client used for scraping when the context carries
+// none from WithHTTPClient.
+var httpClient = &http.Client{Transport: newTransport()}
+
+type clientKey struct{}
+
+// WithHTTPClient returns a context under which pages are fetched through c.
+// Only scrapes made with the context are affected, so concurrent tests and
+// embedders can each bring their own client. Options.Proxy and
+// DomainProxies are applied by the default client's transport; a custom
+// transport must do its own proxying. Options.Timeout and Cookies still
+// apply, to a copy of c.
+func WithHTTPClient(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// clientFor returns the client set by WithHTTPClient, or the package
+// default.
+func clientFor(ctx context.Context) *http.Client {
+	if c, ok := ctx.Value(clientKey{}).(*http.Client); ok && c != nil {
+		return c
+	}
+	return httpClient
+}
+
+// OverrideHTTPClient replaces the HTTP client used by the scraper
+// package and returns a function to restore the original.
+//
+// Deprecated: Use WithHTTPClient, which does not race with concurrent
+// callers.
+func OverrideHTTPClient(c *http.Client) (restore func()) {
+	orig := httpClient
+	httpClient = c
+	return func() { httpClient = orig }
+}
+
+// Options controls how pages are scraped.
+type Options struct {
+	Extractor        string            // extraction backend; empty selects readability
+	DomainExtractors map[string]string // per-domain backend overrides, e.g. {"example.com": "density"}
+
+	// FallbackExtractor is tried when the chosen backend's text is shorter
+	// than MinContentChars, and used if it finds at least that much. Empty
+	// selects ExtractorDensity. MinContentChars of zero uses
+	// DefaultMinContentChars; negative disables the fallback.
+	FallbackExtractor string
+	MinContentChars   int
+
+	Timeout   time.Duration // per-page fetch timeout; zero uses DefaultTimeout
+	HostDelay time.Duration // pause between fetches from one host; zero uses DefaultHostDelay, negative disables per-host serialization
+	Retries   int           // refetches of transient failures (timeouts, 429, 502-504); zero uses DefaultRetries, negative disables
+
+	MaxBodyBytes int64 // bytes of HTML read per page, the rest is dropped; zero uses DefaultMaxBodyBytes, negative means unlimited
+
+	Renderer Renderer // headless browser used for JS-heavy pages; nil disables rendering
+	Render   string   // when to use Renderer: RenderNever (default), RenderAuto, RenderAlways
+
+	Output string // OutputText (default) or OutputMarkdown
+
+	Images bool // collect image URLs into ScrapedPage.Image and Images
+	Links  bool // collect the page's links into ScrapedPage.Links
+
+	GitHubToken string // sent with GitHub API and raw file requests; lifts the anonymous rate limit
+
+	ArchiveFallback  bool // refetch pages behind a paywall, consent wall or geo-block from the Wayback Machine
+	ArchiveDeadLinks bool // read pages answering 404 or 410, or whose host refuses connections or is gone, from the Wayback Machine
+
+	// RespectRobots skips pages the site's robots.txt disallows for
+	// RobotsAgent, failing them with ErrRobotsDisallowed. A robots.txt that
+	// cannot be read for a reason other than 4xx disallows the whole site.
+	RespectRobots bool
+
+	// RespectNoindex discards the content of pages whose X-Robots-Tag
+	// header or robots meta tag says noindex, noarchive or none, for every
+	// agent or for RobotsAgent, failing them with ErrNoindex. Rendered
+	// pages are only checked through their static fetch.
+	RespectNoindex bool
+
+	// GeoProxy is a proxy in another region, tried before the archive for
+	// pages answering HTTP 451 or showing a regional block.
+	GeoProxy string
+
+	Proxy         string            // proxy URL for fetches, or ProxyDirect; empty follows HTTPS_PROXY/HTTP_PROXY
+	DomainProxies map[string]string // per-domain overrides of Proxy, e.g. {"intranet.example": "direct"}
+
+	// BlockPrivate refuses to fetch from loopback, private, link-local and
+	// carrier-grade NAT addresses, failing such pages with
+	// ErrPrivateAddress, so links in search results cannot reach internal
+	// services or cloud metadata endpoints. Addresses are checked as they
+	// are dialed, after DNS resolution and on every redirect. Proxies are
+	// exempt; for proxied and rendered fetches the host is resolved and
+	// checked up front. AllowedNets lists internal networks to allow anyway.
+	// Clients set with WithHTTPClient do their own dialing and skip the
+	// dial-time check.
+	BlockPrivate bool
+	AllowedNets  []netip.Prefix
+
+	// Cookies keeps cookies for the length of one ScrapeWithOptions call,
+	// for sites that set one on a redirect and refuse requests without it.
+	// Every call starts with an empty jar, so nothing leaks between runs.
+	Cookies bool
+
+	// OnPage, if set, is called with each URL's index and result as soon
+	// as it finishes, from the goroutine that scraped it.
+	OnPage func(i int, page ScrapedPage)
+
+	// FetchCache, if set, keeps page bodies with their ETag and
+	// Last-Modified validators. Refetches send If-None-Match and
+	// If-Modified-Since, and a 304 reuses the stored body.
+	FetchCache FetchCache
+
+	// Logger receives debug logs of retries, fallbacks and blocked pages,
+	// and warnings for fetch cache errors; nil logs nothing.
+	Logger *slog.Logger
+
+	jar http.CookieJar // the run's jar when Cookies is set
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger resolves Options.Logger.
+func logger(opts Options) *slog.Logger {
+	if opts.Logger == nil {
+		return discardLogger
+	}
+	return opts.Logger
+}
+
+// ScrapedPage holds the result of scraping a single URL.
+type ScrapedPage struct {
+	URL         string
+	FinalURL    string   // where redirects ended; empty if unknown, as for rendered or GitHub API reads
+	Title       string   // page title, if the extractor found one
+	Author      string   // byline or author meta tag, empty if none
+	SiteName    string   // publication name, e.g. from og:site_name
+	Description string   // the page's own summary from its meta tags
+	Image       string   // lead image URL; only with Options.Images
+	Images      []string // image URLs in the article body; only with Options.Images
+	Links       []Link   // links anywhere on the page, in document order; only with Options.Links
+	Content     string
+	Language    string      // detected ISO 639-1 code, empty if unknown
+	Published   PublishDate // zero if no date could be determined
+	Extractor   string      // backend that produced Content
+	Markdown    bool        // Content is Markdown rather than plain text
+	Rendered    bool        // true if Content came from a headless browser
+	Revalidated bool        // the server answered 304 and a stored body was reused
+	Attempts    int         // fetches made, including retries
+	Truncated   bool        // body exceeded Options.MaxBodyBytes, or raw text its own cap, and was cut off
+	Blocked     bool        // a paywall, consent overlay or geo-block hid the content
+	BlockReason string      // BlockPaywall, BlockConsent or BlockGeo when Blocked
+	Via         string      // ViaGeoProxy or ViaArchive if Content was obtained despite a block or dead link
+	ArchiveURL  string      // Wayback Machine snapshot Content came from, if any
+	Timings     Timings     // per-phase durations of the fetch; Timings.Extract is the extraction time
+	Status      int         // HTTP status of the last response, 0 if there was none (as for rendered pages)
+	Bytes       int64       // body bytes read, after decompression
+	Words       int         // words in Content
+	Err         error
+}
+
+// Scrape concurrently fetches each URL, extracts readable text via
+// go-readability (or the PDF text layer), and returns results for every URL
+// (including per-URL errors).
+func Scrape(ctx context.Context, urls []string) []ScrapedPage {
+	return ScrapeWithOptions(ctx, urls, Options{})
+}
+
+// ScrapeWithOptions is like Scrape but with explicit options.
+func ScrapeWithOptions(ctx context.Context, urls []string, opts Options) []ScrapedPage {
+	if opts.Cookies {
+		opts.jar = newCookieJar()
+	}
+	results := make([]ScrapedPage, len(urls))
+	var wg sync.WaitGroup
+
+	for i, u := range urls {
+		wg.Add(1)
+		go func(idx int, rawURL string) {
+			defer wg.Done()
+			results[idx] = scrapeSingle(ctx, rawURL, opts)
+			if opts.OnPage != nil {
+				opts.OnPage(idx, results[idx])
+			}
+		}(i, u)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// ScrapeStream is like Scrape but sends each page on the returned channel
+// as soon as it finishes, in completion order rather than URL order, so
+// callers can use fast pages without waiting for the slowest. The channel
+// is buffered for every URL and closed once all of them are done; a
+// canceled ctx fails the unfinished pages rather than dropping them.
+func ScrapeStream(ctx context.Context, urls []string) <-chan ScrapedPage {
+	return ScrapeStreamWithOptions(ctx, urls, Options{})
+}
+
+// ScrapeStreamWithOptions is like ScrapeStream but with explicit options.
+// opts.OnPage, if set, is still called for each page before it is sent.
+func ScrapeStreamWithOptions(ctx context.Context, urls []string, opts Options) <-chan ScrapedPage {
+	pages := make(chan ScrapedPage, len(urls))
+	onPage := opts.OnPage
+	opts.OnPage = func(i int, page ScrapedPage) {
+		if onPage != nil {
+			onPage(i, page)
+		}
+		pages <- page
+	}
+	go func() {
+		defer close(pages)
+		ScrapeWithOptions(ctx, urls, opts)
+	}()
+	return pages
+}
+
+func scrapeSingle(ctx context.Context, rawURL string, opts Options) (page ScrapedPage) {
+	start := time.Now()
+	var queued time.Duration
+	defer func() {
+		page.Timings.Queue = queued
+		page.Timings.Total = time.Since(start)
+		page.Words = len(strings.Fields(page.Content))
+		recordTimings(page.Timings, page.Err)
+	}()
+
+	// Pages on the same host are fetched one at a time.
+	release, err := acquireHost(ctx, rawURL, hostDelay(opts))
+	queued = time.Since(start)
+	if err != nil {
+		return ScrapedPage{URL: rawURL, Err: fmt.Errorf("wait for host of %s: %w", rawURL, err)}
+	}
+	defer release()
+
+	if opts.RespectRobots {
+		allowed, err := robotsAllowed(ctx, rawURL, opts)
+		if err != nil {
+			return ScrapedPage{URL: rawURL, Err: fmt.Errorf("robots.txt for %s: %w", rawURL, err)}
+		}
+		if !allowed {
+			return ScrapedPage{URL: rawURL, Err: fmt.Errorf("%s: %w", rawURL, ErrRobotsDisallowed)}
+		}
+	}
+
+	_, github := parseGitHubURL(rawURL)
+	if opts.BlockPrivate && opts.Renderer != nil && (opts.Render == RenderAlways || opts.Render == RenderAuto) && !github {
+		// The browser does its own dialing.
+		if err := checkHost(ctx, rawURL, opts.AllowedNets); err != nil {
+			return ScrapedPage{URL: rawURL, Err: fmt.Errorf("fetch %s: %w", rawURL, err)}
+		}
+	}
+	if opts.Renderer != nil && opts.Render == RenderAlways && !github {
+		return renderPage(ctx, rawURL, opts)
+	}
+
+	page = fetchWithRetry(ctx, rawURL, opts)
+	if page.Err != nil && opts.ArchiveDeadLinks && ctx.Err() == nil && deadLink(page.Err) {
+		return fetchArchived(ctx, page, opts)
+	}
+	if page.Blocked {
+		logger(opts).DebugContext(ctx, "page blocked", "url", rawURL, "reason", page.BlockReason)
+		// A headless browser meets the same wall; another region or an
+		// archived copy may not.
+		if page.BlockReason == BlockGeo && opts.GeoProxy != "" {
+			if alt := fetchViaGeoProxy(ctx, rawURL, opts); alt.Err == nil && !alt.Blocked {
+				alt.Blocked, alt.BlockReason, alt.Via = true, BlockGeo, ViaGeoProxy
+				alt.Attempts += page.Attempts
+				return alt
+			}
+		}
+		if opts.ArchiveFallback {
+			page = fetchArchived(ctx, page, opts)
+		}
+		return page
+	}
+	if opts.Renderer != nil && opts.Render == RenderAuto && page.Err == nil &&
+		page.Extractor != ExtractorPDF && page.Extractor != ExtractorRaw && page.Extractor != ExtractorGitHub &&
+		len(strings.TrimSpace(page.Content)) < minStaticTextChars {
+		// Thin static output usually means client-side rendering.
+		logger(opts).DebugContext(ctx, "rendering thin page", "url", rawURL, "chars", len(strings.TrimSpace(page.Content)))
+		rendered := renderPage(ctx, rawURL, opts)
+		if rendered.Err == nil && len(rendered.Content) > len(page.Content) {
+			if rendered.Published.IsZero() {
+				rendered.Published = page.Published
+			}
+			if rendered.Author == "" {
+				rendered.Author = page.Author
+			}
+			if rendered.SiteName == "" {
+				rendered.SiteName = page.SiteName
+			}
+			if rendered.Description == "" {
+				rendered.Description = page.Description
+			}
+			rendered.FinalURL = page.FinalURL
+			rendered.Status = page.Status
+			rendered.Timings = page.Timings
+			rendered.Attempts = page.Attempts
+			return rendered
+		}
+	}
+	return page
+}
+
+// fetchPage performs a plain HTTP fetch and extracts the static HTML,
+// recording per-phase timings. GitHub repos, files and issues are read
+// through the API or raw.githubusercontent.com instead, falling back to the
+// HTML UI if that fails (rate limits, private repos, unusual refs).
+func fetchPage(ctx context.Context, rawURL string, opts Options) ScrapedPage {
+	if gh, ok := parseGitHubURL(rawURL); ok {
+		page := fetchTarget(ctx, rawURL, gh.url, gh, opts)
+		if page.Err == nil || ctx.Err() != nil {
+			return page
+		}
+	}
+	return fetchTarget(ctx, rawURL, rawURL, githubTarget{}, opts)
+}
+
+// fetchTarget fetches fetchURL and extracts it as the page at rawURL.
+// fetchURL differs for GitHub pages, described by gh, and archived copies.
+func fetchTarget(ctx context.Context, rawURL, fetchURL string, gh githubTarget, opts Options) (page ScrapedPage) {
+	page.URL = rawURL
+	start := time.Now()
+	var tr tracer
+	defer func() {
+		t := tr.timings()
+		t.Download, t.Extract = page.Timings.Download, page.Timings.Extract
+		t.Total = time.Since(start)
+		page.Timings = t
+	}()
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(tr.withTrace(withProxy(ctx, opts, fetchURL)), http.MethodGet, fetchURL, nil)
+	if err != nil {
+		page.Err = fmt.Errorf("create request: %w", err)
+		return page
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if gh.kind != 0 {
+		gh.setHeaders(req, opts.GitHubToken)
+	}
+	// Plain page fetches revalidate a stored body rather than downloading
+	// it again. A failed lookup just means an unconditional fetch.
+	var stored cache.Fetch
+	var haveStored bool
+	conditional := opts.FetchCache != nil && gh.kind == 0 && fetchURL == rawURL
+	if conditional {
+		stored, haveStored, err = opts.FetchCache.GetFetch(ctx, fetchURL)
+		if err != nil {
+			logger(opts).WarnContext(ctx, "fetch cache read failed", "url", fetchURL, "err", err)
+		}
+		if haveStored {
+			setValidators(req, stored)
+		}
+	}
+
+	// ctx carries the timeout. The shared client is used as is, so every
+	// fetch draws on one connection pool; a run's cookie jar needs its own
+	// client, which still shares the transport.
+	client := clientFor(ctx)
+	if opts.jar != nil {
+		withJar := *client
+		withJar.Jar = opts.jar
+		client = &withJar
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		page.Err = fmt.Errorf("http get %s: %w", fetchURL, err)
+		return page
+	}
+	defer resp.Body.Close()
+	page.Status = resp.StatusCode
+
+	var data []byte
+	var pdfType bool
+	contentType := resp.Header.Get("Content-Type")
+	lastModified := resp.Header.Get("Last-Modified")
+	finalURL := resp.Request.URL.String()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveStored:
+		// A 304 may carry fresh validators for the same body.
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			stored.ETag = etag
+		}
+		if lastModified != "" {
+			stored.LastModified = lastModified
+		}
+		data, contentType, finalURL, lastModified = stored.Body, stored.ContentType, stored.FinalURL, stored.LastModified
+		pdfType = isPDF(contentType, data)
+		page.Truncated, page.Revalidated = stored.Truncated, true
+		putFetch(ctx, fetchURL, stored, opts) // restarts its TTL
+	case resp.StatusCode != http.StatusOK:
+		page.Err = &statusError{
+			Code:       resp.StatusCode,
+			URL:        fetchURL,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		if resp.StatusCode == http.StatusUnavailableForLegalReasons && gh.kind == 0 {
+			page.Blocked, page.BlockReason = true, BlockGeo
+		}
+		return page
+	default:
+		downloadStart := time.Now()
+		data, pdfType, page.Truncated, err = readBody(resp, opts)
+		page.Timings.Download = time.Since(downloadStart)
+		if err != nil {
+			page.Err = fmt.Errorf("read %s: %w", rawURL, err)
+			return page
+		}
+		etag := resp.Header.Get("ETag")
+		if conditional && (etag != "" || lastModified != "") {
+			putFetch(ctx, fetchURL, cache.Fetch{
+				ETag: etag, LastModified: lastModified, ContentType: contentType,
+				FinalURL: finalURL, Truncated: page.Truncated, Body: data,
+			}, opts)
+		}
+	}
+	page.Bytes = int64(len(data))
+	if fetchURL == rawURL {
+		page.FinalURL = finalURL
+	}
+	if opts.RespectNoindex && gh.kind == 0 {
+		if d := optOut(headerRobots(resp.Header.Values("X-Robots-Tag"))); d != "" {
+			page.Err = fmt.Errorf("%s: X-Robots-Tag %s: %w", rawURL, d, ErrNoindex)
+			return page
+		}
+	}
+
+	extractStart := time.Now()
+	var article readability.Article
+	var extractor string
+	switch {
+	case pdfType:
+		article, err = extractPDF(bytes.NewReader(data))
+		extractor = ExtractorPDF
+	case gh.kind != 0:
+		var cut bool
+		article, cut, err = gh.extract(data, contentType)
+		page.Truncated = page.Truncated || cut
+		extractor = ExtractorGitHub
+	case isRaw(contentType):
+		// JSON, plain text and XML have no article for readability to find.
+		var cut bool
+		article, cut = extractRaw(data, contentType)
+		page.Truncated = page.Truncated || cut
+		extractor = ExtractorRaw
+	default:
+		article, extractor, err = extractPage(opts, rawURL, data)
+	}
+	page.Timings.Extract = time.Since(extractStart)
+	if err != nil {
+		page.Err = fmt.Errorf("extract %s: %w", rawURL, err)
+		return page
+	}
+	setArticle(&page, article, extractor, opts.Output)
+	var meta pageMeta
+	if extractor != ExtractorPDF && extractor != ExtractorRaw && extractor != ExtractorGitHub {
+		meta = readMeta(bytes.NewReader(data))
+		if d := optOut(metaRobots(meta.robots)); opts.RespectNoindex && d != "" {
+			page = ScrapedPage{URL: page.URL, FinalURL: page.FinalURL, Status: page.Status, Bytes: page.Bytes, Timings: page.Timings,
+				Err: fmt.Errorf("%s: robots meta %s: %w", rawURL, d, ErrNoindex)}
+			return page
+		}
+		setBlocked(&page, data, finalURL)
+	}
+	setMeta(&page, article, meta)
+	if opts.Images {
+		setImages(&page, article, meta)
+	}
+	if opts.Links && extractor != ExtractorPDF && extractor != ExtractorRaw && extractor != ExtractorGitHub {
+		setLinks(&page, data, finalURL)
+	}
+	page.Published = detectPublishDate(article, rawURL, lastModified)
+	return page
+}
+
+// putFetch stores f in opts.FetchCache. It is best effort: a failed write
+// only costs the next fetch its validators, so it is logged and dropped.
+func putFetch(ctx context.Context, fetchURL string, f cache.Fetch, opts Options) {
+	if err := opts.FetchCache.PutFetch(ctx, fetchURL, f); err != nil {
+		logger(opts).WarnContext(ctx, "fetch cache write failed", "url", fetchURL, "err", err)
+	}
+}
+
+// readBody reads a successful response's body up front, so download and
+// extraction time are separable. The size cap keeps one huge page from
+// dominating memory; PDFs are capped separately since the parser needs the
+// whole file. The first bytes are sniffed before choosing the cap, so a
+// PDF served as application/octet-stream is not cut off at the HTML limit.
+func readBody(resp *http.Response, opts Options) (data []byte, pdfType, truncated bool, err error) {
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, false, false, err
+	}
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(len(pdfMagic)) // a short or failed peek surfaces in ReadAll
+	pdfType = isPDF(resp.Header.Get("Content-Type"), head)
+	limit := maxBodyBytes(opts)
+	if pdfType {
+		limit = maxPDFBytes // extractPDF rejects anything larger
+	}
+	var r io.Reader = br
+	if limit > 0 {
+		r = io.LimitReader(br, limit+1) // caps decompressed size too
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return nil, false, false, err
+	}
+	if !pdfType && limit > 0 && int64(len(data)) > limit {
+		data, truncated = data[:limit], true
+	}
+	return data, pdfType, truncated, nil
+}
+
+// setValidators makes req conditional on the stored body being current.
+func setValidators(req *http.Request, f cache.Fetch) {
+	if f.ETag != "" {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if f.LastModified != "" {
+		req.Header.Set("If-Modified-Since", f.LastModified)
+	}
+}
+
+// newCookieJar returns an empty jar that, like a browser, refuses cookies
+// scoped to a public suffix such as co.uk.
+func newCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List}) // never fails
+	return jar
+}
+
+// maxBodyBytes resolves the body size cap; zero or less means unlimited.
+func maxBodyBytes(opts Options) int64 {
+	if opts.MaxBodyBytes == 0 {
+		return DefaultMaxBodyBytes
+	}
+	return max(opts.MaxBodyBytes, 0)
+}
+
+// setArticle copies extraction output into page. Markdown output needs
+// readability's HTML; other backends, and conversion failures, fall back to
+// plain text. Plain text from readability keeps code blocks fenced.
+func setArticle(page *ScrapedPage, article readability.Article, extractor, output string) {
+	page.Title = cleanText(article.Title)
+	page.Content = article.TextContent
+	if extractor == ExtractorReadability && article.Content != "" {
+		if output == OutputMarkdown {
+			if md, err := toMarkdown(article.Content); err == nil && md != "" {
+				page.Content = md
+				page.Markdown = true
+			}
+		} else if text, ok := textWithCode(article.Content); ok {
+			page.Content = text
+		}
+	}
+	page.Language = detectLanguage(article.TextContent, article.Language)
+	page.Extractor = extractor
+}
@@ -0,0 +1,504 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/glsi/pkg/cache"
+)
+
+// fakeArticlePage returns a realistic-looking HTML page that go-readability
+// can extract text from.
+func fakeArticlePage(title, body string) string {
+	return `<!DOCTYPE html>
+<html>
+<head><title>` + title + `</title></head>
+<body>
+<article>
+<h1>` + title + `</h1>
+<p>` + body + `</p>
+</article>
+</body>
+</html>`
+}
+
+// setupScrapeServer creates a test server and overrides the package-level
+// httpClient. Returns the server URL and a cleanup function.
+func setupScrapeServer(t *testing.T, handler http.Handler) (serverURL string, cleanup func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+
+	origClient := httpClient
+	httpClient = srv.Client()
+
+	return srv.URL, func() {
+		srv.Close()
+		httpClient = origClient
+	}
+}
+
+func TestScrapeSinglePage(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage(
+			"Test Article",
+			"This is the main content of the test article. It contains enough text for readability to extract.",
+		)))
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/page"})
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	if !strings.Contains(pages[0].Content, "main content") {
+		t.Errorf("content should contain article text, got: %q", pages[0].Content)
+	}
+}
+
+func TestScrapeMultiplePages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Page 1", "Content from the first page with enough detail.")))
+	})
+	mux.HandleFunc("/page2", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Page 2", "Content from the second page with more detail.")))
+	})
+
+	serverURL, cleanup := setupScrapeServer(t, mux)
+	defer cleanup()
+
+	urls := []string{serverURL + "/page1", serverURL + "/page2"}
+	pages := Scrape(context.Background(), urls)
+
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+
+	for i, p := range pages {
+		if p.Err != nil {
+			t.Errorf("page[%d]: unexpected error: %v", i, p.Err)
+		}
+		if p.URL != urls[i] {
+			t.Errorf("page[%d].URL = %q, want %q", i, p.URL, urls[i])
+		}
+		if p.Content == "" {
+			t.Errorf("page[%d].Content is empty", i)
+		}
+	}
+}
+
+func TestScrapeServerError(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/error"})
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if pages[0].Err == nil {
+		t.Fatal("expected error for 500 response, got nil")
+	}
+}
+
+func TestScrapeNotFound(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/missing"})
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if pages[0].Err == nil {
+		t.Fatal("expected error for 404, got nil")
+	}
+}
+
+func TestScrapePageStats(t *testing.T) {
+	html := fakeArticlePage("Stats", "Seven words of article text right here. And the page carries a few more words after that sentence.")
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer cleanup()
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/page", serverURL + "/missing"}, Options{HostDelay: -1, Retries: -1})
+	page := pages[0]
+	if page.Err != nil {
+		t.Fatal(page.Err)
+	}
+	if page.Status != http.StatusOK || page.Bytes != int64(len(html)) {
+		t.Errorf("Status = %d, Bytes = %d; want 200 and %d", page.Status, page.Bytes, len(html))
+	}
+	if want := len(strings.Fields(page.Content)); page.Words != want || want == 0 {
+		t.Errorf("Words = %d, want %d", page.Words, want)
+	}
+	if page.Timings.Extract <= 0 {
+		t.Error("Timings.Extract not recorded")
+	}
+	if missing := pages[1]; missing.Status != http.StatusNotFound || missing.Bytes != 0 || missing.Words != 0 {
+		t.Errorf("404 page: Status = %d, Bytes = %d, Words = %d", missing.Status, missing.Bytes, missing.Words)
+	}
+}
+
+func TestScrapeEmptyList(t *testing.T) {
+	pages := Scrape(context.Background(), nil)
+	if len(pages) != 0 {
+		t.Fatalf("got %d pages, want 0 for empty URL list", len(pages))
+	}
+}
+
+func TestScrapePreservesOrder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("A", "Alpha article content here.")))
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("B", "Bravo article content here.")))
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("C", "Charlie article content here.")))
+	})
+
+	serverURL, cleanup := setupScrapeServer(t, mux)
+	defer cleanup()
+
+	urls := []string{serverURL + "/a", serverURL + "/b", serverURL + "/c"}
+	pages := Scrape(context.Background(), urls)
+
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	for i, p := range pages {
+		if p.URL != urls[i] {
+			t.Errorf("page[%d].URL = %q, want %q (order mismatch)", i, p.URL, urls[i])
+		}
+	}
+}
+
+func TestScrapePartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("OK Page", "This page loads successfully with good content.")))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	serverURL, cleanup := setupScrapeServer(t, mux)
+	defer cleanup()
+
+	urls := []string{serverURL + "/ok", serverURL + "/fail"}
+	pages := Scrape(context.Background(), urls)
+
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	if pages[0].Err != nil {
+		t.Errorf("page[0] should succeed, got error: %v", pages[0].Err)
+	}
+	if pages[1].Err == nil {
+		t.Error("page[1] should fail, got nil error")
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "article.html"))
+	if err != nil {
+		b.Fatalf("read fixture: %v", err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := extract(bytes.NewReader(data)); err != nil {
+			b.Fatalf("extract: %v", err)
+		}
+	}
+}
+
+func TestScrapeTimeoutOption(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Slow", "A slow documentation page that takes a while to respond.")))
+	}))
+	defer cleanup()
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/slow"}, Options{Timeout: 50 * time.Millisecond})
+	if pages[0].Err == nil {
+		t.Fatal("expected timeout error with a 50ms timeout")
+	}
+
+	pages = ScrapeWithOptions(context.Background(), []string{serverURL + "/slow"}, Options{Timeout: 2 * time.Second})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error with a 2s timeout: %v", pages[0].Err)
+	}
+}
+
+func TestScrapeMaxBodyBytes(t *testing.T) {
+	body := "Readable text about memory limits in scrapers. " + strings.Repeat("Filler sentence to make the page large. ", 200)
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Huge", body)))
+	}))
+	defer cleanup()
+
+	tests := []struct {
+		name          string
+		max           int64
+		wantTruncated bool
+	}{
+		{"default", 0, false},
+		{"capped", 2000, true},
+		{"unlimited", -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := ScrapeWithOptions(context.Background(), []string{serverURL + "/huge"}, Options{MaxBodyBytes: tt.max, HostDelay: -1})[0]
+			if page.Err != nil {
+				t.Fatalf("unexpected error: %v", page.Err)
+			}
+			if page.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", page.Truncated, tt.wantTruncated)
+			}
+			if !strings.Contains(page.Content, "memory limits") {
+				t.Errorf("content lost the start of the page: %.80q", page.Content)
+			}
+			if tt.wantTruncated && len(page.Content) > int(tt.max) {
+				t.Errorf("content is %d bytes, want at most %d", len(page.Content), tt.max)
+			}
+		})
+	}
+}
+
+func TestScrapeCookies(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			// The cookie is only set on the redirect, as consent and
+			// session gateways do.
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			http.Redirect(w, r, "/article", http.StatusFound)
+		case "/article":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+				http.Error(w, "no session", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(fakeArticlePage("Behind the gateway", "This article is only served to clients that kept the session cookie set during the redirect.")))
+		}
+	}))
+	defer cleanup()
+
+	opts := Options{HostDelay: -1, Retries: -1}
+	if page := ScrapeWithOptions(context.Background(), []string{serverURL + "/start"}, opts)[0]; page.Err == nil {
+		t.Errorf("without cookies: page = %+v, want a 403", page)
+	}
+	opts.Cookies = true
+	page := ScrapeWithOptions(context.Background(), []string{serverURL + "/start"}, opts)[0]
+	if page.Err != nil || page.Title != "Behind the gateway" {
+		t.Errorf("with cookies: page = %+v", page)
+	}
+	// A later run starts without the earlier run's cookies.
+	page = ScrapeWithOptions(context.Background(), []string{serverURL + "/article"}, opts)[0]
+	if page.Err == nil {
+		t.Errorf("fresh run: page = %+v, want a 403", page)
+	}
+}
+
+func TestScrapeOnPage(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Page", "Some article content for the progress callback.")))
+	}))
+	defer cleanup()
+
+	var (
+		mu    sync.Mutex
+		order []int
+	)
+	urls := []string{serverURL + "/slow", serverURL + "/fast"}
+	ScrapeWithOptions(context.Background(), urls, Options{HostDelay: -1, OnPage: func(i int, p ScrapedPage) {
+		if p.URL != urls[i] {
+			t.Errorf("OnPage(%d, %s)", i, p.URL)
+		}
+		mu.Lock()
+		order = append(order, i)
+		mu.Unlock()
+	}})
+	if len(order) != 2 || order[0] != 1 {
+		t.Errorf("OnPage order = %v, want the fast page first", order)
+	}
+}
+
+func TestScrapeStream(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Page", "Some article content for the streaming scrape.")))
+	}))
+	defer cleanup()
+
+	urls := []string{serverURL + "/slow", serverURL + "/fast", serverURL + "/missing"}
+	var got []ScrapedPage
+	for p := range ScrapeStreamWithOptions(context.Background(), urls, Options{HostDelay: -1}) {
+		got = append(got, p)
+	}
+	if len(got) != 3 {
+		t.Fatalf("streamed %d pages, want 3", len(got))
+	}
+	if got[2].URL != urls[0] || got[2].Err != nil {
+		t.Errorf("last page = %s (err %v), want the slow page", got[2].URL, got[2].Err)
+	}
+	for _, p := range got[:2] {
+		if (p.Err != nil) != (p.URL == urls[2]) {
+			t.Errorf("page %s: err = %v", p.URL, p.Err)
+		}
+	}
+
+	if _, ok := <-ScrapeStream(context.Background(), nil); ok {
+		t.Error("ScrapeStream(nil) sent a page, want a closed channel")
+	}
+}
+
+func TestScrapeFinalURL(t *testing.T) {
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/s/x1":
+			http.Redirect(w, r, "/r?to=article", http.StatusMovedPermanently)
+		case "/r":
+			http.Redirect(w, r, "/articles/generics", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(fakeArticlePage("Generics", "Type parameters arrived in Go 1.18 after years of design work.")))
+		}
+	}))
+	defer cleanup()
+
+	pages := ScrapeWithOptions(context.Background(), []string{serverURL + "/s/x1", serverURL + "/articles/generics"}, Options{HostDelay: -1})
+	if pages[0].URL != serverURL+"/s/x1" || pages[0].FinalURL != serverURL+"/articles/generics" {
+		t.Errorf("redirected page: URL %q, FinalURL %q", pages[0].URL, pages[0].FinalURL)
+	}
+	if pages[1].FinalURL != pages[1].URL {
+		t.Errorf("direct page: FinalURL %q, want %q", pages[1].FinalURL, pages[1].URL)
+	}
+}
+
+// memFetchCache is an in-memory FetchCache.
+type memFetchCache struct {
+	mu sync.Mutex
+	m  map[string]cache.Fetch
+}
+
+func (c *memFetchCache) GetFetch(ctx context.Context, url string) (cache.Fetch, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.m[url]
+	return f, ok, nil
+}
+
+func (c *memFetchCache) PutFetch(ctx context.Context, url string, f cache.Fetch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[string]cache.Fetch)
+	}
+	c.m[url] = f
+	return nil
+}
+
+func TestScrapeConditionalFetch(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		full     int
+		lastPath string
+	)
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/modified":
+			if r.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2023 15:04:05 GMT" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2023 15:04:05 GMT")
+		}
+		mu.Lock()
+		full++
+		lastPath = r.URL.Path
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Cached "+r.URL.Path, "A page whose body is worth keeping between fetches, since it rarely changes.")))
+	}))
+	defer cleanup()
+
+	fc := &memFetchCache{}
+	opts := Options{HostDelay: -1, FetchCache: fc}
+	urls := []string{serverURL + "/etag", serverURL + "/modified", serverURL + "/none"}
+	first := ScrapeWithOptions(context.Background(), urls, opts)
+	second := ScrapeWithOptions(context.Background(), urls, opts)
+
+	mu.Lock()
+	if full != 4 || lastPath != "/none" {
+		t.Errorf("%d full downloads, last %s; want 4 with only /none refetched", full, lastPath)
+	}
+	mu.Unlock()
+	for i := range urls {
+		if first[i].Err != nil || second[i].Err != nil || first[i].Revalidated {
+			t.Fatalf("%s: first %+v, second %+v", urls[i], first[i], second[i])
+		}
+		if second[i].Title != first[i].Title || second[i].Content != first[i].Content {
+			t.Errorf("%s: revalidated page differs: %q vs %q", urls[i], second[i].Title, first[i].Title)
+		}
+	}
+	if !second[0].Revalidated || !second[1].Revalidated || second[2].Revalidated {
+		t.Errorf("Revalidated = %v %v %v, want true true false", second[0].Revalidated, second[1].Revalidated, second[2].Revalidated)
+	}
+	if second[1].Published.IsZero() {
+		t.Error("Last-Modified date lost on revalidation")
+	}
+	if _, ok := fc.m[serverURL+"/none"]; ok {
+		t.Error("stored a body without validators")
+	}
+}
@@ -0,0 +1,271 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSitemapFiles = 20       // sitemap files read per site, counting nested ones
+	maxSitemapURLs  = 50000    // page URLs kept per site, the protocol's per-file limit
+	maxSitemapBytes = 10 << 20 // bytes read per sitemap file, after decompression
+)
+
+// ErrNoSitemap means a site has no readable sitemap, neither at
+// /sitemap.xml nor listed in robots.txt.
+var ErrNoSitemap = errors.New("no sitemap found")
+
+// sitemapDoc is a <urlset> or a <sitemapindex>; only one list is filled.
+type sitemapDoc struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// Sitemap returns the page URLs in a site's sitemap, in the order listed.
+// site is a host such as "go.dev" or a URL; a URL with a path, such as
+// "https://example.com/docs/", keeps only the pages under that path.
+// /sitemap.xml is read first, then the sitemaps robots.txt lists if that
+// fails. Sitemap indexes are followed, up to 20 files in all, and gzipped
+// sitemaps are decompressed.
+func Sitemap(ctx context.Context, site string, opts Options) ([]string, error) {
+	base, err := siteURL(site)
+	if err != nil {
+		return nil, err
+	}
+	origin := base.Scheme + "://" + base.Host
+
+	root := origin + "/sitemap.xml"
+	queue := []string{root}
+	var (
+		pages    []string
+		seen     = make(map[string]bool)
+		read     = make(map[string]bool)
+		firstErr error
+	)
+	for len(queue) > 0 && len(read) < maxSitemapFiles && len(pages) < maxSitemapURLs {
+		loc := queue[0]
+		queue = queue[1:]
+		if read[loc] {
+			continue
+		}
+		read[loc] = true
+		doc, err := fetchSitemapDoc(ctx, loc, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("sitemap %s: %w", base.Host, ctx.Err())
+			}
+			if loc == root {
+				queue = append(queue, robotsSitemaps(ctx, origin, opts)...)
+				if len(queue) == 0 {
+					return nil, fmt.Errorf("sitemap %s: %w", base.Host, ErrNoSitemap)
+				}
+			} else if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				queue = append(queue, loc)
+			}
+		}
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seen[loc] || !underPath(loc, base.Path) {
+				continue
+			}
+			seen[loc] = true
+			pages = append(pages, loc)
+			if len(pages) == maxSitemapURLs {
+				break
+			}
+		}
+	}
+	if len(pages) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", base.Host, firstErr)
+	}
+	return pages, nil
+}
+
+// ValidSite reports whether site names a site Sitemap can read: a host or
+// an http or https URL.
+func ValidSite(site string) error {
+	_, err := siteURL(site)
+	return err
+}
+
+// siteURL parses a host or URL naming a site, defaulting to HTTPS.
+func siteURL(site string) (*url.URL, error) {
+	raw := strings.TrimSpace(site)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("sitemap: invalid site %q", site)
+	}
+	return u, nil
+}
+
+// underPath reports whether rawURL's path is within prefix. An empty or
+// root prefix admits every URL.
+func underPath(rawURL, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// robotsSitemaps returns the sitemaps listed in origin's robots.txt, or nil
+// if it cannot be read.
+func robotsSitemaps(ctx context.Context, origin string, opts Options) []string {
+	data, err := fetchSitemapFile(ctx, origin+"/robots.txt", opts)
+	if err != nil {
+		return nil
+	}
+	var locs []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		name, value, ok := strings.Cut(sc.Text(), ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "sitemap") {
+			if loc := strings.TrimSpace(value); loc != "" {
+				locs = append(locs, loc)
+			}
+		}
+	}
+	return locs
+}
+
+// fetchSitemapDoc fetches and parses one sitemap file.
+func fetchSitemapDoc(ctx context.Context, loc string, opts Options) (sitemapDoc, error) {
+	data, err := fetchSitemapFile(ctx, loc, opts)
+	if err != nil {
+		return sitemapDoc{}, err
+	}
+	var doc sitemapDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return sitemapDoc{}, fmt.Errorf("parse %s: %w", loc, err)
+	}
+	return doc, nil
+}
+
+// fetchSitemapFile downloads rawURL, paced and proxied like page fetches,
+// and gunzips it if it is gzip data.
+func fetchSitemapFile(ctx context.Context, rawURL string, opts Options) ([]byte, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	release, err := acquireHost(ctx, rawURL, hostDelay(opts))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(withProxy(ctx, opts, rawURL), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{Code: resp.StatusCode, URL: rawURL}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rawURL, err)
+	}
+	if len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gunzip %s: %w", rawURL, err)
+		}
+		if data, err = io.ReadAll(io.LimitReader(zr, maxSitemapBytes)); err != nil {
+			return nil, fmt.Errorf("gunzip %s: %w", rawURL, err)
+		}
+	}
+	return data, nil
+}
+
+// MatchSitemap picks up to n of urls whose path contains a query word of
+// three or more characters. URLs matching more words come first, then
+// sitemap order.
+func MatchSitemap(urls []string, query string, n int) []string {
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(w)) >= 3 && !slices.Contains(terms, w) {
+			terms = append(terms, w)
+		}
+	}
+	type match struct {
+		url   string
+		score int
+	}
+	var matches []match
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		path := strings.ToLower(u.Path)
+		score := 0
+		for _, t := range terms {
+			if strings.Contains(path, t) {
+				score++
+			}
+		}
+		if score > 0 {
+			matches = append(matches, match{raw, score})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	picked := make([]string, len(matches))
+	for i, m := range matches {
+		picked[i] = m.url
+	}
+	return picked
+}
+
+// ScrapeSite scrapes the pages of site whose URLs match query, at most
+// limit of them: the "search this one site" counterpart to searching the
+// web. See Sitemap for what site may be and MatchSitemap for the matching.
+func ScrapeSite(ctx context.Context, site, query string, limit int, opts Options) ([]ScrapedPage, error) {
+	urls, err := Sitemap(ctx, site, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ScrapeWithOptions(ctx, MatchSitemap(urls, query, limit), opts), nil
+}
@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSitemap(t *testing.T) {
+	var srvURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/docs.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/blog.xml.gz</loc></sitemap>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+</sitemapindex>`, srvURL)
+	})
+	mux.HandleFunc("/docs.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%[1]s/docs/install</loc></url>
+  <url><loc> %[1]s/docs/tutorial/generics </loc></url>
+  <url><loc>%[1]s/docs/install</loc></url>
+</urlset>`, srvURL)
+	})
+	mux.HandleFunc("/blog.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		fmt.Fprintf(zw, `<urlset><url><loc>%s/blog/generics-are-here</loc></url></urlset>`, srvURL)
+		zw.Close()
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(b.Bytes())
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	srvURL = srv.URL
+	ctx := WithHTTPClient(context.Background(), srv.Client())
+	opts := Options{HostDelay: -1}
+
+	urls, err := Sitemap(ctx, srv.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{srv.URL + "/docs/install", srv.URL + "/docs/tutorial/generics", srv.URL + "/blog/generics-are-here"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("Sitemap = %q, want %q", urls, want)
+	}
+
+	urls, err = Sitemap(ctx, srv.URL+"/docs/", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 {
+		t.Errorf("under /docs/: Sitemap = %q, want the two docs pages", urls)
+	}
+}
+
+func TestSitemapRobots(t *testing.T) {
+	var srvURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "User-agent: *\nDisallow: /private/\nSitemap: %s/pages.xml\n", srvURL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset><url><loc>%s/about</loc></url></urlset>`, srvURL)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	srvURL = srv.URL
+	ctx := WithHTTPClient(context.Background(), srv.Client())
+
+	urls, err := Sitemap(ctx, srv.URL, Options{HostDelay: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/about"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("Sitemap = %q, want %q", urls, want)
+	}
+
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+	ctx = WithHTTPClient(context.Background(), empty.Client())
+	if _, err := Sitemap(ctx, empty.URL, Options{HostDelay: -1}); !errors.Is(err, ErrNoSitemap) {
+		t.Errorf("no sitemap: err = %v, want ErrNoSitemap", err)
+	}
+}
+
+func TestMatchSitemap(t *testing.T) {
+	urls := []string{
+		"https://go.dev/doc/install",
+		"https://go.dev/blog/intro-generics",
+		"https://go.dev/doc/tutorial/generics",
+		"https://go.dev/doc/tutorial/getting-started",
+	}
+	tests := []struct {
+		query string
+		n     int
+		want  []string
+	}{
+		{"generics tutorial", 5, []string{"https://go.dev/doc/tutorial/generics", "https://go.dev/blog/intro-generics", "https://go.dev/doc/tutorial/getting-started"}},
+		{"Generics", 1, []string{"https://go.dev/blog/intro-generics"}},
+		{"go", 5, []string{}},
+		{"kubernetes", 5, []string{}},
+	}
+	for _, tt := range tests {
+		if got := MatchSitemap(urls, tt.query, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchSitemap(%q, %d) = %q, want %q", tt.query, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestValidSite(t *testing.T) {
+	for site, ok := range map[string]bool{
+		"go.dev":                    true,
+		"https://example.com/docs/": true,
+		"http://localhost:8080":     true,
+		"":                          false,
+		"ftp://example.com":         false,
+	} {
+		if err := ValidSite(site); (err == nil) != ok {
+			t.Errorf("ValidSite(%q) = %v, want ok=%v", site, err, ok)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings breaks down where the time went while scraping one page. Phases
+// that did not happen (e.g. DNS and TLS on a reused connection) are zero.
+type Timings struct {
+	Queue    time.Duration // waiting for other fetches from the same host
+	DNS      time.Duration // name resolution
+	Connect  time.Duration // TCP connect
+	TLS      time.Duration // TLS handshake
+	TTFB     time.Duration // request written until first response byte
+	Download time.Duration // first byte until body fully read
+	Extract  time.Duration // text extraction
+	Total    time.Duration // wall time for the whole page, including queueing and rendering
+	Reused   bool          // connection came from the pool
+}
+
+// tracer collects httptrace callbacks for a single request.
+type tracer struct {
+	mu                         sync.Mutex
+	dnsStart, connStart        time.Time
+	tlsStart, wroteRequest     time.Time
+	firstByte                  time.Time
+	dns, connect, tlsHandshake time.Duration
+	reused                     bool
+}
+
+// withTrace returns ctx instrumented to record connection phases into t.
+func (t *tracer) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.since(&t.dns, &t.dnsStart) },
+		ConnectStart:      func(string, string) { t.set(&t.connStart) },
+		ConnectDone:       func(string, string, error) { t.since(&t.connect, &t.connStart) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.since(&t.tlsHandshake, &t.tlsStart) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	})
+}
+
+func (t *tracer) set(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tracer) since(d *time.Duration, start *time.Time) {
+	t.mu.Lock()
+	if !start.IsZero() {
+		*d = time.Since(*start)
+	}
+	t.mu.Unlock()
+}
+
+// timings returns the connection phases recorded so far.
+func (t *tracer) timings() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm := Timings{DNS: t.dns, Connect: t.connect, TLS: t.tlsHandshake, Reused: t.reused}
+	if !t.wroteRequest.IsZero() && !t.firstByte.IsZero() {
+		tm.TTFB = t.firstByte.Sub(t.wroteRequest)
+	}
+	return tm
+}
+
+// ScrapeStats aggregates page timings since the process started (or the
+// last ResetStats). Durations are sums; use Avg for per-page means.
+type ScrapeStats struct {
+	Pages  int64 // pages attempted
+	Errors int64 // pages that failed
+	Reused int64 // pages fetched over a pooled connection, skipping DNS, connect and TLS
+	Sum    Timings
+}
+
+// Avg returns the mean timings per page.
+func (s ScrapeStats) Avg() Timings {
+	if s.Pages == 0 {
+		return Timings{}
+	}
+	n := time.Duration(s.Pages)
+	return Timings{
+		Queue:    s.Sum.Queue / n,
+		DNS:      s.Sum.DNS / n,
+		Connect:  s.Sum.Connect / n,
+		TLS:      s.Sum.TLS / n,
+		TTFB:     s.Sum.TTFB / n,
+		Download: s.Sum.Download / n,
+		Extract:  s.Sum.Extract / n,
+		Total:    s.Sum.Total / n,
+	}
+}
+
+var (
+	statsMu sync.Mutex
+	stats   ScrapeStats
+)
+
+// recordTimings adds one scraped page to the aggregate statistics.
+func recordTimings(t Timings, err error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats.Pages++
+	if err != nil {
+		stats.Errors++
+	}
+	if t.Reused {
+		stats.Reused++
+	}
+	stats.Sum.Queue += t.Queue
+	stats.Sum.DNS += t.DNS
+	stats.Sum.Connect += t.Connect
+	stats.Sum.TLS += t.TLS
+	stats.Sum.TTFB += t.TTFB
+	stats.Sum.Download += t.Download
+	stats.Sum.Extract += t.Extract
+	stats.Sum.Total += t.Total
+}
+
+// Stats returns a snapshot of aggregate scrape timings.
+func Stats() ScrapeStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	return stats
+}
+
+// ResetStats clears the aggregate scrape timings.
+func ResetStats() {
+	statsMu.Lock()
+	stats = ScrapeStats{}
+	statsMu.Unlock()
+}
@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFetchTimings(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+
+	serverURL, cleanup := setupScrapeServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Timed", "A page whose server takes a little while to answer requests.")))
+	}))
+	defer cleanup()
+
+	pages := Scrape(context.Background(), []string{serverURL + "/timed", serverURL + "/missing\x7f"})
+	if pages[0].Err != nil {
+		t.Fatalf("unexpected error: %v", pages[0].Err)
+	}
+	tm := pages[0].Timings
+	if tm.TTFB < 20*time.Millisecond {
+		t.Errorf("TTFB = %v, want >= 20ms server delay", tm.TTFB)
+	}
+	if tm.Connect <= 0 && !tm.Reused {
+		t.Errorf("Connect = %v on a fresh connection", tm.Connect)
+	}
+	if tm.Total < tm.TTFB+tm.Download+tm.Extract {
+		t.Errorf("Total %v is less than the sum of its phases %+v", tm.Total, tm)
+	}
+
+	s := Stats()
+	if s.Pages != 2 || s.Errors != 1 {
+		t.Errorf("stats = %d pages, %d errors; want 2, 1", s.Pages, s.Errors)
+	}
+	if avg := s.Avg(); avg.Total <= 0 {
+		t.Errorf("Avg().Total = %v, want > 0", avg.Total)
+	}
+}
@@ -0,0 +1,34 @@
+package scraper
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection pool sizing for the shared transport. Every scrape, robots.txt
+// and sitemap fetch goes through one transport, so a page's connection,
+// and its TLS session, is there for the next page from the same host.
+const (
+	maxIdleConns        = 256              // idle connections kept across all hosts
+	maxIdleConnsPerHost = 16               // idle connections kept per host; net/http's default of 2 drops most of them
+	idleConnTimeout     = 90 * time.Second // how long an idle connection is kept
+)
+
+// defaultDialer matches http.DefaultTransport's dialer.
+var defaultDialer = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+// newTransport returns the scraper's HTTP transport: the default one, with
+// a larger connection pool, HTTP/2 where servers offer it, and proxies
+// chosen and internal addresses refused per request.
+func newTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = requestProxy
+	t.DialContext = guardedDial(defaultDialer)
+	// A custom dialer turns off HTTP/2 unless it is forced back on.
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConns = maxIdleConns
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	t.IdleConnTimeout = idleConnTimeout
+	return guardedTransport{t}
+}
@@ -0,0 +1,37 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSharedTransportReusesConnections(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeArticlePage("Pooled", "Pages from one host should share a connection rather than dial again.")))
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: newTransport()}
+	ctx := WithHTTPClient(context.Background(), client)
+
+	// Cookie jars get their own client but must still share the pool.
+	for _, cookies := range []bool{false, false, true} {
+		page := ScrapeWithOptions(ctx, []string{srv.URL + "/page"}, Options{HostDelay: -1, Cookies: cookies})[0]
+		if page.Err != nil {
+			t.Fatal(page.Err)
+		}
+	}
+	if s := Stats(); s.Pages != 3 || s.Reused != 2 {
+		t.Errorf("stats = %d pages, %d reused; want 3, 2", s.Pages, s.Reused)
+	}
+
+	tr := client.Transport.(guardedTransport)
+	if tr.MaxIdleConnsPerHost < 8 || !tr.ForceAttemptHTTP2 {
+		t.Errorf("transport MaxIdleConnsPerHost = %d, ForceAttemptHTTP2 = %v", tr.MaxIdleConnsPerHost, tr.ForceAttemptHTTP2)
+	}
+}
@@ -0,0 +1,248 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Engine names as used for rate limits and statistics.
+const (
+	EngineGoogle     = "google"
+	EngineDuckDuckGo = "duckduckgo"
+)
+
+// ErrUnknownEngine is returned for an engine name that is neither a
+// supported engine nor an alias of one.
+var ErrUnknownEngine = errors.New("unknown search engine")
+
+// ValidEngine reports whether name is a supported engine or alias. The empty
+// string selects the default.
+func ValidEngine(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", EngineGoogle, EngineDuckDuckGo, "ddg":
+		return true
+	}
+	return false
+}
+
+// knownEngine reports whether name is an engine or alias, unlike
+// ValidEngine not accepting the empty default.
+func knownEngine(name string) bool {
+	return strings.TrimSpace(name) != "" && ValidEngine(name)
+}
+
+// CanonicalEngine maps an engine name or alias to its canonical name.
+// Unknown names resolve to Google, matching Search's default.
+func CanonicalEngine(engine string) string {
+	switch strings.ToLower(strings.TrimSpace(engine)) {
+	case "duckduckgo", "ddg":
+		return EngineDuckDuckGo
+	default:
+		return EngineGoogle
+	}
+}
+
+// limiter is a token bucket for one engine: it holds up to burst tokens,
+// refilled one per interval, and each request takes one. With a burst of
+// one, requests are simply spaced by interval.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   float64   // may go negative: requests reserved but not yet due
+	last     time.Time // when tokens was last brought up to date
+}
+
+func newLimiter(interval time.Duration, burst int) *limiter {
+	burst = max(burst, 1)
+	return &limiter{interval: interval, burst: burst, tokens: float64(burst)}
+}
+
+// reserve takes a token, returning how long the caller must wait for it.
+// Each call reserves its slot up front, so concurrent callers are spaced
+// evenly.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = min(l.tokens+float64(now.Sub(l.last))/float64(l.interval), float64(l.burst))
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// cancel returns a token reserved by a caller that gave up waiting, so it
+// does not hold back the callers behind it.
+func (l *limiter) cancel() {
+	l.mu.Lock()
+	l.tokens = min(l.tokens+1, float64(l.burst))
+	l.mu.Unlock()
+}
+
+// wait blocks until the caller's turn or until ctx is done. A turn that
+// would come after ctx's deadline fails at once with
+// context.DeadlineExceeded rather than sleeping in vain.
+func (l *limiter) wait(ctx context.Context) error {
+	now := time.Now()
+	d := l.reserve(now)
+	if d <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(d)) {
+		l.cancel()
+		return context.DeadlineExceeded
+	}
+	if w, ok := ctx.Value(waitKey{}).(*WaitRecorder); ok {
+		defer func(begin time.Time) { w.add(time.Since(begin)) }(time.Now())
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// WaitRecorder totals the time SERP requests spend waiting for their
+// rate limit. It is safe for concurrent use.
+type WaitRecorder struct {
+	ns atomic.Int64
+}
+
+type waitKey struct{}
+
+// WithWaitRecorder returns a context under which rate limit waits are added
+// to w.
+func WithWaitRecorder(ctx context.Context, w *WaitRecorder) context.Context {
+	return context.WithValue(ctx, waitKey{}, w)
+}
+
+// Total returns the time waited so far.
+func (w *WaitRecorder) Total() time.Duration {
+	return time.Duration(w.ns.Load())
+}
+
+func (w *WaitRecorder) add(d time.Duration) {
+	w.ns.Add(int64(d))
+}
+
+// RateLimiter spaces SERP requests to each engine by a minimum interval,
+// optionally letting a burst of them through back to back first. It is
+// safe for concurrent use, and one RateLimiter shared by concurrent
+// searches paces them together. A nil *RateLimiter imposes no limits.
+type RateLimiter struct {
+	limiters map[string]*limiter // fixed at construction
+}
+
+// NewRateLimiter returns a limiter enforcing the minimum delay between
+// consecutive requests to each engine, keyed by engine name (aliases are
+// accepted). Engines without a positive entry are not limited, and names
+// that are not engines are ignored.
+func NewRateLimiter(limits map[string]time.Duration) *RateLimiter {
+	return NewBurstRateLimiter(limits, 1)
+}
+
+// NewBurstRateLimiter is like NewRateLimiter but lets up to burst requests
+// to an engine through without waiting, after which they are spaced by its
+// interval again. An idle engine earns back one request per interval, up
+// to burst. A burst below 1 is treated as 1.
+func NewBurstRateLimiter(limits map[string]time.Duration, burst int) *RateLimiter {
+	m := make(map[string]*limiter, len(limits))
+	for name, d := range limits {
+		if d > 0 && knownEngine(name) {
+			m[CanonicalEngine(name)] = newLimiter(d, burst)
+		}
+	}
+	return &RateLimiter{limiters: m}
+}
+
+// Wait blocks until the given engine may be queried again, or until ctx is
+// done. A turn that would come after ctx's deadline fails at once. Callers
+// making their own requests to an engine use it to share the limits of a
+// RateLimiter passed to SearchWithLimiter.
+func (r *RateLimiter) Wait(ctx context.Context, engine string) error {
+	return r.wait(ctx, CanonicalEngine(engine))
+}
+
+// wait is Wait for a canonical engine name.
+func (r *RateLimiter) wait(ctx context.Context, engine string) error {
+	if r == nil {
+		return nil
+	}
+	l := r.limiters[engine]
+	if l == nil {
+		return nil
+	}
+	if err := l.wait(ctx); err != nil {
+		return fmt.Errorf("rate limit wait: %w", err)
+	}
+	return nil
+}
+
+var (
+	limitersMu     sync.Mutex
+	defaultLimiter *RateLimiter
+)
+
+// SetRateLimits configures the limits used by the package-level Search and
+// SearchWithAnswer functions, replacing any previous configuration. Code
+// that needs its own limits, such as each engine.Engine, uses a RateLimiter
+// with SearchWithLimiter instead.
+func SetRateLimits(limits map[string]time.Duration) {
+	rl := NewRateLimiter(limits)
+	limitersMu.Lock()
+	defaultLimiter = rl
+	limitersMu.Unlock()
+}
+
+// packageLimiter returns the limiter installed by SetRateLimits.
+func packageLimiter() *RateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	return defaultLimiter
+}
+
+// waitTurn blocks until the given engine may be queried again under the
+// package-level limits.
+func waitTurn(ctx context.Context, engine string) error {
+	return packageLimiter().wait(ctx, engine)
+}
+
+// ParseRateLimits parses a comma-separated list of engine=duration pairs,
+// e.g. "google=2s,duckduckgo=500ms", keyed by canonical engine name. An
+// unknown engine fails with ErrUnknownEngine rather than being taken for
+// Google.
+func ParseRateLimits(s string) (map[string]time.Duration, error) {
+	limits := make(map[string]time.Duration)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("search: invalid rate limit %q, want engine=duration", part)
+		}
+		if !knownEngine(name) {
+			return nil, fmt.Errorf("search: rate limit for %q: %w", name, ErrUnknownEngine)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("search: invalid rate limit for %s: %w", name, err)
+		}
+		limits[CanonicalEngine(name)] = d
+	}
+	return limits, nil
+}
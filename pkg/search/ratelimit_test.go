@@ -0,0 +1,177 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCanonicalEngine(t *testing.T) {
+	tests := map[string]string{
+		"google":     EngineGoogle,
+		"":           EngineGoogle,
+		"DuckDuckGo": EngineDuckDuckGo,
+		"ddg":        EngineDuckDuckGo,
+	}
+	for in, want := range tests {
+		if got := CanonicalEngine(in); got != want {
+			t.Errorf("CanonicalEngine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidEngine(t *testing.T) {
+	for _, name := range []string{"", "google", "DuckDuckGo", "ddg"} {
+		if !ValidEngine(name) {
+			t.Errorf("ValidEngine(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"bing", "yahoo", "goggle"} {
+		if ValidEngine(name) {
+			t.Errorf("ValidEngine(%q) = true", name)
+		}
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	got, err := ParseRateLimits("google=2s, ddg=500ms")
+	if err != nil {
+		t.Fatalf("ParseRateLimits: %v", err)
+	}
+	if got[EngineGoogle] != 2*time.Second {
+		t.Errorf("google = %v, want 2s", got[EngineGoogle])
+	}
+	if got[EngineDuckDuckGo] != 500*time.Millisecond {
+		t.Errorf("duckduckgo = %v, want 500ms", got[EngineDuckDuckGo])
+	}
+
+	if _, err := ParseRateLimits("google"); err == nil {
+		t.Error("expected error for missing duration")
+	}
+	if _, err := ParseRateLimits("google=fast"); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+	// A typo must not silently replace Google's limit.
+	for _, s := range []string{"google=2s,bing=10s", "gogle=1s", "=1s"} {
+		if got, err := ParseRateLimits(s); !errors.Is(err, ErrUnknownEngine) {
+			t.Errorf("ParseRateLimits(%q) = %v, %v; want ErrUnknownEngine", s, got, err)
+		}
+	}
+}
+
+func TestRateLimitPerEngine(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body></body></html>`))
+	}))
+	defer cleanup()
+
+	const interval = 150 * time.Millisecond
+	SetRateLimits(map[string]time.Duration{"google": interval})
+	defer SetRateLimits(nil)
+
+	ctx := context.Background()
+
+	// DuckDuckGo has no limit and must never wait.
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := Search(ctx, "q", 5, "ddg"); err != nil {
+			t.Fatalf("Search ddg: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("unlimited engine took %v, expected no rate-limit delay", elapsed)
+	}
+
+	// Two Google searches must be spaced by at least the interval.
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := Search(ctx, "q", 5, "google"); err != nil {
+			t.Fatalf("Search google: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("two google searches took %v, want at least %v", elapsed, interval)
+	}
+}
+
+func TestRateLimitContextCancel(t *testing.T) {
+	SetRateLimits(map[string]time.Duration{"google": time.Hour})
+	defer SetRateLimits(nil)
+
+	// The first call consumes the free slot; the second must wait an hour
+	// unless the context is cancelled.
+	if err := waitTurn(context.Background(), EngineGoogle); err != nil {
+		t.Fatalf("first waitTurn: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitTurn(ctx, EngineGoogle); err == nil {
+		t.Fatal("expected context error while waiting for rate limit")
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	rl := NewBurstRateLimiter(map[string]time.Duration{"google": interval}, 3)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(ctx, "google"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= interval/2 {
+		t.Errorf("burst of 3 took %v, want no wait", elapsed)
+	}
+	if err := rl.Wait(ctx, "google"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < interval*8/10 {
+		t.Errorf("fourth request after %v, want about %v", elapsed, interval)
+	}
+}
+
+func TestRateLimitDeadline(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	rl := NewRateLimiter(map[string]time.Duration{"ddg": interval})
+	if err := rl.Wait(context.Background(), "ddg"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A turn past the deadline fails without waiting and gives its slot
+	// back, so the next caller waits one interval, not two.
+	ctx, cancel := context.WithTimeout(context.Background(), interval/10)
+	defer cancel()
+	start := time.Now()
+	if err := rl.Wait(ctx, "ddg"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= interval/10 {
+		t.Errorf("failed after %v, want at once", elapsed)
+	}
+	if err := rl.Wait(context.Background(), "ddg"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > interval*3/2 {
+		t.Errorf("next turn after %v, want about %v", elapsed, interval)
+	}
+}
+
+func TestWaitRecorder(t *testing.T) {
+	rl := NewRateLimiter(map[string]time.Duration{"google": 30 * time.Millisecond})
+	var w WaitRecorder
+	ctx := WithWaitRecorder(context.Background(), &w)
+
+	rl.wait(ctx, EngineGoogle) // free slot
+	if w.Total() != 0 {
+		t.Errorf("Total after an immediate turn = %v, want 0", w.Total())
+	}
+	rl.wait(ctx, EngineGoogle)
+	if got := w.Total(); got < 20*time.Millisecond || got > time.Second {
+		t.Errorf("Total = %v, want about 30ms", got)
+	}
+}
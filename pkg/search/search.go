@@ -0,0 +1,374 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Result holds a single search-engine result.
+type Result struct {
+	URL       string
+	Title     string
+	Sponsored bool // an ad or paid placement rather than an organic result
+}
+
+// InstantAnswer holds a knowledge-panel style answer shown above the organic
+// results (currently only extracted from DuckDuckGo).
+type InstantAnswer struct {
+	Heading string
+	Text    string
+	URL     string // source of the answer, e.g. a Wikipedia article
+}
+
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// Package-level defaults, used when the context carries no override from
+// WithHTTPClient, WithBaseURLs or WithLogger.
+var (
+	httpClient        = http.DefaultClient
+	baseURLGoogle     = "https://www.google.com"
+	baseURLDuckDuckGo = "https://html.duckduckgo.com"
+)
+
+type clientKey struct{}
+
+type baseURLsKey struct{}
+
+type loggerKey struct{}
+
+type baseURLs struct{ google, ddg string }
+
+// WithHTTPClient returns a context under which searches send their requests
+// through c. Only calls made with the context are affected, so concurrent
+// tests and embedders can each bring their own client.
+func WithHTTPClient(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// WithBaseURLs returns a context under which Google and DuckDuckGo requests
+// go to the given base URLs, such as a test server's. An empty URL keeps
+// that engine's default.
+func WithBaseURLs(ctx context.Context, google, ddg string) context.Context {
+	return context.WithValue(ctx, baseURLsKey{}, baseURLs{google, ddg})
+}
+
+// WithLogger returns a context under which searches log each results page
+// fetched, at debug level, and each failure, as a warning, to l. Without
+// it nothing is logged.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFor returns the logger set by WithLogger, or one that discards.
+func loggerFor(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logResult logs the outcome of one results page request that started at
+// start.
+func logResult(ctx context.Context, engine string, start time.Time, n int, err error) {
+	l := loggerFor(ctx)
+	if err != nil {
+		l.WarnContext(ctx, "search failed", "engine", engine, "duration", time.Since(start), "blocked", errors.Is(err, ErrBlocked), "err", err)
+		return
+	}
+	l.DebugContext(ctx, "search results", "engine", engine, "results", n, "duration", time.Since(start))
+}
+
+// clientFor returns the client set by WithHTTPClient, or the package
+// default.
+func clientFor(ctx context.Context) *http.Client {
+	if c, ok := ctx.Value(clientKey{}).(*http.Client); ok && c != nil {
+		return c
+	}
+	return httpClient
+}
+
+// baseURLFor returns engine's base URL, honoring WithBaseURLs.
+func baseURLFor(ctx context.Context, engine string) string {
+	u, _ := ctx.Value(baseURLsKey{}).(baseURLs)
+	if engine == EngineDuckDuckGo {
+		if u.ddg != "" {
+			return u.ddg
+		}
+		return baseURLDuckDuckGo
+	}
+	if u.google != "" {
+		return u.google
+	}
+	return baseURLGoogle
+}
+
+// OverrideHTTPClient replaces the HTTP client used by the search
+// package and returns a function to restore the original.
+//
+// Deprecated: Use WithHTTPClient, which does not race with concurrent
+// callers.
+func OverrideHTTPClient(c *http.Client) (restore func()) {
+	orig := httpClient
+	httpClient = c
+	return func() { httpClient = orig }
+}
+
+// OverrideBaseURLs replaces the base URLs used for Google and DuckDuckGo
+// search and returns a function to restore the originals.
+//
+// Deprecated: Use WithBaseURLs, which does not race with concurrent
+// callers.
+func OverrideBaseURLs(google, ddg string) (restore func()) {
+	origG, origD := baseURLGoogle, baseURLDuckDuckGo
+	baseURLGoogle = google
+	baseURLDuckDuckGo = ddg
+	return func() { baseURLGoogle = origG; baseURLDuckDuckGo = origD }
+}
+
+// Search scrapes a search engine results page and returns up to count results.
+// Supported engines: "google" (default), "duckduckgo".
+func Search(ctx context.Context, query string, count int, engine string) ([]Result, error) {
+	results, _, err := SearchWithAnswer(ctx, query, count, engine)
+	return results, err
+}
+
+// SearchWithAnswer is like Search but also returns the engine's instant
+// answer when the results page has one. The answer is nil otherwise.
+func SearchWithAnswer(ctx context.Context, query string, count int, engine string) ([]Result, *InstantAnswer, error) {
+	return SearchWithLimiter(ctx, packageLimiter(), query, count, engine)
+}
+
+// SearchWithLimiter is like SearchWithAnswer but paces requests with rl
+// rather than the package-level limits. A nil rl does not wait.
+func SearchWithLimiter(ctx context.Context, rl *RateLimiter, query string, count int, engine string) ([]Result, *InstantAnswer, error) {
+	switch CanonicalEngine(engine) {
+	case EngineDuckDuckGo:
+		return searchDuckDuckGo(ctx, rl, query, count)
+	default: // google
+		results, err := searchGoogle(ctx, rl, query, count)
+		return results, nil, err
+	}
+}
+
+func searchGoogle(ctx context.Context, rl *RateLimiter, query string, count int) ([]Result, error) {
+	u := fmt.Sprintf("%s/search?q=%s&num=%d",
+		baseURLFor(ctx, EngineGoogle), url.QueryEscape(query), count)
+
+	if err := rl.wait(ctx, EngineGoogle); err != nil {
+		return nil, fmt.Errorf("search google: %w", err)
+	}
+	start := time.Now()
+	doc, err := fetchDocument(ctx, u)
+	if err != nil {
+		recordResult(EngineGoogle, 0, err)
+		logResult(ctx, EngineGoogle, start, 0, err)
+		return nil, fmt.Errorf("search google: %w", err)
+	}
+	results := parseGoogle(doc, count)
+	recordResult(EngineGoogle, len(results), nil)
+	logResult(ctx, EngineGoogle, start, len(results), nil)
+	return results, nil
+}
+
+// parseGoogle extracts up to count organic results from a Google SERP.
+// Ads found along the way are included, tagged Sponsored, and do not count
+// toward count.
+func parseGoogle(doc *goquery.Document, count int) []Result {
+	var results []Result
+	organic := 0
+	// Google wraps organic results in divs with class "g".
+	doc.Find("div.g").Each(func(_ int, s *goquery.Selection) {
+		if organic >= count {
+			return
+		}
+		link := s.Find("a").First()
+		href, exists := link.Attr("href")
+		if !exists || href == "" {
+			return
+		}
+		sponsored := isSponsored(s, href, googleAdSelector)
+		if sponsored {
+			href = adTarget(href)
+		}
+		// Skip Google's own links and unresolvable ad redirects.
+		if strings.HasPrefix(href, "/") || strings.Contains(href, "google.com") {
+			return
+		}
+		title := s.Find("h3").First().Text()
+		if title == "" {
+			title = link.Text()
+		}
+		results = append(results, Result{URL: href, Title: strings.TrimSpace(title), Sponsored: sponsored})
+		if !sponsored {
+			organic++
+		}
+	})
+
+	if organic == 0 {
+		// Fallback: try extracting all anchor tags with absolute URLs.
+		results = nil
+		doc.Find("a").Each(func(_ int, s *goquery.Selection) {
+			if organic >= count {
+				return
+			}
+			href, exists := s.Attr("href")
+			if !exists {
+				return
+			}
+			sponsored := isSponsored(s, href, googleAdSelector)
+			if sponsored {
+				href = adTarget(href)
+			}
+			// Extract URL from Google redirect links: /url?q=...&sa=...
+			if strings.HasPrefix(href, "/url?") {
+				if parsed, err := url.Parse(href); err == nil {
+					href = parsed.Query().Get("q")
+				}
+			}
+			if href == "" || !strings.HasPrefix(href, "http") {
+				return
+			}
+			if strings.Contains(href, "google.com") || strings.Contains(href, "youtube.com") {
+				return
+			}
+			title := strings.TrimSpace(s.Text())
+			if title == "" || len(title) > 200 {
+				return
+			}
+			results = append(results, Result{URL: href, Title: title, Sponsored: sponsored})
+			if !sponsored {
+				organic++
+			}
+		})
+	}
+
+	return results
+}
+
+func searchDuckDuckGo(ctx context.Context, rl *RateLimiter, query string, count int) ([]Result, *InstantAnswer, error) {
+	u := fmt.Sprintf("%s/html/?q=%s", baseURLFor(ctx, EngineDuckDuckGo), url.QueryEscape(query))
+
+	if err := rl.wait(ctx, EngineDuckDuckGo); err != nil {
+		return nil, nil, fmt.Errorf("search duckduckgo: %w", err)
+	}
+	start := time.Now()
+	doc, err := fetchDocument(ctx, u)
+	if err != nil {
+		recordResult(EngineDuckDuckGo, 0, err)
+		logResult(ctx, EngineDuckDuckGo, start, 0, err)
+		return nil, nil, fmt.Errorf("search duckduckgo: %w", err)
+	}
+	results := parseDuckDuckGo(doc, count)
+	recordResult(EngineDuckDuckGo, len(results), nil)
+	logResult(ctx, EngineDuckDuckGo, start, len(results), nil)
+	return results, parseDuckDuckGoAnswer(doc), nil
+}
+
+// parseDuckDuckGoAnswer extracts the zero-click info box DuckDuckGo renders
+// above the results for entities, definitions, and similar queries.
+func parseDuckDuckGoAnswer(doc *goquery.Document) *InstantAnswer {
+	box := doc.Find("div.zci").First()
+	if box.Length() == 0 {
+		return nil
+	}
+
+	abstract := box.Find("#zero_click_abstract, .zci__result").First()
+	// The abstract usually ends with a "More at <source>" link; keep its URL
+	// but not its text. Other links are part of the answer.
+	var source, moreText string
+	abstract.Find("a").Each(func(_ int, a *goquery.Selection) {
+		if text := strings.TrimSpace(a.Text()); strings.HasPrefix(text, "More at") {
+			source, _ = a.Attr("href")
+			moreText = text
+		}
+	})
+
+	text := strings.TrimSpace(abstract.Text())
+	if moreText != "" {
+		text = strings.TrimSpace(strings.TrimSuffix(text, moreText))
+	}
+	if text == "" {
+		return nil
+	}
+
+	heading := strings.TrimSpace(box.Find(".zci__heading").First().Text())
+	return &InstantAnswer{Heading: heading, Text: text, URL: source}
+}
+
+// parseDuckDuckGo extracts up to count results from a DuckDuckGo HTML SERP.
+// Like parseGoogle, it tags ads rather than counting them.
+func parseDuckDuckGo(doc *goquery.Document, count int) []Result {
+	var results []Result
+	organic := 0
+	doc.Find("a.result__a").Each(func(_ int, s *goquery.Selection) {
+		if organic >= count {
+			return
+		}
+		href, exists := s.Attr("href")
+		if !exists || href == "" {
+			return
+		}
+		if isSponsored(s, href, duckDuckGoAdSelector) {
+			title := strings.TrimSpace(s.Text())
+			results = append(results, Result{URL: adTarget(href), Title: title, Sponsored: true})
+			return
+		}
+		// DuckDuckGo sometimes wraps URLs in a redirect.
+		if strings.Contains(href, "duckduckgo.com/l/?") {
+			if parsed, err := url.Parse(href); err == nil {
+				if uddg := parsed.Query().Get("uddg"); uddg != "" {
+					href = uddg
+				}
+			}
+		}
+		title := strings.TrimSpace(s.Text())
+		results = append(results, Result{URL: href, Title: title})
+		organic++
+	})
+
+	return results
+}
+
+func fetchDocument(ctx context.Context, rawURL string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := clientFor(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("status %d for %s: %w", resp.StatusCode, rawURL, ErrRateLimited)
+	}
+	if isBlockedStatus(resp.StatusCode) {
+		return nil, fmt.Errorf("status %d for %s: %w", resp.StatusCode, rawURL, ErrBlocked)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d for %s", resp.StatusCode, rawURL)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+	if isBlocked(resp, doc) {
+		return nil, fmt.Errorf("challenge page for %s: %w", rawURL, ErrBlocked)
+	}
+	return doc, nil
+}
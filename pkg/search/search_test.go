@@ -0,0 +1,468 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// fakeGoogleHTML returns a minimal Google-like SERP page with div.g results.
+func fakeGoogleHTML(links []struct{ URL, Title string }) string {
+	html := `<!DOCTYPE html><html><body>`
+	for _, l := range links {
+		html += `<div class="g"><a href="` + l.URL + `"><h3>` + l.Title + `</h3></a></div>`
+	}
+	html += `</body></html>`
+	return html
+}
+
+// fakeGoogleFallbackHTML returns a page with /url?q= redirect-style links
+// (no div.g), triggering the fallback parser.
+func fakeGoogleFallbackHTML(links []struct{ URL, Title string }) string {
+	html := `<!DOCTYPE html><html><body>`
+	for _, l := range links {
+		html += `<a href="/url?q=` + l.URL + `&sa=U">` + l.Title + `</a>`
+	}
+	html += `</body></html>`
+	return html
+}
+
+// fakeDuckDuckGoHTML returns a minimal DDG-like results page.
+func fakeDuckDuckGoHTML(links []struct{ URL, Title string }) string {
+	html := `<!DOCTYPE html><html><body>`
+	for _, l := range links {
+		html += `<a class="result__a" href="` + l.URL + `">` + l.Title + `</a>`
+	}
+	html += `</body></html>`
+	return html
+}
+
+// setupTestServer starts an httptest.Server and overrides the package-level
+// variables to point at it. It returns a cleanup function that restores
+// the original values.
+func setupTestServer(t *testing.T, handler http.Handler) (cleanup func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+
+	origClient := httpClient
+	origGoogle := baseURLGoogle
+	origDDG := baseURLDuckDuckGo
+
+	httpClient = srv.Client()
+	baseURLGoogle = srv.URL
+	baseURLDuckDuckGo = srv.URL
+
+	return func() {
+		srv.Close()
+		httpClient = origClient
+		baseURLGoogle = origGoogle
+		baseURLDuckDuckGo = origDDG
+	}
+}
+
+func TestSearchGoogle(t *testing.T) {
+	links := []struct{ URL, Title string }{
+		{"https://example.com/page1", "Example Page 1"},
+		{"https://example.com/page2", "Example Page 2"},
+		{"https://example.com/page3", "Example Page 3"},
+	}
+
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML(links)))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "test query", 5, "google")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	for i, r := range results {
+		if r.URL != links[i].URL {
+			t.Errorf("result[%d].URL = %q, want %q", i, r.URL, links[i].URL)
+		}
+		if r.Title != links[i].Title {
+			t.Errorf("result[%d].Title = %q, want %q", i, r.Title, links[i].Title)
+		}
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	// Two searches run at once, each against its own server, without
+	// touching the package-level defaults.
+	var wg sync.WaitGroup
+	for _, site := range []string{"a.example", "b.example"} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(fakeGoogleHTML([]struct{ URL, Title string }{{"https://" + site + "/", site}})))
+		}))
+		defer srv.Close()
+		ctx := WithBaseURLs(WithHTTPClient(context.Background(), srv.Client()), srv.URL, "")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, _, err := SearchWithLimiter(ctx, nil, "query", 5, EngineGoogle)
+			if err != nil {
+				t.Errorf("%s: %v", site, err)
+				return
+			}
+			if len(results) != 1 || results[0].URL != "https://"+site+"/" {
+				t.Errorf("%s: results = %+v", site, results)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := baseURLFor(WithBaseURLs(context.Background(), "http://g.test", ""), EngineDuckDuckGo); got != baseURLDuckDuckGo {
+		t.Errorf("empty DuckDuckGo override: base URL = %q, want the default", got)
+	}
+}
+
+func TestSearchGoogleCountLimit(t *testing.T) {
+	links := []struct{ URL, Title string }{
+		{"https://a.com", "A"},
+		{"https://b.com", "B"},
+		{"https://c.com", "C"},
+		{"https://d.com", "D"},
+	}
+
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML(links)))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "test", 2, "google")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2 (count limit)", len(results))
+	}
+}
+
+func TestSearchGoogleFallback(t *testing.T) {
+	links := []struct{ URL, Title string }{
+		{"https://example.com/fallback1", "Fallback 1"},
+		{"https://example.com/fallback2", "Fallback 2"},
+	}
+
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleFallbackHTML(links)))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "test", 5, "google")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected fallback parser to extract results, got 0")
+	}
+	// Fallback should have extracted at least the first link.
+	if results[0].URL != links[0].URL {
+		t.Errorf("result[0].URL = %q, want %q", results[0].URL, links[0].URL)
+	}
+}
+
+func TestParseSponsored(t *testing.T) {
+	google := `<html><body>
+<div id="tads"><div class="g"><a href="https://shop.example/deal"><h3>Deal</h3></a></div></div>
+<div class="g"><a href="https://www.googleadservices.com/pagead/aclk?sa=L&adurl=https://store.example/"><h3>Store</h3></a></div>
+<div class="g"><a href="https://go.dev/"><h3>Go</h3></a></div>
+<div class="g"><a href="https://pkg.go.dev/"><h3>Packages</h3></a></div>
+</body></html>`
+	ddg := `<html><body>
+<div class="result result--ad"><a class="result__a" href="//duckduckgo.com/y.js?ad_domain=shop.example&u3=x">Shop</a></div>
+<div class="result"><a class="result__a" href="https://go.dev/">Go</a></div>
+</body></html>`
+
+	tests := []struct {
+		name  string
+		html  string
+		parse func(*goquery.Document, int) []Result
+		count int
+		want  []Result
+	}{
+		{"google", google, parseGoogle, 1, []Result{
+			{URL: "https://shop.example/deal", Title: "Deal", Sponsored: true},
+			{URL: "https://store.example/", Title: "Store", Sponsored: true},
+			{URL: "https://go.dev/", Title: "Go"},
+		}},
+		{"duckduckgo", ddg, parseDuckDuckGo, 5, []Result{
+			{URL: "https://duckduckgo.com/y.js?ad_domain=shop.example&u3=x", Title: "Shop", Sponsored: true},
+			{URL: "https://go.dev/", Title: "Go"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatalf("parse html: %v", err)
+			}
+			got := tt.parse(doc, tt.count)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("result[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if organic := Organic(got); len(organic) != 1 || organic[0].URL != "https://go.dev/" {
+				t.Errorf("Organic = %+v", organic)
+			}
+		})
+	}
+}
+
+func TestSearchDuckDuckGo(t *testing.T) {
+	links := []struct{ URL, Title string }{
+		{"https://example.com/ddg1", "DDG Result 1"},
+		{"https://example.com/ddg2", "DDG Result 2"},
+	}
+
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeDuckDuckGoHTML(links)))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "duck test", 5, "duckduckgo")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, r := range results {
+		if r.URL != links[i].URL {
+			t.Errorf("result[%d].URL = %q, want %q", i, r.URL, links[i].URL)
+		}
+		if r.Title != links[i].Title {
+			t.Errorf("result[%d].Title = %q, want %q", i, r.Title, links[i].Title)
+		}
+	}
+}
+
+func TestSearchDDGAlias(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeDuckDuckGoHTML([]struct{ URL, Title string }{
+			{"https://example.com/1", "One"},
+		})))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "q", 5, "ddg")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1 (ddg alias)", len(results))
+	}
+}
+
+func TestSearchEmptyPage(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><body><p>No results</p></body></html>`))
+	}))
+	defer cleanup()
+
+	results, err := Search(context.Background(), "nothing here", 5, "google")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("got %d results, want 0 for empty page", len(results))
+	}
+}
+
+func TestSearchServerError(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer cleanup()
+
+	_, err := Search(context.Background(), "error", 5, "google")
+	if err == nil {
+		t.Fatal("expected error for 500 response, got nil")
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "blocked" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeGoogleHTML([]struct{ URL, Title string }{{"https://a.example/", "A"}})))
+	}))
+	defer srv.Close()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := WithLogger(WithBaseURLs(WithHTTPClient(context.Background(), srv.Client()), srv.URL, ""), logger)
+
+	if _, _, err := SearchWithLimiter(ctx, nil, "query", 5, EngineGoogle); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := SearchWithLimiter(ctx, nil, "blocked", 5, EngineGoogle); err == nil {
+		t.Fatal("blocked search: no error")
+	}
+	logs := buf.String()
+	if !strings.Contains(logs, `level=DEBUG msg="search results" engine=google results=1`) {
+		t.Errorf("missing results log:\n%s", logs)
+	}
+	if !strings.Contains(logs, `level=WARN msg="search failed" engine=google`) || !strings.Contains(logs, "blocked=true") {
+		t.Errorf("missing failure log:\n%s", logs)
+	}
+}
+
+// loadFixture reads a SERP fixture from testdata.
+func loadFixture(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatalf("read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(*goquery.Document, int) []Result
+	}{
+		{"google_serp.html", parseGoogle},
+		{"ddg_serp.html", parseDuckDuckGo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(bytes.NewReader(loadFixture(t, tt.name)))
+			if err != nil {
+				t.Fatalf("parse html: %v", err)
+			}
+			results := tt.parse(doc, 100)
+			if len(results) != 100 {
+				t.Fatalf("got %d results, want 100", len(results))
+			}
+			for i, r := range results {
+				if !strings.HasPrefix(r.URL, "https://") {
+					t.Errorf("result[%d].URL = %q, want absolute https URL", i, r.URL)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkParse(b *testing.B, fixture string, parse func(*goquery.Document, int) []Result) {
+	data := loadFixture(b, fixture)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+		if err != nil {
+			b.Fatalf("parse html: %v", err)
+		}
+		parse(doc, 100)
+	}
+}
+
+func BenchmarkParseGoogle(b *testing.B) {
+	benchmarkParse(b, "google_serp.html", parseGoogle)
+}
+
+func BenchmarkParseDuckDuckGo(b *testing.B) {
+	benchmarkParse(b, "ddg_serp.html", parseDuckDuckGo)
+}
+
+func TestSearchDuckDuckGoInstantAnswer(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><body>
+<div class="zci-wrapper"><div class="zci">
+<h1 class="zci__heading"><a href="https://en.wikipedia.org/wiki/Go">Go (programming language)</a></h1>
+<div class="zci__result" id="zero_click_abstract">Go is a statically typed, compiled language designed at Google.
+<a href="https://en.wikipedia.org/wiki/Go_(programming_language)">More at Wikipedia</a></div>
+</div></div>
+<a class="result__a" href="https://go.dev">The Go Programming Language</a>
+</body></html>`))
+	}))
+	defer cleanup()
+
+	results, answer, err := SearchWithAnswer(context.Background(), "golang", 5, "duckduckgo")
+	if err != nil {
+		t.Fatalf("SearchWithAnswer: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if answer == nil {
+		t.Fatal("expected instant answer, got nil")
+	}
+	if answer.Heading != "Go (programming language)" {
+		t.Errorf("Heading = %q", answer.Heading)
+	}
+	if answer.Text != "Go is a statically typed, compiled language designed at Google." {
+		t.Errorf("Text = %q", answer.Text)
+	}
+	if answer.URL != "https://en.wikipedia.org/wiki/Go_(programming_language)" {
+		t.Errorf("URL = %q", answer.URL)
+	}
+}
+
+func TestSearchDuckDuckGoNoInstantAnswer(t *testing.T) {
+	cleanup := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(fakeDuckDuckGoHTML([]struct{ URL, Title string }{
+			{"https://example.com/1", "One"},
+		})))
+	}))
+	defer cleanup()
+
+	_, answer, err := SearchWithAnswer(context.Background(), "q", 5, "ddg")
+	if err != nil {
+		t.Fatalf("SearchWithAnswer: %v", err)
+	}
+	if answer != nil {
+		t.Errorf("expected no instant answer, got %+v", answer)
+	}
+}
+
+func TestParseDuckDuckGoAnswerWithoutMoreLink(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="zci">
+<div id="zero_click_abstract">Go was designed at <a href="https://google.com">Google</a> in 2007.</div>
+</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer := parseDuckDuckGoAnswer(doc)
+	if answer == nil {
+		t.Fatal("expected instant answer, got nil")
+	}
+	if answer.Text != "Go was designed at Google in 2007." {
+		t.Errorf("Text = %q", answer.Text)
+	}
+	if answer.URL != "" {
+		t.Errorf("URL = %q, want empty without a More at link", answer.URL)
+	}
+}
@@ -0,0 +1,156 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Verdict summarizes how a search engine treats the current egress IP.
+type Verdict string
+
+const (
+	VerdictClean       Verdict = "clean"        // every probe returned results
+	VerdictEmpty       Verdict = "empty"        // responses parsed but had no results: a soft block or changed markup
+	VerdictRateLimited Verdict = "rate_limited" // the engine answered 429
+	VerdictBlocked     Verdict = "blocked"      // CAPTCHA, challenge page, or 403
+	VerdictUnreachable Verdict = "unreachable"  // network errors or unexpected statuses
+	VerdictSkipped     Verdict = "skipped"      // no probe was sent; see ErrProbeSkipped
+)
+
+// ErrProbeSkipped is wrapped by a ProbeFunc that declined to send a probe,
+// such as when a search budget is spent or a circuit breaker is open.
+var ErrProbeSkipped = errors.New("probe skipped")
+
+// ProbeFunc sends one canary search for SelfCheckWith.
+type ProbeFunc func(ctx context.Context, query string, count int, engine string) ([]Result, error)
+
+// canaryQueries are searches every engine answers with plenty of results,
+// so an empty or refused answer points at the client rather than the query.
+var canaryQueries = []string{"wikipedia", "weather forecast", "golang"}
+
+// ProbeResult is the outcome of one canary search.
+type ProbeResult struct {
+	Query   string
+	Results int
+	Latency time.Duration
+	Err     error
+}
+
+// CheckResult reports the self-check outcome for one engine.
+type CheckResult struct {
+	Engine         string
+	Verdict        Verdict
+	Probes         []ProbeResult
+	Recommendation string // empty when the engine is clean
+}
+
+// SelfCheck runs up to probes canary searches (at most len(canaryQueries))
+// against each engine and classifies the responses. Probes are paced by rl
+// and count toward Stats like any other search. Engines default to all
+// supported ones; an unknown name fails with ErrUnknownEngine before any
+// probe is sent, rather than silently checking Google.
+func SelfCheck(ctx context.Context, rl *RateLimiter, engines []string, probes int) ([]CheckResult, error) {
+	return SelfCheckWith(ctx, func(ctx context.Context, query string, count int, engine string) ([]Result, error) {
+		results, _, err := SearchWithLimiter(ctx, rl, query, count, engine)
+		return results, err
+	}, engines, probes)
+}
+
+// SelfCheckWith is SelfCheck with the canary searches sent by probe, so
+// callers can put them through their own budgets and circuit breakers. An
+// engine stops being probed once a probe is blocked or skipped.
+func SelfCheckWith(ctx context.Context, probe ProbeFunc, engines []string, probes int) ([]CheckResult, error) {
+	if len(engines) == 0 {
+		engines = []string{EngineGoogle, EngineDuckDuckGo}
+	}
+	for _, name := range engines {
+		if !ValidEngine(name) {
+			return nil, fmt.Errorf("search: self-check %q: %w", name, ErrUnknownEngine)
+		}
+	}
+	probes = min(max(probes, 1), len(canaryQueries))
+
+	checks := make([]CheckResult, len(engines))
+	for i, name := range engines {
+		name = CanonicalEngine(name)
+		c := CheckResult{Engine: name}
+		for _, q := range canaryQueries[:probes] {
+			start := time.Now()
+			results, err := probe(ctx, q, 5, name)
+			c.Probes = append(c.Probes, ProbeResult{Query: q, Results: len(results), Latency: time.Since(start), Err: err})
+			if errors.Is(err, ErrBlocked) || errors.Is(err, ErrProbeSkipped) || ctx.Err() != nil {
+				break // more probes would only deepen the block, or be skipped too
+			}
+		}
+		c.Verdict = classify(c.Probes)
+		checks[i] = c
+	}
+	for i := range checks {
+		checks[i].Recommendation = recommend(checks[i], checks)
+	}
+	return checks, nil
+}
+
+// classify picks the most severe symptom among probes.
+func classify(probes []ProbeResult) Verdict {
+	var blocked, limited, empty, failed, skipped int
+	for _, p := range probes {
+		switch {
+		case errors.Is(p.Err, ErrProbeSkipped):
+			skipped++
+		case errors.Is(p.Err, ErrRateLimited):
+			limited++
+		case errors.Is(p.Err, ErrBlocked):
+			blocked++
+		case p.Err != nil:
+			failed++
+		case p.Results == 0:
+			empty++
+		}
+	}
+	switch {
+	case blocked > 0:
+		return VerdictBlocked
+	case limited > 0:
+		return VerdictRateLimited
+	case skipped == len(probes):
+		return VerdictSkipped
+	case failed == len(probes)-skipped:
+		return VerdictUnreachable
+	case empty > 0:
+		return VerdictEmpty
+	case failed > 0:
+		return VerdictUnreachable
+	}
+	return VerdictClean
+}
+
+// recommend suggests a remedy for c, pointing at a clean engine if any.
+func recommend(c CheckResult, all []CheckResult) string {
+	alt := ""
+	for _, o := range all {
+		if o.Engine != c.Engine && o.Verdict == VerdictClean {
+			alt = o.Engine
+			break
+		}
+	}
+	switchTo := ""
+	if alt != "" {
+		switchTo = fmt.Sprintf("switch to %s (GLSI_SEARCH_ENGINE=%s) or ", alt, alt)
+	}
+	switch c.Verdict {
+	case VerdictBlocked:
+		return "this IP is flagged: " + switchTo + "route searches through a proxy or another egress IP, and pause for a few hours before retrying"
+	case VerdictRateLimited:
+		return "slow down: raise GLSI_RATE_LIMITS (e.g. " + c.Engine + "=3s) or set a search budget; otherwise " + switchTo + "use a proxy"
+	case VerdictEmpty:
+		return "results pages parse as empty, usually a soft block or a markup change: " + switchTo + "retry later"
+	case VerdictUnreachable:
+		return "the engine could not be reached: check network access, DNS, and any firewall"
+	case VerdictSkipped:
+		return "not probed: its search budget is spent or its circuit breaker is open; retry once searches are allowed again"
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	ok := ProbeResult{Results: 5}
+	tests := []struct {
+		name   string
+		probes []ProbeResult
+		want   Verdict
+	}{
+		{"clean", []ProbeResult{ok, ok}, VerdictClean},
+		{"captcha", []ProbeResult{ok, {Err: ErrBlocked}}, VerdictBlocked},
+		{"429", []ProbeResult{{Err: ErrRateLimited}}, VerdictRateLimited},
+		{"empty", []ProbeResult{ok, {Results: 0}}, VerdictEmpty},
+		{"down", []ProbeResult{{Err: errors.New("dial tcp: refused")}}, VerdictUnreachable},
+		{"flaky", []ProbeResult{ok, {Err: errors.New("status 500")}}, VerdictUnreachable},
+		{"skipped", []ProbeResult{{Err: ErrProbeSkipped}}, VerdictSkipped},
+		{"skipped after clean", []ProbeResult{ok, {Err: ErrProbeSkipped}}, VerdictClean},
+		{"skipped after failure", []ProbeResult{{Err: errors.New("status 500")}, {Err: ErrProbeSkipped}}, VerdictUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.probes); got != tt.want {
+				t.Errorf("classify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+
+	mux := http.NewServeMux()
+	// Google serves a CAPTCHA; DuckDuckGo answers normally.
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><form id="captcha-form" action="index"><div class="g-recaptcha"></div></form></body></html>`))
+	})
+	mux.HandleFunc("/html/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><a class="result__a" href="https://en.wikipedia.org/">Wikipedia</a></body></html>`))
+	})
+	cleanup := setupTestServer(t, mux)
+	defer cleanup()
+
+	checks, err := SelfCheck(context.Background(), nil, nil, 2)
+	if err != nil {
+		t.Fatalf("SelfCheck: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	google, ddg := checks[0], checks[1]
+	if google.Verdict != VerdictBlocked || len(google.Probes) != 1 {
+		t.Errorf("google = %+v, want blocked after one probe", google)
+	}
+	if !strings.Contains(google.Recommendation, "GLSI_SEARCH_ENGINE=duckduckgo") {
+		t.Errorf("google recommendation = %q", google.Recommendation)
+	}
+	if ddg.Verdict != VerdictClean || len(ddg.Probes) != 2 || ddg.Recommendation != "" {
+		t.Errorf("duckduckgo = %+v, want clean after two probes", ddg)
+	}
+}
+
+func TestSelfCheckUnknownEngine(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+
+	if _, err := SelfCheck(context.Background(), nil, []string{"duckduckgo", "bing"}, 1); !errors.Is(err, ErrUnknownEngine) {
+		t.Fatalf("err = %v, want ErrUnknownEngine", err)
+	}
+	if len(Stats()) != 0 {
+		t.Errorf("no probe should run before validation, got stats %+v", Stats())
+	}
+}
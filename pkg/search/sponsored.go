@@ -0,0 +1,60 @@
+package search
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Ad containers on each engine's results page. A result inside one of them
+// is a paid placement even when its link points straight at the advertiser.
+const (
+	googleAdSelector     = "#tads, #tadsb, #bottomads, [data-text-ad], .commercial-unit-desktop-top"
+	duckDuckGoAdSelector = ".result--ad, .result--ad--small"
+)
+
+// adURLMarkers are substrings of click-tracking URLs that only ads use.
+var adURLMarkers = []string{
+	"googleadservices.com/",
+	"/aclk?",
+	"duckduckgo.com/y.js?",
+	"ad_provider=",
+	"bing.com/aclick?",
+}
+
+// isSponsored reports whether the result at s, linking to href, is an ad.
+func isSponsored(s *goquery.Selection, href, adSelector string) bool {
+	for _, m := range adURLMarkers {
+		if strings.Contains(href, m) {
+			return true
+		}
+	}
+	return s.Closest(adSelector).Length() > 0
+}
+
+// adTarget returns the advertiser URL behind an ad click-tracking link when
+// the link carries it, and href otherwise. Protocol-relative links are made
+// absolute.
+func adTarget(href string) string {
+	if u, err := url.Parse(href); err == nil {
+		if target := u.Query().Get("adurl"); strings.HasPrefix(target, "http") {
+			return target
+		}
+	}
+	if strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	return href
+}
+
+// Organic returns results with sponsored entries removed, keeping order.
+func Organic(results []Result) []Result {
+	out := make([]Result, 0, len(results))
+	for _, r := range results {
+		if !r.Sponsored {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ErrBlocked is returned when a search engine answers with a CAPTCHA,
+// challenge page, or an access-denied status instead of results.
+var ErrBlocked = errors.New("blocked by search engine")
+
+// ErrRateLimited is returned for 429 responses. It wraps ErrBlocked, since
+// callers usually treat both the same way.
+var ErrRateLimited = fmt.Errorf("rate limited: %w", ErrBlocked)
+
+// blockedSelector matches elements that only appear on bot-challenge pages.
+// Matching elements rather than raw page text keeps a results page whose
+// snippets merely mention "captcha" from being counted as blocked.
+const blockedSelector = "form#captcha-form, div.g-recaptcha, #anomaly-modal, .anomaly-modal__modal"
+
+// blockedTitles are complete <title>s of challenge pages. They are compared
+// whole because a SERP title echoes the query.
+var blockedTitles = []string{
+	"sorry...", // Google /sorry/ page
+	"are you a robot?",
+	"attention required! | cloudflare",
+	"just a moment...",
+}
+
+// isBlocked reports whether resp/doc look like a bot-detection page.
+func isBlocked(resp *http.Response, doc *goquery.Document) bool {
+	if resp.Request != nil && strings.Contains(resp.Request.URL.Path, "/sorry/") {
+		return true
+	}
+	if doc == nil {
+		return false
+	}
+	if doc.Find(blockedSelector).Length() > 0 {
+		return true
+	}
+	title := strings.ToLower(strings.TrimSpace(doc.Find("title").First().Text()))
+	return slices.Contains(blockedTitles, title)
+}
+
+// isBlockedStatus reports whether an HTTP status indicates the client has
+// been refused or throttled.
+func isBlockedStatus(code int) bool {
+	return code == http.StatusForbidden || code == http.StatusTooManyRequests
+}
+
+// EngineStats counts SERP request outcomes for a single engine since the
+// process started (or the last ResetStats).
+type EngineStats struct {
+	Requests    int64     // total SERP requests attempted
+	Blocked     int64     // CAPTCHA/challenge pages and 403/429 responses
+	Empty       int64     // successful responses that yielded no results
+	Errors      int64     // other failures (network errors, 5xx, parse errors)
+	LastBlocked time.Time // zero if never blocked
+}
+
+// BlockRate returns the fraction of requests that were blocked or empty,
+// the usual symptoms of a flagged IP.
+func (s EngineStats) BlockRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Blocked+s.Empty) / float64(s.Requests)
+}
+
+type outcome int
+
+const (
+	outcomeOK outcome = iota
+	outcomeBlocked
+	outcomeEmpty
+	outcomeError
+)
+
+var (
+	statsMu sync.Mutex
+	stats   = map[string]*EngineStats{}
+)
+
+// record updates the counters for engine.
+func record(engine string, o outcome) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s := stats[engine]
+	if s == nil {
+		s = &EngineStats{}
+		stats[engine] = s
+	}
+	s.Requests++
+	switch o {
+	case outcomeBlocked:
+		s.Blocked++
+		s.LastBlocked = time.Now()
+	case outcomeEmpty:
+		s.Empty++
+	case outcomeError:
+		s.Errors++
+	}
+}
+
+// recordResult classifies a search outcome and records it.
+func recordResult(engine string, n int, err error) {
+	switch {
+	case errors.Is(err, ErrBlocked):
+		record(engine, outcomeBlocked)
+	case err != nil:
+		record(engine, outcomeError)
+	case n == 0:
+		record(engine, outcomeEmpty)
+	default:
+		record(engine, outcomeOK)
+	}
+}
+
+// Stats returns a snapshot of per-engine request statistics keyed by
+// canonical engine name.
+func Stats() map[string]EngineStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	out := make(map[string]EngineStats, len(stats))
+	for name, s := range stats {
+		out[name] = *s
+	}
+	return out
+}
+
+// ResetStats clears all engine statistics.
+func ResetStats() {
+	statsMu.Lock()
+	stats = map[string]*EngineStats{}
+	statsMu.Unlock()
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestStatsBlockedAndEmpty(t *testing.T) {
+	ResetStats()
+	defer ResetStats()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "captcha":
+			w.Write([]byte(`<html><body><form id="captcha-form" action="index"><div class="g-recaptcha"></div></form></body></html>`))
+		case "throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "empty":
+			w.Write([]byte(`<html><body><p>No results</p></body></html>`))
+		default:
+			w.Write([]byte(fakeGoogleHTML([]struct{ URL, Title string }{{"https://a.com", "A"}})))
+		}
+	})
+	cleanup := setupTestServer(t, mux)
+	defer cleanup()
+
+	ctx := context.Background()
+	if _, err := Search(ctx, "captcha", 5, "google"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("captcha page: err = %v, want ErrBlocked", err)
+	}
+	if _, err := Search(ctx, "throttled", 5, "google"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("429: err = %v, want ErrBlocked", err)
+	}
+	if _, err := Search(ctx, "empty", 5, "google"); err != nil {
+		t.Errorf("empty: %v", err)
+	}
+	if _, err := Search(ctx, "ok", 5, "google"); err != nil {
+		t.Errorf("ok: %v", err)
+	}
+
+	s := Stats()[EngineGoogle]
+	if s.Requests != 4 {
+		t.Errorf("Requests = %d, want 4", s.Requests)
+	}
+	if s.Blocked != 2 {
+		t.Errorf("Blocked = %d, want 2", s.Blocked)
+	}
+	if s.Empty != 1 {
+		t.Errorf("Empty = %d, want 1", s.Empty)
+	}
+	if s.LastBlocked.IsZero() {
+		t.Error("LastBlocked should be set")
+	}
+	if got := s.BlockRate(); got != 0.75 {
+		t.Errorf("BlockRate = %v, want 0.75", got)
+	}
+	if _, ok := Stats()[EngineDuckDuckGo]; ok {
+		t.Error("duckduckgo should have no stats")
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		html string
+		want bool
+	}{
+		{"sorry_url", "/sorry/index", `<html><body></body></html>`, true},
+		{"captcha_form", "/search", `<html><body><form id="captcha-form"></form></body></html>`, true},
+		{"ddg_anomaly", "/html/", `<html><body><div class="anomaly-modal__modal"></div></body></html>`, true},
+		{"challenge_title", "/search", `<html><head><title>Are you a robot?</title></head></html>`, true},
+		{"snippet_mentions_captcha", "/search",
+			`<html><head><title>are you a robot? - Google Search</title></head><body>` +
+				`<div class="g"><a href="https://a.com"><h3>How g-recaptcha works</h3></a>` +
+				`<span>Sites see unusual traffic from your computer network.</span></div></body></html>`, false},
+		{"results", "/search", fakeGoogleHTML([]struct{ URL, Title string }{{"https://a.com", "A"}}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := &http.Response{Request: &http.Request{URL: &url.URL{Path: tt.path}}}
+			if got := isBlocked(resp, doc); got != tt.want {
+				t.Errorf("isBlocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only identify the campaign or
+// click that led to a page; the page is the same without them.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "gclsrc": true, "dclid": true,
+	"gbraid": true, "wbraid": true, "msclkid": true, "yclid": true,
+	"twclid": true, "ttclid": true, "li_fat_id": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true,
+	"mkt_tok": true, "oly_anon_id": true, "oly_enc_id": true,
+	"vero_id": true, "_ga": true, "_gl": true, "srsltid": true,
+}
+
+// trackingParam reports whether the query parameter key is a tracking
+// parameter: one of trackingParams or any utm_* parameter.
+func trackingParam(key string) bool {
+	key = strings.ToLower(key)
+	return trackingParams[key] || strings.HasPrefix(key, "utm_")
+}
+
+// StripTracking removes tracking parameters such as utm_source, fbclid and
+// gclid from rawURL's query, so links to the same page compare equal. The
+// remaining parameters keep their order and encoding; a URL without
+// tracking parameters, or one that does not parse, is returned unchanged.
+func StripTracking(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil && trackingParam(k) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	if len(kept) == len(pairs) {
+		return rawURL
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	u.ForceQuery = false
+	return u.String()
+}
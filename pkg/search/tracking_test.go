@@ -0,0 +1,20 @@
+package search
+
+import "testing"
+
+func TestStripTracking(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://example.com/a?utm_source=news&utm_medium=email":     "https://example.com/a",
+		"https://example.com/a?id=7&fbclid=IwAR0x&page=2":            "https://example.com/a?id=7&page=2",
+		"https://example.com/a?GCLID=abc&q=go+generics#intro":        "https://example.com/a?q=go+generics#intro",
+		"https://example.com/a?z=1&a=2":                              "https://example.com/a?z=1&a=2",
+		"https://example.com/a?utm_campaign=x&msclkid=y&_hsenc=z&b=": "https://example.com/a?b=",
+		"https://example.com/a":                                      "https://example.com/a",
+		"https://example.com/a?ref=home":                             "https://example.com/a?ref=home",
+		"%zz":                                                        "%zz",
+	} {
+		if got := StripTracking(in); got != want {
+			t.Errorf("StripTracking(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
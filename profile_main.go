@@ -8,8 +8,8 @@ import (
 	"runtime/pprof"
 	"time"
 
-	"github.com/user/glsi/internal/cache"
-	"github.com/user/glsi/internal/engine"
+	"github.com/user/glsi/pkg/cache"
+	"github.com/user/glsi/pkg/engine"
 )
 
 func main() {